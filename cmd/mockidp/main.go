@@ -0,0 +1,269 @@
+// Command mockidp is a tiny OpenID Connect provider for trying out single
+// sign-on locally. It signs in whoever fills in its login form; never expose it.
+//
+//	go run ./cmd/mockidp -addr :9000
+//
+// and start the server with
+//
+//	OIDC_ISSUER=http://localhost:9000 OIDC_CLIENT_ID=employee-management \
+//	OIDC_CLIENT_SECRET=secret OIDC_REDIRECT_URL=http://localhost:8080/api/v1/auth/oidc/callback \
+//	OIDC_ROLE_MAPPING=hr-team=hr,it-admins=admin
+//
+// Passing login_hint=<email> (and optionally groups=a,b) on the authorization
+// request skips the form, which is handy for scripted tests.
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"html/template"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const keyID = "mockidp"
+
+type authorization struct {
+	clientID      string
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+	email         string
+	name          string
+	groups        []string
+	expiresAt     time.Time
+}
+
+type provider struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	key          *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]*authorization
+}
+
+func main() {
+	addr := flag.String("addr", ":9000", "listen address")
+	issuer := flag.String("issuer", "http://localhost:9000", "issuer URL as seen by the application")
+	clientID := flag.String("client-id", "employee-management", "accepted client ID")
+	clientSecret := flag.String("client-secret", "secret", "accepted client secret")
+	flag.Parse()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	p := &provider{
+		issuer:       strings.TrimRight(*issuer, "/"),
+		clientID:     *clientID,
+		clientSecret: *clientSecret,
+		key:          key,
+		codes:        map[string]*authorization{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/jwks", p.jwks)
+	mux.HandleFunc("/authorize", p.authorize)
+	mux.HandleFunc("/token", p.token)
+
+	log.Printf("mock OIDC provider listening on %s (issuer %s)", *addr, p.issuer)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+func (p *provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                p.issuer,
+		"authorization_endpoint":                p.issuer + "/authorize",
+		"token_endpoint":                        p.issuer + "/token",
+		"jwks_uri":                              p.issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (p *provider) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": keyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+		}},
+	})
+}
+
+var loginForm = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html><body>
+<h1>Mock identity provider</h1>
+<form method="post">
+  {{range $name, $value := .Params}}<input type="hidden" name="{{$name}}" value="{{$value}}">{{end}}
+  <p><label>Email <input name="email" value="jane.doe@example.com"></label></p>
+  <p><label>Name <input name="name" value="Jane Doe"></label></p>
+  <p><label>Groups (comma separated) <input name="groups" value="hr-team"></label></p>
+  <p><button type="submit">Sign in</button></p>
+</form>
+</body></html>`))
+
+func (p *provider) authorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if r.Form.Get("client_id") != p.clientID {
+		http.Error(w, "unknown client_id", http.StatusBadRequest)
+		return
+	}
+	redirectURI, err := url.Parse(r.Form.Get("redirect_uri"))
+	if err != nil || redirectURI.Scheme == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	if r.Form.Get("response_type") != "code" || r.Form.Get("code_challenge_method") != "S256" || r.Form.Get("code_challenge") == "" {
+		http.Error(w, "only response_type=code with S256 PKCE is supported", http.StatusBadRequest)
+		return
+	}
+
+	email := r.Form.Get("login_hint")
+	if r.Method == http.MethodPost {
+		email = r.PostForm.Get("email")
+	}
+	if email == "" {
+		params := map[string]string{}
+		for _, name := range []string{"client_id", "redirect_uri", "response_type", "scope", "state", "nonce", "code_challenge", "code_challenge_method"} {
+			params[name] = r.Form.Get(name)
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		loginForm.Execute(w, map[string]interface{}{"Params": params})
+		return
+	}
+
+	var groups []string
+	for _, group := range strings.Split(r.Form.Get("groups"), ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+
+	code := randomString()
+	p.mu.Lock()
+	p.codes[code] = &authorization{
+		clientID:      p.clientID,
+		redirectURI:   redirectURI.String(),
+		nonce:         r.Form.Get("nonce"),
+		codeChallenge: r.Form.Get("code_challenge"),
+		email:         email,
+		name:          r.Form.Get("name"),
+		groups:        groups,
+		expiresAt:     time.Now().Add(time.Minute),
+	}
+	p.mu.Unlock()
+
+	query := redirectURI.Query()
+	query.Set("code", code)
+	query.Set("state", r.Form.Get("state"))
+	redirectURI.RawQuery = query.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func (p *provider) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != p.clientID || subtle.ConstantTimeCompare([]byte(clientSecret), []byte(p.clientSecret)) != 1 {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	p.mu.Lock()
+	auth := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	p.mu.Unlock()
+
+	if r.PostForm.Get("grant_type") != "authorization_code" || auth == nil || time.Now().After(auth.expiresAt) ||
+		auth.redirectURI != r.PostForm.Get("redirect_uri") {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != auth.codeChallenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+		return
+	}
+
+	now := time.Now()
+	preferredUsername, _, _ := strings.Cut(auth.email, "@")
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":                p.issuer,
+		"sub":                "mock|" + auth.email,
+		"aud":                auth.clientID,
+		"iat":                now.Unix(),
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"nonce":              auth.nonce,
+		"email":              auth.email,
+		"email_verified":     true,
+		"name":               auth.name,
+		"preferred_username": preferredUsername,
+		"groups":             auth.groups,
+	})
+	token.Header["kid"] = keyID
+	idToken, err := token.SignedString(p.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func randomString() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
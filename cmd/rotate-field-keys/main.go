@@ -0,0 +1,75 @@
+// Command rotate-field-keys rewrites encrypted employee fields and salary
+// amounts to match the current field encryption settings. Run it after
+// changing the primary key in FIELD_ENCRYPTION_KEYS_FILE, or after changing
+// FIELD_ENCRYPTION_FIELDS:
+//
+//	go run ./cmd/rotate-field-keys -batch-size 500
+//
+// Records already stored as configured are left alone, so an interrupted run
+// can simply be started again. Old master keys must stay in the key file
+// until it has finished.
+package main
+
+import (
+	"employee-management/internal/audit"
+	"employee-management/internal/database"
+	"employee-management/internal/employee"
+	"employee-management/internal/fieldcrypt"
+	"employee-management/internal/logging"
+	"employee-management/internal/payroll"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/joho/godotenv"
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	batchSize := flag.Int("batch-size", 500, "number of records rewritten per transaction")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: rotate-field-keys [-batch-size n]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 0 || *batchSize < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found")
+	}
+	logger := logging.InitLogger()
+
+	cipher, err := fieldcrypt.FromEnv()
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to load field encryption keys")
+	}
+	if cipher == nil {
+		log.Fatal("FIELD_ENCRYPTION_KEYS_FILE is not set")
+	}
+
+	db, err := database.Initialize()
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to connect to database")
+	}
+	defer db.Close()
+
+	entry := logrus.NewEntry(logger)
+	employees, err := employee.NewService(employee.NewRepository(db, cipher), audit.NewRecorder(db)).ReencryptEmployees(entry, *batchSize)
+	if err != nil {
+		db.Close()
+		log.Fatal("Failed to re-encrypt employee records: ", err)
+	}
+	// The payroll service only needs the employee service to run payrolls
+	salaries, err := payroll.NewService(payroll.NewRepository(db, cipher), nil, payroll.BandEnforcementOff).ReencryptSalaries(entry, *batchSize)
+	if err != nil {
+		db.Close()
+		log.Fatal("Failed to re-encrypt salaries: ", err)
+	}
+
+	fmt.Printf("Rewrote %d employees, %d record versions, %d change requests and %d salaries\n",
+		employees.Employees, employees.Versions, employees.ChangeRequests, salaries)
+}
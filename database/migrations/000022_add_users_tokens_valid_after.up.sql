@@ -0,0 +1,2 @@
+-- Tokens issued before this timestamp are rejected
+ALTER TABLE users ADD COLUMN tokens_valid_after TIMESTAMP;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_revoked_access_tokens_expires_at;
+DROP TABLE IF EXISTS revoked_access_tokens;
+DROP INDEX IF EXISTS idx_refresh_tokens_family_id;
+DROP INDEX IF EXISTS idx_refresh_tokens_user_id;
+DROP TABLE IF EXISTS refresh_tokens;
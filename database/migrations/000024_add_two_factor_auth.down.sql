@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS two_factor_policies;
+DROP INDEX IF EXISTS idx_recovery_codes_user_id;
+DROP TABLE IF EXISTS recovery_codes;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS two_factor_last_step,
+    DROP COLUMN IF EXISTS two_factor_enabled,
+    DROP COLUMN IF EXISTS two_factor_secret;
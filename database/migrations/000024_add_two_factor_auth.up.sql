@@ -0,0 +1,28 @@
+ALTER TABLE users
+    ADD COLUMN two_factor_secret VARCHAR(64),
+    ADD COLUMN two_factor_enabled BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN two_factor_last_step BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE recovery_codes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_id ON recovery_codes(user_id);
+
+-- Roles for which two-factor authentication is mandatory
+CREATE TABLE two_factor_policies (
+    role VARCHAR(50) PRIMARY KEY,
+    required BOOLEAN NOT NULL DEFAULT false,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO two_factor_policies (role, required) VALUES
+    ('admin', false),
+    ('hr', false),
+    ('manager', false),
+    ('employee', false)
+ON CONFLICT (role) DO NOTHING;
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_audit_events_created_at;
+DROP INDEX IF EXISTS idx_audit_events_target;
+DROP INDEX IF EXISTS idx_audit_events_action;
+DROP TABLE IF EXISTS audit_events;
+DROP TABLE IF EXISTS login_throttles;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS locked_until,
+    DROP COLUMN IF EXISTS failed_login_attempts;
@@ -0,0 +1,28 @@
+ALTER TABLE users
+    ADD COLUMN failed_login_attempts INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN locked_until TIMESTAMP;
+
+-- Failed login counters keyed by username or client IP
+CREATE TABLE login_throttles (
+    scope VARCHAR(20) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    blocked_until TIMESTAMP,
+    PRIMARY KEY (scope, key)
+);
+
+CREATE TABLE audit_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    actor_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    action VARCHAR(100) NOT NULL,
+    target_type VARCHAR(50),
+    target_id VARCHAR(255),
+    details JSONB,
+    ip_address VARCHAR(45),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_action ON audit_events(action);
+CREATE INDEX IF NOT EXISTS idx_audit_events_target ON audit_events(target_type, target_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events(created_at);
@@ -0,0 +1,15 @@
+CREATE TABLE invitations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(50) NOT NULL,
+    employee_id UUID REFERENCES employees(id),
+    code_hash VARCHAR(64) UNIQUE NOT NULL,
+    invited_by UUID NOT NULL REFERENCES users(id),
+    expires_at TIMESTAMP NOT NULL,
+    accepted_at TIMESTAMP,
+    accepted_user_id UUID REFERENCES users(id),
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_invitations_email ON invitations(email);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_users_role;
+
+ALTER TABLE users DROP COLUMN IF EXISTS password_changed_at;
+ALTER TABLE users DROP COLUMN IF EXISTS must_change_password;
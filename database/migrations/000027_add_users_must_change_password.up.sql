@@ -0,0 +1,4 @@
+ALTER TABLE users ADD COLUMN must_change_password BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE users ADD COLUMN password_changed_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_users_role ON users(role);
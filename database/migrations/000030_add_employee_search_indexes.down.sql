@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_employees_hire_date_id;
+DROP INDEX IF EXISTS idx_employees_email_id;
+DROP INDEX IF EXISTS idx_employees_first_name_id;
+DROP INDEX IF EXISTS idx_employees_last_name_id;
+DROP INDEX IF EXISTS idx_employees_employment_status;
+DROP INDEX IF EXISTS idx_employees_search;
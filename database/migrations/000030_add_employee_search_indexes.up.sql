@@ -0,0 +1,14 @@
+-- Full-text search over name, email and employee ID. The expression must match
+-- searchVector in internal/employee/search.go for the index to be used.
+CREATE INDEX IF NOT EXISTS idx_employees_search ON employees USING GIN (
+    to_tsvector('simple', first_name || ' ' || last_name || ' ' || email || ' ' || translate(email, '@.', '  ') || ' ' || employee_id)
+);
+
+-- Filter on status; department, position and manager are indexed since 000018
+CREATE INDEX IF NOT EXISTS idx_employees_employment_status ON employees(employment_status);
+
+-- Sort orders, with id as the keyset pagination tie-breaker
+CREATE INDEX IF NOT EXISTS idx_employees_last_name_id ON employees(last_name, id);
+CREATE INDEX IF NOT EXISTS idx_employees_first_name_id ON employees(first_name, id);
+CREATE INDEX IF NOT EXISTS idx_employees_email_id ON employees(email, id);
+CREATE INDEX IF NOT EXISTS idx_employees_hire_date_id ON employees(hire_date, id);
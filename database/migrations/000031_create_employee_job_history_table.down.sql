@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_employee_job_history_employee_effective;
+DROP TABLE IF EXISTS employee_job_history;
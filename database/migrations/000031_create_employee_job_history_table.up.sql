@@ -0,0 +1,21 @@
+CREATE TABLE employee_job_history (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    employee_id UUID NOT NULL REFERENCES employees(id) ON DELETE CASCADE,
+    event_type VARCHAR(30) NOT NULL CHECK (event_type IN ('hire', 'transfer', 'position_change', 'promotion', 'suspension', 'reinstatement', 'termination', 'rehire')),
+    effective_date DATE NOT NULL,
+    department_id UUID REFERENCES departments(id),
+    position_id UUID REFERENCES positions(id),
+    manager_id UUID REFERENCES employees(id) ON DELETE SET NULL,
+    employment_status VARCHAR(20) NOT NULL,
+    reason TEXT,
+    last_working_day DATE,
+    recorded_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_employee_job_history_employee_effective ON employee_job_history(employee_id, effective_date, created_at);
+
+-- Start every existing employee's history from their current job
+INSERT INTO employee_job_history (employee_id, event_type, effective_date, department_id, position_id, manager_id, employment_status, reason)
+SELECT id, 'hire', hire_date, department_id, position_id, manager_id, employment_status, 'Recorded from the employee record when job history was introduced'
+FROM employees;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_employee_versions_recorded;
+DROP INDEX IF EXISTS idx_employee_versions_current;
+DROP TABLE IF EXISTS employee_versions;
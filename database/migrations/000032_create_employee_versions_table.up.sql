@@ -0,0 +1,28 @@
+CREATE TABLE employee_versions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    employee_id UUID NOT NULL REFERENCES employees(id) ON DELETE CASCADE,
+    valid_from DATE NOT NULL,
+    valid_to DATE,
+    recorded_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    superseded_at TIMESTAMP,
+    recorded_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    change_type VARCHAR(30) NOT NULL,
+    changed_fields TEXT[] NOT NULL DEFAULT '{}',
+    data JSONB NOT NULL,
+    CHECK (valid_to IS NULL OR valid_to > valid_from)
+);
+
+-- Versions currently believed, looked up by valid time
+CREATE INDEX IF NOT EXISTS idx_employee_versions_current ON employee_versions(employee_id, valid_from) WHERE superseded_at IS NULL;
+-- All versions, looked up by transaction time
+CREATE INDEX IF NOT EXISTS idx_employee_versions_recorded ON employee_versions(employee_id, recorded_at);
+
+-- Start every existing employee's history from their current record. Dates
+-- and timestamps are written the way encoding/json formats time.Time.
+INSERT INTO employee_versions (employee_id, valid_from, change_type, data)
+SELECT id, hire_date, 'create', to_jsonb(e) || jsonb_build_object(
+    'date_of_birth', to_char(date_of_birth, 'YYYY-MM-DD"T00:00:00Z"'),
+    'hire_date', to_char(hire_date, 'YYYY-MM-DD"T00:00:00Z"'),
+    'created_at', to_char(created_at, 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"'),
+    'updated_at', to_char(updated_at, 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"'))
+FROM employees e;
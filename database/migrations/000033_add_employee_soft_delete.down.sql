@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_employees_deleted_at;
+
+ALTER TABLE employees
+    DROP CONSTRAINT IF EXISTS employees_anonymized_after_delete,
+    DROP COLUMN IF EXISTS anonymized_at,
+    DROP COLUMN IF EXISTS deleted_by,
+    DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,8 @@
+ALTER TABLE employees
+    ADD COLUMN deleted_at TIMESTAMP,
+    ADD COLUMN deleted_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    ADD COLUMN anonymized_at TIMESTAMP,
+    ADD CONSTRAINT employees_anonymized_after_delete CHECK (anonymized_at IS NULL OR deleted_at IS NOT NULL);
+
+-- Deleted employees are listed separately and swept by the retention job
+CREATE INDEX IF NOT EXISTS idx_employees_deleted_at ON employees(deleted_at) WHERE deleted_at IS NOT NULL;
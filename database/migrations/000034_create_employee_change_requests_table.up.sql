@@ -0,0 +1,15 @@
+CREATE TABLE employee_change_requests (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    employee_id UUID NOT NULL REFERENCES employees(id),
+    requested_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    changes JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected', 'cancelled')),
+    reviewed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMP,
+    review_note TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- An employee has at most one change waiting for review
+CREATE UNIQUE INDEX IF NOT EXISTS idx_employee_change_requests_pending ON employee_change_requests(employee_id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_employee_change_requests_status ON employee_change_requests(status, created_at);
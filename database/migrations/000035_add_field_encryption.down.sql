@@ -0,0 +1,20 @@
+-- Decrypt everything first by running cmd/rotate-field-keys with
+-- FIELD_ENCRYPTION_FIELDS set to an empty list, or this migration fails.
+ALTER TABLE employee_salaries
+    DROP CONSTRAINT IF EXISTS employee_salaries_amount_present,
+    DROP COLUMN IF EXISTS amount_encrypted,
+    ALTER COLUMN amount SET NOT NULL;
+
+DROP INDEX IF EXISTS idx_employees_phone_number_index;
+DROP INDEX IF EXISTS idx_employees_email_index;
+
+ALTER TABLE employees
+    DROP COLUMN IF EXISTS phone_number_index,
+    DROP COLUMN IF EXISTS email_index,
+    DROP CONSTRAINT IF EXISTS employees_date_of_birth_present,
+    DROP COLUMN IF EXISTS date_of_birth_encrypted,
+    ALTER COLUMN date_of_birth SET NOT NULL,
+    ALTER COLUMN emergency_contact_phone TYPE VARCHAR(20),
+    ALTER COLUMN emergency_contact_name TYPE VARCHAR(255),
+    ALTER COLUMN email TYPE VARCHAR(255),
+    ALTER COLUMN phone_number TYPE VARCHAR(20);
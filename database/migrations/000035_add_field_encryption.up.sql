@@ -0,0 +1,25 @@
+-- Encrypted values are longer than the plaintext columns allowed. An
+-- encrypted date of birth is kept in date_of_birth_encrypted, with
+-- date_of_birth left NULL.
+ALTER TABLE employees
+    ALTER COLUMN phone_number TYPE TEXT,
+    ALTER COLUMN email TYPE TEXT,
+    ALTER COLUMN emergency_contact_name TYPE TEXT,
+    ALTER COLUMN emergency_contact_phone TYPE TEXT,
+    ALTER COLUMN date_of_birth DROP NOT NULL,
+    ADD COLUMN date_of_birth_encrypted TEXT,
+    ADD CONSTRAINT employees_date_of_birth_present CHECK (date_of_birth IS NOT NULL OR date_of_birth_encrypted IS NOT NULL);
+
+-- Blind indexes: keyed hashes of the normalized email and phone number, so
+-- they can be looked up when encrypted
+ALTER TABLE employees
+    ADD COLUMN email_index BYTEA,
+    ADD COLUMN phone_number_index BYTEA;
+
+CREATE INDEX idx_employees_email_index ON employees(email_index) WHERE email_index IS NOT NULL;
+CREATE INDEX idx_employees_phone_number_index ON employees(phone_number_index) WHERE phone_number_index IS NOT NULL;
+
+ALTER TABLE employee_salaries
+    ALTER COLUMN amount DROP NOT NULL,
+    ADD COLUMN amount_encrypted TEXT,
+    ADD CONSTRAINT employee_salaries_amount_present CHECK (amount IS NOT NULL OR amount_encrypted IS NOT NULL);
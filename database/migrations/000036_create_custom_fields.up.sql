@@ -0,0 +1,19 @@
+CREATE TABLE custom_field_definitions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    key VARCHAR(50) NOT NULL UNIQUE,
+    label VARCHAR(100) NOT NULL,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('text', 'number', 'boolean', 'date', 'select')),
+    required BOOLEAN NOT NULL DEFAULT FALSE,
+    options TEXT[] NOT NULL DEFAULT '{}',
+    pattern TEXT NOT NULL DEFAULT '',
+    min_value NUMERIC,
+    max_value NUMERIC,
+    visible_to TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Values are keyed by custom_field_definitions.key
+ALTER TABLE employees ADD COLUMN custom_fields JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS idx_employees_custom_fields ON employees USING GIN (custom_fields jsonb_path_ops);
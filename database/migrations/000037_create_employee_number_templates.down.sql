@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS employee_number_sequences;
+DROP INDEX IF EXISTS idx_employee_number_templates_default;
+DROP TABLE IF EXISTS employee_number_templates;
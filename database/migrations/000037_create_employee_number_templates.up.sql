@@ -0,0 +1,18 @@
+-- A template with no department is the default for employees without one of their own
+CREATE TABLE employee_number_templates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    department_id UUID UNIQUE REFERENCES departments(id) ON DELETE CASCADE,
+    pattern VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_employee_number_templates_default ON employee_number_templates ((department_id IS NULL)) WHERE department_id IS NULL;
+
+-- One counter per rendered prefix, such as ENG-2026-{seq}, so numbers restart
+-- whenever the date part of a pattern changes
+CREATE TABLE employee_number_sequences (
+    scope VARCHAR(100) PRIMARY KEY,
+    last_value BIGINT NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_departments_legal_entity_id;
+DROP INDEX IF EXISTS idx_departments_parent_id;
+DROP INDEX IF EXISTS idx_departments_cost_center;
+ALTER TABLE departments
+    DROP CONSTRAINT IF EXISTS departments_parent_not_self,
+    DROP COLUMN IF EXISTS merged_at,
+    DROP COLUMN IF EXISTS merged_into_id,
+    DROP COLUMN IF EXISTS legal_entity_id,
+    DROP COLUMN IF EXISTS cost_center,
+    DROP COLUMN IF EXISTS parent_id;
+DROP TABLE IF EXISTS legal_entities;
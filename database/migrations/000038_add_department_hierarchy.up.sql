@@ -0,0 +1,22 @@
+CREATE TABLE legal_entities (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(255) NOT NULL UNIQUE,
+    registration_number VARCHAR(100) NOT NULL DEFAULT '',
+    country CHAR(2) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Departments without a cost center or legal entity inherit their parent's.
+-- Merged departments are kept for the job history that refers to them.
+ALTER TABLE departments
+    ADD COLUMN parent_id UUID REFERENCES departments(id),
+    ADD COLUMN cost_center VARCHAR(50),
+    ADD COLUMN legal_entity_id UUID REFERENCES legal_entities(id),
+    ADD COLUMN merged_into_id UUID REFERENCES departments(id),
+    ADD COLUMN merged_at TIMESTAMP,
+    ADD CONSTRAINT departments_parent_not_self CHECK (parent_id <> id);
+
+CREATE INDEX IF NOT EXISTS idx_departments_cost_center ON departments(cost_center);
+CREATE INDEX IF NOT EXISTS idx_departments_parent_id ON departments(parent_id);
+CREATE INDEX IF NOT EXISTS idx_departments_legal_entity_id ON departments(legal_entity_id);
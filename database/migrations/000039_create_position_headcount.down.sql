@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS position_requisitions;
+DROP TABLE IF EXISTS position_headcount_budgets;
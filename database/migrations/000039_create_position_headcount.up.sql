@@ -0,0 +1,36 @@
+-- Approved seats per position over a period. Periods of a position do not
+-- overlap; the service checks this under a lock on the position.
+CREATE TABLE position_headcount_budgets (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    position_id UUID NOT NULL REFERENCES positions(id) ON DELETE CASCADE,
+    period_start DATE NOT NULL,
+    period_end DATE NOT NULL,
+    headcount INTEGER NOT NULL CHECK (headcount >= 0),
+    notes TEXT NOT NULL DEFAULT '',
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT position_headcount_budgets_period CHECK (period_end >= period_start)
+);
+
+CREATE INDEX IF NOT EXISTS idx_position_headcount_budgets_position ON position_headcount_budgets(position_id, period_start);
+
+-- An approved requisition is an open vacancy until it is filled or cancelled
+CREATE TABLE position_requisitions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    position_id UUID NOT NULL REFERENCES positions(id) ON DELETE CASCADE,
+    headcount INTEGER NOT NULL DEFAULT 1 CHECK (headcount > 0),
+    reason TEXT NOT NULL DEFAULT '',
+    target_start_date DATE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected', 'filled', 'cancelled')),
+    requested_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMP,
+    review_note TEXT,
+    closed_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_position_requisitions_position ON position_requisitions(position_id, status);
+CREATE INDEX IF NOT EXISTS idx_position_requisitions_status ON position_requisitions(status, created_at);
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_employee_salaries_band_pending;
+ALTER TABLE employee_salaries
+    DROP COLUMN IF EXISTS band_review_note,
+    DROP COLUMN IF EXISTS band_reviewed_at,
+    DROP COLUMN IF EXISTS band_reviewed_by,
+    DROP COLUMN IF EXISTS requested_by,
+    DROP COLUMN IF EXISTS band_status;
+ALTER TABLE positions DROP CONSTRAINT IF EXISTS positions_salary_range;
@@ -0,0 +1,15 @@
+-- NOT VALID leaves existing positions alone; new and updated ones must have
+-- a range that is not upside down
+ALTER TABLE positions
+    ADD CONSTRAINT positions_salary_range CHECK (salary_range_min <= salary_range_max) NOT VALID;
+
+-- Salaries outside the position's salary band wait for approval when bands
+-- are enforced by approval. Only approved salaries are paid.
+ALTER TABLE employee_salaries
+    ADD COLUMN band_status VARCHAR(20) NOT NULL DEFAULT 'approved' CHECK (band_status IN ('approved', 'pending', 'rejected')),
+    ADD COLUMN requested_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    ADD COLUMN band_reviewed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    ADD COLUMN band_reviewed_at TIMESTAMP,
+    ADD COLUMN band_review_note TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_employee_salaries_band_pending ON employee_salaries(created_at) WHERE band_status = 'pending';
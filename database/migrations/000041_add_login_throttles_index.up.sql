@@ -0,0 +1,2 @@
+-- Lets expired login throttles be pruned without scanning the table
+CREATE INDEX IF NOT EXISTS idx_login_throttles_last_failed_at ON login_throttles(last_failed_at);
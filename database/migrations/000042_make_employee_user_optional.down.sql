@@ -0,0 +1 @@
+ALTER TABLE employees ALTER COLUMN user_id SET NOT NULL;
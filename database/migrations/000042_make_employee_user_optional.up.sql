@@ -0,0 +1,2 @@
+-- Employees can be created before their user account and linked by invitation
+ALTER TABLE employees ALTER COLUMN user_id DROP NOT NULL;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_departments_name;
+ALTER TABLE departments ADD CONSTRAINT departments_name_key UNIQUE (name);
@@ -0,0 +1,3 @@
+-- Merged departments are kept for their job history but give up their name
+ALTER TABLE departments DROP CONSTRAINT IF EXISTS departments_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_departments_name ON departments(name) WHERE merged_into_id IS NULL;
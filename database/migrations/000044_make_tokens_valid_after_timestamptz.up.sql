@@ -0,0 +1,4 @@
+-- Access tokens are compared against this in Go, so it must not depend on
+-- the session time zone. Existing values were written in the session time
+-- zone, which the conversion assumes.
+ALTER TABLE users ALTER COLUMN tokens_valid_after TYPE TIMESTAMPTZ;
@@ -0,0 +1,15 @@
+UPDATE employee_versions SET data = data || COALESCE((
+    SELECT jsonb_object_agg(key, to_char((value #>> '{}')::timestamptz AT TIME ZONE current_setting('TimeZone'), 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"'))
+    FROM jsonb_each(data)
+    WHERE key IN ('created_at', 'updated_at', 'deleted_at', 'anonymized_at') AND jsonb_typeof(value) = 'string'), '{}')
+WHERE data ?| ARRAY['created_at', 'updated_at', 'deleted_at', 'anonymized_at'];
+
+ALTER TABLE employees
+    ALTER COLUMN anonymized_at TYPE TIMESTAMP,
+    ALTER COLUMN deleted_at TYPE TIMESTAMP,
+    ALTER COLUMN updated_at TYPE TIMESTAMP,
+    ALTER COLUMN created_at TYPE TIMESTAMP;
+
+ALTER TABLE employee_versions
+    ALTER COLUMN superseded_at TYPE TIMESTAMP,
+    ALTER COLUMN recorded_at TYPE TIMESTAMP;
@@ -0,0 +1,21 @@
+-- Versions are looked up by the moment they were recorded, and employee
+-- timestamps are copied into version data, so neither may depend on the
+-- session time zone. Existing values were written in the session time zone,
+-- which the conversion assumes.
+ALTER TABLE employee_versions
+    ALTER COLUMN recorded_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN superseded_at TYPE TIMESTAMPTZ;
+
+ALTER TABLE employees
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN updated_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN deleted_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN anonymized_at TYPE TIMESTAMPTZ;
+
+-- Version data so far holds these timestamps in the session time zone, marked
+-- as UTC. Rewrite them in UTC.
+UPDATE employee_versions SET data = data || COALESCE((
+    SELECT jsonb_object_agg(key, to_char((value #>> '{}')::timestamp AT TIME ZONE current_setting('TimeZone') AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"'))
+    FROM jsonb_each(data)
+    WHERE key IN ('created_at', 'updated_at', 'deleted_at', 'anonymized_at') AND jsonb_typeof(value) = 'string'), '{}')
+WHERE data ?| ARRAY['created_at', 'updated_at', 'deleted_at', 'anonymized_at'];
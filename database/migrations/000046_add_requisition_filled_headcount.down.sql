@@ -0,0 +1,3 @@
+ALTER TABLE position_requisitions
+    DROP CONSTRAINT IF EXISTS position_requisitions_filled_headcount,
+    DROP COLUMN IF EXISTS filled_headcount;
@@ -0,0 +1,4 @@
+-- Employees joining a position fill the seats of its approved requisitions
+ALTER TABLE position_requisitions
+    ADD COLUMN filled_headcount INTEGER NOT NULL DEFAULT 0,
+    ADD CONSTRAINT position_requisitions_filled_headcount CHECK (filled_headcount BETWEEN 0 AND headcount);
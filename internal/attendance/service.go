@@ -162,6 +162,39 @@ func (s *Service) ListAttendance() ([]models.Attendance, error) {
 	return attendances, nil
 }
 
+// ListAttendanceByEmployeeID retrieves an employee's attendance records, newest first
+func (s *Service) ListAttendanceByEmployeeID(employeeID uuid.UUID) ([]models.Attendance, error) {
+	attendances := []models.Attendance{}
+	query := `
+		SELECT id, employee_id, check_in_time, check_out_time, date, status, notes, created_at
+		FROM attendance
+		WHERE employee_id = $1
+		ORDER BY date DESC, check_in_time DESC
+	`
+	rows, err := s.db.Query(query, employeeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var attendance models.Attendance
+		var checkOutTime sql.NullTime
+		err := rows.Scan(
+			&attendance.ID, &attendance.EmployeeID, &attendance.CheckInTime, &checkOutTime, &attendance.Date, &attendance.Status, &attendance.Notes, &attendance.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if checkOutTime.Valid {
+			attendance.CheckOutTime = &checkOutTime.Time
+		}
+		attendances = append(attendances, attendance)
+	}
+
+	return attendances, rows.Err()
+}
+
 // CheckIn creates a new attendance record for an employee checking in
 func (s *Service) CheckIn(employeeID uuid.UUID) (*models.Attendance, error) {
 	// Check if employee already has a check-in for today
@@ -0,0 +1,35 @@
+package audit
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler handles HTTP requests for audit events
+type Handler struct {
+	recorder *Recorder
+}
+
+// NewHandler creates a new audit handler
+func NewHandler(recorder *Recorder) *Handler {
+	return &Handler{recorder}
+}
+
+// ListEvents returns recent audit events, optionally filtered by action and target
+func (h *Handler) ListEvents(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	events, err := h.recorder.List(Filter{
+		Action:     c.Query("action"),
+		TargetType: c.Query("target_type"),
+		TargetID:   c.Query("target_id"),
+		Limit:      limit,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list audit events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
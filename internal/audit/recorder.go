@@ -0,0 +1,96 @@
+package audit
+
+import (
+	"database/sql"
+	"employee-management/internal/database"
+	"employee-management/internal/models"
+	"encoding/json"
+)
+
+// Execer is satisfied by both *sql.DB and *sql.Tx, so events can be recorded
+// inside the transaction of the change they describe
+type Execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// Recorder writes and reads audit events
+type Recorder struct {
+	db *database.DB
+}
+
+// NewRecorder creates a new audit recorder
+func NewRecorder(db *database.DB) *Recorder {
+	return &Recorder{db: db}
+}
+
+// Record stores an audit event
+func (r *Recorder) Record(event *models.AuditEventCreate) error {
+	return r.RecordWith(r.db, event)
+}
+
+// RecordWith stores an audit event using the given connection or transaction
+func (r *Recorder) RecordWith(exec Execer, event *models.AuditEventCreate) error {
+	details, err := json.Marshal(event.Details)
+	if err != nil {
+		return err
+	}
+
+	_, err = exec.Exec(
+		`INSERT INTO audit_events (actor_user_id, action, target_type, target_id, details, ip_address)
+		 VALUES ($1, $2, $3, $4, $5, $6)`,
+		event.ActorUserID, event.Action, nullString(event.TargetType), nullString(event.TargetID), details, nullString(event.IPAddress))
+	return err
+}
+
+// Filter narrows down a list of audit events
+type Filter struct {
+	Action     string
+	TargetType string
+	TargetID   string
+	Limit      int
+}
+
+// List returns the most recent audit events matching the filter
+func (r *Recorder) List(filter Filter) ([]models.AuditEvent, error) {
+	if filter.Limit <= 0 || filter.Limit > 500 {
+		filter.Limit = 100
+	}
+
+	rows, err := r.db.Query(
+		`SELECT id, actor_user_id, action, target_type, target_id, details, ip_address, created_at
+		 FROM audit_events
+		 WHERE ($1 = '' OR action = $1)
+		   AND ($2 = '' OR target_type = $2)
+		   AND ($3 = '' OR target_id = $3)
+		 ORDER BY created_at DESC
+		 LIMIT $4`,
+		filter.Action, filter.TargetType, filter.TargetID, filter.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.AuditEvent{}
+	for rows.Next() {
+		var event models.AuditEvent
+		var targetType, targetID, ipAddress sql.NullString
+		var details []byte
+		if err := rows.Scan(&event.ID, &event.ActorUserID, &event.Action, &targetType, &targetID, &details, &ipAddress, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		event.TargetType = targetType.String
+		event.TargetID = targetID.String
+		event.IPAddress = ipAddress.String
+		if len(details) > 0 {
+			if err := json.Unmarshal(details, &event.Details); err != nil {
+				return nil, err
+			}
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,233 @@
+package auth
+
+import (
+	"database/sql"
+	"employee-management/internal/models"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// APITokenPrefix marks personal API tokens so they can be told apart from JWTs
+const APITokenPrefix = "emt_"
+
+const (
+	defaultAPITokenTTL = 90 * 24 * time.Hour
+	// apiTokenUsageInterval limits how often last-used tracking writes to the database
+	apiTokenUsageInterval = time.Minute
+)
+
+// Scopes that can be granted to API tokens
+const (
+	ScopeEmployeesRead   = "employees:read"
+	ScopeEmployeesWrite  = "employees:write"
+	ScopeDepartmentsRead = "departments:read"
+	ScopePositionsRead   = "positions:read"
+	ScopeAttendanceRead  = "attendance:read"
+	ScopeLeaveRead       = "leave:read"
+	ScopePayrollRead     = "payroll:read"
+	ScopePayrollExport   = "payroll:export"
+	ScopeDocumentsRead   = "documents:read"
+)
+
+// APIScopes describes every scope an API token can be granted
+var APIScopes = map[string]string{
+	ScopeEmployeesRead:   "Read employee records",
+	ScopeEmployeesWrite:  "Create, update and delete employee records",
+	ScopeDepartmentsRead: "Read departments",
+	ScopePositionsRead:   "Read positions",
+	ScopeAttendanceRead:  "Read attendance records",
+	ScopeLeaveRead:       "Read leave types and requests",
+	ScopePayrollRead:     "Read payroll runs, components and salaries",
+	ScopePayrollExport:   "Read payroll runs and payslips and export reports",
+	ScopeDocumentsRead:   "Read document metadata",
+}
+
+var (
+	// ErrInvalidAPIToken is returned when an API token is unknown, expired or revoked
+	ErrInvalidAPIToken = errors.New("invalid or expired API token")
+	// ErrAPITokenNotFound is returned when revoking an unknown token
+	ErrAPITokenNotFound = errors.New("API token not found")
+	// ErrInvalidScope is returned when a token is requested with an unknown scope
+	ErrInvalidScope = errors.New("invalid scope")
+)
+
+// CreatedAPIToken is returned once when a token is created. The token itself
+// is not stored and cannot be retrieved later.
+type CreatedAPIToken struct {
+	*models.APIToken
+	Token string `json:"token"`
+}
+
+// CreateAPIToken issues a new API token for the user
+func (s *Service) CreateAPIToken(userID uuid.UUID, create *models.APITokenCreate, ip string) (*CreatedAPIToken, error) {
+	scopes := make([]string, 0, len(create.Scopes))
+	for _, scope := range create.Scopes {
+		if _, ok := APIScopes[scope]; !ok {
+			return nil, fmt.Errorf("%w: unknown scope %q", ErrInvalidScope, scope)
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	slices.Sort(scopes)
+
+	ttl := defaultAPITokenTTL
+	if create.ExpiresInDays > 0 {
+		ttl = time.Duration(create.ExpiresInDays) * 24 * time.Hour
+	}
+
+	secret, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	token := APITokenPrefix + secret
+
+	apiToken := &models.APIToken{
+		UserID:      userID,
+		Name:        create.Name,
+		TokenPrefix: token[:len(APITokenPrefix)+8],
+		Scopes:      scopes,
+		ExpiresAt:   time.Now().Add(ttl),
+	}
+	err = s.withTx(func(tx *sql.Tx) error {
+		err := tx.QueryRow(
+			`INSERT INTO api_tokens (user_id, name, token_prefix, token_hash, scopes, expires_at)
+			 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`,
+			apiToken.UserID, apiToken.Name, apiToken.TokenPrefix, hashToken(token), pq.Array(apiToken.Scopes), apiToken.ExpiresAt,
+		).Scan(&apiToken.ID, &apiToken.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		return s.audit.RecordWith(tx, &models.AuditEventCreate{
+			ActorUserID: &userID,
+			Action:      "auth.api_token_created",
+			TargetType:  "api_token",
+			TargetID:    apiToken.ID.String(),
+			Details:     map[string]interface{}{"name": apiToken.Name, "scopes": apiToken.Scopes},
+			IPAddress:   ip,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &CreatedAPIToken{APIToken: apiToken, Token: token}, nil
+}
+
+// ListAPITokens returns the user's tokens, or every user's tokens when userID is nil
+func (s *Service) ListAPITokens(userID *uuid.UUID) ([]models.APIToken, error) {
+	query := `SELECT id, user_id, name, token_prefix, scopes, expires_at, last_used_at, last_used_ip, revoked_at, created_at
+		 FROM api_tokens`
+	var args []interface{}
+	if userID != nil {
+		query += " WHERE user_id = $1"
+		args = append(args, *userID)
+	}
+	rows, err := s.db.Query(query+" ORDER BY created_at DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []models.APIToken{}
+	for rows.Next() {
+		var token models.APIToken
+		var lastUsedAt, revokedAt sql.NullTime
+		var lastUsedIP sql.NullString
+		if err := rows.Scan(&token.ID, &token.UserID, &token.Name, &token.TokenPrefix, pq.Array(&token.Scopes),
+			&token.ExpiresAt, &lastUsedAt, &lastUsedIP, &revokedAt, &token.CreatedAt); err != nil {
+			return nil, err
+		}
+		if lastUsedAt.Valid {
+			token.LastUsedAt = &lastUsedAt.Time
+		}
+		if revokedAt.Valid {
+			token.RevokedAt = &revokedAt.Time
+		}
+		token.LastUsedIP = lastUsedIP.String
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// RevokeAPIToken revokes a token. ownerID restricts the operation to the
+// owner's tokens; admins pass nil to revoke any token.
+func (s *Service) RevokeAPIToken(tokenID uuid.UUID, ownerID *uuid.UUID, actorID uuid.UUID, ip string) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		query := "UPDATE api_tokens SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL"
+		args := []interface{}{tokenID}
+		if ownerID != nil {
+			query += " AND user_id = $2"
+			args = append(args, *ownerID)
+		}
+
+		result, err := tx.Exec(query, args...)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return ErrAPITokenNotFound
+		}
+
+		return s.audit.RecordWith(tx, &models.AuditEventCreate{
+			ActorUserID: &actorID,
+			Action:      "auth.api_token_revoked",
+			TargetType:  "api_token",
+			TargetID:    tokenID.String(),
+			IPAddress:   ip,
+		})
+	})
+}
+
+// ValidateAPIToken looks up an API token and returns claims describing its
+// owner and scopes. The owner's current role applies, so a token never grants
+// more than its owner could do, and like access tokens, tokens created before
+// the owner's sessions were invalidated are refused.
+func (s *Service) ValidateAPIToken(token, ip string) (*Claims, error) {
+	if !strings.HasPrefix(token, APITokenPrefix) {
+		return nil, ErrInvalidAPIToken
+	}
+
+	var claims Claims
+	var tokenID uuid.UUID
+	var expiresAt time.Time
+	var isActive bool
+	err := s.db.QueryRow(
+		`SELECT t.id, t.scopes, t.expires_at, u.id, u.username, u.role, u.is_active
+		 FROM api_tokens t JOIN users u ON u.id = t.user_id
+		 WHERE t.token_hash = $1 AND t.revoked_at IS NULL
+		   AND (u.tokens_valid_after IS NULL OR t.created_at > u.tokens_valid_after)`,
+		hashToken(token)).Scan(&tokenID, pq.Array(&claims.Scopes), &expiresAt, &claims.UserID, &claims.Username, &claims.Role, &isActive)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidAPIToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !isActive || time.Now().After(expiresAt) {
+		return nil, ErrInvalidAPIToken
+	}
+
+	if _, err := s.db.Exec(
+		`UPDATE api_tokens SET last_used_at = NOW(), last_used_ip = $1
+		 WHERE id = $2 AND (last_used_at IS NULL OR last_used_at < NOW() - make_interval(secs => $3))`,
+		ip, tokenID, apiTokenUsageInterval.Seconds()); err != nil {
+		return nil, err
+	}
+
+	claims.ID = tokenID.String()
+	claims.Subject = claims.UserID
+	return &claims, nil
+}
@@ -1,11 +1,15 @@
 package auth
 
 import (
+	"database/sql"
 	"employee-management/internal/models"
+	"errors"
 	"net/http"
-	"os"
+	"net/url"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 type Handler struct {
@@ -18,8 +22,38 @@ type LoginRequest struct {
 }
 
 type LoginResponse struct {
-	Token string       `json:"token"`
-	User  *models.User `json:"user"`
+	*TokenPair
+	User *models.User `json:"user"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type TwoFactorVerifyRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required_without=RecoveryCode"`
+	RecoveryCode   string `json:"recovery_code"`
+}
+
+type TwoFactorChallengeRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+}
+
+type TwoFactorConfirmRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code" binding:"required"`
+}
+
+type TwoFactorDisableRequest struct {
+	Code         string `json:"code" binding:"required_without=RecoveryCode"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+	// AllDevices revokes every session of the user, not just the current one
+	AllDevices bool `json:"all_devices"`
 }
 
 func NewHandler(service *Service) *Handler {
@@ -35,15 +69,26 @@ func (h *Handler) Login(c *gin.Context) {
 		return
 	}
 
-	token, user, err := h.service.Login(req.Username, req.Password)
+	result, err := h.service.Login(req.Username, req.Password, clientInfo(c))
 	if err != nil {
+		var throttled *LoginThrottledError
+		if errors.As(err, &throttled) {
+			c.Header("Retry-After", strconv.Itoa(int(throttled.RetryAfter.Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
 
+	if result.Challenge != nil {
+		c.JSON(http.StatusOK, result.Challenge)
+		return
+	}
+
 	c.JSON(http.StatusOK, LoginResponse{
-		Token: token,
-		User:  user,
+		TokenPair: result.Tokens,
+		User:      result.User,
 	})
 }
 
@@ -56,6 +101,14 @@ func (h *Handler) Register(c *gin.Context) {
 
 	user, err := h.service.Register(&userReg)
 	if err != nil {
+		if errors.Is(err, ErrSelfRegistrationDisabled) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if isPasswordPolicyError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -64,34 +117,81 @@ func (h *Handler) Register(c *gin.Context) {
 }
 
 func (h *Handler) RefreshToken(c *gin.Context) {
-
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	user, err := h.service.GetUserByID(userID.(string))
+	tokens, user, err := h.service.Refresh(req.RefreshToken, clientInfo(c))
 	if err != nil {
+		if errors.Is(err, ErrInvalidRefreshToken) || errors.Is(err, ErrRefreshTokenReused) || errors.Is(err, ErrPasswordChangeRequired) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, LoginResponse{
+		TokenPair: tokens,
+		User:      user,
+	})
+}
+
+func (h *Handler) Logout(c *gin.Context) {
+	claims, ok := c.MustGet("claims").(*Claims)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
-	token, err := h.service.GenerateToken(user)
+	var req LogoutRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if err := h.service.Logout(claims, req.RefreshToken); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	if req.AllDevices {
+		if err := h.service.RevokeUserTokens(claims.UserID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out other devices"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+func (h *Handler) ListSessions(c *gin.Context) {
+	sessions, err := h.service.ListSessions(c.GetString("user_id"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list sessions"})
 		return
 	}
 
-	c.JSON(http.StatusOK, LoginResponse{
-		Token: token,
-		User:  user,
-	})
+	c.JSON(http.StatusOK, sessions)
 }
 
-func (h *Handler) Logout(c *gin.Context) {
+func (h *Handler) RevokeSession(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+	if err := h.service.RevokeSession(c.GetString("user_id"), id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
 }
 
 func (h *Handler) ForgotPassword(c *gin.Context) {
@@ -105,7 +205,12 @@ func (h *Handler) ForgotPassword(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Password reset instructions sent to your email"})
+	if err := h.service.RequestPasswordReset(req.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process password reset request"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If an account exists for that email, password reset instructions have been sent"})
 }
 
 func (h *Handler) ResetPassword(c *gin.Context) {
@@ -120,45 +225,765 @@ func (h *Handler) ResetPassword(c *gin.Context) {
 		return
 	}
 
+	if err := h.service.ResetPassword(req.Token, req.NewPassword); err != nil {
+		if errors.Is(err, ErrInvalidResetToken) || isPasswordPolicyError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
 }
 
-func SetupAuthMiddleware() gin.HandlerFunc {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		secret = "default-secret"
+// clientInfo describes the device making the request
+func clientInfo(c *gin.Context) ClientInfo {
+	return ClientInfo{
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+	}
+}
+
+// UnlockUser lifts a login lockout on another user's account
+func (h *Handler) UnlockUser(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	actorID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if err := h.service.UnlockUser(id, actorID, c.ClientIP()); err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked"})
+}
+
+// JWKS publishes the public keys that verify access tokens
+func (h *Handler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.service.JWKS())
+}
+
+// --- API tokens ---
+
+// ListAPIScopes lists the scopes API tokens can be granted
+func (h *Handler) ListAPIScopes(c *gin.Context) {
+	c.JSON(http.StatusOK, APIScopes)
+}
+
+// ListAPITokens lists the caller's own API tokens
+func (h *Handler) ListAPITokens(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	tokens, err := h.service.ListAPITokens(&userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list API tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// ListAllAPITokens lists every user's API tokens, optionally for one user
+func (h *Handler) ListAllAPITokens(c *gin.Context) {
+	var userID *uuid.UUID
+	if raw := c.Query("user_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		userID = &id
+	}
+
+	tokens, err := h.service.ListAPITokens(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list API tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (h *Handler) CreateAPIToken(c *gin.Context) {
+	var create models.APITokenCreate
+	if err := c.ShouldBindJSON(&create); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	return func(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
 
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
-			c.Abort()
+	token, err := h.service.CreateAPIToken(userID, &create, c.ClientIP())
+	if err != nil {
+		if errors.Is(err, ErrInvalidScope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API token"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, token)
+}
+
+// RevokeAPIToken revokes one of the caller's own API tokens
+func (h *Handler) RevokeAPIToken(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	h.revokeAPIToken(c, &userID, userID)
+}
 
-		if len(authHeader) < 7 || authHeader[:7] != "Bearer " {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
-			c.Abort()
+// AdminRevokeAPIToken revokes any user's API token
+func (h *Handler) AdminRevokeAPIToken(c *gin.Context) {
+	actorID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	h.revokeAPIToken(c, nil, actorID)
+}
+
+func (h *Handler) revokeAPIToken(c *gin.Context, ownerID *uuid.UUID, actorID uuid.UUID) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token ID"})
+		return
+	}
+
+	if err := h.service.RevokeAPIToken(id, ownerID, actorID, c.ClientIP()); err != nil {
+		if errors.Is(err, ErrAPITokenNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 			return
 		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API token revoked"})
+}
+
+// --- Single sign-on ---
+
+// OIDCLogin redirects the browser to the identity provider
+func (h *Handler) OIDCLogin(c *gin.Context) {
+	authURL, state, err := h.service.BeginOIDCLogin(c.Query("redirect"))
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrOIDCDisabled):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, ErrInvalidRedirect):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to start single sign-on"})
+		}
+		return
+	}
+
+	// Lax, since the identity provider sends the browser back with a top-level GET
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state, int(oidcStateTTL.Seconds()), "/api/v1/auth/oidc", "", isHTTPS(c), true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback completes a single sign-on login. If the login was started with
+// a redirect path the browser is sent there with the result in the URL
+// fragment, otherwise the result is returned as JSON.
+func (h *Handler) OIDCCallback(c *gin.Context) {
+	if idpError := c.Query("error"); idpError != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Identity provider returned " + idpError, "description": c.Query("error_description")})
+		return
+	}
+
+	browserState, _ := c.Cookie(oidcStateCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, "", -1, "/api/v1/auth/oidc", "", isHTTPS(c), true)
+
+	result, redirectTo, err := h.service.CompleteOIDCLogin(c.Query("state"), browserState, c.Query("code"), clientInfo(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrOIDCDisabled):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, ErrInvalidOIDCState):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, ErrOIDCAccessDenied), errors.Is(err, ErrLastAdmin):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Single sign-on failed"})
+		}
+		return
+	}
+
+	if redirectTo != "" {
+		fragment := url.Values{}
+		if result.Challenge != nil {
+			fragment.Set("challenge_token", result.Challenge.Token)
+			fragment.Set("two_factor_required", strconv.FormatBool(result.Challenge.TwoFactorRequired))
+			fragment.Set("setup_required", strconv.FormatBool(result.Challenge.SetupRequired))
+		} else {
+			fragment.Set("token", result.Tokens.AccessToken)
+			fragment.Set("refresh_token", result.Tokens.RefreshToken)
+			fragment.Set("expires_in", strconv.FormatInt(result.Tokens.ExpiresIn, 10))
+		}
+		c.Redirect(http.StatusFound, redirectTo+"#"+fragment.Encode())
+		return
+	}
+
+	if result.Challenge != nil {
+		c.JSON(http.StatusOK, result.Challenge)
+		return
+	}
+
+	c.JSON(http.StatusOK, LoginResponse{
+		TokenPair: result.Tokens,
+		User:      result.User,
+	})
+}
+
+// isHTTPS reports whether the request reached the server, or the proxy in
+// front of it, over HTTPS
+func isHTTPS(c *gin.Context) bool {
+	return c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+}
+
+// --- Passwords ---
+
+type PasswordChangeChallengeRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	NewPassword    string `json:"new_password" binding:"required"`
+}
+
+// ChangePassword changes the caller's own password and ends all their sessions
+func (h *Handler) ChangePassword(c *gin.Context) {
+	var change models.PasswordChange
+	if err := c.ShouldBindJSON(&change); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if err := h.service.ChangePassword(userID, &change); err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidCurrentPassword), isPasswordPolicyError(err):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed, please log in again"})
+}
+
+// CompletePasswordChange answers a password change challenge and logs the user in
+func (h *Handler) CompletePasswordChange(c *gin.Context) {
+	var req PasswordChangeChallengeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.service.CompletePasswordChange(req.ChallengeToken, req.NewPassword, clientInfo(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidChallenge):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case isPasswordPolicyError(err):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		}
+		return
+	}
 
-		tokenString := authHeader[7:]
+	c.JSON(http.StatusOK, LoginResponse{
+		TokenPair: result.Tokens,
+		User:      result.User,
+	})
+}
+
+func isPasswordPolicyError(err error) bool {
+	var policyErr *PasswordPolicyError
+	return errors.As(err, &policyErr)
+}
 
-		service := NewService(nil, secret)
+// --- User administration ---
 
-		claims, err := service.ValidateToken(tokenString)
+func (h *Handler) ListUsers(c *gin.Context) {
+	filter := UserFilter{
+		Role:   c.Query("role"),
+		Search: c.Query("q"),
+	}
+	if active := c.Query("is_active"); active != "" {
+		isActive, err := strconv.ParseBool(active)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid is_active"})
 			return
 		}
+		filter.IsActive = &isActive
+	}
+	filter.Page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
+	filter.PageSize, _ = strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	users, err := h.service.ListUsers(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
+func (h *Handler) GetUser(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	user, err := h.service.GetUserByID(id.String())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": ErrUserNotFound.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *Handler) UpdateUserRole(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
 
-		c.Set("user_id", claims.UserID)
-		c.Set("username", claims.Username)
-		c.Set("role", claims.Role)
+	var update models.UserRoleUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actorID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if err := h.service.SetUserRole(id, update.Role, actorID, c.ClientIP()); err != nil {
+		userAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role updated, the user's sessions have been ended"})
+}
+
+func (h *Handler) UpdateUserStatus(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var update models.UserStatusUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actorID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if err := h.service.SetUserActive(id, *update.IsActive, actorID, c.ClientIP()); err != nil {
+		userAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User status updated"})
+}
+
+func (h *Handler) SetUserPassword(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var set models.UserPasswordSet
+	if err := c.ShouldBindJSON(&set); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if set.Password == "" && set.MustChangePassword == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "password or must_change_password is required"})
+		return
+	}
+
+	actorID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if err := h.service.SetUserPassword(id, &set, actorID, c.ClientIP()); err != nil {
+		userAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password settings updated"})
+}
+
+func userAdminError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrCannotModifySelf), errors.Is(err, ErrLastAdmin):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case isPasswordPolicyError(err):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "User operation failed"})
+	}
+}
+
+// --- Invitations ---
+
+func (h *Handler) CreateInvitation(c *gin.Context) {
+	var create models.InvitationCreate
+	if err := c.ShouldBindJSON(&create); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actorID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	invitation, err := h.service.CreateInvitation(&create, actorID, c.GetString("role"), c.ClientIP())
+	if err != nil {
+		invitationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, invitation)
+}
+
+func (h *Handler) ListInvitations(c *gin.Context) {
+	invitations, err := h.service.ListInvitations()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list invitations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, invitations)
+}
+
+func (h *Handler) RevokeInvitation(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation ID"})
+		return
+	}
+
+	actorID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if err := h.service.RevokeInvitation(id, actorID, c.ClientIP()); err != nil {
+		invitationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation revoked"})
+}
+
+func (h *Handler) AcceptInvitation(c *gin.Context) {
+	var accept models.InvitationAccept
+	if err := c.ShouldBindJSON(&accept); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.service.AcceptInvitation(&accept, clientInfo(c))
+	if err != nil {
+		invitationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, user)
+}
+
+func invitationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidInvitation), isPasswordPolicyError(err):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrRoleNotAssignable):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrInvitationNotFound), errors.Is(err, ErrEmployeeNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrEmailTaken), errors.Is(err, ErrUsernameTaken), errors.Is(err, ErrEmployeeLinked):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invitation operation failed"})
+	}
+}
+
+// --- Two-factor authentication ---
+
+func (h *Handler) VerifyTwoFactor(c *gin.Context) {
+	var req TwoFactorVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.service.VerifyTwoFactor(req.ChallengeToken, req.Code, req.RecoveryCode, clientInfo(c))
+	if err != nil {
+		var throttled *LoginThrottledError
+		if errors.As(err, &throttled) {
+			c.Header("Retry-After", strconv.Itoa(int(throttled.RetryAfter.Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		twoFactorError(c, err)
+		return
+	}
+
+	if result.Challenge != nil {
+		c.JSON(http.StatusOK, result.Challenge)
+		return
+	}
+
+	c.JSON(http.StatusOK, LoginResponse{
+		TokenPair: result.Tokens,
+		User:      result.User,
+	})
+}
+
+// SetupTwoFactor starts enrollment for the authenticated user
+func (h *Handler) SetupTwoFactor(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	enrollment, err := h.service.BeginEnrollment(userID)
+	if err != nil {
+		twoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmTwoFactor enables 2FA for the authenticated user
+func (h *Handler) ConfirmTwoFactor(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req TwoFactorConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := h.service.ConfirmEnrollment(userID, req.Code)
+	if err != nil {
+		twoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// EnrollTwoFactor starts a mandatory enrollment using a setup challenge from Login
+func (h *Handler) EnrollTwoFactor(c *gin.Context) {
+	var req TwoFactorChallengeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	enrollment, err := h.service.BeginEnrollmentWithChallenge(req.ChallengeToken)
+	if err != nil {
+		twoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmTwoFactorEnrollment finishes a mandatory enrollment and logs the user in
+func (h *Handler) ConfirmTwoFactorEnrollment(c *gin.Context) {
+	var req TwoFactorConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.ChallengeToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "challenge_token and code are required"})
+		return
+	}
+
+	result, codes, err := h.service.ConfirmEnrollmentWithChallenge(req.ChallengeToken, req.Code, clientInfo(c))
+	if err != nil {
+		twoFactorError(c, err)
+		return
+	}
+
+	if result.Challenge != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"password_change_required": true,
+			"challenge_token":          result.Challenge.Token,
+			"expires_in":               result.Challenge.ExpiresIn,
+			"recovery_codes":           codes,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":          result.Tokens.AccessToken,
+		"refresh_token":  result.Tokens.RefreshToken,
+		"expires_in":     result.Tokens.ExpiresIn,
+		"user":           result.User,
+		"recovery_codes": codes,
+	})
+}
+
+func (h *Handler) DisableTwoFactor(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req TwoFactorDisableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.DisableTwoFactor(userID, c.GetString("role"), req.Code, req.RecoveryCode); err != nil {
+		twoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+func (h *Handler) RegenerateRecoveryCodes(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req TwoFactorConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := h.service.RegenerateRecoveryCodes(userID, req.Code)
+	if err != nil {
+		twoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+func (h *Handler) ListTwoFactorPolicies(c *gin.Context) {
+	policies, err := h.service.ListTwoFactorPolicies()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list two-factor policies"})
+		return
+	}
+
+	c.JSON(http.StatusOK, policies)
+}
+
+func (h *Handler) UpdateTwoFactorPolicy(c *gin.Context) {
+	role := c.Param("role")
+	if !IsKnownRole(role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown role"})
+		return
+	}
+
+	var req models.TwoFactorPolicyUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy, err := h.service.SetTwoFactorPolicy(role, *req.Required)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update two-factor policy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+// twoFactorError maps 2FA service errors to HTTP responses
+func twoFactorError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidChallenge), errors.Is(err, ErrInvalidTwoFactorCode):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrTwoFactorAlreadyEnabled), errors.Is(err, ErrTwoFactorNotEnabled),
+		errors.Is(err, ErrTwoFactorNotStarted):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrTwoFactorMandatory):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Two-factor operation failed"})
+	}
+}
 
-		c.Next()
+// IsKnownRole reports whether role is one of the roles users can hold
+func IsKnownRole(role string) bool {
+	switch role {
+	case "admin", "hr", "manager", "employee":
+		return true
 	}
+	return false
 }
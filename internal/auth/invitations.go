@@ -0,0 +1,308 @@
+package auth
+
+import (
+	"database/sql"
+	"employee-management/internal/mail"
+	"employee-management/internal/models"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrSelfRegistrationDisabled is returned by Register unless public sign-up is enabled
+	ErrSelfRegistrationDisabled = errors.New("self-registration is disabled, ask HR for an invitation")
+	// ErrInvalidInvitation is returned when an invitation code is unknown, expired, revoked or already used
+	ErrInvalidInvitation = errors.New("invalid or expired invitation")
+	// ErrInvitationNotFound is returned when revoking an unknown or no longer pending invitation
+	ErrInvitationNotFound = errors.New("invitation not found")
+	// ErrRoleNotAssignable is returned when the inviter may not grant the requested role
+	ErrRoleNotAssignable = errors.New("you are not allowed to assign this role")
+	// ErrEmailTaken is returned when an account with the email already exists
+	ErrEmailTaken = errors.New("a user with this email already exists")
+	// ErrUsernameTaken is returned when the chosen username is already in use
+	ErrUsernameTaken = errors.New("username is already taken")
+	// ErrEmployeeNotFound is returned when an invitation links to an unknown employee
+	ErrEmployeeNotFound = errors.New("employee not found")
+	// ErrEmployeeLinked is returned when an invitation links to an employee that already has a user account
+	ErrEmployeeLinked = errors.New("employee is already linked to a user account")
+)
+
+// Invitation is an invitation as listed to hr and admins
+type Invitation struct {
+	models.Invitation
+	Status string `json:"status"`
+}
+
+// CreatedInvitation is returned once when an invitation is created. The code
+// is not stored and cannot be retrieved later.
+type CreatedInvitation struct {
+	*models.Invitation
+	Code string `json:"code"`
+}
+
+// canAssignRole reports whether a user with actorRole may give role to someone
+// else. Only admins can create other admins.
+func canAssignRole(actorRole, role string) bool {
+	switch actorRole {
+	case "admin":
+		return IsKnownRole(role)
+	case "hr":
+		return IsKnownRole(role) && role != "admin"
+	}
+	return false
+}
+
+// CreateInvitation stores a single-use invitation and mails its code to the invitee
+func (s *Service) CreateInvitation(create *models.InvitationCreate, actorID uuid.UUID, actorRole, ip string) (*CreatedInvitation, error) {
+	if !canAssignRole(actorRole, create.Role) {
+		return nil, ErrRoleNotAssignable
+	}
+
+	email := strings.ToLower(strings.TrimSpace(create.Email))
+
+	var exists bool
+	if err := s.db.QueryRow("SELECT EXISTS (SELECT 1 FROM users WHERE LOWER(email) = $1)", email).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrEmailTaken
+	}
+
+	if create.EmployeeID != nil {
+		var linkedUserID uuid.NullUUID
+		err := s.db.QueryRow("SELECT user_id FROM employees WHERE id = $1 AND deleted_at IS NULL", create.EmployeeID).Scan(&linkedUserID)
+		if err == sql.ErrNoRows {
+			return nil, ErrEmployeeNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		if linkedUserID.Valid {
+			return nil, ErrEmployeeLinked
+		}
+	}
+
+	ttl := s.config.InvitationTTL
+	if create.ExpiresInHours > 0 {
+		ttl = time.Duration(create.ExpiresInHours) * time.Hour
+	}
+
+	code, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	invitation := &models.Invitation{
+		Email:      email,
+		Role:       create.Role,
+		EmployeeID: create.EmployeeID,
+		InvitedBy:  actorID,
+		ExpiresAt:  time.Now().Add(ttl),
+	}
+	err = s.withTx(func(tx *sql.Tx) error {
+		err := tx.QueryRow(
+			`INSERT INTO invitations (email, role, employee_id, code_hash, invited_by, expires_at)
+			 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`,
+			invitation.Email, invitation.Role, invitation.EmployeeID, hashToken(code), invitation.InvitedBy, invitation.ExpiresAt,
+		).Scan(&invitation.ID, &invitation.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		return s.audit.RecordWith(tx, &models.AuditEventCreate{
+			ActorUserID: &actorID,
+			Action:      "auth.invitation_created",
+			TargetType:  "invitation",
+			TargetID:    invitation.ID.String(),
+			Details:     map[string]interface{}{"email": invitation.Email, "role": invitation.Role},
+			IPAddress:   ip,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	link := fmt.Sprintf("%s/accept-invitation?code=%s", strings.TrimRight(s.config.AppURL, "/"), url.QueryEscape(code))
+	if err := s.mailer.Send(mail.Message{
+		To:      invitation.Email,
+		Subject: "You have been invited to Employee Management",
+		Body: fmt.Sprintf("You have been invited to join Employee Management as %s.\n\n"+
+			"Use the link below to choose a username and password. It expires on %s and can only be used once.\n\n%s",
+			invitation.Role, invitation.ExpiresAt.Format(time.RFC1123), link),
+	}); err != nil {
+		return nil, err
+	}
+
+	return &CreatedInvitation{Invitation: invitation, Code: code}, nil
+}
+
+// ListInvitations returns all invitations, newest first
+func (s *Service) ListInvitations() ([]Invitation, error) {
+	rows, err := s.db.Query(
+		`SELECT id, email, role, employee_id, invited_by, expires_at, accepted_at, accepted_user_id, revoked_at, created_at
+		 FROM invitations ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	invitations := []Invitation{}
+	for rows.Next() {
+		var inv Invitation
+		var employeeID, acceptedUserID uuid.NullUUID
+		var acceptedAt, revokedAt sql.NullTime
+		if err := rows.Scan(&inv.ID, &inv.Email, &inv.Role, &employeeID, &inv.InvitedBy, &inv.ExpiresAt,
+			&acceptedAt, &acceptedUserID, &revokedAt, &inv.CreatedAt); err != nil {
+			return nil, err
+		}
+		if employeeID.Valid {
+			inv.EmployeeID = &employeeID.UUID
+		}
+		if acceptedUserID.Valid {
+			inv.AcceptedUserID = &acceptedUserID.UUID
+		}
+		if acceptedAt.Valid {
+			inv.AcceptedAt = &acceptedAt.Time
+		}
+		if revokedAt.Valid {
+			inv.RevokedAt = &revokedAt.Time
+		}
+
+		switch {
+		case inv.AcceptedAt != nil:
+			inv.Status = "accepted"
+		case inv.RevokedAt != nil:
+			inv.Status = "revoked"
+		case now.After(inv.ExpiresAt):
+			inv.Status = "expired"
+		default:
+			inv.Status = "pending"
+		}
+		invitations = append(invitations, inv)
+	}
+	return invitations, rows.Err()
+}
+
+// RevokeInvitation cancels a pending invitation
+func (s *Service) RevokeInvitation(id, actorID uuid.UUID, ip string) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec(
+			"UPDATE invitations SET revoked_at = NOW() WHERE id = $1 AND accepted_at IS NULL AND revoked_at IS NULL",
+			id)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return ErrInvitationNotFound
+		}
+
+		return s.audit.RecordWith(tx, &models.AuditEventCreate{
+			ActorUserID: &actorID,
+			Action:      "auth.invitation_revoked",
+			TargetType:  "invitation",
+			TargetID:    id.String(),
+			IPAddress:   ip,
+		})
+	})
+}
+
+// AcceptInvitation redeems an invitation code, creating the invitee's account
+// with the role chosen by the inviter and linking it to their employee record
+func (s *Service) AcceptInvitation(accept *models.InvitationAccept, client ClientInfo) (*models.User, error) {
+	if err := s.config.PasswordPolicy.Validate(accept.Password, accept.Username, ""); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := s.HashPassword(accept.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	err = s.withTx(func(tx *sql.Tx) error {
+		var invitationID uuid.UUID
+		var employeeID uuid.NullUUID
+		err := tx.QueryRow(
+			`SELECT id, email, role, employee_id FROM invitations
+			 WHERE code_hash = $1 AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > NOW()
+			 FOR UPDATE`,
+			hashToken(accept.Code)).Scan(&invitationID, &user.Email, &user.Role, &employeeID)
+		if err == sql.ErrNoRows {
+			return ErrInvalidInvitation
+		}
+		if err != nil {
+			return err
+		}
+
+		var taken bool
+		if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM users WHERE username = $1)", accept.Username).Scan(&taken); err != nil {
+			return err
+		}
+		if taken {
+			return ErrUsernameTaken
+		}
+		if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM users WHERE LOWER(email) = $1)", user.Email).Scan(&taken); err != nil {
+			return err
+		}
+		if taken {
+			return ErrEmailTaken
+		}
+
+		err = tx.QueryRow(
+			`INSERT INTO users (username, email, password, role, is_active, password_changed_at) VALUES ($1, $2, $3, $4, true, NOW())
+			 RETURNING id, username, is_active, created_at, updated_at`,
+			accept.Username, user.Email, hashedPassword, user.Role,
+		).Scan(&user.ID, &user.Username, &user.IsActive, &user.CreatedAt, &user.UpdatedAt)
+		if err != nil {
+			return err
+		}
+
+		if employeeID.Valid {
+			// The employee may have been linked to someone else since the
+			// invitation was sent; never take their record over
+			linked, err := tx.Exec(
+				"UPDATE employees SET user_id = $1, updated_at = NOW() WHERE id = $2 AND user_id IS NULL",
+				user.ID, employeeID.UUID)
+			if err != nil {
+				return err
+			}
+			rowsAffected, err := linked.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if rowsAffected == 0 {
+				return ErrEmployeeLinked
+			}
+		}
+
+		if _, err := tx.Exec(
+			"UPDATE invitations SET accepted_at = NOW(), accepted_user_id = $1 WHERE id = $2",
+			user.ID, invitationID); err != nil {
+			return err
+		}
+
+		return s.audit.RecordWith(tx, &models.AuditEventCreate{
+			ActorUserID: &user.ID,
+			Action:      "auth.invitation_accepted",
+			TargetType:  "invitation",
+			TargetID:    invitationID.String(),
+			Details:     map[string]interface{}{"username": user.Username, "role": user.Role},
+			IPAddress:   client.IPAddress,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
@@ -0,0 +1,372 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported signing algorithms
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+	AlgHS256 = "HS256"
+)
+
+// minHMACSecretLength is the shortest HS256 secret accepted
+const minHMACSecretLength = 32
+
+var (
+	// ErrNoSigningKey is returned when no key in the set is currently active
+	ErrNoSigningKey = errors.New("no active signing key")
+	// ErrUnknownKey is returned when a token names a key that is not in the set
+	ErrUnknownKey = errors.New("unknown signing key")
+)
+
+// SigningKey is one key of a KeySet. A key signs new tokens from NotBefore
+// until a newer key becomes active, and verifies tokens until NotAfter, which
+// is the grace period for tokens it signed before being rotated out.
+type SigningKey struct {
+	ID        string
+	Algorithm string
+	NotBefore time.Time
+	// NotAfter is zero for keys that never expire
+	NotAfter time.Time
+
+	signer crypto.Signer
+	secret []byte
+}
+
+func (k *SigningKey) method() jwt.SigningMethod {
+	switch k.Algorithm {
+	case AlgRS256:
+		return jwt.SigningMethodRS256
+	case AlgEdDSA:
+		return jwt.SigningMethodEdDSA
+	default:
+		return jwt.SigningMethodHS256
+	}
+}
+
+func (k *SigningKey) signingKey() interface{} {
+	if k.secret != nil {
+		return k.secret
+	}
+	return k.signer
+}
+
+func (k *SigningKey) verificationKey() interface{} {
+	if k.secret != nil {
+		return k.secret
+	}
+	return k.signer.Public()
+}
+
+func (k *SigningKey) validAt(now time.Time) bool {
+	return k.NotAfter.IsZero() || now.Before(k.NotAfter)
+}
+
+// KeySet holds the keys used to sign and verify tokens. Every token carries a
+// kid header naming the key that signed it.
+type KeySet struct {
+	mu   sync.RWMutex
+	keys []*SigningKey
+	// path is the manifest the set was loaded from, if any
+	path string
+}
+
+// keyManifest is the JSON file listing the configured keys
+type keyManifest struct {
+	Keys []struct {
+		ID             string    `json:"kid"`
+		Algorithm      string    `json:"algorithm"`
+		PrivateKeyFile string    `json:"private_key_file"`
+		NotBefore      time.Time `json:"not_before"`
+		NotAfter       time.Time `json:"not_after"`
+	} `json:"keys"`
+}
+
+// LoadKeySet reads a key manifest. Relative key file paths are resolved
+// against the manifest's directory. Example:
+//
+//	{"keys": [
+//	  {"kid": "2026-09", "algorithm": "EdDSA", "private_key_file": "2026-09.pem",
+//	   "not_before": "2026-09-01T00:00:00Z", "not_after": "2026-10-02T00:00:00Z"},
+//	  {"kid": "2026-10", "algorithm": "EdDSA", "private_key_file": "2026-10.pem",
+//	   "not_before": "2026-10-01T00:00:00Z"}
+//	]}
+func LoadKeySet(path string) (*KeySet, error) {
+	ks := &KeySet{path: path}
+	if err := ks.Reload(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// Reload re-reads the manifest the set was loaded from, so keys can be added
+// or retired without a restart. The current keys are kept if loading fails.
+func (ks *KeySet) Reload() error {
+	if ks.path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(ks.path)
+	if err != nil {
+		return fmt.Errorf("read key manifest: %w", err)
+	}
+
+	var manifest keyManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return fmt.Errorf("parse key manifest: %w", err)
+	}
+	if len(manifest.Keys) == 0 {
+		return errors.New("key manifest lists no keys")
+	}
+
+	seen := map[string]bool{}
+	keys := make([]*SigningKey, 0, len(manifest.Keys))
+	for _, entry := range manifest.Keys {
+		if entry.ID == "" {
+			return errors.New("key manifest entry without kid")
+		}
+		if seen[entry.ID] {
+			return fmt.Errorf("duplicate kid %q in key manifest", entry.ID)
+		}
+		seen[entry.ID] = true
+
+		if !entry.NotAfter.IsZero() && !entry.NotAfter.After(entry.NotBefore) {
+			return fmt.Errorf("key %q: not_after must be later than not_before", entry.ID)
+		}
+
+		file := entry.PrivateKeyFile
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(ks.path), file)
+		}
+		signer, err := loadPrivateKey(file)
+		if err != nil {
+			return fmt.Errorf("key %q: %w", entry.ID, err)
+		}
+
+		key := &SigningKey{
+			ID:        entry.ID,
+			Algorithm: entry.Algorithm,
+			NotBefore: entry.NotBefore,
+			NotAfter:  entry.NotAfter,
+			signer:    signer,
+		}
+		if err := checkAlgorithm(key); err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+
+	ks.setKeys(keys)
+	return nil
+}
+
+// NewHMACKeySet returns a set holding a single HS256 key. HS256 tokens can only
+// be verified by holders of the secret, so the key is not published as a JWK.
+func NewHMACKeySet(kid string, secret []byte) (*KeySet, error) {
+	if len(secret) < minHMACSecretLength {
+		return nil, fmt.Errorf("HS256 secret must be at least %d bytes", minHMACSecretLength)
+	}
+	ks := &KeySet{}
+	ks.setKeys([]*SigningKey{{ID: kid, Algorithm: AlgHS256, secret: secret}})
+	return ks, nil
+}
+
+// NewEphemeralKeySet generates an in-memory Ed25519 key. Tokens signed with it
+// do not survive a restart, so it is only meant for development.
+func NewEphemeralKeySet() (*KeySet, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	ks := &KeySet{}
+	ks.setKeys([]*SigningKey{{
+		ID:        "ephemeral-" + time.Now().UTC().Format("20060102150405"),
+		Algorithm: AlgEdDSA,
+		signer:    private,
+	}})
+	return ks, nil
+}
+
+func (ks *KeySet) setKeys(keys []*SigningKey) {
+	// Newest first, so the first usable key is the one that signs
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i].NotBefore.After(keys[j].NotBefore) })
+
+	ks.mu.Lock()
+	ks.keys = keys
+	ks.mu.Unlock()
+}
+
+// SigningKey returns the key that signs new tokens at the given time: the most
+// recently activated key that has not expired
+func (ks *KeySet) SigningKey(now time.Time) (*SigningKey, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	for _, key := range ks.keys {
+		if !now.Before(key.NotBefore) && key.validAt(now) {
+			return key, nil
+		}
+	}
+	return nil, ErrNoSigningKey
+}
+
+// Sign signs the claims with the active key and sets the kid header
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	key, err := ks.SigningKey(time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.signingKey())
+}
+
+// Parse verifies a token signed by any unexpired key in the set
+func (ks *KeySet) Parse(tokenString string, claims jwt.Claims, options ...jwt.ParserOption) (*jwt.Token, error) {
+	options = append(options, jwt.WithValidMethods([]string{AlgRS256, AlgEdDSA, AlgHS256}))
+	return jwt.ParseWithClaims(tokenString, claims, ks.keyfunc, options...)
+}
+
+func (ks *KeySet) keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	for _, key := range ks.keys {
+		if key.ID != kid {
+			continue
+		}
+		// The algorithm is fixed per key so a token cannot pick a weaker one
+		if token.Method.Alg() != key.Algorithm || !key.validAt(time.Now()) {
+			return nil, ErrUnknownKey
+		}
+		return key.verificationKey(), nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517)
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of all unexpired asymmetric keys, including
+// ones scheduled for the future so that verifiers can cache them in advance
+func (ks *KeySet) JWKS() JWKS {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	set := JWKS{Keys: []JWK{}}
+	now := time.Now()
+	for _, key := range ks.keys {
+		if key.secret != nil || !key.validAt(now) {
+			continue
+		}
+
+		jwk := JWK{KeyID: key.ID, Use: "sig", Algorithm: key.Algorithm}
+		switch public := key.signer.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// loadPrivateKey reads a PEM encoded PKCS#8 or PKCS#1 private key
+func loadPrivateKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("unsupported private key type")
+		}
+		return signer, nil
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+}
+
+// checkAlgorithm fills in the algorithm from the key type if it was left out
+// and makes sure the two agree
+func checkAlgorithm(key *SigningKey) error {
+	var expected string
+	switch public := key.signer.Public().(type) {
+	case *rsa.PublicKey:
+		if public.N.BitLen() < 2048 {
+			return fmt.Errorf("key %q: RSA keys must be at least 2048 bits", key.ID)
+		}
+		expected = AlgRS256
+	case ed25519.PublicKey:
+		expected = AlgEdDSA
+	default:
+		return fmt.Errorf("key %q: unsupported key type %T", key.ID, public)
+	}
+
+	if key.Algorithm == "" {
+		key.Algorithm = expected
+	}
+	if key.Algorithm != expected {
+		return fmt.Errorf("key %q: algorithm %s does not match the key type", key.ID, key.Algorithm)
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package auth
+
+import (
+	"database/sql"
+	"employee-management/internal/models"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	throttleScopeUsername = "username"
+	throttleScopeIP       = "ip"
+
+	// maxBackoff caps the exponential backoff applied after repeated lockouts
+	maxBackoff = 24 * time.Hour
+	// ipBackoffBase is the first block applied to a client IP over its threshold
+	ipBackoffBase = time.Minute
+)
+
+// ErrUserNotFound is returned when an admin operation targets an unknown user
+var ErrUserNotFound = errors.New("user not found")
+
+// LoginThrottledError is returned while a username or client IP is locked out
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, try again in %s", e.RetryAfter.Round(time.Second))
+}
+
+// dummyPasswordHash is compared against when the username is unknown, so that
+// unknown users and wrong passwords take the same time to reject
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("not-a-real-password"), bcrypt.DefaultCost)
+
+// checkThrottle returns a LoginThrottledError if the username or client IP is
+// currently blocked
+func (s *Service) checkThrottle(username, ip string) error {
+	var remaining float64
+	err := s.db.QueryRow(
+		`SELECT COALESCE(EXTRACT(EPOCH FROM MAX(blocked_until) - NOW()), 0)
+		 FROM (
+		     SELECT blocked_until FROM login_throttles
+		     WHERE (scope = $1 AND key = $2) OR (scope = $3 AND key = $4)
+		     UNION ALL
+		     SELECT locked_until FROM users WHERE username = $2
+		 ) blocks`,
+		throttleScopeUsername, username, throttleScopeIP, ip).Scan(&remaining)
+	if err != nil {
+		return err
+	}
+
+	if remaining > 0 {
+		return &LoginThrottledError{RetryAfter: time.Duration(remaining * float64(time.Second))}
+	}
+	return nil
+}
+
+// recordLoginFailure counts a failed attempt against the username and client
+// IP, blocking either once it crosses its threshold. user is nil when the
+// username does not exist.
+func (s *Service) recordLoginFailure(username string, user *models.User, client ClientInfo) error {
+	if err := s.pruneThrottles(); err != nil {
+		return err
+	}
+
+	failures, err := s.incrementThrottle(throttleScopeUsername, username)
+	if err != nil {
+		return err
+	}
+	if failures >= s.config.MaxFailedLogins {
+		block := backoff(s.config.LockoutDuration, failures-s.config.MaxFailedLogins)
+		if err := s.blockThrottle(throttleScopeUsername, username, block); err != nil {
+			return err
+		}
+
+		details := map[string]interface{}{
+			"username":        username,
+			"failed_attempts": failures,
+			"locked_seconds":  int64(block.Seconds()),
+		}
+		targetID := ""
+		if user != nil {
+			targetID = user.ID.String()
+			if _, err := s.db.Exec(
+				"UPDATE users SET failed_login_attempts = $1, locked_until = NOW() + make_interval(secs => $2) WHERE id = $3",
+				failures, block.Seconds(), user.ID); err != nil {
+				return err
+			}
+		}
+		if err := s.audit.Record(&models.AuditEventCreate{
+			Action:     "auth.account_locked",
+			TargetType: "user",
+			TargetID:   targetID,
+			Details:    details,
+			IPAddress:  client.IPAddress,
+		}); err != nil {
+			return err
+		}
+	} else if user != nil {
+		if _, err := s.db.Exec("UPDATE users SET failed_login_attempts = $1 WHERE id = $2", failures, user.ID); err != nil {
+			return err
+		}
+	}
+
+	if client.IPAddress == "" {
+		return nil
+	}
+
+	ipFailures, err := s.incrementThrottle(throttleScopeIP, client.IPAddress)
+	if err != nil {
+		return err
+	}
+	if ipFailures >= s.config.MaxFailedLoginsPerIP {
+		block := backoff(ipBackoffBase, ipFailures-s.config.MaxFailedLoginsPerIP)
+		if err := s.blockThrottle(throttleScopeIP, client.IPAddress, block); err != nil {
+			return err
+		}
+		return s.audit.Record(&models.AuditEventCreate{
+			Action:     "auth.ip_blocked",
+			TargetType: "ip",
+			TargetID:   client.IPAddress,
+			Details: map[string]interface{}{
+				"failed_attempts": ipFailures,
+				"blocked_seconds": int64(block.Seconds()),
+			},
+			IPAddress: client.IPAddress,
+		})
+	}
+
+	return nil
+}
+
+// recordLoginSuccess clears the username's failure counters
+func (s *Service) recordLoginSuccess(user *models.User) error {
+	if _, err := s.db.Exec(
+		"DELETE FROM login_throttles WHERE scope = $1 AND key = $2",
+		throttleScopeUsername, user.Username); err != nil {
+		return err
+	}
+	_, err := s.db.Exec("UPDATE users SET failed_login_attempts = 0, locked_until = NULL WHERE id = $1", user.ID)
+	return err
+}
+
+// incrementThrottle adds a failure to the counter and returns the new count.
+// Counters whose last failure is older than the failure window start over.
+func (s *Service) incrementThrottle(scope, key string) (int, error) {
+	var failures int
+	err := s.db.QueryRow(
+		`INSERT INTO login_throttles (scope, key, failures, last_failed_at) VALUES ($1, $2, 1, NOW())
+		 ON CONFLICT (scope, key) DO UPDATE SET
+		     failures = CASE
+		         WHEN login_throttles.last_failed_at < NOW() - make_interval(secs => $3) THEN 1
+		         ELSE login_throttles.failures + 1
+		     END,
+		     last_failed_at = NOW()
+		 RETURNING failures`,
+		scope, key, s.config.FailureWindow.Seconds()).Scan(&failures)
+	return failures, err
+}
+
+// pruneThrottles deletes counters that would start over on the next failure
+// and are not blocking, so that attempts with ever new usernames or client
+// IPs do not pile up
+func (s *Service) pruneThrottles() error {
+	_, err := s.db.Exec(
+		`DELETE FROM login_throttles
+		 WHERE last_failed_at < NOW() - make_interval(secs => $1)
+		   AND (blocked_until IS NULL OR blocked_until < NOW())`,
+		s.config.FailureWindow.Seconds())
+	return err
+}
+
+func (s *Service) blockThrottle(scope, key string, block time.Duration) error {
+	_, err := s.db.Exec(
+		"UPDATE login_throttles SET blocked_until = NOW() + make_interval(secs => $1) WHERE scope = $2 AND key = $3",
+		block.Seconds(), scope, key)
+	return err
+}
+
+// backoff doubles base for every failure beyond the threshold, up to maxBackoff
+func backoff(base time.Duration, excess int) time.Duration {
+	if excess > 16 {
+		return maxBackoff
+	}
+	block := time.Duration(float64(base) * math.Pow(2, float64(excess)))
+	if block > maxBackoff {
+		return maxBackoff
+	}
+	return block
+}
+
+// UnlockUser lifts a lockout before it expires
+func (s *Service) UnlockUser(userID uuid.UUID, actorID uuid.UUID, ip string) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		var username string
+		err := tx.QueryRow(
+			"UPDATE users SET failed_login_attempts = 0, locked_until = NULL WHERE id = $1 RETURNING username",
+			userID).Scan(&username)
+		if err == sql.ErrNoRows {
+			return ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(
+			"DELETE FROM login_throttles WHERE scope = $1 AND key = $2",
+			throttleScopeUsername, username); err != nil {
+			return err
+		}
+
+		return s.audit.RecordWith(tx, &models.AuditEventCreate{
+			ActorUserID: &actorID,
+			Action:      "auth.account_unlocked",
+			TargetType:  "user",
+			TargetID:    userID.String(),
+			IPAddress:   ip,
+		})
+	})
+}
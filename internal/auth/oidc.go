@@ -0,0 +1,370 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"employee-management/internal/models"
+	"employee-management/internal/oidc"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// oidcStateTTL is how long the user has to complete the login at the identity provider
+	oidcStateTTL = 10 * time.Minute
+	// oidcStateCookie holds the state in the browser that started the login,
+	// so a callback cannot be replayed in someone else's browser
+	oidcStateCookie = "oidc_state"
+)
+
+var (
+	// ErrOIDCDisabled is returned when single sign-on is not configured
+	ErrOIDCDisabled = errors.New("single sign-on is not configured")
+	// ErrInvalidOIDCState is returned when the callback state is unknown, expired or already used
+	ErrInvalidOIDCState = errors.New("invalid or expired login state")
+	// ErrInvalidRedirect is returned when the post-login redirect is not a local path
+	ErrInvalidRedirect = errors.New("redirect must be a path on this site")
+	// ErrOIDCAccessDenied is returned when the identity provider user may not use the application
+	ErrOIDCAccessDenied = errors.New("your account is not allowed to use this application")
+)
+
+// OIDCConfig configures single sign-on through an OpenID Connect provider
+type OIDCConfig struct {
+	Provider *oidc.Provider
+	// GroupsClaim is the ID token claim listing the user's groups
+	GroupsClaim string
+	// RoleMapping maps IdP groups to application roles. When a user is in several
+	// mapped groups the most privileged role wins.
+	RoleMapping map[string]string
+	// DefaultRole is given to new users in no mapped group, and to existing
+	// ones when SyncRoles is set. Empty means such users are refused.
+	DefaultRole string
+	// SyncRoles updates existing users' roles from their groups on every
+	// login. Users in no mapped group get DefaultRole, or are refused.
+	SyncRoles bool
+}
+
+// rolePriority orders roles from least to most privileged
+var rolePriority = map[string]int{"employee": 1, "manager": 2, "hr": 3, "admin": 4}
+
+// mappedRole returns the most privileged role mapped from the user's groups,
+// or "" if none of their groups is mapped
+func (c *OIDCConfig) mappedRole(groups []string) string {
+	role := ""
+	for _, group := range groups {
+		if mapped, ok := c.RoleMapping[group]; ok && rolePriority[mapped] > rolePriority[role] {
+			role = mapped
+		}
+	}
+	return role
+}
+
+// BeginOIDCLogin starts an authorization code flow with PKCE and returns the
+// identity provider URL to send the user to, and the state the browser must
+// present again on the callback. redirectTo is an optional local path the
+// user is sent back to after logging in.
+func (s *Service) BeginOIDCLogin(redirectTo string) (string, string, error) {
+	if s.config.OIDC == nil {
+		return "", "", ErrOIDCDisabled
+	}
+	if redirectTo != "" && (!strings.HasPrefix(redirectTo, "/") || strings.HasPrefix(redirectTo, "//") || strings.Contains(redirectTo, "\\")) {
+		return "", "", ErrInvalidRedirect
+	}
+
+	state, err := oidc.GenerateState()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := oidc.GenerateState()
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := oidc.GenerateVerifier()
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL, err := s.config.OIDC.Provider.AuthCodeURL(state, nonce, verifier)
+	if err != nil {
+		return "", "", err
+	}
+
+	if _, err := s.db.Exec(
+		`INSERT INTO oidc_login_states (state_hash, nonce, code_verifier, redirect_to, expires_at)
+		 VALUES ($1, $2, $3, $4, $5)`,
+		hashToken(state), nonce, verifier, sql.NullString{String: redirectTo, Valid: redirectTo != ""},
+		time.Now().Add(oidcStateTTL)); err != nil {
+		return "", "", err
+	}
+
+	// Abandoned logins leave their state behind
+	if _, err := s.db.Exec("DELETE FROM oidc_login_states WHERE expires_at < NOW()"); err != nil {
+		return "", "", err
+	}
+
+	return authURL, state, nil
+}
+
+// CompleteOIDCLogin handles the identity provider's callback. The user is
+// found by their IdP identity, linked by verified email to an existing account,
+// or provisioned on the fly. It also returns the redirect path given to
+// BeginOIDCLogin. browserState is the state kept by the browser that started
+// the login, and must match state.
+func (s *Service) CompleteOIDCLogin(state, browserState, code string, client ClientInfo) (*LoginResult, string, error) {
+	if s.config.OIDC == nil {
+		return nil, "", ErrOIDCDisabled
+	}
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(browserState)) != 1 {
+		return nil, "", ErrInvalidOIDCState
+	}
+
+	var nonce, verifier string
+	var redirectTo sql.NullString
+	err := s.db.QueryRow(
+		`DELETE FROM oidc_login_states WHERE state_hash = $1 AND expires_at > NOW()
+		 RETURNING nonce, code_verifier, redirect_to`,
+		hashToken(state)).Scan(&nonce, &verifier, &redirectTo)
+	if err == sql.ErrNoRows {
+		return nil, "", ErrInvalidOIDCState
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	claims, err := s.config.OIDC.Provider.Exchange(code, verifier, nonce)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var user *models.User
+	err = s.withTx(func(tx *sql.Tx) error {
+		user, err = s.resolveOIDCUser(tx, claims, client)
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	if !user.IsActive {
+		return nil, "", ErrOIDCAccessDenied
+	}
+
+	challenge, err := s.twoFactorChallenge(user)
+	if err != nil {
+		return nil, "", err
+	}
+	if challenge != nil {
+		return &LoginResult{User: user, Challenge: challenge}, redirectTo.String, nil
+	}
+
+	result, err := s.completeLogin(user, client)
+	if err != nil {
+		return nil, "", err
+	}
+	return result, redirectTo.String, nil
+}
+
+// resolveOIDCUser finds or creates the local user for an IdP identity
+func (s *Service) resolveOIDCUser(tx *sql.Tx, claims *oidc.IDTokenClaims, client ClientInfo) (*models.User, error) {
+	config := s.config.OIDC
+	issuer := config.Provider.Issuer()
+	role := config.mappedRole(claims.StringsClaim(config.GroupsClaim))
+	email := strings.ToLower(strings.TrimSpace(claims.Email))
+
+	var userID uuid.UUID
+	err := tx.QueryRow(
+		"SELECT user_id FROM user_identities WHERE issuer = $1 AND subject = $2",
+		issuer, claims.Subject).Scan(&userID)
+	switch {
+	case err == nil:
+		if _, err := tx.Exec(
+			"UPDATE user_identities SET last_login_at = NOW(), email = $1 WHERE issuer = $2 AND subject = $3",
+			email, issuer, claims.Subject); err != nil {
+			return nil, err
+		}
+		if config.SyncRoles {
+			if err := s.syncOIDCRole(tx, userID, role, client); err != nil {
+				return nil, err
+			}
+		}
+
+	case err == sql.ErrNoRows:
+		if email == "" || !claims.EmailVerified {
+			return nil, fmt.Errorf("%w: the identity provider did not supply a verified email", ErrOIDCAccessDenied)
+		}
+
+		// Link to an existing account with the same email, or provision a new one
+		err := tx.QueryRow("SELECT id FROM users WHERE LOWER(email) = $1", email).Scan(&userID)
+		action := "auth.sso_linked"
+		if err == sql.ErrNoRows {
+			if role == "" {
+				role = config.DefaultRole
+			}
+			if role == "" {
+				return nil, ErrOIDCAccessDenied
+			}
+			if userID, err = s.provisionOIDCUser(tx, claims, email, role); err != nil {
+				return nil, err
+			}
+			action = "auth.sso_provisioned"
+		} else if err != nil {
+			return nil, err
+		} else if config.SyncRoles {
+			if err := s.syncOIDCRole(tx, userID, role, client); err != nil {
+				return nil, err
+			}
+		}
+
+		if _, err := tx.Exec(
+			`INSERT INTO user_identities (user_id, issuer, subject, email, last_login_at)
+			 VALUES ($1, $2, $3, $4, NOW())`,
+			userID, issuer, claims.Subject, email); err != nil {
+			return nil, err
+		}
+
+		if err := s.audit.RecordWith(tx, &models.AuditEventCreate{
+			ActorUserID: &userID,
+			Action:      action,
+			TargetType:  "user",
+			TargetID:    userID.String(),
+			Details:     map[string]interface{}{"issuer": issuer, "subject": claims.Subject, "email": email},
+			IPAddress:   client.IPAddress,
+		}); err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, err
+	}
+
+	var user models.User
+	var lastLogin sql.NullTime
+	err = tx.QueryRow(
+		`SELECT id, username, email, role, is_active, last_login, created_at, updated_at, two_factor_enabled
+		 FROM users WHERE id = $1`,
+		userID).Scan(&user.ID, &user.Username, &user.Email, &user.Role, &user.IsActive, &lastLogin,
+		&user.CreatedAt, &user.UpdatedAt, &user.TwoFactorEnabled)
+	if err != nil {
+		return nil, err
+	}
+	if lastLogin.Valid {
+		user.LastLogin = &lastLogin.Time
+	}
+	return &user, nil
+}
+
+// provisionOIDCUser creates a user for a first-time single sign-on login. The
+// account gets an unusable random password, so it can only log in through the
+// identity provider until someone sets one.
+func (s *Service) provisionOIDCUser(tx *sql.Tx, claims *oidc.IDTokenClaims, email, role string) (uuid.UUID, error) {
+	username, err := uniqueUsername(tx, claims.PreferredUsername, email)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	password, err := generateOpaqueToken()
+	if err != nil {
+		return uuid.Nil, err
+	}
+	hashedPassword, err := s.HashPassword(password)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	var userID uuid.UUID
+	err = tx.QueryRow(
+		"INSERT INTO users (username, email, password, role, is_active) VALUES ($1, $2, $3, $4, true) RETURNING id",
+		username, email, hashedPassword, role).Scan(&userID)
+	return userID, err
+}
+
+// syncOIDCRole applies the role mapped from the user's IdP groups. A user
+// in no mapped group falls back to DefaultRole, or is refused when there is
+// none, so leaving every mapped group takes away the role they had. All
+// tokens are revoked on a change so other sessions cannot keep the old role.
+// The last active admin keeps their role, so that the login still succeeds;
+// the refused change is recorded as an audit event.
+func (s *Service) syncOIDCRole(tx *sql.Tx, userID uuid.UUID, role string, client ClientInfo) error {
+	if role == "" {
+		role = s.config.OIDC.DefaultRole
+	}
+	if role == "" {
+		return ErrOIDCAccessDenied
+	}
+
+	var current string
+	if err := tx.QueryRow("SELECT role FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&current); err != nil {
+		return err
+	}
+	if current == role {
+		return nil
+	}
+	if current == "admin" {
+		err := ensureOtherActiveAdmin(tx, userID)
+		if errors.Is(err, ErrLastAdmin) {
+			return s.audit.RecordWith(tx, &models.AuditEventCreate{
+				ActorUserID: &userID,
+				Action:      "user.role_change_refused",
+				TargetType:  "user",
+				TargetID:    userID.String(),
+				Details:     map[string]interface{}{"from": current, "to": role, "source": "sso", "reason": err.Error()},
+				IPAddress:   client.IPAddress,
+			})
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE users SET role = $1, updated_at = NOW() WHERE id = $2", role, userID); err != nil {
+		return err
+	}
+	if err := revokeUserTokens(tx, userID.String()); err != nil {
+		return err
+	}
+
+	return s.audit.RecordWith(tx, &models.AuditEventCreate{
+		ActorUserID: &userID,
+		Action:      "user.role_changed",
+		TargetType:  "user",
+		TargetID:    userID.String(),
+		Details:     map[string]interface{}{"from": current, "to": role, "source": "sso"},
+		IPAddress:   client.IPAddress,
+	})
+}
+
+var usernameInvalidChars = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// uniqueUsername derives a free username from the preferred username or the
+// email's local part, adding a numeric suffix when it is taken
+func uniqueUsername(tx *sql.Tx, preferred, email string) (string, error) {
+	base := preferred
+	if base == "" {
+		base, _, _ = strings.Cut(email, "@")
+	}
+	base = usernameInvalidChars.ReplaceAllString(strings.ToLower(base), "")
+	if len(base) < 3 {
+		base = "user"
+	}
+	if len(base) > 24 {
+		base = base[:24]
+	}
+
+	candidate := base
+	for i := 2; i < 1000; i++ {
+		var taken bool
+		if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM users WHERE username = $1)", candidate).Scan(&taken); err != nil {
+			return "", err
+		}
+		if !taken {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s%d", base, i)
+	}
+	return "", errors.New("could not find a free username")
+}
@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// maxPasswordLength is bcrypt's input limit; longer passwords would be silently truncated
+const maxPasswordLength = 72
+
+// PasswordPolicyError explains why a password was rejected
+type PasswordPolicyError struct {
+	Reason string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "password does not meet the policy: " + e.Reason
+}
+
+// PasswordPolicy validates new passwords
+type PasswordPolicy struct {
+	MinLength int
+	// breached holds upper-case SHA-1 digests of known breached passwords
+	breached map[string]struct{}
+}
+
+// NewPasswordPolicy creates a policy. breachedFile is optional; when set it is
+// read once, one entry per line, either as a plain password or as a SHA-1 hex
+// digest optionally followed by ":count" (the Have I Been Pwned export format).
+func NewPasswordPolicy(minLength int, breachedFile string) (*PasswordPolicy, error) {
+	policy := &PasswordPolicy{MinLength: minLength, breached: map[string]struct{}{}}
+	if breachedFile == "" {
+		return policy, nil
+	}
+
+	f, err := os.Open(breachedFile)
+	if err != nil {
+		return nil, fmt.Errorf("open breached password list: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if digest, _, _ := strings.Cut(line, ":"); isSHA1Hex(digest) {
+			policy.breached[strings.ToUpper(digest)] = struct{}{}
+			continue
+		}
+		policy.breached[sha1Hex(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read breached password list: %w", err)
+	}
+
+	return policy, nil
+}
+
+// Validate returns a PasswordPolicyError if password is too short or long,
+// matches the user's own username or email, or appears in the breached list
+func (p *PasswordPolicy) Validate(password, username, email string) error {
+	if len([]rune(password)) < p.MinLength {
+		return &PasswordPolicyError{Reason: fmt.Sprintf("must be at least %d characters", p.MinLength)}
+	}
+	if len(password) > maxPasswordLength {
+		return &PasswordPolicyError{Reason: fmt.Sprintf("must be at most %d bytes", maxPasswordLength)}
+	}
+
+	lower := strings.ToLower(password)
+	if (username != "" && lower == strings.ToLower(username)) || (email != "" && lower == strings.ToLower(email)) {
+		return &PasswordPolicyError{Reason: "must not match your username or email"}
+	}
+
+	if _, found := p.breached[sha1Hex(password)]; found {
+		return &PasswordPolicyError{Reason: "appears in a list of breached passwords"}
+	}
+
+	return nil
+}
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func isSHA1Hex(s string) bool {
+	if len(s) != 2*sha1.Size {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
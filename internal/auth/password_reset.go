@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"employee-management/internal/mail"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrInvalidResetToken is returned when a reset token is unknown, expired or already used
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// RequestPasswordReset issues a single-use reset token for the user with the
+// given email and mails it to them. Unknown or inactive accounts are ignored so
+// that callers cannot probe which emails are registered.
+func (s *Service) RequestPasswordReset(email string) error {
+	var userID string
+	var isActive bool
+	err := s.db.QueryRow("SELECT id, is_active FROM users WHERE email = $1", email).Scan(&userID, &isActive)
+	if err == sql.ErrNoRows || (err == nil && !isActive) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(
+		"INSERT INTO password_reset_tokens (user_id, token_hash, expires_at) VALUES ($1, $2, $3)",
+		userID, hashToken(token), time.Now().Add(s.config.PasswordResetTTL))
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", strings.TrimRight(s.config.AppURL, "/"), url.QueryEscape(token))
+	return s.mailer.Send(mail.Message{
+		To:      email,
+		Subject: "Password reset request",
+		Body: fmt.Sprintf("A password reset was requested for your account.\n\n"+
+			"Use the link below to choose a new password. It expires in %s and can only be used once.\n\n%s\n\n"+
+			"If you did not request this, you can ignore this email.", s.config.PasswordResetTTL, link),
+	})
+}
+
+// ResetPassword verifies a reset token, sets the new password and
+// invalidates the user's existing sessions and outstanding reset tokens
+func (s *Service) ResetPassword(token, newPassword string) error {
+	if err := s.config.PasswordPolicy.Validate(newPassword, "", ""); err != nil {
+		return err
+	}
+
+	hashedPassword, err := s.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var tokenID, userID string
+	err = tx.QueryRow(
+		`SELECT id, user_id FROM password_reset_tokens
+		 WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		 FOR UPDATE`,
+		hashToken(token)).Scan(&tokenID, &userID)
+	if err == sql.ErrNoRows {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(
+		"UPDATE users SET password = $1, must_change_password = false, password_changed_at = NOW(), updated_at = NOW() WHERE id = $2",
+		hashedPassword, userID); err != nil {
+		return err
+	}
+
+	if err := revokeUserTokens(tx, userID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(
+		"UPDATE password_reset_tokens SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL",
+		userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// generateOpaqueToken returns a random URL-safe token
+func generateOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hashToken returns the SHA-256 hex digest stored in place of an opaque token
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -2,34 +2,139 @@ package auth
 
 import (
 	"database/sql"
+	"employee-management/internal/audit"
 	"employee-management/internal/database"
+	"employee-management/internal/mail"
 	"employee-management/internal/models"
 	"errors"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	// accessTokenAudience is the aud claim of access tokens. Services that
+	// verify tokens against the published keys must require it.
+	accessTokenAudience = "employee-management-api"
+	// challengeTokenAudience is the aud claim of the tokens that carry a login
+	// from the password step to the next one, so they are never taken for
+	// access tokens
+	challengeTokenAudience = "employee-management-login-challenge"
+)
+
+func init() {
+	// Issue iat with sub-second precision, so a token issued in the same
+	// second as, but after, a user's tokens_valid_after stays valid
+	jwt.TimePrecision = time.Microsecond
+}
+
 type Service struct {
 	db     *database.DB
-	secret []byte
+	keys   *KeySet
+	config Config
+	mailer mail.Sender
+	audit  *audit.Recorder
+}
+
+// Config holds the settings for the auth service
+type Config struct {
+	// Keys signs and verifies all tokens issued by the service
+	Keys *KeySet
+	// Issuer is set as the iss claim and required on every token
+	Issuer string
+	// AppURL is the public base URL used to build links in emails
+	AppURL string
+	// PasswordResetTTL is how long a password reset token stays valid
+	PasswordResetTTL time.Duration
+	// AccessTokenTTL is the lifetime of signed access tokens
+	AccessTokenTTL time.Duration
+	// RefreshTokenTTL is the lifetime of opaque refresh tokens
+	RefreshTokenTTL time.Duration
+	// MaxFailedLogins is the number of failed attempts after which a username is locked
+	MaxFailedLogins int
+	// LockoutDuration is the first lockout period; it doubles on every further failure
+	LockoutDuration time.Duration
+	// MaxFailedLoginsPerIP is the number of failed attempts after which a client IP is blocked
+	MaxFailedLoginsPerIP int
+	// FailureWindow is how long failed attempts are remembered
+	FailureWindow time.Duration
+	// AllowSelfRegistration enables the public register endpoint; new accounts
+	// otherwise come from invitations
+	AllowSelfRegistration bool
+	// InvitationTTL is how long an invitation stays valid unless the inviter overrides it
+	InvitationTTL time.Duration
+	// PasswordPolicy validates every new password
+	PasswordPolicy *PasswordPolicy
+	// OIDC enables single sign-on; nil disables it
+	OIDC *OIDCConfig
 }
 
 type Claims struct {
 	UserID   string `json:"user_id"`
 	Username string `json:"username"`
 	Role     string `json:"role"`
+	// Purpose is set on restricted tokens such as 2FA challenges; access tokens leave it empty
+	Purpose string `json:"purpose,omitempty"`
+	// Scopes is only set for API tokens and limits what they can access
+	Scopes []string `json:"scopes,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func NewService(db *database.DB, secret string) *Service {
+// LoginResult is the outcome of a login attempt. Exactly one of Tokens and
+// Challenge is set.
+type LoginResult struct {
+	Tokens    *TokenPair
+	User      *models.User
+	Challenge *Challenge
+}
+
+func NewService(db *database.DB, config Config, mailer mail.Sender, recorder *audit.Recorder) *Service {
+	if config.Issuer == "" {
+		config.Issuer = "employee-management"
+	}
+	if config.PasswordResetTTL == 0 {
+		config.PasswordResetTTL = time.Hour
+	}
+	if config.AccessTokenTTL == 0 {
+		config.AccessTokenTTL = 15 * time.Minute
+	}
+	if config.RefreshTokenTTL == 0 {
+		config.RefreshTokenTTL = 30 * 24 * time.Hour
+	}
+	if config.MaxFailedLogins == 0 {
+		config.MaxFailedLogins = 5
+	}
+	if config.LockoutDuration == 0 {
+		config.LockoutDuration = 15 * time.Minute
+	}
+	if config.MaxFailedLoginsPerIP == 0 {
+		config.MaxFailedLoginsPerIP = 20
+	}
+	if config.FailureWindow == 0 {
+		config.FailureWindow = 24 * time.Hour
+	}
+	if config.InvitationTTL == 0 {
+		config.InvitationTTL = 7 * 24 * time.Hour
+	}
+	if config.PasswordPolicy == nil {
+		config.PasswordPolicy = &PasswordPolicy{MinLength: 8}
+	}
 	return &Service{
 		db:     db,
-		secret: []byte(secret),
+		keys:   config.Keys,
+		config: config,
+		mailer: mailer,
+		audit:  recorder,
 	}
 }
 
+// JWKS returns the public keys that verify tokens issued by the service
+func (s *Service) JWKS() JWKS {
+	return s.keys.JWKS()
+}
+
 func (s *Service) HashPassword(password string) (string, error) {
 	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
@@ -45,7 +150,7 @@ func (s *Service) CheckPasswordHash(password, hash string) bool {
 
 func (s *Service) GenerateToken(user *models.User) (string, error) {
 
-	expirationTime := time.Now().Add(24 * time.Hour)
+	expirationTime := time.Now().Add(s.config.AccessTokenTTL)
 
 	claims := &Claims{
 		UserID:   user.ID.String(),
@@ -55,12 +160,13 @@ func (s *Service) GenerateToken(user *models.User) (string, error) {
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Subject:   user.ID.String(),
+			Issuer:    s.config.Issuer,
+			Audience:  jwt.ClaimStrings{accessTokenAudience},
+			ID:        uuid.New().String(),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	tokenString, err := token.SignedString(s.secret)
+	tokenString, err := s.keys.Sign(claims)
 	if err != nil {
 		return "", err
 	}
@@ -70,9 +176,7 @@ func (s *Service) GenerateToken(user *models.User) (string, error) {
 
 func (s *Service) ValidateToken(tokenString string) (*Claims, error) {
 
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return s.secret, nil
-	})
+	token, err := s.keys.Parse(tokenString, &Claims{}, jwt.WithIssuer(s.config.Issuer), jwt.WithAudience(accessTokenAudience))
 
 	if err != nil {
 		return nil, err
@@ -83,23 +187,35 @@ func (s *Service) ValidateToken(tokenString string) (*Claims, error) {
 	}
 
 	claims, ok := token.Claims.(*Claims)
-	if !ok {
+	if !ok || claims.Purpose != "" || claims.Scopes != nil {
 		return nil, errors.New("invalid token claims")
 	}
 
 	return claims, nil
 }
 
-func (s *Service) Login(username, password string) (string, *models.User, error) {
+func (s *Service) Login(username, password string, client ClientInfo) (*LoginResult, error) {
+
+	if err := s.checkThrottle(username, client.IPAddress); err != nil {
+		return nil, err
+	}
 
 	var user models.User
 	var lastLogin sql.NullTime
 	err := s.db.QueryRow(
-		"SELECT id, username, email, password, role, is_active, last_login, created_at, updated_at FROM users WHERE username = $1",
-		username).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.Role, &user.IsActive, &lastLogin, &user.CreatedAt, &user.UpdatedAt)
-
+		"SELECT id, username, email, password, role, is_active, last_login, created_at, updated_at, two_factor_enabled, must_change_password FROM users WHERE username = $1",
+		username).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.Role, &user.IsActive, &lastLogin, &user.CreatedAt, &user.UpdatedAt, &user.TwoFactorEnabled, &user.MustChangePassword)
+
+	if err == sql.ErrNoRows {
+		// Spend the same time as a real password check so unknown usernames cannot be told apart
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		if err := s.recordLoginFailure(username, nil, client); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("invalid username or password")
+	}
 	if err != nil {
-		return "", nil, errors.New("invalid username or password")
+		return nil, err
 	}
 
 	// Convert sql.NullTime to *time.Time
@@ -109,16 +225,51 @@ func (s *Service) Login(username, password string) (string, *models.User, error)
 		user.LastLogin = nil
 	}
 
+	if !s.CheckPasswordHash(password, user.Password) {
+		if err := s.recordLoginFailure(username, &user, client); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("invalid username or password")
+	}
+
 	if !user.IsActive {
-		return "", nil, errors.New("user account is deactivated")
+		return nil, errors.New("user account is deactivated")
 	}
 
-	if !s.CheckPasswordHash(password, user.Password) {
-		return "", nil, errors.New("invalid username or password")
+	user.Password = ""
+
+	// A correct password alone is not enough when a second factor is enabled or mandatory
+	challenge, err := s.twoFactorChallenge(&user)
+	if err != nil {
+		return nil, err
+	}
+	if challenge != nil {
+		return &LoginResult{User: &user, Challenge: challenge}, nil
+	}
+
+	return s.completeLogin(&user, client)
+}
+
+// completeLogin records the login and issues a new session for the user, unless
+// they must first replace their password. It is only called once every
+// factor has been checked, so that is when the failure counters are cleared;
+// clearing them after the password alone would let second-factor guesses go
+// on without a lockout.
+func (s *Service) completeLogin(user *models.User, client ClientInfo) (*LoginResult, error) {
+	if err := s.recordLoginSuccess(user); err != nil {
+		return nil, err
+	}
+
+	if user.MustChangePassword {
+		challenge, err := s.passwordChangeChallenge(user)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{User: user, Challenge: challenge}, nil
 	}
 
 	now := time.Now()
-	_, err = s.db.Exec(
+	_, err := s.db.Exec(
 		"UPDATE users SET last_login = $1 WHERE id = $2",
 		now, user.ID)
 
@@ -130,17 +281,23 @@ func (s *Service) Login(username, password string) (string, *models.User, error)
 	// Update the user's LastLogin field with the current time
 	user.LastLogin = &now
 
-	token, err := s.GenerateToken(&user)
+	tokens, err := s.issueTokenPair(user, uuid.New(), client)
 	if err != nil {
-		return "", nil, err
+		return nil, err
 	}
 
-	user.Password = ""
-
-	return token, &user, nil
+	return &LoginResult{Tokens: tokens, User: user}, nil
 }
 
+// Register creates an account through public self-registration. Such accounts
+// always get the employee role; elevated roles are only granted by invitation.
 func (s *Service) Register(userReg *models.UserRegister) (*models.User, error) {
+	if !s.config.AllowSelfRegistration {
+		return nil, ErrSelfRegistrationDisabled
+	}
+	if err := s.config.PasswordPolicy.Validate(userReg.Password, userReg.Username, userReg.Email); err != nil {
+		return nil, err
+	}
 
 	hashedPassword, err := s.HashPassword(userReg.Password)
 	if err != nil {
@@ -151,7 +308,7 @@ func (s *Service) Register(userReg *models.UserRegister) (*models.User, error) {
 	var lastLogin sql.NullTime
 	err = s.db.QueryRow(
 		"INSERT INTO users (username, email, password, role, is_active) VALUES ($1, $2, $3, $4, $5) RETURNING id, username, email, role, is_active, last_login, created_at, updated_at",
-		userReg.Username, userReg.Email, hashedPassword, userReg.Role, true).Scan(&user.ID, &user.Username, &user.Email, &user.Role, &user.IsActive, &lastLogin, &user.CreatedAt, &user.UpdatedAt)
+		userReg.Username, userReg.Email, hashedPassword, "employee", true).Scan(&user.ID, &user.Username, &user.Email, &user.Role, &user.IsActive, &lastLogin, &user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
 		return nil, err
@@ -169,10 +326,10 @@ func (s *Service) Register(userReg *models.UserRegister) (*models.User, error) {
 
 func (s *Service) GetUserByID(id string) (*models.User, error) {
 	var user models.User
-	var lastLogin sql.NullTime
+	var lastLogin, lockedUntil sql.NullTime
 	err := s.db.QueryRow(
-		"SELECT id, username, email, role, is_active, last_login, created_at, updated_at FROM users WHERE id = $1",
-		id).Scan(&user.ID, &user.Username, &user.Email, &user.Role, &user.IsActive, &lastLogin, &user.CreatedAt, &user.UpdatedAt)
+		"SELECT id, username, email, role, is_active, last_login, created_at, updated_at, two_factor_enabled, must_change_password, locked_until FROM users WHERE id = $1",
+		id).Scan(&user.ID, &user.Username, &user.Email, &user.Role, &user.IsActive, &lastLogin, &user.CreatedAt, &user.UpdatedAt, &user.TwoFactorEnabled, &user.MustChangePassword, &lockedUntil)
 
 	if err != nil {
 		return nil, err
@@ -184,6 +341,9 @@ func (s *Service) GetUserByID(id string) (*models.User, error) {
 	} else {
 		user.LastLogin = nil
 	}
+	if lockedUntil.Valid {
+		user.LockedUntil = &lockedUntil.Time
+	}
 
 	return &user, nil
 }
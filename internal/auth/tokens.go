@@ -0,0 +1,291 @@
+package auth
+
+import (
+	"database/sql"
+	"employee-management/internal/models"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalidRefreshToken is returned when a refresh token is unknown, expired or revoked
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	// ErrRefreshTokenReused is returned when an already rotated refresh token is presented again
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+	// ErrPasswordChangeRequired is returned when refreshing the session of a
+	// user who must first choose a new password by logging in again
+	ErrPasswordChangeRequired = errors.New("password change required, log in again")
+)
+
+// ClientInfo identifies the device a session belongs to
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
+}
+
+// TokenPair is a short-lived access token and its long-lived refresh token
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// Session is a refresh token family as shown to its owner
+type Session struct {
+	ID         uuid.UUID  `json:"id"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+}
+
+// issueTokenPair signs an access token and stores a new refresh token in the given family
+func (s *Service) issueTokenPair(user *models.User, familyID uuid.UUID, client ClientInfo) (*TokenPair, error) {
+	refreshToken, _, err := s.createRefreshToken(s.db, user.ID, familyID, client)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := s.GenerateToken(user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(s.config.AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx
+type queryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func (s *Service) createRefreshToken(q queryer, userID, familyID uuid.UUID, client ClientInfo) (string, uuid.UUID, error) {
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return "", uuid.Nil, err
+	}
+
+	var id uuid.UUID
+	err = q.QueryRow(
+		`INSERT INTO refresh_tokens (user_id, family_id, token_hash, user_agent, ip_address, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		userID, familyID, hashToken(token), client.UserAgent, client.IPAddress, time.Now().Add(s.config.RefreshTokenTTL),
+	).Scan(&id)
+	if err != nil {
+		return "", uuid.Nil, err
+	}
+
+	return token, id, nil
+}
+
+// Refresh rotates a refresh token: the presented token is consumed and a new
+// access/refresh pair in the same family is returned. Presenting a token that
+// was already rotated revokes the whole family, since it means the token leaked.
+func (s *Service) Refresh(refreshToken string, client ClientInfo) (*TokenPair, *models.User, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	var tokenID, userID, familyID uuid.UUID
+	var expiresAt time.Time
+	var revokedAt sql.NullTime
+	err = tx.QueryRow(
+		`SELECT id, user_id, family_id, expires_at, revoked_at FROM refresh_tokens
+		 WHERE token_hash = $1 FOR UPDATE`,
+		hashToken(refreshToken)).Scan(&tokenID, &userID, &familyID, &expiresAt, &revokedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if revokedAt.Valid {
+		if _, err := tx.Exec(
+			"UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL",
+			familyID); err != nil {
+			return nil, nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, ErrRefreshTokenReused
+	}
+
+	if time.Now().After(expiresAt) {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.GetUserByID(userID.String())
+	if err != nil || !user.IsActive {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	if user.MustChangePassword {
+		return nil, nil, ErrPasswordChangeRequired
+	}
+
+	newToken, newID, err := s.createRefreshToken(tx, userID, familyID, client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if _, err := tx.Exec(
+		"UPDATE refresh_tokens SET revoked_at = NOW(), replaced_by = $1, last_used_at = NOW() WHERE id = $2",
+		newID, tokenID); err != nil {
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+
+	accessToken, err := s.GenerateToken(user)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: newToken,
+		ExpiresIn:    int64(s.config.AccessTokenTTL.Seconds()),
+	}, user, nil
+}
+
+// Logout denylists the given access token and revokes the refresh token family
+// it was used with, if one is supplied
+func (s *Service) Logout(claims *Claims, refreshToken string) error {
+	if _, err := s.db.Exec(
+		`INSERT INTO revoked_access_tokens (jti, user_id, expires_at) VALUES ($1, $2, $3)
+		 ON CONFLICT (jti) DO NOTHING`,
+		claims.ID, claims.UserID, claims.ExpiresAt.Time); err != nil {
+		return err
+	}
+
+	if refreshToken != "" {
+		if _, err := s.db.Exec(
+			`UPDATE refresh_tokens SET revoked_at = NOW()
+			 WHERE revoked_at IS NULL AND family_id = (
+			     SELECT family_id FROM refresh_tokens WHERE token_hash = $1 AND user_id = $2)`,
+			hashToken(refreshToken), claims.UserID); err != nil {
+			return err
+		}
+	}
+
+	// Denylist entries are only needed until the token would have expired anyway
+	_, err := s.db.Exec("DELETE FROM revoked_access_tokens WHERE expires_at < NOW()")
+	return err
+}
+
+// IsTokenRevoked reports whether an otherwise valid access token has been
+// denylisted or issued before the user's sessions were invalidated
+func (s *Service) IsTokenRevoked(claims *Claims) (bool, error) {
+	var validAfter sql.NullTime
+	var denylisted bool
+	err := s.db.QueryRow(
+		`SELECT tokens_valid_after, EXISTS (SELECT 1 FROM revoked_access_tokens WHERE jti = $2)
+		 FROM users WHERE id = $1`,
+		claims.UserID, claims.ID).Scan(&validAfter, &denylisted)
+	if err == sql.ErrNoRows {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if denylisted {
+		return true, nil
+	}
+
+	if validAfter.Valid && (claims.IssuedAt == nil || !claims.IssuedAt.After(validAfter.Time)) {
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// RevokeUserTokens invalidates every access, refresh and API token issued to the user so far
+func (s *Service) RevokeUserTokens(userID string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := revokeUserTokens(tx, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func revokeUserTokens(tx *sql.Tx, userID string) error {
+	if _, err := tx.Exec("UPDATE users SET tokens_valid_after = NOW() WHERE id = $1", userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", userID); err != nil {
+		return err
+	}
+	_, err := tx.Exec("UPDATE api_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", userID)
+	return err
+}
+
+// ListSessions returns the user's active refresh token families
+func (s *Service) ListSessions(userID string) ([]Session, error) {
+	rows, err := s.db.Query(
+		`SELECT family_id, user_agent, ip_address, last_used_at, created_at, expires_at
+		 FROM refresh_tokens
+		 WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		 ORDER BY created_at DESC`,
+		userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var session Session
+		var userAgent, ipAddress sql.NullString
+		var lastUsedAt sql.NullTime
+		if err := rows.Scan(&session.ID, &userAgent, &ipAddress, &lastUsedAt, &session.CreatedAt, &session.ExpiresAt); err != nil {
+			return nil, err
+		}
+		session.UserAgent = userAgent.String
+		session.IPAddress = ipAddress.String
+		if lastUsedAt.Valid {
+			session.LastUsedAt = &lastUsedAt.Time
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// RevokeSession revokes one of the user's refresh token families
+func (s *Service) RevokeSession(userID string, familyID uuid.UUID) error {
+	result, err := s.db.Exec(
+		"UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND family_id = $2 AND revoked_at IS NULL",
+		userID, familyID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("session not found")
+	}
+
+	return nil
+}
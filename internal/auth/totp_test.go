@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the shared secret of the RFC 6238 SHA1 test vectors
+var rfcSecret = totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCodeMatchesRFC6238(t *testing.T) {
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		if got := totpCode([]byte("12345678901234567890"), tt.unix/totpPeriod); got != tt.code {
+			t.Errorf("totpCode at %d = %s, want %s", tt.unix, got, tt.code)
+		}
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	current := now.Unix() / totpPeriod
+	key := []byte("12345678901234567890")
+
+	tests := []struct {
+		name     string
+		code     string
+		lastStep int64
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", "005924", 0, current, true},
+		{"spaces are ignored", "005 924", 0, current, true},
+		{"previous step within skew", totpCode(key, current-1), 0, current - 1, true},
+		{"next step within skew", totpCode(key, current+1), 0, current + 1, true},
+		{"outside skew", totpCode(key, current-2), 0, 0, false},
+		{"replayed step", "005924", current, 0, false},
+		{"wrong code", "123456", 0, 0, false},
+		{"wrong length", "05924", 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := verifyTOTP(rfcSecret, tt.code, now, tt.lastStep)
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("verifyTOTP(%q) = %d, %v, want %d, %v", tt.code, step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestVerifyTOTPAcceptsLowercaseSecret(t *testing.T) {
+	if _, ok := verifyTOTP(strings.ToLower(rfcSecret), "005924", time.Unix(1234567890, 0), 0); !ok {
+		t.Error("verifyTOTP rejected a lowercase secret")
+	}
+}
+
+func TestVerifyTOTPRejectsInvalidSecret(t *testing.T) {
+	if _, ok := verifyTOTP("not base32!", "005924", time.Unix(1234567890, 0), 0); ok {
+		t.Error("verifyTOTP accepted an invalid secret")
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		t.Fatalf("secret %q is not base32: %v", secret, err)
+	}
+	if len(key) != 20 {
+		t.Errorf("secret has %d bytes, want 20", len(key))
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := totpURI("Employee Management", "jane@example.com", "ABC")
+	want := "otpauth://totp/Employee%20Management:jane@example.com?algorithm=SHA1&digits=6&issuer=Employee+Management&period=30&secret=ABC"
+	if uri != want {
+		t.Errorf("totpURI = %s, want %s", uri, want)
+	}
+}
@@ -0,0 +1,421 @@
+package auth
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"employee-management/internal/models"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	// challengeTTL is how long a 2FA challenge token stays valid after the password step
+	challengeTTL = 5 * time.Minute
+	// recoveryCodeCount is the number of recovery codes issued on enrollment
+	recoveryCodeCount = 10
+
+	purposeTwoFactor      = "2fa"
+	purposeTwoFactorSetup = "2fa_setup"
+	purposePasswordChange = "password_change"
+
+	totpIssuer = "Employee Management"
+)
+
+var (
+	ErrInvalidChallenge        = errors.New("invalid or expired challenge token")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorNotStarted     = errors.New("two-factor enrollment has not been started")
+	ErrTwoFactorMandatory      = errors.New("two-factor authentication is mandatory for your role")
+)
+
+// Challenge is returned by Login instead of tokens when a second factor or a
+// new password is needed
+type Challenge struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	Token             string `json:"challenge_token"`
+	// SetupRequired means the user must enroll before they can log in
+	SetupRequired bool `json:"setup_required"`
+	// PasswordChangeRequired means the user must choose a new password before they can log in
+	PasswordChangeRequired bool  `json:"password_change_required,omitempty"`
+	ExpiresIn              int64 `json:"expires_in"`
+}
+
+// Enrollment holds the secret shown to the user while setting up 2FA
+type Enrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// twoFactorChallenge returns a challenge if the user must present or set up a
+// second factor, or nil if the password is sufficient
+func (s *Service) twoFactorChallenge(user *models.User) (*Challenge, error) {
+	purpose := purposeTwoFactor
+	if !user.TwoFactorEnabled {
+		required, err := s.twoFactorRequired(user.Role)
+		if err != nil {
+			return nil, err
+		}
+		if !required {
+			return nil, nil
+		}
+		purpose = purposeTwoFactorSetup
+	}
+
+	token, err := s.generateChallengeToken(user, purpose)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Challenge{
+		TwoFactorRequired: true,
+		Token:             token,
+		SetupRequired:     purpose == purposeTwoFactorSetup,
+		ExpiresIn:         int64(challengeTTL.Seconds()),
+	}, nil
+}
+
+func (s *Service) twoFactorRequired(role string) (bool, error) {
+	var required bool
+	err := s.db.QueryRow("SELECT required FROM two_factor_policies WHERE role = $1", role).Scan(&required)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return required, err
+}
+
+func (s *Service) generateChallengeToken(user *models.User, purpose string) (string, error) {
+	claims := &Claims{
+		Purpose: purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(challengeTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Subject:   user.ID.String(),
+			Issuer:    s.config.Issuer,
+			Audience:  jwt.ClaimStrings{challengeTokenAudience},
+			ID:        uuid.New().String(),
+		},
+	}
+	return s.keys.Sign(claims)
+}
+
+// parseChallengeToken validates a challenge token and returns the user it was issued to
+func (s *Service) parseChallengeToken(tokenString, purpose string) (*models.User, error) {
+	token, err := s.keys.Parse(tokenString, &Claims{}, jwt.WithIssuer(s.config.Issuer), jwt.WithAudience(challengeTokenAudience))
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidChallenge
+	}
+
+	claims, ok := token.Claims.(*Claims)
+	if !ok || claims.Purpose != purpose {
+		return nil, ErrInvalidChallenge
+	}
+
+	user, err := s.GetUserByID(claims.Subject)
+	if err != nil || !user.IsActive {
+		return nil, ErrInvalidChallenge
+	}
+
+	return user, nil
+}
+
+// VerifyTwoFactor completes a login that was answered with a challenge. Either
+// a TOTP code or an unused recovery code must be supplied.
+func (s *Service) VerifyTwoFactor(challengeToken, code, recoveryCode string, client ClientInfo) (*LoginResult, error) {
+	user, err := s.parseChallengeToken(challengeToken, purposeTwoFactor)
+	if err != nil {
+		return nil, err
+	}
+
+	// Second-factor guesses count towards the same lockout as password guesses
+	if err := s.checkThrottle(user.Username, client.IPAddress); err != nil {
+		return nil, err
+	}
+
+	if err := s.withTx(func(tx *sql.Tx) error {
+		return verifySecondFactor(tx, user.ID, code, recoveryCode)
+	}); err != nil {
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			if err := s.recordLoginFailure(user.Username, user, client); err != nil {
+				return nil, err
+			}
+		}
+		return nil, err
+	}
+
+	return s.completeLogin(user, client)
+}
+
+// BeginEnrollment generates a new pending TOTP secret for the user. It only
+// takes effect once confirmed with a valid code.
+func (s *Service) BeginEnrollment(userID uuid.UUID) (*Enrollment, error) {
+	user, err := s.GetUserByID(userID.String())
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.db.Exec(
+		"UPDATE users SET two_factor_secret = $1, two_factor_last_step = 0 WHERE id = $2 AND two_factor_enabled = false",
+		secret, userID); err != nil {
+		return nil, err
+	}
+
+	return &Enrollment{
+		Secret: secret,
+		URI:    totpURI(totpIssuer, user.Username, secret),
+	}, nil
+}
+
+// ConfirmEnrollment enables 2FA once the user proves their authenticator works
+// and returns a fresh set of recovery codes
+func (s *Service) ConfirmEnrollment(userID uuid.UUID, code string) ([]string, error) {
+	var codes []string
+	err := s.withTx(func(tx *sql.Tx) error {
+		var secret sql.NullString
+		var enabled bool
+		var lastStep int64
+		err := tx.QueryRow(
+			"SELECT two_factor_secret, two_factor_enabled, two_factor_last_step FROM users WHERE id = $1 FOR UPDATE",
+			userID).Scan(&secret, &enabled, &lastStep)
+		if err != nil {
+			return err
+		}
+		if enabled {
+			return ErrTwoFactorAlreadyEnabled
+		}
+		if !secret.Valid {
+			return ErrTwoFactorNotStarted
+		}
+
+		step, ok := verifyTOTP(secret.String, code, time.Now(), lastStep)
+		if !ok {
+			return ErrInvalidTwoFactorCode
+		}
+
+		if _, err := tx.Exec(
+			"UPDATE users SET two_factor_enabled = true, two_factor_last_step = $1, updated_at = NOW() WHERE id = $2",
+			step, userID); err != nil {
+			return err
+		}
+
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// BeginEnrollmentWithChallenge starts enrollment for a user whose role requires
+// 2FA but who has not set it up yet
+func (s *Service) BeginEnrollmentWithChallenge(challengeToken string) (*Enrollment, error) {
+	user, err := s.parseChallengeToken(challengeToken, purposeTwoFactorSetup)
+	if err != nil {
+		return nil, err
+	}
+	return s.BeginEnrollment(user.ID)
+}
+
+// ConfirmEnrollmentWithChallenge confirms a mandatory enrollment and completes the login
+func (s *Service) ConfirmEnrollmentWithChallenge(challengeToken, code string, client ClientInfo) (*LoginResult, []string, error) {
+	user, err := s.parseChallengeToken(challengeToken, purposeTwoFactorSetup)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	codes, err := s.ConfirmEnrollment(user.ID, code)
+	if err != nil {
+		return nil, nil, err
+	}
+	user.TwoFactorEnabled = true
+
+	result, err := s.completeLogin(user, client)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, codes, nil
+}
+
+// DisableTwoFactor turns off 2FA after checking a current code. It is refused
+// when the user's role requires 2FA.
+func (s *Service) DisableTwoFactor(userID uuid.UUID, role, code, recoveryCode string) error {
+	required, err := s.twoFactorRequired(role)
+	if err != nil {
+		return err
+	}
+	if required {
+		return ErrTwoFactorMandatory
+	}
+
+	return s.withTx(func(tx *sql.Tx) error {
+		if err := verifySecondFactor(tx, userID, code, recoveryCode); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(
+			"UPDATE users SET two_factor_enabled = false, two_factor_secret = NULL, two_factor_last_step = 0, updated_at = NOW() WHERE id = $1",
+			userID); err != nil {
+			return err
+		}
+		_, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = $1", userID)
+		return err
+	})
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes after checking a current TOTP code
+func (s *Service) RegenerateRecoveryCodes(userID uuid.UUID, code string) ([]string, error) {
+	var codes []string
+	err := s.withTx(func(tx *sql.Tx) error {
+		if err := verifySecondFactor(tx, userID, code, ""); err != nil {
+			return err
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// ListTwoFactorPolicies returns the per-role 2FA requirements
+func (s *Service) ListTwoFactorPolicies() ([]models.TwoFactorPolicy, error) {
+	rows, err := s.db.Query("SELECT role, required, updated_at FROM two_factor_policies ORDER BY role")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var policies []models.TwoFactorPolicy
+	for rows.Next() {
+		var policy models.TwoFactorPolicy
+		if err := rows.Scan(&policy.Role, &policy.Required, &policy.UpdatedAt); err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	return policies, rows.Err()
+}
+
+// SetTwoFactorPolicy makes 2FA mandatory or optional for a role
+func (s *Service) SetTwoFactorPolicy(role string, required bool) (*models.TwoFactorPolicy, error) {
+	var policy models.TwoFactorPolicy
+	err := s.db.QueryRow(
+		`INSERT INTO two_factor_policies (role, required) VALUES ($1, $2)
+		 ON CONFLICT (role) DO UPDATE SET required = EXCLUDED.required, updated_at = NOW()
+		 RETURNING role, required, updated_at`,
+		role, required).Scan(&policy.Role, &policy.Required, &policy.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// verifySecondFactor checks a TOTP code, or failing that consumes a recovery code
+func verifySecondFactor(tx *sql.Tx, userID uuid.UUID, code, recoveryCode string) error {
+	if code != "" {
+		var secret sql.NullString
+		var enabled bool
+		var lastStep int64
+		err := tx.QueryRow(
+			"SELECT two_factor_secret, two_factor_enabled, two_factor_last_step FROM users WHERE id = $1 FOR UPDATE",
+			userID).Scan(&secret, &enabled, &lastStep)
+		if err != nil {
+			return err
+		}
+		if !enabled || !secret.Valid {
+			return ErrTwoFactorNotEnabled
+		}
+
+		step, ok := verifyTOTP(secret.String, code, time.Now(), lastStep)
+		if !ok {
+			return ErrInvalidTwoFactorCode
+		}
+
+		_, err = tx.Exec("UPDATE users SET two_factor_last_step = $1 WHERE id = $2", step, userID)
+		return err
+	}
+
+	if recoveryCode != "" {
+		result, err := tx.Exec(
+			"UPDATE recovery_codes SET used_at = NOW() WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL",
+			userID, hashToken(normalizeRecoveryCode(recoveryCode)))
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 1 {
+			return nil
+		}
+	}
+
+	return ErrInvalidTwoFactorCode
+}
+
+// replaceRecoveryCodes deletes the user's recovery codes and issues a new set
+func replaceRecoveryCodes(tx *sql.Tx, userID uuid.UUID) ([]string, error) {
+	if _, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = $1", userID); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(
+			"INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)",
+			userID, hashToken(normalizeRecoveryCode(code))); err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// generateRecoveryCode returns a random code formatted as xxxx-xxxx-xxxx
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	raw := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))[:12]
+	return raw[0:4] + "-" + raw[4:8] + "-" + raw[8:12], nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
+
+// withTx runs fn inside a transaction, committing only if it succeeds
+func (s *Service) withTx(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,410 @@
+package auth
+
+import (
+	"database/sql"
+	"employee-management/internal/models"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrLastAdmin is returned when a change would leave no active admin
+	ErrLastAdmin = errors.New("cannot remove the last active admin")
+	// ErrCannotModifySelf is returned when an admin tries to demote or deactivate themselves
+	ErrCannotModifySelf = errors.New("you cannot change your own role or status")
+	// ErrInvalidCurrentPassword is returned when a password change does not supply the current password
+	ErrInvalidCurrentPassword = errors.New("current password is incorrect")
+)
+
+// UserFilter narrows down and pages a list of users
+type UserFilter struct {
+	Role     string
+	IsActive *bool
+	// Search matches username or email, case-insensitively
+	Search   string
+	Page     int
+	PageSize int
+}
+
+// UserList is one page of users
+type UserList struct {
+	Users    []models.User `json:"users"`
+	Total    int           `json:"total"`
+	Page     int           `json:"page"`
+	PageSize int           `json:"page_size"`
+}
+
+// ListUsers returns a page of users matching the filter, ordered by username
+func (s *Service) ListUsers(filter UserFilter) (*UserList, error) {
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.PageSize < 1 || filter.PageSize > 100 {
+		filter.PageSize = 20
+	}
+
+	var conditions []string
+	var args []interface{}
+	if filter.Role != "" {
+		args = append(args, filter.Role)
+		conditions = append(conditions, fmt.Sprintf("role = $%d", len(args)))
+	}
+	if filter.IsActive != nil {
+		args = append(args, *filter.IsActive)
+		conditions = append(conditions, fmt.Sprintf("is_active = $%d", len(args)))
+	}
+	if filter.Search != "" {
+		args = append(args, "%"+strings.ToLower(filter.Search)+"%")
+		conditions = append(conditions, fmt.Sprintf("(LOWER(username) LIKE $%d OR LOWER(email) LIKE $%d)", len(args), len(args)))
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	list := &UserList{Users: []models.User{}, Page: filter.Page, PageSize: filter.PageSize}
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM users"+where, args...).Scan(&list.Total); err != nil {
+		return nil, err
+	}
+
+	args = append(args, filter.PageSize, (filter.Page-1)*filter.PageSize)
+	rows, err := s.db.Query(fmt.Sprintf(
+		`SELECT id, username, email, role, is_active, last_login, created_at, updated_at,
+		        two_factor_enabled, must_change_password, locked_until
+		 FROM users%s ORDER BY username LIMIT $%d OFFSET $%d`, where, len(args)-1, len(args)), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user models.User
+		var lastLogin, lockedUntil sql.NullTime
+		if err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.Role, &user.IsActive, &lastLogin,
+			&user.CreatedAt, &user.UpdatedAt, &user.TwoFactorEnabled, &user.MustChangePassword, &lockedUntil); err != nil {
+			return nil, err
+		}
+		if lastLogin.Valid {
+			user.LastLogin = &lastLogin.Time
+		}
+		if lockedUntil.Valid {
+			user.LockedUntil = &lockedUntil.Time
+		}
+		list.Users = append(list.Users, user)
+	}
+	return list, rows.Err()
+}
+
+// SetUserRole changes a user's role. Tokens already issued carry the old role,
+// so all of the user's sessions are invalidated.
+func (s *Service) SetUserRole(userID uuid.UUID, role string, actorID uuid.UUID, ip string) error {
+	if userID == actorID {
+		return ErrCannotModifySelf
+	}
+	if !IsKnownRole(role) {
+		return fmt.Errorf("unknown role %q", role)
+	}
+
+	return s.withTx(func(tx *sql.Tx) error {
+		var oldRole string
+		var isActive bool
+		err := tx.QueryRow("SELECT role, is_active FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&oldRole, &isActive)
+		if err == sql.ErrNoRows {
+			return ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if oldRole == role {
+			return nil
+		}
+		if oldRole == "admin" && isActive {
+			if err := ensureOtherActiveAdmin(tx, userID); err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.Exec("UPDATE users SET role = $1, updated_at = NOW() WHERE id = $2", role, userID); err != nil {
+			return err
+		}
+		if err := revokeUserTokens(tx, userID.String()); err != nil {
+			return err
+		}
+
+		return s.audit.RecordWith(tx, &models.AuditEventCreate{
+			ActorUserID: &actorID,
+			Action:      "user.role_changed",
+			TargetType:  "user",
+			TargetID:    userID.String(),
+			Details:     map[string]interface{}{"from": oldRole, "to": role},
+			IPAddress:   ip,
+		})
+	})
+}
+
+// DeactivateUserWith deactivates a user and revokes their sessions as part
+// of a larger transaction, such as an employee's termination. It reports
+// whether the user was active.
+func DeactivateUserWith(tx *sql.Tx, userID uuid.UUID) (bool, error) {
+	var role string
+	var active bool
+	err := tx.QueryRow("SELECT role, is_active FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&role, &active)
+	if err == sql.ErrNoRows {
+		return false, ErrUserNotFound
+	}
+	if err != nil || !active {
+		return false, err
+	}
+	if role == "admin" {
+		if err := ensureOtherActiveAdmin(tx, userID); err != nil {
+			return false, err
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE users SET is_active = false, updated_at = NOW() WHERE id = $1", userID); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec("UPDATE api_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", userID); err != nil {
+		return false, err
+	}
+	return true, revokeUserTokens(tx, userID.String())
+}
+
+// AnonymizeUserWith deactivates a user, erases their username and email and
+// makes the account unusable, as part of a larger transaction such as the
+// anonymization of a deleted employee. The account itself is kept for the
+// records that refer to it.
+func AnonymizeUserWith(tx *sql.Tx, userID uuid.UUID) error {
+	if _, err := DeactivateUserWith(tx, userID); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`
+		UPDATE users
+		SET username = 'deleted-' || id, email = 'deleted-' || id || '@invalid', password = '',
+		    two_factor_secret = NULL, two_factor_enabled = false, updated_at = NOW()
+		WHERE id = $1`, userID)
+	if err != nil {
+		return err
+	}
+	for _, table := range []string{"user_identities", "recovery_codes", "password_reset_tokens"} {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE user_id = $1", userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetUserActive activates or deactivates a user. Deactivation also ends all
+// of the user's sessions.
+func (s *Service) SetUserActive(userID uuid.UUID, active bool, actorID uuid.UUID, ip string) error {
+	if userID == actorID {
+		return ErrCannotModifySelf
+	}
+
+	return s.withTx(func(tx *sql.Tx) error {
+		var role string
+		var wasActive bool
+		err := tx.QueryRow("SELECT role, is_active FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&role, &wasActive)
+		if err == sql.ErrNoRows {
+			return ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if wasActive == active {
+			return nil
+		}
+		if !active && role == "admin" {
+			if err := ensureOtherActiveAdmin(tx, userID); err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.Exec("UPDATE users SET is_active = $1, updated_at = NOW() WHERE id = $2", active, userID); err != nil {
+			return err
+		}
+
+		action := "user.activated"
+		if !active {
+			action = "user.deactivated"
+			if err := revokeUserTokens(tx, userID.String()); err != nil {
+				return err
+			}
+		}
+
+		return s.audit.RecordWith(tx, &models.AuditEventCreate{
+			ActorUserID: &actorID,
+			Action:      action,
+			TargetType:  "user",
+			TargetID:    userID.String(),
+			IPAddress:   ip,
+		})
+	})
+}
+
+// SetUserPassword lets an admin set another user's password and/or require
+// them to choose a new one at their next login. Setting a password or
+// requiring a change ends all of the user's sessions.
+func (s *Service) SetUserPassword(userID uuid.UUID, set *models.UserPasswordSet, actorID uuid.UUID, ip string) error {
+	user, err := s.GetUserByID(userID.String())
+	if err == sql.ErrNoRows {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	// A password chosen by someone else should be replaced by default
+	mustChange := set.Password != ""
+	if set.MustChangePassword != nil {
+		mustChange = *set.MustChangePassword
+	}
+
+	var hashedPassword string
+	if set.Password != "" {
+		if err := s.config.PasswordPolicy.Validate(set.Password, user.Username, user.Email); err != nil {
+			return err
+		}
+		if hashedPassword, err = s.HashPassword(set.Password); err != nil {
+			return err
+		}
+	}
+
+	return s.withTx(func(tx *sql.Tx) error {
+		if hashedPassword == "" {
+			if _, err := tx.Exec(
+				"UPDATE users SET must_change_password = $1, updated_at = NOW() WHERE id = $2",
+				mustChange, userID); err != nil {
+				return err
+			}
+		} else {
+			if _, err := tx.Exec(
+				"UPDATE users SET password = $1, must_change_password = $2, password_changed_at = NOW(), updated_at = NOW() WHERE id = $3",
+				hashedPassword, mustChange, userID); err != nil {
+				return err
+			}
+		}
+		if hashedPassword != "" || mustChange {
+			if err := revokeUserTokens(tx, userID.String()); err != nil {
+				return err
+			}
+		}
+
+		return s.audit.RecordWith(tx, &models.AuditEventCreate{
+			ActorUserID: &actorID,
+			Action:      "user.password_set",
+			TargetType:  "user",
+			TargetID:    userID.String(),
+			Details: map[string]interface{}{
+				"password_changed":     hashedPassword != "",
+				"must_change_password": mustChange,
+			},
+			IPAddress: ip,
+		})
+	})
+}
+
+// ChangePassword replaces the user's own password after checking the current
+// one. All sessions, including the caller's, are ended.
+func (s *Service) ChangePassword(userID uuid.UUID, change *models.PasswordChange) error {
+	var username, email, currentHash string
+	err := s.db.QueryRow("SELECT username, email, password FROM users WHERE id = $1", userID).Scan(&username, &email, &currentHash)
+	if err == sql.ErrNoRows {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if !s.CheckPasswordHash(change.CurrentPassword, currentHash) {
+		return ErrInvalidCurrentPassword
+	}
+
+	return s.withTx(func(tx *sql.Tx) error {
+		if err := s.updatePassword(tx, userID, username, email, change.NewPassword); err != nil {
+			return err
+		}
+		return revokeUserTokens(tx, userID.String())
+	})
+}
+
+// CompletePasswordChange sets a new password for a user whose login was
+// answered with a password change challenge, and completes the login
+func (s *Service) CompletePasswordChange(challengeToken, newPassword string, client ClientInfo) (*LoginResult, error) {
+	user, err := s.parseChallengeToken(challengeToken, purposePasswordChange)
+	if err != nil {
+		return nil, err
+	}
+	if !user.MustChangePassword {
+		return nil, ErrInvalidChallenge
+	}
+
+	if err := s.withTx(func(tx *sql.Tx) error {
+		return s.updatePassword(tx, user.ID, user.Username, user.Email, newPassword)
+	}); err != nil {
+		return nil, err
+	}
+	user.MustChangePassword = false
+
+	return s.completeLogin(user, client)
+}
+
+// updatePassword validates and stores a password chosen by the user, clearing
+// the must-change flag
+func (s *Service) updatePassword(tx *sql.Tx, userID uuid.UUID, username, email, password string) error {
+	if err := s.config.PasswordPolicy.Validate(password, username, email); err != nil {
+		return err
+	}
+
+	var currentHash string
+	if err := tx.QueryRow("SELECT password FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&currentHash); err != nil {
+		return err
+	}
+	if s.CheckPasswordHash(password, currentHash) {
+		return &PasswordPolicyError{Reason: "must differ from the current password"}
+	}
+
+	hashedPassword, err := s.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		"UPDATE users SET password = $1, must_change_password = false, password_changed_at = NOW(), updated_at = NOW() WHERE id = $2",
+		hashedPassword, userID)
+	return err
+}
+
+// passwordChangeChallenge returns the challenge sent instead of tokens while
+// the user must change their password
+func (s *Service) passwordChangeChallenge(user *models.User) (*Challenge, error) {
+	token, err := s.generateChallengeToken(user, purposePasswordChange)
+	if err != nil {
+		return nil, err
+	}
+	return &Challenge{
+		Token:                  token,
+		PasswordChangeRequired: true,
+		ExpiresIn:              int64(challengeTTL.Seconds()),
+	}, nil
+}
+
+// ensureOtherActiveAdmin returns ErrLastAdmin unless an active admin other
+// than userID exists. The admin rows stay locked so that two admins cannot
+// demote each other concurrently.
+func ensureOtherActiveAdmin(tx *sql.Tx, userID uuid.UUID) error {
+	var others int
+	if err := tx.QueryRow(
+		`SELECT COUNT(*) FROM (
+		     SELECT id FROM users WHERE role = 'admin' AND is_active AND id <> $1 FOR UPDATE
+		 ) admins`,
+		userID).Scan(&others); err != nil {
+		return err
+	}
+	if others == 0 {
+		return ErrLastAdmin
+	}
+	return nil
+}
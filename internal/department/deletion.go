@@ -0,0 +1,164 @@
+package department
+
+import (
+	"database/sql"
+	"employee-management/internal/models"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// ErrReassignToSelf is returned when deleting a department and reassigning
+// its dependents to the same department
+var ErrReassignToSelf = errors.New("a department's dependents cannot be reassigned to itself")
+
+// InUseError is returned when deleting a department that something still
+// refers to, without a department to reassign it to
+type InUseError struct {
+	Dependents models.DepartmentDependents
+}
+
+func (e *InUseError) Error() string {
+	return ErrDepartmentInUse.Error()
+}
+
+// Unwrap makes errors.Is match ErrDepartmentInUse
+func (e *InUseError) Unwrap() error {
+	return ErrDepartmentInUse
+}
+
+// dependentsQuery counts what refers to the department $1. Current employees
+// are the ones TransferDepartmentWith moves.
+const dependentsQuery = `
+	SELECT
+		(SELECT COUNT(*) FROM employees e WHERE e.department_id = d.id AND e.deleted_at IS NULL AND e.employment_status <> 'terminated'),
+		(SELECT COUNT(*) FROM employees e WHERE e.department_id = d.id AND (e.deleted_at IS NOT NULL OR e.employment_status = 'terminated')),
+		(SELECT COUNT(*) FROM positions p WHERE p.department_id = d.id),
+		(SELECT COUNT(*) FROM departments c WHERE c.parent_id = d.id AND c.merged_into_id IS NULL),
+		(SELECT COUNT(*) FROM departments c WHERE c.merged_into_id IS NOT NULL AND (c.parent_id = d.id OR c.merged_into_id = d.id)),
+		(SELECT COUNT(*) FROM employee_job_history h WHERE h.department_id = d.id)
+	FROM departments d
+	WHERE d.id = $1`
+
+// countDependents counts what refers to a department
+func countDependents(q interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}, id uuid.UUID) (models.DepartmentDependents, error) {
+	var dependents models.DepartmentDependents
+	err := q.QueryRow(dependentsQuery, id).Scan(
+		&dependents.Employees, &dependents.FormerEmployees, &dependents.Positions,
+		&dependents.Departments, &dependents.MergedDepartments, &dependents.JobHistory,
+	)
+	if err == sql.ErrNoRows {
+		return dependents, ErrDepartmentNotFound
+	}
+	return dependents, err
+}
+
+// DepartmentDependents counts the employees, positions, departments and job
+// history that refer to a department
+func (s *Service) DepartmentDependents(id uuid.UUID) (*models.DepartmentDependents, error) {
+	dependents, err := countDependents(s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	return &dependents, nil
+}
+
+// deleteError maps foreign key violations on deleting a department, left by
+// rows added after its dependents were counted, to ErrDepartmentInUse
+func deleteError(err error) error {
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+		return ErrDepartmentInUse
+	}
+	return err
+}
+
+// DeleteDepartment deletes a department in one transaction. Without
+// reassignTo, a department that anything still refers to is not deleted
+// and an *InUseError lists its dependents.
+//
+// With reassignTo, positions and sub-departments are moved to that
+// department as when merging, and departments merged into the department
+// are repointed to it. A department that job history refers to, which
+// includes any that ever had employees, is never deleted: it returns
+// ErrDepartmentHasHistory and should be merged instead, which keeps it.
+func (s *Service) DeleteDepartment(id uuid.UUID, reassignTo *uuid.UUID, deletedBy *uuid.UUID) (*models.DepartmentDeletion, error) {
+	if reassignTo != nil && *reassignTo == id {
+		return nil, ErrReassignToSelf
+	}
+
+	deletion := &models.DepartmentDeletion{ID: id, ReassignedTo: reassignTo}
+	err := s.withTx(func(tx *sql.Tx) error {
+		if reassignTo == nil {
+			return deleteUnused(tx, id)
+		}
+
+		names, err := lockForMerge(tx, id, *reassignTo)
+		if err != nil {
+			return err
+		}
+		dependents, err := countDependents(tx, id)
+		if err != nil {
+			return err
+		}
+		if dependents.JobHistory > 0 {
+			return ErrDepartmentHasHistory
+		}
+
+		reason := fmt.Sprintf("Department %s deleted, reassigned to %s", names[id], names[*reassignTo])
+		merged := &models.DepartmentMergeResult{}
+		if err := s.mergeWith(tx, id, *reassignTo, reason, deletedBy, merged); err != nil {
+			return err
+		}
+		deletion.Moved.Employees = merged.Employees
+		deletion.Moved.Positions = merged.Positions
+		deletion.Moved.Departments = merged.Departments
+
+		moved, err := tx.Exec(`
+			UPDATE departments SET
+				parent_id = CASE WHEN parent_id = $2 THEN $1 ELSE parent_id END,
+				merged_into_id = CASE WHEN merged_into_id = $2 THEN $1 ELSE merged_into_id END,
+				updated_at = NOW()
+			WHERE merged_into_id IS NOT NULL AND (parent_id = $2 OR merged_into_id = $2)`, *reassignTo, id)
+		if err != nil {
+			return err
+		}
+		if deletion.Moved.MergedDepartments, err = moved.RowsAffected(); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("DELETE FROM departments WHERE id = $1", id)
+		return deleteError(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deletion, nil
+}
+
+// deleteUnused deletes a department that nothing refers to. Locking the row
+// keeps new references out until the transaction ends.
+func deleteUnused(tx *sql.Tx, id uuid.UUID) error {
+	var locked uuid.UUID
+	err := tx.QueryRow("SELECT id FROM departments WHERE id = $1 FOR UPDATE", id).Scan(&locked)
+	if err == sql.ErrNoRows {
+		return ErrDepartmentNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	dependents, err := countDependents(tx, id)
+	if err != nil {
+		return err
+	}
+	if !dependents.Empty() {
+		return &InUseError{Dependents: dependents}
+	}
+
+	_, err = tx.Exec("DELETE FROM departments WHERE id = $1", id)
+	return deleteError(err)
+}
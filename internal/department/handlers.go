@@ -2,7 +2,9 @@ package department
 
 import (
 	"employee-management/internal/models"
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -29,6 +31,8 @@ func NewHandler(service *Service) *Handler {
 // @Param department body models.DepartmentCreate true "Department data"
 // @Success 201 {object} models.Department
 // @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /departments [post]
 func (h *Handler) CreateDepartment(c *gin.Context) {
@@ -40,7 +44,7 @@ func (h *Handler) CreateDepartment(c *gin.Context) {
 
 	department, err := h.service.CreateDepartment(&departmentData)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		departmentError(c, err)
 		return
 	}
 
@@ -83,6 +87,7 @@ func (h *Handler) GetDepartment(c *gin.Context) {
 // @Success 200 {object} models.Department
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /departments/{id} [put]
 func (h *Handler) UpdateDepartment(c *gin.Context) {
@@ -100,7 +105,7 @@ func (h *Handler) UpdateDepartment(c *gin.Context) {
 
 	department, err := h.service.UpdateDepartment(id, &departmentData)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		departmentError(c, err)
 		return
 	}
 
@@ -109,13 +114,16 @@ func (h *Handler) UpdateDepartment(c *gin.Context) {
 
 // DeleteDepartment handles deleting a department by its ID
 // @Summary Delete a department
-// @Description Delete a department by its ID
+// @Description Delete a department that nothing refers to. With reassign_to, its positions and sub-departments are moved to that department first. A department that job history refers to is never deleted; merge it instead.
 // @Tags Departments
 // @Produce json
 // @Param id path string true "Department ID"
+// @Param reassign_to query string false "Department to reassign dependents to"
+// @Success 200 {object} models.DepartmentDeletion
 // @Success 204 "No Content"
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
 // @Router /departments/{id} [delete]
 func (h *Handler) DeleteDepartment(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
@@ -124,13 +132,53 @@ func (h *Handler) DeleteDepartment(c *gin.Context) {
 		return
 	}
 
-	err = h.service.DeleteDepartment(id)
+	var reassignTo *uuid.UUID
+	if raw := c.Query("reassign_to"); raw != "" {
+		target, err := uuid.Parse(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reassign_to department ID"})
+			return
+		}
+		reassignTo = &target
+	}
+
+	deletion, err := h.service.DeleteDepartment(id, reassignTo, currentUserID(c))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		departmentError(c, err)
 		return
 	}
 
-	c.Status(http.StatusNoContent)
+	if reassignTo == nil {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	c.JSON(http.StatusOK, deletion)
+}
+
+// GetDepartmentDependents handles counting what refers to a department
+// @Summary Get a department's dependents
+// @Description Counts the employees, positions, departments and job history that refer to the department and keep it from being deleted without reassign_to
+// @Tags Departments
+// @Produce json
+// @Param id path string true "Department ID"
+// @Success 200 {object} models.DepartmentDependents
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /departments/{id}/dependents [get]
+func (h *Handler) GetDepartmentDependents(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid department ID"})
+		return
+	}
+
+	dependents, err := h.service.DepartmentDependents(id)
+	if err != nil {
+		departmentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dependents)
 }
 
 // ListDepartments handles listing all departments
@@ -150,3 +198,299 @@ func (h *Handler) ListDepartments(c *gin.Context) {
 
 	c.JSON(http.StatusOK, departments)
 }
+
+// MoveDepartment handles moving a department in the department tree
+// @Summary Move a department
+// @Description Place a department, with its sub-departments, under another parent, or at the top level when parent_id is null
+// @Tags Departments
+// @Accept json
+// @Produce json
+// @Param id path string true "Department ID"
+// @Param move body models.DepartmentMove true "New parent"
+// @Success 200 {object} models.Department
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /departments/{id}/move [post]
+func (h *Handler) MoveDepartment(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid department ID"})
+		return
+	}
+
+	var data models.DepartmentMove
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	department, err := h.service.MoveDepartment(id, &data)
+	if err != nil {
+		departmentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, department)
+}
+
+// MergeDepartments handles merging a department into another
+// @Summary Merge a department into another
+// @Description Transfer the department's current employees, and move its positions and sub-departments, to the department named by into_id. The merged department is kept for history.
+// @Tags Departments
+// @Accept json
+// @Produce json
+// @Param id path string true "Department ID"
+// @Param merge body models.DepartmentMerge true "Department to merge into"
+// @Success 200 {object} models.DepartmentMergeResult
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /departments/{id}/merge [post]
+func (h *Handler) MergeDepartments(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid department ID"})
+		return
+	}
+
+	var data models.DepartmentMerge
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.service.MergeDepartments(id, &data, currentUserID(c))
+	if err != nil {
+		departmentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetDepartmentTree handles getting the department tree with headcounts
+// @Summary Get the department tree
+// @Description Returns the departments as a tree with their own and rolled-up headcounts, starting from root or from every top-level department
+// @Tags Departments
+// @Produce json
+// @Param root query string false "Department at the top of the tree"
+// @Success 200 {array} models.DepartmentNode
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /departments/tree [get]
+func (h *Handler) GetDepartmentTree(c *gin.Context) {
+	var root *uuid.UUID
+	if value := c.Query("root"); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid root department ID"})
+			return
+		}
+		root = &id
+	}
+
+	tree, err := h.service.DepartmentTree(root)
+	if err != nil {
+		departmentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tree)
+}
+
+// GetPayrollCosts handles getting payroll costs rolled up by department,
+// cost center or legal entity
+// @Summary Get payroll costs
+// @Description Sums processed payroll runs whose pay period ends between from and to. By department, every department includes its sub-departments.
+// @Tags Departments
+// @Produce json
+// @Param from query string true "First pay period end date (YYYY-MM-DD)"
+// @Param to query string true "Last pay period end date (YYYY-MM-DD)"
+// @Param group_by query string false "department, cost_center or legal_entity"
+// @Success 200 {object} models.PayrollCostReport
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /departments/costs [get]
+func (h *Handler) GetPayrollCosts(c *gin.Context) {
+	from, err := time.Parse("2006-01-02", c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a date (YYYY-MM-DD)"})
+		return
+	}
+	to, err := time.Parse("2006-01-02", c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a date (YYYY-MM-DD)"})
+		return
+	}
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must not be before from"})
+		return
+	}
+	groupBy := c.DefaultQuery("group_by", models.CostGroupDepartment)
+	switch groupBy {
+	case models.CostGroupDepartment, models.CostGroupCostCenter, models.CostGroupLegalEntity:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group_by must be department, cost_center or legal_entity"})
+		return
+	}
+
+	report, err := h.service.PayrollCosts(from, to, groupBy)
+	if err != nil {
+		departmentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// CreateLegalEntity handles the creation of a new legal entity
+// @Summary Create a legal entity
+// @Tags Legal entities
+// @Accept json
+// @Produce json
+// @Param entity body models.LegalEntityCreate true "Legal entity data"
+// @Success 201 {object} models.LegalEntity
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /legal-entities [post]
+func (h *Handler) CreateLegalEntity(c *gin.Context) {
+	var data models.LegalEntityCreate
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entity, err := h.service.CreateLegalEntity(&data)
+	if err != nil {
+		departmentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, entity)
+}
+
+// GetLegalEntity handles retrieving a legal entity by its ID
+// @Summary Get a legal entity by ID
+// @Tags Legal entities
+// @Produce json
+// @Param id path string true "Legal entity ID"
+// @Success 200 {object} models.LegalEntity
+// @Failure 404 {object} map[string]string
+// @Router /legal-entities/{id} [get]
+func (h *Handler) GetLegalEntity(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid legal entity ID"})
+		return
+	}
+
+	entity, err := h.service.GetLegalEntity(id)
+	if err != nil {
+		departmentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, entity)
+}
+
+// UpdateLegalEntity handles replacing a legal entity's details
+// @Summary Update a legal entity
+// @Tags Legal entities
+// @Accept json
+// @Produce json
+// @Param id path string true "Legal entity ID"
+// @Param entity body models.LegalEntityCreate true "Legal entity data"
+// @Success 200 {object} models.LegalEntity
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /legal-entities/{id} [put]
+func (h *Handler) UpdateLegalEntity(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid legal entity ID"})
+		return
+	}
+
+	var data models.LegalEntityCreate
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entity, err := h.service.UpdateLegalEntity(id, &data)
+	if err != nil {
+		departmentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, entity)
+}
+
+// DeleteLegalEntity handles deleting a legal entity that owns no departments
+// @Summary Delete a legal entity
+// @Tags Legal entities
+// @Param id path string true "Legal entity ID"
+// @Success 204 "No Content"
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /legal-entities/{id} [delete]
+func (h *Handler) DeleteLegalEntity(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid legal entity ID"})
+		return
+	}
+
+	if err := h.service.DeleteLegalEntity(id); err != nil {
+		departmentError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListLegalEntities handles listing all legal entities
+// @Summary List legal entities
+// @Tags Legal entities
+// @Produce json
+// @Success 200 {array} models.LegalEntity
+// @Failure 500 {object} map[string]string
+// @Router /legal-entities [get]
+func (h *Handler) ListLegalEntities(c *gin.Context) {
+	entities, err := h.service.ListLegalEntities()
+	if err != nil {
+		departmentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, entities)
+}
+
+// departmentError maps department and legal entity errors to HTTP responses
+func departmentError(c *gin.Context, err error) {
+	var inUse *InUseError
+	switch {
+	case errors.As(err, &inUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "dependents": inUse.Dependents})
+	case errors.Is(err, ErrDepartmentNotFound), errors.Is(err, ErrParentNotFound), errors.Is(err, ErrLegalEntityNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrMergeIntoSelf), errors.Is(err, ErrReassignToSelf):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrDepartmentMerged), errors.Is(err, ErrDepartmentCycle), errors.Is(err, ErrDepartmentInUse),
+		errors.Is(err, ErrDepartmentHasHistory), errors.Is(err, ErrLegalEntityExists), errors.Is(err, ErrLegalEntityInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// currentUserID returns the authenticated user's ID, or nil if there is none
+func currentUserID(c *gin.Context) *uuid.UUID {
+	id, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		return nil
+	}
+	return &id
+}
@@ -0,0 +1,491 @@
+package department
+
+import (
+	"database/sql"
+	"employee-management/internal/models"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// unassignedName names the group of pay not assigned to a department, cost
+// center or legal entity
+const unassignedName = "Unassigned"
+
+// withTx runs fn in a transaction, committing if it returns nil
+func (s *Service) withTx(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// checkParent returns an error unless the department exists and has not
+// been merged
+func checkParent(q interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}, parentID uuid.UUID) error {
+	var merged bool
+	err := q.QueryRow("SELECT merged_into_id IS NOT NULL FROM departments WHERE id = $1", parentID).Scan(&merged)
+	if err == sql.ErrNoRows {
+		return ErrParentNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if merged {
+		return ErrDepartmentMerged
+	}
+	return nil
+}
+
+// lockTree serializes changes to department parents for the rest of the
+// transaction, so two concurrent moves cannot together create a cycle
+func lockTree(tx *sql.Tx) error {
+	_, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext('departments.parent_id'))")
+	return err
+}
+
+// isBelow reports whether the department is id itself or one of its
+// sub-departments
+func isBelow(tx *sql.Tx, department, id uuid.UUID) (bool, error) {
+	var below bool
+	err := tx.QueryRow(`
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id FROM departments WHERE id = $1
+			UNION
+			SELECT d.id, d.parent_id FROM departments d JOIN ancestors a ON d.id = a.parent_id
+		)
+		SELECT EXISTS (SELECT 1 FROM ancestors WHERE id = $2)`, department, id,
+	).Scan(&below)
+	return below, err
+}
+
+// MoveDepartment places a department, with everything below it, under a new
+// parent or at the top level
+func (s *Service) MoveDepartment(id uuid.UUID, data *models.DepartmentMove) (*models.Department, error) {
+	var department models.Department
+	err := s.withTx(func(tx *sql.Tx) error {
+		if err := lockTree(tx); err != nil {
+			return err
+		}
+		var merged bool
+		err := tx.QueryRow("SELECT merged_into_id IS NOT NULL FROM departments WHERE id = $1 FOR UPDATE", id).Scan(&merged)
+		if err == sql.ErrNoRows {
+			return ErrDepartmentNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if merged {
+			return ErrDepartmentMerged
+		}
+
+		if data.ParentID != nil {
+			if err := checkParent(tx, *data.ParentID); err != nil {
+				return err
+			}
+			below, err := isBelow(tx, *data.ParentID, id)
+			if err != nil {
+				return err
+			}
+			if below {
+				return ErrDepartmentCycle
+			}
+		}
+
+		return scanDepartment(tx.QueryRow(
+			`UPDATE departments SET parent_id = $1, updated_at = NOW() WHERE id = $2 RETURNING `+departmentColumns, data.ParentID, id,
+		), &department)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &department, nil
+}
+
+// MergeDepartments merges a department into another in one transaction. The
+// current employees of the department are transferred, and its positions
+// and sub-departments move to the other department. The merged department
+// is kept, marked as merged, for the history that refers to it.
+func (s *Service) MergeDepartments(id uuid.UUID, data *models.DepartmentMerge, mergedBy *uuid.UUID) (*models.DepartmentMergeResult, error) {
+	if id == data.IntoID {
+		return nil, ErrMergeIntoSelf
+	}
+
+	result := &models.DepartmentMergeResult{MergedID: id}
+	err := s.withTx(func(tx *sql.Tx) error {
+		names, err := lockForMerge(tx, id, data.IntoID)
+		if err != nil {
+			return err
+		}
+		reason := fmt.Sprintf("Department %s merged into %s", names[id], names[data.IntoID])
+		return s.mergeWith(tx, id, data.IntoID, reason, mergedBy, result)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Department, err = s.GetDepartmentByID(data.IntoID); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// lockForMerge locks the department tree and both departments for the rest
+// of the transaction and returns their names. Neither may be merged, and
+// into may not be below id.
+func lockForMerge(tx *sql.Tx, id, into uuid.UUID) (map[uuid.UUID]string, error) {
+	if err := lockTree(tx); err != nil {
+		return nil, err
+	}
+	rows, err := tx.Query("SELECT id, name, merged_into_id IS NOT NULL FROM departments WHERE id IN ($1, $2) ORDER BY id FOR UPDATE", id, into)
+	if err != nil {
+		return nil, err
+	}
+	names := map[uuid.UUID]string{}
+	merged := false
+	for rows.Next() {
+		var locked uuid.UUID
+		var name string
+		var isMerged bool
+		if err := rows.Scan(&locked, &name, &isMerged); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		names[locked] = name
+		merged = merged || isMerged
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(names) < 2 {
+		return nil, ErrDepartmentNotFound
+	}
+	if merged {
+		return nil, ErrDepartmentMerged
+	}
+	below, err := isBelow(tx, into, id)
+	if err != nil {
+		return nil, err
+	}
+	if below {
+		return nil, ErrDepartmentCycle
+	}
+	return names, nil
+}
+
+// mergeWith transfers the current employees of a department, and moves its
+// positions and sub-departments, to another department and marks it as
+// merged, counting what moved in result. Both must be locked by
+// lockForMerge.
+func (s *Service) mergeWith(tx *sql.Tx, id, into uuid.UUID, reason string, mergedBy *uuid.UUID, result *models.DepartmentMergeResult) error {
+	var err error
+	if result.Employees, err = s.employees.TransferDepartmentWith(tx, id, into, reason, mergedBy); err != nil {
+		return err
+	}
+	moved, err := tx.Exec("UPDATE positions SET department_id = $1, updated_at = NOW() WHERE department_id = $2", into, id)
+	if err != nil {
+		return err
+	}
+	if result.Positions, err = moved.RowsAffected(); err != nil {
+		return err
+	}
+	moved, err = tx.Exec("UPDATE departments SET parent_id = $1, updated_at = NOW() WHERE parent_id = $2 AND merged_into_id IS NULL", into, id)
+	if err != nil {
+		return err
+	}
+	if result.Departments, err = moved.RowsAffected(); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE departments SET merged_into_id = $1, merged_at = NOW(), manager_id = NULL, updated_at = NOW() WHERE id = $2", into, id)
+	return err
+}
+
+// departmentIndex holds every department, merged ones included, for rollups
+type departmentIndex map[uuid.UUID]*models.Department
+
+// loadDepartments retrieves every department by ID
+func (s *Service) loadDepartments() (departmentIndex, error) {
+	rows, err := s.db.Query(`SELECT ` + departmentColumns + ` FROM departments`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	index := departmentIndex{}
+	for rows.Next() {
+		var department models.Department
+		if err := scanDepartment(rows, &department); err != nil {
+			return nil, err
+		}
+		index[department.ID] = &department
+	}
+	return index, rows.Err()
+}
+
+// resolve follows merges to the department that took over from id. It
+// returns nil for unknown departments.
+func (index departmentIndex) resolve(id uuid.UUID) *models.Department {
+	department := index[id]
+	for seen := 0; department != nil && department.MergedIntoID != nil && seen < len(index); seen++ {
+		department = index[*department.MergedIntoID]
+	}
+	return department
+}
+
+// ancestors returns the department followed by its parents, up to the top
+func (index departmentIndex) ancestors(department *models.Department) []*models.Department {
+	chain := []*models.Department{department}
+	for department.ParentID != nil && len(chain) <= len(index) {
+		parent := index[*department.ParentID]
+		if parent == nil {
+			break
+		}
+		chain = append(chain, parent)
+		department = parent
+	}
+	return chain
+}
+
+// costCenter returns the cost center in effect for the department
+func (index departmentIndex) costCenter(department *models.Department) string {
+	for _, d := range index.ancestors(department) {
+		if d.CostCenter != "" {
+			return d.CostCenter
+		}
+	}
+	return ""
+}
+
+// legalEntity returns the legal entity in effect for the department
+func (index departmentIndex) legalEntity(department *models.Department) *uuid.UUID {
+	for _, d := range index.ancestors(department) {
+		if d.LegalEntityID != nil {
+			return d.LegalEntityID
+		}
+	}
+	return nil
+}
+
+// DepartmentTree returns the departments as a tree with their headcounts,
+// starting from rootID or, if it is nil, from every top-level department
+func (s *Service) DepartmentTree(rootID *uuid.UUID) ([]*models.DepartmentNode, error) {
+	index, err := s.loadDepartments()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT department_id, COUNT(*) FROM employees
+		WHERE department_id IS NOT NULL AND deleted_at IS NULL AND employment_status <> 'terminated'
+		GROUP BY department_id`)
+	if err != nil {
+		return nil, err
+	}
+	headcounts := map[uuid.UUID]int{}
+	for rows.Next() {
+		var id uuid.UUID
+		var count int
+		if err := rows.Scan(&id, &count); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if department := index.resolve(id); department != nil {
+			headcounts[department.ID] += count
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	nodes := map[uuid.UUID]*models.DepartmentNode{}
+	for id, department := range index {
+		if department.MergedIntoID != nil {
+			continue
+		}
+		nodes[id] = &models.DepartmentNode{
+			ID:            id,
+			Name:          department.Name,
+			ParentID:      department.ParentID,
+			ManagerID:     department.ManagerID,
+			CostCenter:    index.costCenter(department),
+			LegalEntityID: index.legalEntity(department),
+			Headcount:     headcounts[id],
+		}
+	}
+	var roots []*models.DepartmentNode
+	for _, node := range nodes {
+		if parent, ok := nodes[derefID(node.ParentID)]; ok && node.ParentID != nil {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	for _, root := range roots {
+		sumHeadcount(root)
+	}
+
+	if rootID != nil {
+		root, ok := nodes[*rootID]
+		if !ok {
+			return nil, ErrDepartmentNotFound
+		}
+		return []*models.DepartmentNode{root}, nil
+	}
+	sortNodes(roots)
+	if roots == nil {
+		roots = []*models.DepartmentNode{}
+	}
+	return roots, nil
+}
+
+// derefID returns the ID, or the nil UUID for nil
+func derefID(id *uuid.UUID) uuid.UUID {
+	if id == nil {
+		return uuid.Nil
+	}
+	return *id
+}
+
+// sumHeadcount sets the total headcount of a node and everything below it,
+// sorting children by name on the way
+func sumHeadcount(node *models.DepartmentNode) int {
+	sortNodes(node.Children)
+	node.TotalHeadcount = node.Headcount
+	for _, child := range node.Children {
+		node.TotalHeadcount += sumHeadcount(child)
+	}
+	return node.TotalHeadcount
+}
+
+// sortNodes sorts department nodes by name
+func sortNodes(nodes []*models.DepartmentNode) {
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+}
+
+// PayrollCosts sums the payroll runs whose pay period ends between from and
+// to, except drafts. Pay is assigned to the department the employee was in
+// at the end of the pay period; by department, each department includes its
+// sub-departments.
+func (s *Service) PayrollCosts(from, to time.Time, groupBy string) (*models.PayrollCostReport, error) {
+	index, err := s.loadDepartments()
+	if err != nil {
+		return nil, err
+	}
+	entities := map[uuid.UUID]string{}
+	if groupBy == models.CostGroupLegalEntity {
+		list, err := s.ListLegalEntities()
+		if err != nil {
+			return nil, err
+		}
+		for _, entity := range list {
+			entities[entity.ID] = entity.Name
+		}
+	}
+
+	rows, err := s.db.Query(`
+		SELECT d.employee_id, CASE WHEN j.found THEN j.department_id ELSE e.department_id END,
+		       SUM(d.gross_pay), SUM(d.tax_amount), SUM(d.other_deductions), SUM(d.net_pay)
+		FROM payroll_details d
+		JOIN payroll p ON p.id = d.payroll_id
+		JOIN employees e ON e.id = d.employee_id
+		LEFT JOIN LATERAL (
+			SELECT h.department_id, true AS found FROM employee_job_history h
+			WHERE h.employee_id = d.employee_id AND h.effective_date <= p.pay_period_end
+			ORDER BY h.effective_date DESC, h.created_at DESC
+			LIMIT 1
+		) j ON true
+		WHERE p.status <> 'draft' AND p.pay_period_end BETWEEN $1 AND $2
+		GROUP BY 1, 2`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := map[string]*models.PayrollCost{}
+	paid := map[string]map[uuid.UUID]bool{}
+	add := func(key, name string, employeeID uuid.UUID, line *models.PayrollCost) {
+		group, ok := groups[key]
+		if !ok {
+			group = &models.PayrollCost{Key: key, Name: name}
+			groups[key] = group
+			paid[key] = map[uuid.UUID]bool{}
+		}
+		paid[key][employeeID] = true
+		group.GrossPay += line.GrossPay
+		group.TaxAmount += line.TaxAmount
+		group.OtherDeductions += line.OtherDeductions
+		group.NetPay += line.NetPay
+	}
+
+	for rows.Next() {
+		var employeeID uuid.UUID
+		var departmentID *uuid.UUID
+		var line models.PayrollCost
+		if err := rows.Scan(&employeeID, &departmentID, &line.GrossPay, &line.TaxAmount, &line.OtherDeductions, &line.NetPay); err != nil {
+			return nil, err
+		}
+		department := index.resolve(derefID(departmentID))
+		if department == nil {
+			add("", unassignedName, employeeID, &line)
+			continue
+		}
+		switch groupBy {
+		case models.CostGroupDepartment:
+			for _, d := range index.ancestors(department) {
+				add(d.ID.String(), d.Name, employeeID, &line)
+			}
+		case models.CostGroupCostCenter:
+			if costCenter := index.costCenter(department); costCenter != "" {
+				add(costCenter, costCenter, employeeID, &line)
+			} else {
+				add("", unassignedName, employeeID, &line)
+			}
+		case models.CostGroupLegalEntity:
+			if entity := index.legalEntity(department); entity != nil {
+				add(entity.String(), entities[*entity], employeeID, &line)
+			} else {
+				add("", unassignedName, employeeID, &line)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	report := &models.PayrollCostReport{From: from, To: to, GroupBy: groupBy, Groups: []models.PayrollCost{}}
+	for key, group := range groups {
+		group.Employees = len(paid[key])
+		group.GrossPay = roundMoney(group.GrossPay)
+		group.TaxAmount = roundMoney(group.TaxAmount)
+		group.OtherDeductions = roundMoney(group.OtherDeductions)
+		group.NetPay = roundMoney(group.NetPay)
+		report.Groups = append(report.Groups, *group)
+	}
+	sort.Slice(report.Groups, func(i, j int) bool {
+		a, b := report.Groups[i], report.Groups[j]
+		if (a.Key == "") != (b.Key == "") {
+			return b.Key == ""
+		}
+		return a.Name < b.Name
+	})
+	return report, nil
+}
+
+// roundMoney rounds an amount to cents
+func roundMoney(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,115 @@
+package department
+
+import (
+	"database/sql"
+	"employee-management/internal/models"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrLegalEntityNotFound is returned when a legal entity does not exist
+	ErrLegalEntityNotFound = errors.New("legal entity not found")
+	// ErrLegalEntityExists is returned when another legal entity has the same name
+	ErrLegalEntityExists = errors.New("a legal entity with this name already exists")
+	// ErrLegalEntityInUse is returned when deleting a legal entity that still owns departments
+	ErrLegalEntityInUse = errors.New("legal entity still owns departments")
+)
+
+// legalEntityColumns are the columns read for a legal entity
+const legalEntityColumns = `id, name, registration_number, country, created_at, updated_at`
+
+// scanLegalEntity scans a row of legalEntityColumns
+func scanLegalEntity(row interface{ Scan(...interface{}) error }, entity *models.LegalEntity) error {
+	err := row.Scan(&entity.ID, &entity.Name, &entity.RegistrationNumber, &entity.Country, &entity.CreatedAt, &entity.UpdatedAt)
+	entity.Country = strings.TrimSpace(entity.Country)
+	return err
+}
+
+// legalEntityError maps constraint violations on legal_entities to errors
+func legalEntityError(err error) error {
+	if pqErr, ok := err.(*pq.Error); ok {
+		switch pqErr.Code {
+		case "23505":
+			return ErrLegalEntityExists
+		case "23503":
+			return ErrLegalEntityInUse
+		}
+	}
+	if err == sql.ErrNoRows {
+		return ErrLegalEntityNotFound
+	}
+	return err
+}
+
+// CreateLegalEntity creates a new legal entity
+func (s *Service) CreateLegalEntity(data *models.LegalEntityCreate) (*models.LegalEntity, error) {
+	var entity models.LegalEntity
+	query := `
+		INSERT INTO legal_entities (name, registration_number, country)
+		VALUES ($1, $2, $3)
+		RETURNING ` + legalEntityColumns
+	if err := scanLegalEntity(s.db.QueryRow(query, data.Name, data.RegistrationNumber, strings.ToUpper(data.Country)), &entity); err != nil {
+		return nil, legalEntityError(err)
+	}
+	return &entity, nil
+}
+
+// GetLegalEntity retrieves a legal entity by its ID
+func (s *Service) GetLegalEntity(id uuid.UUID) (*models.LegalEntity, error) {
+	var entity models.LegalEntity
+	if err := scanLegalEntity(s.db.QueryRow(`SELECT `+legalEntityColumns+` FROM legal_entities WHERE id = $1`, id), &entity); err != nil {
+		return nil, legalEntityError(err)
+	}
+	return &entity, nil
+}
+
+// UpdateLegalEntity replaces a legal entity's details
+func (s *Service) UpdateLegalEntity(id uuid.UUID, data *models.LegalEntityCreate) (*models.LegalEntity, error) {
+	var entity models.LegalEntity
+	query := `
+		UPDATE legal_entities
+		SET name = $1, registration_number = $2, country = $3, updated_at = NOW()
+		WHERE id = $4
+		RETURNING ` + legalEntityColumns
+	if err := scanLegalEntity(s.db.QueryRow(query, data.Name, data.RegistrationNumber, strings.ToUpper(data.Country), id), &entity); err != nil {
+		return nil, legalEntityError(err)
+	}
+	return &entity, nil
+}
+
+// DeleteLegalEntity deletes a legal entity that owns no departments
+func (s *Service) DeleteLegalEntity(id uuid.UUID) error {
+	result, err := s.db.Exec("DELETE FROM legal_entities WHERE id = $1", id)
+	if err != nil {
+		return legalEntityError(err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrLegalEntityNotFound
+	}
+	return nil
+}
+
+// ListLegalEntities retrieves every legal entity by name
+func (s *Service) ListLegalEntities() ([]models.LegalEntity, error) {
+	rows, err := s.db.Query(`SELECT ` + legalEntityColumns + ` FROM legal_entities ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entities := []models.LegalEntity{}
+	for rows.Next() {
+		var entity models.LegalEntity
+		if err := scanLegalEntity(rows, &entity); err != nil {
+			return nil, err
+		}
+		entities = append(entities, entity)
+	}
+	return entities, rows.Err()
+}
@@ -1,41 +1,104 @@
 package department
 
 import (
+	"database/sql"
 	"employee-management/internal/database"
 	"employee-management/internal/models"
 	"errors"
+	"strings"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+var (
+	// ErrDepartmentNotFound is returned when a department does not exist
+	ErrDepartmentNotFound = errors.New("department not found")
+	// ErrParentNotFound is returned when a parent department does not exist
+	ErrParentNotFound = errors.New("parent department not found")
+	// ErrDepartmentMerged is returned when changing, or placing departments under, a merged department
+	ErrDepartmentMerged = errors.New("department has been merged into another department")
+	// ErrDepartmentCycle is returned when a department would end up below itself
+	ErrDepartmentCycle = errors.New("a department cannot be placed below itself or one of its sub-departments")
+	// ErrMergeIntoSelf is returned when merging a department into itself
+	ErrMergeIntoSelf = errors.New("a department cannot be merged into itself")
+	// ErrDepartmentInUse is returned when deleting a department that something still refers to
+	ErrDepartmentInUse = errors.New("department still has dependents")
+	// ErrDepartmentHasHistory is returned when deleting a department that job history refers to
+	ErrDepartmentHasHistory = errors.New("job history refers to the department; merge it into another department instead")
+)
+
+// EmployeeTransferrer moves the employees of a department inside a
+// transaction. It is implemented by the employee repository, so the moves
+// show up in each employee's job history.
+type EmployeeTransferrer interface {
+	TransferDepartmentWith(tx *sql.Tx, from, to uuid.UUID, reason string, recordedBy *uuid.UUID) (int64, error)
+}
+
 // Service handles department-related operations
 type Service struct {
-	db *database.DB
+	db        *database.DB
+	employees EmployeeTransferrer
 }
 
 // NewService creates a new department service
-func NewService(db *database.DB) *Service {
+func NewService(db *database.DB, employees EmployeeTransferrer) *Service {
 	return &Service{
-		db: db,
+		db:        db,
+		employees: employees,
+	}
+}
+
+// departmentColumns are the columns read for a department
+const departmentColumns = `id, name, description, manager_id, parent_id, COALESCE(cost_center, ''), legal_entity_id, merged_into_id, merged_at, created_at, updated_at`
+
+// scanDepartment scans a row of departmentColumns
+func scanDepartment(row interface{ Scan(...interface{}) error }, department *models.Department) error {
+	return row.Scan(
+		&department.ID, &department.Name, &department.Description, &department.ManagerID, &department.ParentID, &department.CostCenter,
+		&department.LegalEntityID, &department.MergedIntoID, &department.MergedAt, &department.CreatedAt, &department.UpdatedAt,
+	)
+}
+
+// nullCostCenter stores an empty cost center as NULL
+func nullCostCenter(costCenter string) sql.NullString {
+	costCenter = strings.TrimSpace(costCenter)
+	return sql.NullString{String: costCenter, Valid: costCenter != ""}
+}
+
+// referenceError maps foreign key violations on departments to errors
+func referenceError(err error) error {
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+		switch pqErr.Constraint {
+		case "departments_parent_id_fkey":
+			return ErrParentNotFound
+		case "departments_legal_entity_id_fkey":
+			return ErrLegalEntityNotFound
+		}
 	}
+	return err
 }
 
 // CreateDepartment creates a new department
 func (s *Service) CreateDepartment(departmentData *models.DepartmentCreate) (*models.Department, error) {
+	if departmentData.ParentID != nil {
+		if err := checkParent(s.db, *departmentData.ParentID); err != nil {
+			return nil, err
+		}
+	}
+
 	var department models.Department
 	query := `
-		INSERT INTO departments (name, description, manager_id)
-		VALUES ($1, $2, $3)
-		RETURNING id, name, description, manager_id, created_at, updated_at
-	`
-	err := s.db.QueryRow(query,
-		departmentData.Name, departmentData.Description, departmentData.ManagerID,
-	).Scan(
-		&department.ID, &department.Name, &department.Description, &department.ManagerID, &department.CreatedAt, &department.UpdatedAt,
-	)
+		INSERT INTO departments (name, description, manager_id, parent_id, cost_center, legal_entity_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING ` + departmentColumns
+	err := scanDepartment(s.db.QueryRow(query,
+		departmentData.Name, departmentData.Description, departmentData.ManagerID, departmentData.ParentID,
+		nullCostCenter(departmentData.CostCenter), departmentData.LegalEntityID,
+	), &department)
 
 	if err != nil {
-		return nil, err
+		return nil, referenceError(err)
 	}
 
 	return &department, nil
@@ -44,16 +107,11 @@ func (s *Service) CreateDepartment(departmentData *models.DepartmentCreate) (*mo
 // GetDepartmentByID retrieves a department by its ID
 func (s *Service) GetDepartmentByID(id uuid.UUID) (*models.Department, error) {
 	var department models.Department
-	query := `
-		SELECT id, name, description, manager_id, created_at, updated_at
-		FROM departments WHERE id = $1
-	`
-	err := s.db.QueryRow(query, id).Scan(
-		&department.ID, &department.Name, &department.Description, &department.ManagerID, &department.CreatedAt, &department.UpdatedAt,
-	)
+	query := `SELECT ` + departmentColumns + ` FROM departments WHERE id = $1`
+	err := scanDepartment(s.db.QueryRow(query, id), &department)
 
 	if err != nil {
-		return nil, errors.New("department not found")
+		return nil, ErrDepartmentNotFound
 	}
 
 	return &department, nil
@@ -61,52 +119,47 @@ func (s *Service) GetDepartmentByID(id uuid.UUID) (*models.Department, error) {
 
 // UpdateDepartment updates an existing department's information
 func (s *Service) UpdateDepartment(id uuid.UUID, departmentData *models.DepartmentUpdate) (*models.Department, error) {
-	var department models.Department
-	query := `
-		UPDATE departments
-		SET name = $1, description = $2, manager_id = $3, updated_at = NOW()
-		WHERE id = $4
-		RETURNING id, name, description, manager_id, created_at, updated_at
-	`
-	err := s.db.QueryRow(query,
-		departmentData.Name, departmentData.Description, departmentData.ManagerID, id,
-	).Scan(
-		&department.ID, &department.Name, &department.Description, &department.ManagerID, &department.CreatedAt, &department.UpdatedAt,
-	)
-
+	current, err := s.GetDepartmentByID(id)
 	if err != nil {
 		return nil, err
 	}
-
-	return &department, nil
-}
-
-// DeleteDepartment deletes a department by its ID
-func (s *Service) DeleteDepartment(id uuid.UUID) error {
-	result, err := s.db.Exec("DELETE FROM departments WHERE id = $1", id)
-	if err != nil {
-		return err
+	if current.MergedIntoID != nil {
+		return nil, ErrDepartmentMerged
 	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return err
+	costCenter := current.CostCenter
+	if departmentData.CostCenter != nil {
+		costCenter = *departmentData.CostCenter
+	}
+	legalEntityID := current.LegalEntityID
+	if departmentData.LegalEntityID != nil {
+		legalEntityID = departmentData.LegalEntityID
+		if *legalEntityID == uuid.Nil {
+			legalEntityID = nil
+		}
 	}
 
-	if rowsAffected == 0 {
-		return errors.New("department not found")
+	var department models.Department
+	query := `
+		UPDATE departments
+		SET name = $1, description = $2, manager_id = $3, cost_center = $4, legal_entity_id = $5, updated_at = NOW()
+		WHERE id = $6
+		RETURNING ` + departmentColumns
+	err = scanDepartment(s.db.QueryRow(query,
+		departmentData.Name, departmentData.Description, departmentData.ManagerID, nullCostCenter(costCenter), legalEntityID, id,
+	), &department)
+
+	if err != nil {
+		return nil, referenceError(err)
 	}
 
-	return nil
+	return &department, nil
 }
 
-// ListDepartments retrieves a list of all departments
+// ListDepartments retrieves a list of all departments that have not been
+// merged into another
 func (s *Service) ListDepartments() ([]models.Department, error) {
 	var departments []models.Department
-	query := `
-		SELECT id, name, description, manager_id, created_at, updated_at
-		FROM departments
-	`
+	query := `SELECT ` + departmentColumns + ` FROM departments WHERE merged_into_id IS NULL`
 	rows, err := s.db.Query(query)
 	if err != nil {
 		return nil, err
@@ -115,10 +168,7 @@ func (s *Service) ListDepartments() ([]models.Department, error) {
 
 	for rows.Next() {
 		var department models.Department
-		err := rows.Scan(
-			&department.ID, &department.Name, &department.Description, &department.ManagerID, &department.CreatedAt, &department.UpdatedAt,
-		)
-		if err != nil {
+		if err := scanDepartment(rows, &department); err != nil {
 			return nil, err
 		}
 		departments = append(departments, department)
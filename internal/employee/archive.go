@@ -0,0 +1,296 @@
+package employee
+
+import (
+	"database/sql"
+	"employee-management/internal/auth"
+	"employee-management/internal/fieldcrypt"
+	"employee-management/internal/models"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrEmployeeInUse is returned when deleting an employee other records still depend on
+	ErrEmployeeInUse = errors.New("employee is still referenced")
+	// ErrEmployeeNotDeleted is returned when restoring an employee who was not deleted
+	ErrEmployeeNotDeleted = errors.New("employee is not deleted")
+	// ErrEmployeeAnonymized is returned when restoring an employee whose personal details were erased
+	ErrEmployeeAnonymized = errors.New("employee has been anonymized and cannot be restored")
+	// ErrManagerDeleted is returned when restoring an employee whose manager is deleted
+	ErrManagerDeleted = errors.New("the employee's manager is deleted, restore them first")
+)
+
+// DeleteEmployee marks an employee as deleted, deactivates their user account
+// and cancels their pending change requests. The row and everything referring
+// to it are kept. Employees who still manage other employees or head a
+// department cannot be deleted.
+func (r *repository) DeleteEmployee(logger *logrus.Entry, id uuid.UUID, deletedBy *uuid.UUID) error {
+	startTime := time.Now()
+	err := r.withTx(func(tx *sql.Tx) error {
+		var userID uuid.NullUUID
+		err := tx.QueryRow("SELECT user_id FROM employees WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", id).Scan(&userID)
+		if err == sql.ErrNoRows {
+			return ErrEmployeeNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := checkEmployeeUnused(tx, id); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec("UPDATE employees SET deleted_at = NOW(), deleted_by = $2, updated_at = NOW() WHERE id = $1", id, deletedBy); err != nil {
+			return err
+		}
+		if err := cancelChangeRequests(tx, id); err != nil {
+			return err
+		}
+		if !userID.Valid {
+			return nil
+		}
+		if _, err := auth.DeactivateUserWith(tx, userID.UUID); err != nil && !errors.Is(err, auth.ErrUserNotFound) {
+			return err
+		}
+		return nil
+	})
+
+	logger.WithFields(logrus.Fields{
+		"duration": time.Since(startTime),
+	}).Debug("Executed DeleteEmployee transaction")
+
+	return err
+}
+
+// checkEmployeeUnused returns ErrEmployeeInUse if the employee still manages
+// other employees or heads a department
+func checkEmployeeUnused(tx *sql.Tx, id uuid.UUID) error {
+	var reports, departments int
+	err := tx.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM employees WHERE manager_id = $1 AND deleted_at IS NULL),
+		       (SELECT COUNT(*) FROM departments WHERE manager_id = $1)`, id,
+	).Scan(&reports, &departments)
+	if err != nil {
+		return err
+	}
+	var uses []string
+	if reports > 0 {
+		uses = append(uses, fmt.Sprintf("manages %d employees", reports))
+	}
+	if departments > 0 {
+		uses = append(uses, fmt.Sprintf("heads %d departments", departments))
+	}
+	if len(uses) > 0 {
+		return fmt.Errorf("%w: the employee %s; reassign them first", ErrEmployeeInUse, strings.Join(uses, " and "))
+	}
+	return nil
+}
+
+// RestoreEmployee undoes the deletion of an employee who has not been
+// anonymized yet. Their user account stays deactivated.
+func (r *repository) RestoreEmployee(logger *logrus.Entry, id uuid.UUID) (*models.Employee, error) {
+	startTime := time.Now()
+	err := r.withTx(func(tx *sql.Tx) error {
+		var deleted, anonymized, managerDeleted bool
+		err := tx.QueryRow(`
+			SELECT e.deleted_at IS NOT NULL, e.anonymized_at IS NOT NULL, COALESCE(m.deleted_at IS NOT NULL, false)
+			FROM employees e LEFT JOIN employees m ON m.id = e.manager_id
+			WHERE e.id = $1
+			FOR UPDATE OF e`, id,
+		).Scan(&deleted, &anonymized, &managerDeleted)
+		switch {
+		case err == sql.ErrNoRows:
+			return ErrEmployeeNotFound
+		case err != nil:
+			return err
+		case !deleted:
+			return ErrEmployeeNotDeleted
+		case anonymized:
+			return ErrEmployeeAnonymized
+		case managerDeleted:
+			return ErrManagerDeleted
+		}
+
+		_, err = tx.Exec("UPDATE employees SET deleted_at = NULL, deleted_by = NULL, updated_at = NOW() WHERE id = $1", id)
+		return err
+	})
+
+	logger.WithFields(logrus.Fields{
+		"duration": time.Since(startTime),
+	}).Debug("Executed RestoreEmployee transaction")
+
+	if err != nil {
+		return nil, err
+	}
+	return r.GetEmployeeByID(logger, id)
+}
+
+// AnonymizeEmployees erases the personal details of employees deleted before
+// the given time, in their current record, every version of it, their
+// change requests and their user account. The employee ID, job details and year of birth are kept
+// for the records that must be retained.
+func (r *repository) AnonymizeEmployees(logger *logrus.Entry, deletedBefore time.Time) (int, error) {
+	startTime := time.Now()
+	var ids []uuid.UUID
+	err := r.withTx(func(tx *sql.Tx) error {
+		rows, err := tx.Query(`
+			UPDATE employees
+			SET first_name = 'Deleted', last_name = 'Employee',
+			    date_of_birth = make_date(EXTRACT(YEAR FROM date_of_birth)::int, 1, 1),
+			    phone_number = '', email = 'deleted-' || id || '@invalid', address = '',
+			    emergency_contact_name = '', emergency_contact_phone = '',
+			    email_index = NULL, phone_number_index = NULL, custom_fields = '{}',
+			    anonymized_at = NOW(), updated_at = NOW()
+			WHERE deleted_at < $1 AND anonymized_at IS NULL
+			RETURNING id`, deletedBefore)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var id uuid.UUID
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil || len(ids) == 0 {
+			return err
+		}
+		if err := r.anonymizeEncryptedDatesOfBirth(tx, ids); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			UPDATE employee_versions v
+			SET data = v.data || jsonb_build_object(
+			    'first_name', e.first_name, 'last_name', e.last_name,
+			    'date_of_birth', COALESCE(to_jsonb(e.date_of_birth_encrypted), to_jsonb(to_char(e.date_of_birth, 'YYYY-MM-DD"T00:00:00Z"'))),
+			    'phone_number', e.phone_number, 'email', e.email, 'address', e.address,
+			    'emergency_contact_name', e.emergency_contact_name, 'emergency_contact_phone', e.emergency_contact_phone,
+			    'custom_fields', e.custom_fields)
+			FROM employees e
+			WHERE e.id = v.employee_id AND e.id = ANY($1)`, pq.Array(ids))
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("UPDATE employee_change_requests SET changes = '{}', review_note = NULL WHERE employee_id = ANY($1)", pq.Array(ids))
+		if err != nil {
+			return err
+		}
+		return anonymizeUsers(tx, logger, ids)
+	})
+
+	logger.WithFields(logrus.Fields{
+		"anonymized": len(ids),
+		"duration":   time.Since(startTime),
+	}).Debug("Executed AnonymizeEmployees transaction")
+
+	if err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+// anonymizeUsers anonymizes the user accounts of the anonymized employees
+// that no other employee still uses. The last active admin keeps their
+// account.
+func anonymizeUsers(tx *sql.Tx, logger *logrus.Entry, ids []uuid.UUID) error {
+	rows, err := tx.Query(`
+		SELECT DISTINCT e.user_id FROM employees e
+		WHERE e.id = ANY($1) AND e.user_id IS NOT NULL
+		  AND NOT EXISTS (SELECT 1 FROM employees o WHERE o.user_id = e.user_id AND o.anonymized_at IS NULL)`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	var users []uuid.UUID
+	for rows.Next() {
+		var userID uuid.UUID
+		if err := rows.Scan(&userID); err != nil {
+			rows.Close()
+			return err
+		}
+		users = append(users, userID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, userID := range users {
+		err := auth.AnonymizeUserWith(tx, userID)
+		if errors.Is(err, auth.ErrLastAdmin) {
+			logger.WithField("userID", userID).Warn("Kept the user account of an anonymized employee who is the last active admin")
+			continue
+		}
+		if err != nil && !errors.Is(err, auth.ErrUserNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// anonymizeEncryptedDatesOfBirth does for encrypted dates of birth what
+// AnonymizeEmployees does in SQL for plaintext ones: keep only the year
+func (r *repository) anonymizeEncryptedDatesOfBirth(tx *sql.Tx, ids []uuid.UUID) error {
+	rows, err := tx.Query("SELECT id, date_of_birth_encrypted FROM employees WHERE id = ANY($1) AND date_of_birth_encrypted IS NOT NULL", pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	anonymized := map[uuid.UUID]string{}
+	for rows.Next() {
+		var id uuid.UUID
+		var stored string
+		var dateOfBirth time.Time
+		if err := rows.Scan(&id, &stored); err != nil {
+			rows.Close()
+			return err
+		}
+		if _, err := r.openValue(fieldcrypt.FieldDateOfBirth, stored, &dateOfBirth); err != nil {
+			rows.Close()
+			return err
+		}
+		if anonymized[id], err = r.sealValue(fieldcrypt.FieldDateOfBirth, time.Date(dateOfBirth.Year(), 1, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+			rows.Close()
+			return err
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, dateOfBirth := range anonymized {
+		if _, err := tx.Exec("UPDATE employees SET date_of_birth_encrypted = $1 WHERE id = $2", dateOfBirth, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteEmployee deletes an employee, keeping their records
+func (s *Service) DeleteEmployee(logger *logrus.Entry, id uuid.UUID, deletedBy *uuid.UUID) error {
+	logger.WithField("employeeID", id).Info("Deleting employee")
+	return s.repo.DeleteEmployee(logger, id, deletedBy)
+}
+
+// RestoreEmployee restores a deleted employee
+func (s *Service) RestoreEmployee(logger *logrus.Entry, id uuid.UUID) (*models.Employee, error) {
+	logger.WithField("employeeID", id).Info("Restoring employee")
+	return s.repo.RestoreEmployee(logger, id)
+}
+
+// ApplyRetentionPolicy anonymizes employees who were deleted longer ago
+// than the retention period and returns how many were anonymized
+func (s *Service) ApplyRetentionPolicy(logger *logrus.Entry, retention time.Duration) (int, error) {
+	logger.WithField("retention", retention).Info("Applying employee retention policy")
+	return s.repo.AnonymizeEmployees(logger, time.Now().Add(-retention))
+}
@@ -0,0 +1,526 @@
+package employee
+
+import (
+	"database/sql"
+	"employee-management/internal/middleware"
+	"employee-management/internal/models"
+	"employee-management/internal/spreadsheet"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrCustomFieldNotFound is returned when a custom field definition does not exist
+	ErrCustomFieldNotFound = errors.New("custom field not found")
+	// ErrCustomFieldExists is returned when defining a custom field with a key already in use
+	ErrCustomFieldExists = errors.New("a custom field with this key already exists")
+	// ErrInvalidCustomField is returned for inconsistent custom field definitions
+	ErrInvalidCustomField = errors.New("invalid custom field")
+	// ErrInvalidCustomFieldValue is returned for custom field values that do not match their definition
+	ErrInvalidCustomFieldValue = errors.New("invalid custom field value")
+)
+
+// customFieldKey is the form of custom field keys. They double as import
+// and export column names.
+var customFieldKey = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+// customFieldRoles are the roles custom fields can be made visible to
+var customFieldRoles = map[string]bool{
+	middleware.RoleAdmin: true, middleware.RoleHR: true, middleware.RoleManager: true, middleware.RoleEmployee: true,
+}
+
+// jsonColumn scans a JSON column into target
+type jsonColumn struct{ target interface{} }
+
+func (c jsonColumn) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(src, c.target)
+	case string:
+		return json.Unmarshal([]byte(src), c.target)
+	default:
+		return fmt.Errorf("cannot scan %T into a JSON column", src)
+	}
+}
+
+// customFieldsJSON encodes custom field values for the custom_fields column
+func customFieldsJSON(values models.CustomFields) ([]byte, error) {
+	if values == nil {
+		values = models.CustomFields{}
+	}
+	return json.Marshal(values)
+}
+
+const customFieldColumns = `id, key, label, type, required, options, pattern, min_value, max_value, visible_to, created_at, updated_at`
+
+func scanCustomField(row interface{ Scan(...interface{}) error }, d *models.CustomFieldDefinition) error {
+	return row.Scan(&d.ID, &d.Key, &d.Label, &d.Type, &d.Required, pq.Array(&d.Options), &d.Pattern, &d.Min, &d.Max,
+		pq.Array(&d.VisibleTo), &d.CreatedAt, &d.UpdatedAt)
+}
+
+// ListCustomFields retrieves all custom field definitions in the order they were defined
+func (r *repository) ListCustomFields(logger *logrus.Entry) ([]models.CustomFieldDefinition, error) {
+	startTime := time.Now()
+	query := `SELECT ` + customFieldColumns + ` FROM custom_field_definitions ORDER BY created_at, key`
+	rows, err := r.db.Query(query)
+
+	logger.WithFields(logrus.Fields{
+		"query":    query,
+		"duration": time.Since(startTime),
+	}).Debug("Executed ListCustomFields query")
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	definitions := []models.CustomFieldDefinition{}
+	for rows.Next() {
+		var d models.CustomFieldDefinition
+		if err := scanCustomField(rows, &d); err != nil {
+			return nil, err
+		}
+		definitions = append(definitions, d)
+	}
+	return definitions, rows.Err()
+}
+
+// GetCustomField retrieves a custom field definition by its ID
+func (r *repository) GetCustomField(logger *logrus.Entry, id uuid.UUID) (*models.CustomFieldDefinition, error) {
+	startTime := time.Now()
+	var d models.CustomFieldDefinition
+	query := `SELECT ` + customFieldColumns + ` FROM custom_field_definitions WHERE id = $1`
+	err := scanCustomField(r.db.QueryRow(query, id), &d)
+
+	logger.WithFields(logrus.Fields{
+		"query":    query,
+		"duration": time.Since(startTime),
+	}).Debug("Executed GetCustomField query")
+
+	if err == sql.ErrNoRows {
+		return nil, ErrCustomFieldNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// CreateCustomField stores a new custom field definition
+func (r *repository) CreateCustomField(logger *logrus.Entry, d *models.CustomFieldDefinition) (*models.CustomFieldDefinition, error) {
+	startTime := time.Now()
+	var created models.CustomFieldDefinition
+	query := `
+		INSERT INTO custom_field_definitions (key, label, type, required, options, pattern, min_value, max_value, visible_to)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING ` + customFieldColumns
+	err := scanCustomField(r.db.QueryRow(query, d.Key, d.Label, d.Type, d.Required, pq.Array(d.Options), d.Pattern, d.Min, d.Max, pq.Array(d.VisibleTo)), &created)
+
+	logger.WithFields(logrus.Fields{
+		"query":    query,
+		"duration": time.Since(startTime),
+	}).Debug("Executed CreateCustomField query")
+
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return nil, ErrCustomFieldExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// UpdateCustomField replaces the settings of a custom field definition
+func (r *repository) UpdateCustomField(logger *logrus.Entry, d *models.CustomFieldDefinition) (*models.CustomFieldDefinition, error) {
+	startTime := time.Now()
+	var updated models.CustomFieldDefinition
+	query := `
+		UPDATE custom_field_definitions
+		SET label = $1, required = $2, options = $3, pattern = $4, min_value = $5, max_value = $6, visible_to = $7, updated_at = NOW()
+		WHERE id = $8
+		RETURNING ` + customFieldColumns
+	err := scanCustomField(r.db.QueryRow(query, d.Label, d.Required, pq.Array(d.Options), d.Pattern, d.Min, d.Max, pq.Array(d.VisibleTo), d.ID), &updated)
+
+	logger.WithFields(logrus.Fields{
+		"query":    query,
+		"duration": time.Since(startTime),
+	}).Debug("Executed UpdateCustomField query")
+
+	if err == sql.ErrNoRows {
+		return nil, ErrCustomFieldNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// DeleteCustomField deletes a custom field definition along with its values,
+// in the current records and in every version of them
+func (r *repository) DeleteCustomField(logger *logrus.Entry, id uuid.UUID) error {
+	startTime := time.Now()
+	err := r.withTx(func(tx *sql.Tx) error {
+		var key string
+		err := tx.QueryRow("DELETE FROM custom_field_definitions WHERE id = $1 RETURNING key", id).Scan(&key)
+		if err == sql.ErrNoRows {
+			return ErrCustomFieldNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE employees SET custom_fields = custom_fields - $1 WHERE custom_fields ? $1", key); err != nil {
+			return err
+		}
+		_, err = tx.Exec("UPDATE employee_versions SET data = data #- ARRAY['custom_fields', $1] WHERE data->'custom_fields' ? $1", key)
+		return err
+	})
+
+	logger.WithFields(logrus.Fields{
+		"duration": time.Since(startTime),
+	}).Debug("Executed DeleteCustomField transaction")
+
+	return err
+}
+
+// ListCustomFields retrieves all custom field definitions
+func (s *Service) ListCustomFields(logger *logrus.Entry) ([]models.CustomFieldDefinition, error) {
+	logger.Info("Listing custom fields")
+	return s.repo.ListCustomFields(logger)
+}
+
+// CreateCustomField defines a new custom field
+func (s *Service) CreateCustomField(logger *logrus.Entry, data *models.CustomFieldDefinitionCreate) (*models.CustomFieldDefinition, error) {
+	logger.WithField("key", data.Key).Info("Creating custom field")
+	d := &models.CustomFieldDefinition{
+		Key:       data.Key,
+		Label:     data.Label,
+		Type:      data.Type,
+		Required:  data.Required,
+		Options:   data.Options,
+		Pattern:   data.Pattern,
+		Min:       data.Min,
+		Max:       data.Max,
+		VisibleTo: data.VisibleTo,
+	}
+	if err := checkCustomFieldDefinition(d); err != nil {
+		return nil, err
+	}
+	return s.repo.CreateCustomField(logger, d)
+}
+
+// UpdateCustomField changes the settings of a custom field. Existing values
+// are not checked against the new settings until they are next changed.
+func (s *Service) UpdateCustomField(logger *logrus.Entry, id uuid.UUID, data *models.CustomFieldDefinitionUpdate) (*models.CustomFieldDefinition, error) {
+	logger.WithField("customFieldID", id).Info("Updating custom field")
+	d, err := s.repo.GetCustomField(logger, id)
+	if err != nil {
+		return nil, err
+	}
+	d.Label = data.Label
+	d.Required = data.Required
+	d.Options = data.Options
+	d.Pattern = data.Pattern
+	d.Min = data.Min
+	d.Max = data.Max
+	d.VisibleTo = data.VisibleTo
+	if err := checkCustomFieldDefinition(d); err != nil {
+		return nil, err
+	}
+	return s.repo.UpdateCustomField(logger, d)
+}
+
+// DeleteCustomField deletes a custom field and all of its values
+func (s *Service) DeleteCustomField(logger *logrus.Entry, id uuid.UUID) error {
+	logger.WithField("customFieldID", id).Info("Deleting custom field")
+	return s.repo.DeleteCustomField(logger, id)
+}
+
+// VisibleCustomFields retrieves the custom field definitions users with the
+// role can see
+func (s *Service) VisibleCustomFields(logger *logrus.Entry, role string) ([]models.CustomFieldDefinition, error) {
+	definitions, err := s.repo.ListCustomFields(logger)
+	if err != nil {
+		return nil, err
+	}
+	visible := definitions[:0]
+	for _, d := range definitions {
+		if customFieldVisible(&d, role) {
+			visible = append(visible, d)
+		}
+	}
+	return visible, nil
+}
+
+// HideCustomFields removes the custom fields users with the role cannot see
+// from the employees
+func (s *Service) HideCustomFields(logger *logrus.Entry, role string, employees ...*models.Employee) error {
+	definitions, err := s.VisibleCustomFields(logger, role)
+	if err != nil {
+		return err
+	}
+	visible := map[string]bool{}
+	for _, d := range definitions {
+		visible[d.Key] = true
+	}
+	for _, e := range employees {
+		for key := range e.CustomFields {
+			if !visible[key] {
+				delete(e.CustomFields, key)
+			}
+		}
+	}
+	return nil
+}
+
+// checkCustomFields validates custom field values against their definitions
+// and returns them normalized. For a partial update, nil values clear
+// optional fields and required fields may be left out.
+func (s *Service) checkCustomFields(logger *logrus.Entry, values models.CustomFields, partial bool) (models.CustomFields, error) {
+	definitions, err := s.repo.ListCustomFields(logger)
+	if err != nil {
+		return nil, err
+	}
+	byKey := map[string]*models.CustomFieldDefinition{}
+	for i := range definitions {
+		byKey[definitions[i].Key] = &definitions[i]
+	}
+
+	checked := models.CustomFields{}
+	for key, value := range values {
+		d, ok := byKey[key]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown custom field %q", ErrInvalidCustomFieldValue, key)
+		}
+		if value == nil {
+			if d.Required {
+				return nil, fmt.Errorf("%w: %s is required", ErrInvalidCustomFieldValue, key)
+			}
+			if partial {
+				checked[key] = nil
+			}
+			continue
+		}
+		normalized, problem := checkCustomFieldValue(d, value)
+		if problem != "" {
+			return nil, fmt.Errorf("%w: %s %s", ErrInvalidCustomFieldValue, key, problem)
+		}
+		checked[key] = normalized
+	}
+	if !partial {
+		for _, d := range definitions {
+			if _, ok := checked[d.Key]; d.Required && !ok {
+				return nil, fmt.Errorf("%w: %s is required", ErrInvalidCustomFieldValue, d.Key)
+			}
+		}
+	}
+	return checked, nil
+}
+
+// mergeCustomFields applies a partial update of custom fields, where nil
+// clears a field, to the values held in a version of the record
+func mergeCustomFields(current json.RawMessage, update models.CustomFields) (models.CustomFields, error) {
+	merged := models.CustomFields{}
+	if len(current) > 0 {
+		if err := json.Unmarshal(current, &merged); err != nil {
+			return nil, err
+		}
+	}
+	for key, value := range update {
+		if value == nil {
+			delete(merged, key)
+		} else {
+			merged[key] = value
+		}
+	}
+	return merged, nil
+}
+
+func customFieldVisible(d *models.CustomFieldDefinition, role string) bool {
+	if role == middleware.RoleAdmin || len(d.VisibleTo) == 0 {
+		return true
+	}
+	for _, visibleTo := range d.VisibleTo {
+		if visibleTo == role {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCustomFieldDefinition checks that a definition's settings fit its type
+func checkCustomFieldDefinition(d *models.CustomFieldDefinition) error {
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s", ErrInvalidCustomField, fmt.Sprintf(format, args...))
+	}
+
+	if !customFieldKey.MatchString(d.Key) {
+		return invalid("key must start with a lowercase letter and contain only lowercase letters, digits and underscores")
+	}
+	if _, builtIn := importColumns[d.Key]; builtIn {
+		return invalid("key %q is a built-in field", d.Key)
+	}
+	if strings.TrimSpace(d.Label) == "" {
+		return invalid("label is required")
+	}
+
+	if d.Type == models.CustomFieldSelect {
+		if len(d.Options) == 0 {
+			return invalid("select fields need options")
+		}
+		seen := map[string]bool{}
+		for _, option := range d.Options {
+			if option == "" || seen[option] {
+				return invalid("options must be unique and not empty")
+			}
+			seen[option] = true
+		}
+	} else if len(d.Options) > 0 {
+		return invalid("only select fields have options")
+	}
+
+	if d.Pattern != "" {
+		if d.Type != models.CustomFieldText {
+			return invalid("only text fields have a pattern")
+		}
+		if _, err := regexp.Compile(d.Pattern); err != nil {
+			return invalid("pattern: %v", err)
+		}
+	}
+
+	if d.Min != nil || d.Max != nil {
+		switch {
+		case d.Type != models.CustomFieldText && d.Type != models.CustomFieldNumber:
+			return invalid("only text and number fields have a min and max")
+		case d.Min != nil && d.Max != nil && *d.Min > *d.Max:
+			return invalid("min is greater than max")
+		case d.Type == models.CustomFieldText && ((d.Min != nil && *d.Min < 0) || (d.Max != nil && *d.Max < 0)):
+			return invalid("text length cannot be negative")
+		}
+	}
+
+	if d.VisibleTo == nil {
+		d.VisibleTo = []string{}
+	}
+	for _, role := range d.VisibleTo {
+		if !customFieldRoles[role] {
+			return invalid("unknown role %q", role)
+		}
+	}
+	return nil
+}
+
+// checkCustomFieldValue checks a value decoded from JSON against its
+// definition. It returns the value to store, or what is wrong with it.
+func checkCustomFieldValue(d *models.CustomFieldDefinition, value interface{}) (interface{}, string) {
+	switch d.Type {
+	case models.CustomFieldText:
+		text, ok := value.(string)
+		if !ok {
+			return nil, "must be text"
+		}
+		length := float64(utf8.RuneCountInString(text))
+		if d.Min != nil && length < *d.Min {
+			return nil, fmt.Sprintf("must be at least %g characters", *d.Min)
+		}
+		if d.Max != nil && length > *d.Max {
+			return nil, fmt.Sprintf("must be at most %g characters", *d.Max)
+		}
+		if d.Pattern != "" {
+			if matched, _ := regexp.MatchString(`^(?:`+d.Pattern+`)$`, text); !matched {
+				return nil, fmt.Sprintf("must match %s", d.Pattern)
+			}
+		}
+		return text, ""
+
+	case models.CustomFieldNumber:
+		number, ok := value.(float64)
+		if !ok {
+			return nil, "must be a number"
+		}
+		if d.Min != nil && number < *d.Min {
+			return nil, fmt.Sprintf("must be at least %g", *d.Min)
+		}
+		if d.Max != nil && number > *d.Max {
+			return nil, fmt.Sprintf("must be at most %g", *d.Max)
+		}
+		return number, ""
+
+	case models.CustomFieldBoolean:
+		if _, ok := value.(bool); !ok {
+			return nil, "must be true or false"
+		}
+		return value, ""
+
+	case models.CustomFieldDate:
+		text, _ := value.(string)
+		date, err := time.Parse("2006-01-02", text)
+		if err != nil {
+			return nil, "must be a date (YYYY-MM-DD)"
+		}
+		return date.Format("2006-01-02"), ""
+
+	case models.CustomFieldSelect:
+		text, _ := value.(string)
+		for _, option := range d.Options {
+			if text == option {
+				return text, ""
+			}
+		}
+		return nil, "must be one of: " + strings.Join(d.Options, ", ")
+	}
+	return nil, "has an unknown type"
+}
+
+// parseCustomFieldValue reads a custom field value from text, as found in
+// spreadsheets and query strings, into the form checkCustomFieldValue expects
+func parseCustomFieldValue(d *models.CustomFieldDefinition, text string) (interface{}, string) {
+	switch d.Type {
+	case models.CustomFieldNumber:
+		number, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, "must be a number"
+		}
+		return number, ""
+	case models.CustomFieldBoolean:
+		switch strings.ToLower(text) {
+		case "true", "yes", "1":
+			return true, ""
+		case "false", "no", "0":
+			return false, ""
+		}
+		return nil, "must be true or false"
+	case models.CustomFieldDate:
+		date, err := spreadsheet.ParseDate(text)
+		if err != nil {
+			return nil, err.Error()
+		}
+		return date.Format("2006-01-02"), ""
+	}
+	return text, ""
+}
+
+// formatCustomFieldValue writes a custom field value as text for exports
+func formatCustomFieldValue(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	default:
+		return fmt.Sprint(value)
+	}
+}
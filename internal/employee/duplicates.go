@@ -0,0 +1,373 @@
+package employee
+
+import (
+	"database/sql"
+	"employee-management/internal/auth"
+	"employee-management/internal/fieldcrypt"
+	"employee-management/internal/models"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrMergeIntoSelf is returned when merging an employee into themselves
+	ErrMergeIntoSelf = errors.New("an employee cannot be merged into themselves")
+	// ErrOverlappingSalaries is returned when merging would give an employee two salaries of the same component at once
+	ErrOverlappingSalaries = errors.New("both employees have salaries of the same component in effect at the same time")
+	// ErrOverlappingPayroll is returned when merging would pay an employee twice in a payroll run or pay period
+	ErrOverlappingPayroll = errors.New("both employees were paid in the same payroll run or pay period")
+	// ErrOverlappingAttendance is returned when merging would give an employee two attendance records for a day
+	ErrOverlappingAttendance = errors.New("both employees have attendance recorded on the same day")
+)
+
+// DefaultDuplicateScore is the lowest score reported as a duplicate unless
+// asked otherwise
+const DefaultDuplicateScore = 0.7
+
+// Weights of each kind of evidence in a duplicate score. A similar name adds
+// its weight times the similarity.
+const (
+	emailWeight       = 0.5
+	phoneWeight       = 0.3
+	dateOfBirthWeight = 0.3
+	nameWeight        = 0.5
+	// minNameSimilarity is the similarity below which names count as different
+	minNameSimilarity = 0.75
+)
+
+// mergedReferences are the columns re-pointed from a duplicate employee to
+// the one it is merged into, keyed by how they are reported in the result
+var mergedReferences = []struct{ key, table, column string }{
+	{"attendance", "attendance", "employee_id"},
+	{"leave_requests", "leave_requests", "employee_id"},
+	{"leave_requests_approved", "leave_requests", "approved_by"},
+	{"employee_salaries", "employee_salaries", "employee_id"},
+	{"payroll_details", "payroll_details", "employee_id"},
+	{"payslips", "payslips", "employee_id"},
+	{"documents", "documents", "employee_id"},
+	{"performance_reviews", "performance_reviews", "employee_id"},
+	{"performance_reviews_given", "performance_reviews", "reviewer_id"},
+}
+
+// MergeEmployees moves everything recorded against the duplicate to the
+// employee and deletes the duplicate, all in one transaction. record is called
+// last, inside the transaction. The duplicate must not manage employees or
+// head a department, and the salaries, pay and attendance of the two must
+// not overlap.
+func (r *repository) MergeEmployees(logger *logrus.Entry, id, duplicateID uuid.UUID, mergedBy *uuid.UUID, record func(tx *sql.Tx, merge *models.EmployeeMerge) error) (*models.EmployeeMerge, error) {
+	if id == duplicateID {
+		return nil, ErrMergeIntoSelf
+	}
+
+	startTime := time.Now()
+	merge := &models.EmployeeMerge{DuplicateID: duplicateID, Moved: map[string]int64{}}
+	err := r.withTx(func(tx *sql.Tx) error {
+		// Lock in a fixed order so concurrent merges of the same pair cannot deadlock
+		rows, err := tx.Query("SELECT id, user_id, employee_id FROM employees WHERE id IN ($1, $2) AND deleted_at IS NULL ORDER BY id FOR UPDATE", id, duplicateID)
+		if err != nil {
+			return err
+		}
+		users := map[uuid.UUID]uuid.NullUUID{}
+		for rows.Next() {
+			var locked uuid.UUID
+			var userID uuid.NullUUID
+			var employeeID string
+			if err := rows.Scan(&locked, &userID, &employeeID); err != nil {
+				rows.Close()
+				return err
+			}
+			users[locked] = userID
+			if locked == duplicateID {
+				merge.DuplicateEmployeeID = employeeID
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(users) < 2 {
+			return ErrEmployeeNotFound
+		}
+
+		if err := checkEmployeeUnused(tx, duplicateID); err != nil {
+			return err
+		}
+		if err := checkRecordsDisjoint(tx, id, duplicateID); err != nil {
+			return err
+		}
+		// The unique index on pending requests allows only one per employee
+		if err := cancelChangeRequests(tx, duplicateID); err != nil {
+			return err
+		}
+
+		for _, ref := range mergedReferences {
+			result, err := tx.Exec("UPDATE "+ref.table+" SET "+ref.column+" = $1 WHERE "+ref.column+" = $2", id, duplicateID)
+			if err != nil {
+				return err
+			}
+			if merge.Moved[ref.key], err = result.RowsAffected(); err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.Exec("UPDATE employees SET deleted_at = NOW(), deleted_by = $2, updated_at = NOW() WHERE id = $1", duplicateID, mergedBy); err != nil {
+			return err
+		}
+		if users[duplicateID].Valid && users[duplicateID] != users[id] {
+			if _, err := auth.DeactivateUserWith(tx, users[duplicateID].UUID); err != nil && !errors.Is(err, auth.ErrUserNotFound) {
+				return err
+			}
+		}
+		return record(tx, merge)
+	})
+
+	logger.WithFields(logrus.Fields{
+		"duration": time.Since(startTime),
+	}).Debug("Executed MergeEmployees transaction")
+
+	if err != nil {
+		return nil, err
+	}
+	if merge.Employee, err = r.GetEmployeeByID(logger, id); err != nil {
+		return nil, err
+	}
+	return merge, nil
+}
+
+// mergeConflicts find records of two employees, $1 and $2, that would
+// clash once they belong to one employee. Salaries clash when they pay the
+// same component, not rejected, on a common day; payroll details when they
+// are in the same payroll run; payslips when their pay periods overlap; and
+// attendance when it is for the same day.
+var mergeConflicts = []struct {
+	err   error
+	hint  string
+	query string
+}{
+	{ErrOverlappingSalaries, "%d of the duplicate's salaries overlap; end them before merging", `
+		SELECT COUNT(DISTINCT b.id)
+		FROM employee_salaries a
+		JOIN employee_salaries b ON b.salary_component_id = a.salary_component_id
+		WHERE a.employee_id = $1 AND b.employee_id = $2
+		  AND a.band_status <> 'rejected' AND b.band_status <> 'rejected'
+		  AND a.effective_date < COALESCE(b.end_date, 'infinity') AND b.effective_date < COALESCE(a.end_date, 'infinity')`},
+	{ErrOverlappingPayroll, "%d payroll runs pay both; correct them before merging", `
+		SELECT COUNT(DISTINCT a.payroll_id)
+		FROM payroll_details a
+		JOIN payroll_details b ON b.payroll_id = a.payroll_id
+		WHERE a.employee_id = $1 AND b.employee_id = $2`},
+	{ErrOverlappingPayroll, "%d of the duplicate's payslips overlap; correct them before merging", `
+		SELECT COUNT(DISTINCT b.id)
+		FROM payslips a
+		JOIN payslips b ON a.pay_period_start <= b.pay_period_end AND b.pay_period_start <= a.pay_period_end
+		WHERE a.employee_id = $1 AND b.employee_id = $2`},
+	{ErrOverlappingAttendance, "%d days have both; delete one of the records before merging", `
+		SELECT COUNT(DISTINCT a.date)
+		FROM attendance a
+		JOIN attendance b ON b.date = a.date
+		WHERE a.employee_id = $1 AND b.employee_id = $2`},
+}
+
+// checkRecordsDisjoint returns the error of the first of mergeConflicts
+// found between the two employees. Moving clashing records to one employee
+// would pay them twice or record a day twice.
+func checkRecordsDisjoint(tx *sql.Tx, id, duplicateID uuid.UUID) error {
+	for _, conflict := range mergeConflicts {
+		var clashing int
+		if err := tx.QueryRow(conflict.query, id, duplicateID).Scan(&clashing); err != nil {
+			return err
+		}
+		if clashing > 0 {
+			return fmt.Errorf("%w; "+conflict.hint, conflict.err, clashing)
+		}
+	}
+	return nil
+}
+
+// FindDuplicates lists pairs of employees scoring at least minScore as
+// duplicates, most likely first
+func (s *Service) FindDuplicates(logger *logrus.Entry, minScore float64) ([]models.DuplicateCandidate, error) {
+	logger.WithField("minScore", minScore).Info("Finding duplicate employees")
+	employees, err := s.repo.ListEmployees(logger)
+	if err != nil {
+		return nil, err
+	}
+	return findDuplicates(employees, minScore), nil
+}
+
+// MergeEmployees merges a duplicate record into an employee and records the
+// merge in the audit log
+func (s *Service) MergeEmployees(logger *logrus.Entry, id uuid.UUID, data *models.EmployeeMergeRequest, mergedBy *uuid.UUID, ip string) (*models.EmployeeMerge, error) {
+	logger.WithFields(logrus.Fields{
+		"employeeID":  id,
+		"duplicateID": data.DuplicateID,
+	}).Info("Merging employees")
+	return s.repo.MergeEmployees(logger, id, data.DuplicateID, mergedBy, func(tx *sql.Tx, merge *models.EmployeeMerge) error {
+		return s.audit.RecordWith(tx, &models.AuditEventCreate{
+			ActorUserID: mergedBy,
+			Action:      "employee.merged",
+			TargetType:  "employee",
+			TargetID:    id.String(),
+			Details: map[string]interface{}{
+				"duplicate_id":          merge.DuplicateID,
+				"duplicate_employee_id": merge.DuplicateEmployeeID,
+				"reason":                data.Reason,
+				"moved":                 merge.Moved,
+			},
+			IPAddress: ip,
+		})
+	})
+}
+
+// findDuplicates scores the pairs of employees that share an email address,
+// phone number, date of birth or name. Pairs sharing none of them are not
+// compared. They could only score through a similar name, at most
+// nameWeight, so a minScore at or below nameWeight misses them.
+func findDuplicates(employees []models.Employee, minScore float64) []models.DuplicateCandidate {
+	sort.Slice(employees, func(i, j int) bool {
+		if !employees[i].CreatedAt.Equal(employees[j].CreatedAt) {
+			return employees[i].CreatedAt.Before(employees[j].CreatedAt)
+		}
+		return employees[i].ID.String() < employees[j].ID.String()
+	})
+
+	blocks := map[string][]int{}
+	for i := range employees {
+		for _, key := range duplicateKeys(&employees[i]) {
+			blocks[key] = append(blocks[key], i)
+		}
+	}
+
+	type pair struct{ a, b int }
+	compared := map[pair]bool{}
+	candidates := []models.DuplicateCandidate{}
+	for _, block := range blocks {
+		for x := 0; x < len(block); x++ {
+			for y := x + 1; y < len(block); y++ {
+				p := pair{block[x], block[y]}
+				if compared[p] {
+					continue
+				}
+				compared[p] = true
+				candidate := scoreDuplicate(&employees[p.a], &employees[p.b])
+				if candidate.Score >= minScore {
+					candidates = append(candidates, candidate)
+				}
+			}
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		return candidates[i].Employee.EmployeeID < candidates[j].Employee.EmployeeID
+	})
+	return candidates
+}
+
+// duplicateKeys returns the values an employee is grouped by for comparison
+func duplicateKeys(e *models.Employee) []string {
+	var keys []string
+	if email := strings.ToLower(strings.TrimSpace(e.Email)); email != "" {
+		keys = append(keys, "email:"+email)
+	}
+	if phone := fieldcrypt.NormalizePhone(e.PhoneNumber); len(phone) >= 6 {
+		keys = append(keys, "phone:"+phone)
+	}
+	if !e.DateOfBirth.IsZero() {
+		keys = append(keys, "dob:"+e.DateOfBirth.Format("2006-01-02"))
+	}
+	if name := strings.Fields(normalizeName(e.FirstName + " " + e.LastName)); len(name) > 0 {
+		sort.Strings(name)
+		keys = append(keys, "name:"+strings.Join(name, " "))
+	}
+	return keys
+}
+
+// scoreDuplicate works out how likely two employees are the same person
+func scoreDuplicate(a, b *models.Employee) models.DuplicateCandidate {
+	candidate := models.DuplicateCandidate{Employee: *a, Duplicate: *b, Reasons: []string{}}
+	if email := strings.ToLower(strings.TrimSpace(a.Email)); email != "" && email == strings.ToLower(strings.TrimSpace(b.Email)) {
+		candidate.Score += emailWeight
+		candidate.Reasons = append(candidate.Reasons, models.DuplicateSameEmail)
+	}
+	if phone := fieldcrypt.NormalizePhone(a.PhoneNumber); len(phone) >= 6 && phone == fieldcrypt.NormalizePhone(b.PhoneNumber) {
+		candidate.Score += phoneWeight
+		candidate.Reasons = append(candidate.Reasons, models.DuplicateSamePhone)
+	}
+	if !a.DateOfBirth.IsZero() && a.DateOfBirth.Format("2006-01-02") == b.DateOfBirth.Format("2006-01-02") {
+		candidate.Score += dateOfBirthWeight
+		candidate.Reasons = append(candidate.Reasons, models.DuplicateSameDateOfBirth)
+	}
+	candidate.NameSimilarity = nameSimilarity(a, b)
+	if candidate.NameSimilarity >= minNameSimilarity {
+		candidate.Score += nameWeight * candidate.NameSimilarity
+		candidate.Reasons = append(candidate.Reasons, models.DuplicateSimilarName)
+	}
+	candidate.Score = math.Round(math.Min(candidate.Score, 1)*100) / 100
+	candidate.NameSimilarity = math.Round(candidate.NameSimilarity*100) / 100
+	return candidate
+}
+
+// nameSimilarity compares the full names of two employees, also with first
+// and last name swapped
+func nameSimilarity(a, b *models.Employee) float64 {
+	name := normalizeName(a.FirstName + " " + a.LastName)
+	similarity := stringSimilarity(name, normalizeName(b.FirstName+" "+b.LastName))
+	if swapped := stringSimilarity(name, normalizeName(b.LastName+" "+b.FirstName)); swapped > similarity {
+		similarity = swapped
+	}
+	return similarity
+}
+
+// normalizeName lowercases a name and reduces everything but letters to
+// single spaces
+func normalizeName(name string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}), " ")
+}
+
+// stringSimilarity is one minus the edit distance of two strings relative to
+// the longer one
+func stringSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 0
+	}
+	return 1 - float64(editDistance(ra, rb))/float64(longest)
+}
+
+// editDistance is the Levenshtein distance between two strings
+func editDistance(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
@@ -0,0 +1,91 @@
+package employee
+
+import (
+	"testing"
+	"time"
+
+	"employee-management/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"kitten", "sitting", 3},
+		{"jane doe", "jane doe", 0},
+		{"zoë", "zoe", 1},
+	}
+	for _, tt := range tests {
+		if got := editDistance([]rune(tt.a), []rune(tt.b)); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestNameSimilarity(t *testing.T) {
+	a := &models.Employee{FirstName: "Jane", LastName: "Doe"}
+	if got := nameSimilarity(a, &models.Employee{FirstName: "DOE", LastName: "jane"}); got != 1 {
+		t.Errorf("swapped names similarity = %v, want 1", got)
+	}
+	if got := nameSimilarity(a, &models.Employee{FirstName: "Jane", LastName: "Doe-"}); got != 1 {
+		t.Errorf("punctuation similarity = %v, want 1", got)
+	}
+	if got := nameSimilarity(a, &models.Employee{FirstName: "Bob", LastName: "Smith"}); got >= minNameSimilarity {
+		t.Errorf("different names similarity = %v, want below %v", got, minNameSimilarity)
+	}
+}
+
+func TestFindDuplicates(t *testing.T) {
+	created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	employee := func(code, first, last, email, phone string) models.Employee {
+		created = created.Add(time.Hour)
+		return models.Employee{ID: uuid.New(), EmployeeID: code, FirstName: first, LastName: last, Email: email, PhoneNumber: phone, CreatedAt: created}
+	}
+	employees := []models.Employee{
+		employee("E1", "Jane", "Doe", "jane@example.com", "+1 555 0100"),
+		employee("E2", "Jane", "Do", "JANE@example.com ", "(1) 555-0100"),
+		employee("E3", "Bob", "Smith", "bob@example.com", "+1 555 0200"),
+		// Shares nothing but a similar name, so it is never compared
+		employee("E4", "Janet", "Doe", "janet@example.com", ""),
+	}
+
+	candidates := findDuplicates(employees, 0.5)
+	if len(candidates) != 1 {
+		t.Fatalf("found %d candidates, want 1: %+v", len(candidates), candidates)
+	}
+	c := candidates[0]
+	if c.Employee.EmployeeID != "E1" || c.Duplicate.EmployeeID != "E2" {
+		t.Errorf("candidate = %s and %s, want E1 and E2", c.Employee.EmployeeID, c.Duplicate.EmployeeID)
+	}
+	if c.Score != 1 {
+		t.Errorf("score = %v, want 1", c.Score)
+	}
+	want := []string{models.DuplicateSameEmail, models.DuplicateSamePhone, models.DuplicateSimilarName}
+	if len(c.Reasons) != len(want) {
+		t.Fatalf("reasons = %v, want %v", c.Reasons, want)
+	}
+	for i := range want {
+		if c.Reasons[i] != want[i] {
+			t.Errorf("reasons = %v, want %v", c.Reasons, want)
+		}
+	}
+}
+
+func TestFindDuplicatesMinScore(t *testing.T) {
+	dob := time.Date(1990, 5, 1, 0, 0, 0, 0, time.UTC)
+	employees := []models.Employee{
+		{ID: uuid.New(), EmployeeID: "E1", FirstName: "Jane", LastName: "Doe", DateOfBirth: dob},
+		{ID: uuid.New(), EmployeeID: "E2", FirstName: "Bob", LastName: "Smith", DateOfBirth: dob},
+	}
+	if candidates := findDuplicates(employees, DefaultDuplicateScore); len(candidates) != 0 {
+		t.Errorf("a shared date of birth alone was reported: %+v", candidates)
+	}
+	if candidates := findDuplicates(employees, dateOfBirthWeight); len(candidates) != 1 {
+		t.Errorf("found %d candidates at score %v, want 1", len(candidates), dateOfBirthWeight)
+	}
+}
@@ -0,0 +1,288 @@
+package employee
+
+import (
+	"database/sql"
+	"employee-management/internal/models"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrEmployeeIDTaken is returned when creating an employee with an employee ID already in use
+	ErrEmployeeIDTaken = errors.New("employee ID is already in use")
+	// ErrNumberTemplateNotFound is returned when an employee number template does not exist
+	ErrNumberTemplateNotFound = errors.New("employee number template not found")
+	// ErrNumberTemplateExists is returned when the department, or the default, already has a template
+	ErrNumberTemplateExists = errors.New("an employee number template already exists for this department")
+	// ErrInvalidNumberTemplate is returned for patterns employee IDs cannot be generated from
+	ErrInvalidNumberTemplate = errors.New("invalid employee number pattern")
+)
+
+// DefaultEmployeeNumberPattern generates employee IDs when no template applies
+const DefaultEmployeeNumberPattern = "EMP-{seq:5}"
+
+// maxEmployeeIDLength is the length of the employees.employee_id column
+const maxEmployeeIDLength = 50
+
+// maxNumberAttempts bounds how many sequence numbers are skipped because
+// their employee ID was entered by hand
+const maxNumberAttempts = 1000
+
+// numberPlaceholder matches the placeholders of an employee number pattern
+var numberPlaceholder = regexp.MustCompile(`\{([A-Za-z]+)(?::(\d+))?\}`)
+
+// employeeNumberTemplateColumns are the columns read for a template
+const employeeNumberTemplateColumns = `id, department_id, pattern, created_at, updated_at`
+
+// checkNumberPattern reports why employee IDs cannot be generated from a
+// pattern, if they cannot
+func checkNumberPattern(pattern string) error {
+	seqs := 0
+	var problem string
+	rest := numberPlaceholder.ReplaceAllStringFunc(pattern, func(placeholder string) string {
+		match := numberPlaceholder.FindStringSubmatch(placeholder)
+		switch match[1] {
+		case "seq":
+			seqs++
+			if match[2] != "" {
+				if width, _ := strconv.Atoi(match[2]); width < 1 || width > 18 {
+					problem = "the {seq} width must be between 1 and 18"
+				}
+			}
+		case "YYYY", "YY", "MM":
+			if match[2] != "" {
+				problem = fmt.Sprintf("{%s} takes no width", match[1])
+			}
+		default:
+			problem = fmt.Sprintf("unknown placeholder {%s}", match[1])
+		}
+		return ""
+	})
+	switch {
+	case problem != "":
+	case seqs != 1:
+		problem = "the pattern must contain {seq} exactly once"
+	case strings.ContainsAny(rest, "{}"):
+		problem = "braces are only allowed around placeholders"
+	case strings.TrimSpace(pattern) != pattern:
+		problem = "the pattern cannot start or end with spaces"
+	case len(renderNumber(pattern, time.Now(), 1)) > maxEmployeeIDLength:
+		problem = fmt.Sprintf("generated IDs would be longer than %d characters", maxEmployeeIDLength)
+	}
+	if problem != "" {
+		return fmt.Errorf("%w: %s", ErrInvalidNumberTemplate, problem)
+	}
+	return nil
+}
+
+// renderNumber fills in a checked pattern for an employee hired on date
+func renderNumber(pattern string, date time.Time, seq int64) string {
+	return expandNumber(pattern, date, func(width int) string {
+		return fmt.Sprintf("%0*d", width, seq)
+	})
+}
+
+// numberScope is the pattern with its date filled in, naming the sequence
+// shared by every employee ID it produces
+func numberScope(pattern string, date time.Time) string {
+	return expandNumber(pattern, date, func(int) string { return "{seq}" })
+}
+
+// expandNumber fills in the date placeholders of a pattern and replaces
+// {seq} with what seq returns for its width
+func expandNumber(pattern string, date time.Time, seq func(width int) string) string {
+	return numberPlaceholder.ReplaceAllStringFunc(pattern, func(placeholder string) string {
+		match := numberPlaceholder.FindStringSubmatch(placeholder)
+		switch match[1] {
+		case "YYYY":
+			return date.Format("2006")
+		case "YY":
+			return date.Format("06")
+		case "MM":
+			return date.Format("01")
+		}
+		width, _ := strconv.Atoi(match[2])
+		return seq(width)
+	})
+}
+
+// nextEmployeeNumber allocates the next employee ID from the template of the
+// department, or the default template. The sequence row stays locked until
+// the transaction ends, so concurrent hires get different numbers. Numbers
+// already taken by hand-entered IDs are skipped.
+func nextEmployeeNumber(tx *sql.Tx, departmentID *uuid.UUID, hireDate time.Time) (string, error) {
+	pattern := DefaultEmployeeNumberPattern
+	err := tx.QueryRow(`
+		SELECT pattern FROM employee_number_templates
+		WHERE department_id = $1 OR department_id IS NULL
+		ORDER BY department_id NULLS LAST LIMIT 1`, departmentID,
+	).Scan(&pattern)
+	if err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+
+	scope := numberScope(pattern, hireDate)
+	for attempt := 0; attempt < maxNumberAttempts; attempt++ {
+		var seq int64
+		err := tx.QueryRow(`
+			INSERT INTO employee_number_sequences (scope, last_value) VALUES ($1, 1)
+			ON CONFLICT (scope) DO UPDATE SET last_value = employee_number_sequences.last_value + 1, updated_at = NOW()
+			RETURNING last_value`, scope,
+		).Scan(&seq)
+		if err != nil {
+			return "", err
+		}
+
+		number := renderNumber(pattern, hireDate, seq)
+		var taken bool
+		if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM employees WHERE employee_id = $1)", number).Scan(&taken); err != nil {
+			return "", err
+		}
+		if !taken {
+			return number, nil
+		}
+	}
+	return "", fmt.Errorf("no free employee ID for pattern %q after %d attempts", pattern, maxNumberAttempts)
+}
+
+// scanNumberTemplate scans a row of employeeNumberTemplateColumns
+func scanNumberTemplate(row interface{ Scan(...interface{}) error }, t *models.EmployeeNumberTemplate) error {
+	if err := row.Scan(&t.ID, &t.DepartmentID, &t.Pattern, &t.CreatedAt, &t.UpdatedAt); err != nil {
+		return err
+	}
+	t.Example = renderNumber(t.Pattern, time.Now(), 1)
+	return nil
+}
+
+// ListNumberTemplates retrieves every employee number template, the default first
+func (r *repository) ListNumberTemplates(logger *logrus.Entry) ([]models.EmployeeNumberTemplate, error) {
+	startTime := time.Now()
+	query := `SELECT ` + employeeNumberTemplateColumns + ` FROM employee_number_templates ORDER BY department_id NULLS FIRST`
+	rows, err := r.db.Query(query)
+
+	logger.WithFields(logrus.Fields{
+		"query":    query,
+		"duration": time.Since(startTime),
+	}).Debug("Executed ListNumberTemplates query")
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := []models.EmployeeNumberTemplate{}
+	for rows.Next() {
+		var t models.EmployeeNumberTemplate
+		if err := scanNumberTemplate(rows, &t); err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
+
+// CreateNumberTemplate stores a new employee number template
+func (r *repository) CreateNumberTemplate(logger *logrus.Entry, t *models.EmployeeNumberTemplate) (*models.EmployeeNumberTemplate, error) {
+	startTime := time.Now()
+	var created models.EmployeeNumberTemplate
+	query := `INSERT INTO employee_number_templates (department_id, pattern) VALUES ($1, $2) RETURNING ` + employeeNumberTemplateColumns
+	err := scanNumberTemplate(r.db.QueryRow(query, t.DepartmentID, t.Pattern), &created)
+
+	logger.WithFields(logrus.Fields{
+		"query":    query,
+		"duration": time.Since(startTime),
+	}).Debug("Executed CreateNumberTemplate query")
+
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return nil, ErrNumberTemplateExists
+	}
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+		return nil, ErrInvalidReference
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// UpdateNumberTemplate changes the pattern of an employee number template
+func (r *repository) UpdateNumberTemplate(logger *logrus.Entry, id uuid.UUID, pattern string) (*models.EmployeeNumberTemplate, error) {
+	startTime := time.Now()
+	var updated models.EmployeeNumberTemplate
+	query := `UPDATE employee_number_templates SET pattern = $1, updated_at = NOW() WHERE id = $2 RETURNING ` + employeeNumberTemplateColumns
+	err := scanNumberTemplate(r.db.QueryRow(query, pattern, id), &updated)
+
+	logger.WithFields(logrus.Fields{
+		"query":    query,
+		"duration": time.Since(startTime),
+	}).Debug("Executed UpdateNumberTemplate query")
+
+	if err == sql.ErrNoRows {
+		return nil, ErrNumberTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// DeleteNumberTemplate deletes an employee number template. Sequences are
+// kept, so numbers are not reused if the pattern comes back.
+func (r *repository) DeleteNumberTemplate(logger *logrus.Entry, id uuid.UUID) error {
+	startTime := time.Now()
+	query := `DELETE FROM employee_number_templates WHERE id = $1`
+	result, err := r.db.Exec(query, id)
+
+	logger.WithFields(logrus.Fields{
+		"query":    query,
+		"duration": time.Since(startTime),
+	}).Debug("Executed DeleteNumberTemplate query")
+
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNumberTemplateNotFound
+	}
+	return nil
+}
+
+// ListNumberTemplates retrieves the employee number templates
+func (s *Service) ListNumberTemplates(logger *logrus.Entry) ([]models.EmployeeNumberTemplate, error) {
+	logger.Info("Listing employee number templates")
+	return s.repo.ListNumberTemplates(logger)
+}
+
+// CreateNumberTemplate creates an employee number template
+func (s *Service) CreateNumberTemplate(logger *logrus.Entry, data *models.EmployeeNumberTemplateCreate) (*models.EmployeeNumberTemplate, error) {
+	logger.WithField("pattern", data.Pattern).Info("Creating employee number template")
+	if err := checkNumberPattern(data.Pattern); err != nil {
+		return nil, err
+	}
+	return s.repo.CreateNumberTemplate(logger, &models.EmployeeNumberTemplate{DepartmentID: data.DepartmentID, Pattern: data.Pattern})
+}
+
+// UpdateNumberTemplate changes the pattern of an employee number template
+func (s *Service) UpdateNumberTemplate(logger *logrus.Entry, id uuid.UUID, data *models.EmployeeNumberTemplateUpdate) (*models.EmployeeNumberTemplate, error) {
+	logger.WithField("templateID", id).Info("Updating employee number template")
+	if err := checkNumberPattern(data.Pattern); err != nil {
+		return nil, err
+	}
+	return s.repo.UpdateNumberTemplate(logger, id, data.Pattern)
+}
+
+// DeleteNumberTemplate deletes an employee number template
+func (s *Service) DeleteNumberTemplate(logger *logrus.Entry, id uuid.UUID) error {
+	logger.WithField("templateID", id).Info("Deleting employee number template")
+	return s.repo.DeleteNumberTemplate(logger, id)
+}
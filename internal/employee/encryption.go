@@ -0,0 +1,481 @@
+package employee
+
+import (
+	"bytes"
+	"database/sql"
+	"employee-management/internal/fieldcrypt"
+	"employee-management/internal/models"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+// encryptedPrefix starts a JSON-encoded encrypted value
+var encryptedPrefix = []byte(`"enc:`)
+
+// storedEmployee holds the columns of an employee that may be encrypted, as
+// they are written to the database
+type storedEmployee struct {
+	DateOfBirth           interface{}
+	DateOfBirthEncrypted  interface{}
+	PhoneNumber           string
+	Email                 string
+	Address               string
+	EmergencyContactName  string
+	EmergencyContactPhone string
+	EmailIndex            []byte
+	PhoneIndex            []byte
+}
+
+// textFields returns the employee's text fields that may be encrypted
+func textFields(e *models.Employee) map[string]*string {
+	return map[string]*string{
+		fieldcrypt.FieldPhoneNumber:           &e.PhoneNumber,
+		fieldcrypt.FieldEmail:                 &e.Email,
+		fieldcrypt.FieldAddress:               &e.Address,
+		fieldcrypt.FieldEmergencyContactName:  &e.EmergencyContactName,
+		fieldcrypt.FieldEmergencyContactPhone: &e.EmergencyContactPhone,
+	}
+}
+
+// sealValue returns the stored form of a field value: its JSON encoding,
+// encrypted if the field is. The JSON form is shared by the employee row,
+// the versions of the record and change requests.
+func (r *repository) sealValue(field string, value interface{}) (string, error) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return r.cipher.Encrypt(field, raw)
+}
+
+// openValue decodes a stored field value into target if it is encrypted and
+// reports whether it was
+func (r *repository) openValue(field, stored string, target interface{}) (bool, error) {
+	if !r.cipher.Sealed(field, stored) {
+		return false, nil
+	}
+	return true, r.decryptValue(field, stored, target)
+}
+
+// decryptValue decodes an encrypted field value into target
+func (r *repository) decryptValue(field, stored string, target interface{}) error {
+	raw, err := r.cipher.Decrypt(field, stored)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, target)
+}
+
+// checkPlaintext refuses text fields that start like encrypted values. They
+// would be decrypted, and fail to, on every read of the employee.
+func checkPlaintext(e *models.Employee) error {
+	for field, value := range textFields(e) {
+		if err := fieldcrypt.CheckPlaintext(*value); err != nil {
+			return fmt.Errorf("%s: %w", field, err)
+		}
+	}
+	return nil
+}
+
+// sealEmployee encrypts the employee's fields that are configured to be
+// encrypted and computes the blind indexes
+func (r *repository) sealEmployee(e *models.Employee) (*storedEmployee, error) {
+	stored := &storedEmployee{
+		DateOfBirth:           e.DateOfBirth,
+		PhoneNumber:           e.PhoneNumber,
+		Email:                 e.Email,
+		Address:               e.Address,
+		EmergencyContactName:  e.EmergencyContactName,
+		EmergencyContactPhone: e.EmergencyContactPhone,
+		EmailIndex:            r.cipher.EmailIndex(e.Email),
+		PhoneIndex:            r.cipher.PhoneIndex(e.PhoneNumber),
+	}
+	if r.cipher.Encrypts(fieldcrypt.FieldDateOfBirth) {
+		sealed, err := r.sealValue(fieldcrypt.FieldDateOfBirth, e.DateOfBirth)
+		if err != nil {
+			return nil, err
+		}
+		stored.DateOfBirth, stored.DateOfBirthEncrypted = nil, sealed
+	}
+
+	columns := map[string]*string{
+		fieldcrypt.FieldPhoneNumber:           &stored.PhoneNumber,
+		fieldcrypt.FieldEmail:                 &stored.Email,
+		fieldcrypt.FieldAddress:               &stored.Address,
+		fieldcrypt.FieldEmergencyContactName:  &stored.EmergencyContactName,
+		fieldcrypt.FieldEmergencyContactPhone: &stored.EmergencyContactPhone,
+	}
+	for field, column := range columns {
+		if !r.cipher.Encrypts(field) {
+			continue
+		}
+		sealed, err := r.sealValue(field, *column)
+		if err != nil {
+			return nil, err
+		}
+		*column = sealed
+	}
+	return stored, nil
+}
+
+// openEmployee decrypts the encrypted fields of a scanned employee
+func (r *repository) openEmployee(e *models.Employee, dateOfBirthEncrypted sql.NullString) error {
+	// The separate column says the date of birth is encrypted
+	if dateOfBirthEncrypted.Valid {
+		if err := r.decryptValue(fieldcrypt.FieldDateOfBirth, dateOfBirthEncrypted.String, &e.DateOfBirth); err != nil {
+			return err
+		}
+	}
+	for field, value := range textFields(e) {
+		if _, err := r.openValue(field, *value, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sealFields encrypts the values of a version's fields that are configured
+// to be encrypted
+func (r *repository) sealFields(data map[string]json.RawMessage) (map[string]json.RawMessage, error) {
+	sealed := make(map[string]json.RawMessage, len(data))
+	for field, value := range data {
+		sealed[field] = value
+		if !r.cipher.Encrypts(field) {
+			continue
+		}
+		encrypted, err := r.cipher.Encrypt(field, value)
+		if err != nil {
+			return nil, err
+		}
+		if sealed[field], err = json.Marshal(encrypted); err != nil {
+			return nil, err
+		}
+	}
+	return sealed, nil
+}
+
+// openFields decrypts the encrypted values of a version's fields in place
+func (r *repository) openFields(data map[string]json.RawMessage) error {
+	for field, value := range data {
+		if !bytes.HasPrefix(value, encryptedPrefix) {
+			continue
+		}
+		var stored string
+		if err := json.Unmarshal(value, &stored); err != nil {
+			return err
+		}
+		if !r.cipher.Sealed(field, stored) {
+			continue
+		}
+		raw, err := r.cipher.Decrypt(field, stored)
+		if err != nil {
+			return err
+		}
+		data[field] = raw
+	}
+	return nil
+}
+
+// sealChanges encrypts the values of a change request that are configured
+// to be encrypted
+func (r *repository) sealChanges(changes map[string]string) (map[string]string, error) {
+	sealed := make(map[string]string, len(changes))
+	for field, value := range changes {
+		sealed[field] = value
+		if !r.cipher.Encrypts(field) {
+			continue
+		}
+		var err error
+		if sealed[field], err = r.sealValue(field, value); err != nil {
+			return nil, err
+		}
+	}
+	return sealed, nil
+}
+
+// openChanges decrypts the encrypted values of a change request in place
+func (r *repository) openChanges(changes map[string]string) error {
+	for field, value := range changes {
+		var plain string
+		encrypted, err := r.openValue(field, value, &plain)
+		if err != nil {
+			return err
+		}
+		if encrypted {
+			changes[field] = plain
+		}
+	}
+	return nil
+}
+
+// currentFields reports whether a version's data or a change request's
+// values are stored as configured now
+func (r *repository) currentFields(data map[string]string) bool {
+	for field, value := range data {
+		if !r.cipher.Current(field, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// storedForm returns a stored JSON value as the string checked by
+// Cipher.Current: the encrypted value, or the raw JSON if not encrypted
+func storedForm(value json.RawMessage) string {
+	var stored string
+	if bytes.HasPrefix(value, encryptedPrefix) && json.Unmarshal(value, &stored) == nil {
+		return stored
+	}
+	return string(value)
+}
+
+// ReencryptResult counts the records rewritten by ReencryptEmployees
+type ReencryptResult struct {
+	Employees      int `json:"employees"`
+	Versions       int `json:"versions"`
+	ChangeRequests int `json:"change_requests"`
+}
+
+// ReencryptEmployees rewrites every employee record, version and change
+// request not stored as currently configured: fields that should be
+// encrypted but are not, or are encrypted under an old master key, fields
+// that should no longer be encrypted, and outdated blind indexes. Records
+// are processed in batches, each in its own transaction.
+func (r *repository) ReencryptEmployees(logger *logrus.Entry, batchSize int) (*ReencryptResult, error) {
+	startTime := time.Now()
+	result := &ReencryptResult{}
+
+	tables := []struct {
+		table     string
+		reencrypt func(tx *sql.Tx, ids []uuid.UUID) (int, error)
+		count     *int
+	}{
+		{"employees", r.reencryptEmployees, &result.Employees},
+		{"employee_versions", r.reencryptVersions, &result.Versions},
+		{"employee_change_requests", r.reencryptChangeRequests, &result.ChangeRequests},
+	}
+	for _, t := range tables {
+		after := uuid.Nil
+		for {
+			var ids []uuid.UUID
+			err := r.withTx(func(tx *sql.Tx) error {
+				rows, err := tx.Query("SELECT id FROM "+t.table+" WHERE id > $1 ORDER BY id LIMIT $2 FOR UPDATE", after, batchSize)
+				if err != nil {
+					return err
+				}
+				for rows.Next() {
+					var id uuid.UUID
+					if err := rows.Scan(&id); err != nil {
+						rows.Close()
+						return err
+					}
+					ids = append(ids, id)
+				}
+				rows.Close()
+				if err := rows.Err(); err != nil || len(ids) == 0 {
+					return err
+				}
+
+				rewritten, err := t.reencrypt(tx, ids)
+				*t.count += rewritten
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+			if len(ids) < batchSize {
+				break
+			}
+			after = ids[len(ids)-1]
+		}
+	}
+
+	logger.WithFields(logrus.Fields{
+		"employees":       result.Employees,
+		"versions":        result.Versions,
+		"change_requests": result.ChangeRequests,
+		"duration":        time.Since(startTime),
+	}).Debug("Executed ReencryptEmployees transactions")
+
+	return result, nil
+}
+
+func (r *repository) reencryptEmployees(tx *sql.Tx, ids []uuid.UUID) (int, error) {
+	rows, err := tx.Query(`
+		SELECT `+employeeColumns+`, email_index, phone_number_index
+		FROM employees WHERE id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return 0, err
+	}
+	type stale struct {
+		employee *models.Employee
+		stored   *storedEmployee
+	}
+	var rewrite []stale
+	for rows.Next() {
+		var e models.Employee
+		var dateOfBirth sql.NullTime
+		var dateOfBirthEncrypted sql.NullString
+		var emailIndex, phoneIndex []byte
+		err := rows.Scan(append(employeeFields(&e, &dateOfBirth, &dateOfBirthEncrypted), &emailIndex, &phoneIndex)...)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+
+		current := dateOfBirthEncrypted.Valid == r.cipher.Encrypts(fieldcrypt.FieldDateOfBirth) &&
+			(!dateOfBirthEncrypted.Valid || r.cipher.Current(fieldcrypt.FieldDateOfBirth, dateOfBirthEncrypted.String))
+		for field, value := range textFields(&e) {
+			current = current && r.cipher.Current(field, *value)
+		}
+
+		e.DateOfBirth = dateOfBirth.Time
+		if err := r.openEmployee(&e, dateOfBirthEncrypted); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		current = current && bytes.Equal(emailIndex, r.cipher.EmailIndex(e.Email)) && bytes.Equal(phoneIndex, r.cipher.PhoneIndex(e.PhoneNumber))
+		if current {
+			continue
+		}
+		stored, err := r.sealEmployee(&e)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		rewrite = append(rewrite, stale{&e, stored})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, s := range rewrite {
+		_, err := tx.Exec(`
+			UPDATE employees
+			SET date_of_birth = $1, date_of_birth_encrypted = $2, phone_number = $3, email = $4, address = $5,
+			    emergency_contact_name = $6, emergency_contact_phone = $7, email_index = $8, phone_number_index = $9
+			WHERE id = $10`,
+			s.stored.DateOfBirth, s.stored.DateOfBirthEncrypted, s.stored.PhoneNumber, s.stored.Email, s.stored.Address,
+			s.stored.EmergencyContactName, s.stored.EmergencyContactPhone, s.stored.EmailIndex, s.stored.PhoneIndex, s.employee.ID)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(rewrite), nil
+}
+
+func (r *repository) reencryptVersions(tx *sql.Tx, ids []uuid.UUID) (int, error) {
+	rows, err := tx.Query("SELECT id, data FROM employee_versions WHERE id = ANY($1)", pq.Array(ids))
+	if err != nil {
+		return 0, err
+	}
+	rewrite := map[uuid.UUID][]byte{}
+	for rows.Next() {
+		var id uuid.UUID
+		var raw []byte
+		var data map[string]json.RawMessage
+		if err := rows.Scan(&id, &raw); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if err := json.Unmarshal(raw, &data); err != nil {
+			rows.Close()
+			return 0, err
+		}
+
+		stored := map[string]string{}
+		for field, value := range data {
+			stored[field] = storedForm(value)
+		}
+		if r.currentFields(stored) {
+			continue
+		}
+		if err := r.openFields(data); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		sealed, err := r.sealFields(data)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if rewrite[id], err = json.Marshal(sealed); err != nil {
+			rows.Close()
+			return 0, err
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	// Only the storage of the data changes, so this does not count as a
+	// correction superseding the version
+	for id, data := range rewrite {
+		if _, err := tx.Exec("UPDATE employee_versions SET data = $1 WHERE id = $2", data, id); err != nil {
+			return 0, err
+		}
+	}
+	return len(rewrite), nil
+}
+
+func (r *repository) reencryptChangeRequests(tx *sql.Tx, ids []uuid.UUID) (int, error) {
+	rows, err := tx.Query("SELECT id, changes FROM employee_change_requests WHERE id = ANY($1)", pq.Array(ids))
+	if err != nil {
+		return 0, err
+	}
+	rewrite := map[uuid.UUID][]byte{}
+	for rows.Next() {
+		var id uuid.UUID
+		var raw []byte
+		var changes map[string]string
+		if err := rows.Scan(&id, &raw); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if err := json.Unmarshal(raw, &changes); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if r.currentFields(changes) {
+			continue
+		}
+		if err := r.openChanges(changes); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		sealed, err := r.sealChanges(changes)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if rewrite[id], err = json.Marshal(sealed); err != nil {
+			rows.Close()
+			return 0, err
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for id, changes := range rewrite {
+		if _, err := tx.Exec("UPDATE employee_change_requests SET changes = $1 WHERE id = $2", changes, id); err != nil {
+			return 0, err
+		}
+	}
+	return len(rewrite), nil
+}
+
+// ReencryptEmployees brings the storage of employee records in line with the
+// field encryption settings
+func (s *Service) ReencryptEmployees(logger *logrus.Entry, batchSize int) (*ReencryptResult, error) {
+	logger.WithField("batchSize", batchSize).Info("Re-encrypting employee records")
+	return s.repo.ReencryptEmployees(logger, batchSize)
+}
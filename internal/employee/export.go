@@ -0,0 +1,91 @@
+package employee
+
+import (
+	"strings"
+
+	"employee-management/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// exportColumns are the built-in columns of an export. They are named the way
+// the import expects, so an export can be edited and imported again.
+var exportColumns = []string{
+	"employee_id", "first_name", "last_name", "date_of_birth", "gender", "marital_status", "phone_number", "email",
+	"address", "emergency_contact_name", "emergency_contact_phone", "hire_date", "employment_status",
+	"department_id", "position_id", "manager_id", "user_id",
+}
+
+// ExportEmployees retrieves every employee matching the filter, ignoring its
+// limit and cursor
+func (s *Service) ExportEmployees(logger *logrus.Entry, filter *SearchFilter) ([]models.Employee, error) {
+	logger.WithField("query", filter.Query).Info("Exporting employees")
+	page := *filter
+	page.Limit = maxPageSize
+	page.Cursor = ""
+
+	employees := []models.Employee{}
+	for {
+		result, err := s.repo.SearchEmployees(logger, &page)
+		if err != nil {
+			return nil, err
+		}
+		employees = append(employees, result.Employees...)
+		if result.NextCursor == "" {
+			return employees, nil
+		}
+		page.Cursor = result.NextCursor
+	}
+}
+
+// exportHeader returns the header row of an export with the given custom fields
+func exportHeader(definitions []models.CustomFieldDefinition) []string {
+	header := append([]string{}, exportColumns...)
+	for _, d := range definitions {
+		header = append(header, d.Key)
+	}
+	return header
+}
+
+// exportRow returns the values of exportHeader for an employee
+func exportRow(e *models.Employee, definitions []models.CustomFieldDefinition) []string {
+	id := func(value *uuid.UUID) string {
+		if value == nil {
+			return ""
+		}
+		return value.String()
+	}
+	row := []string{
+		e.EmployeeID, e.FirstName, e.LastName, e.DateOfBirth.Format("2006-01-02"), e.Gender, e.MaritalStatus, e.PhoneNumber, e.Email,
+		e.Address, e.EmergencyContactName, e.EmergencyContactPhone, e.HireDate.Format("2006-01-02"), e.EmploymentStatus,
+		id(e.DepartmentID), id(e.PositionID), id(e.ManagerID), id(e.UserID),
+	}
+	for _, d := range definitions {
+		row = append(row, formatCustomFieldValue(e.CustomFields[d.Key]))
+	}
+	for i := range row {
+		row[i] = escapeFormula(row[i])
+	}
+	return row
+}
+
+// formulaPrefixes are the characters spreadsheet programs start a formula with
+const formulaPrefixes = "=+-@\t\r"
+
+// escapeFormula keeps spreadsheet programs from evaluating a cell as a
+// formula by prefixing those that start like one with a quote
+func escapeFormula(value string) string {
+	if value != "" && strings.ContainsRune(formulaPrefixes, rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// unescapeFormula reverses escapeFormula, so exports can be imported again
+func unescapeFormula(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune(formulaPrefixes, rune(value[1])) {
+		return value[1:]
+	}
+	return value
+}
@@ -1,8 +1,18 @@
 package employee
 
 import (
+	"employee-management/internal/auth"
+	"employee-management/internal/fieldcrypt"
+	"employee-management/internal/middleware"
 	"employee-management/internal/models"
+	"employee-management/internal/spreadsheet"
+	"encoding/csv"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -23,13 +33,14 @@ func NewHandler(service *Service) *Handler {
 
 // CreateEmployee handles the creation of a new employee
 // @Summary Create a new employee
-// @Description Create a new employee with the provided data
+// @Description Create a new employee with the provided data. Without an employee_id, one is generated from the employee number template of the department.
 // @Tags Employees
 // @Accept json
 // @Produce json
 // @Param employee body models.EmployeeCreate true "Employee data"
 // @Success 201 {object} models.Employee
 // @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /employees [post]
 func (h *Handler) CreateEmployee(c *gin.Context) {
@@ -40,13 +51,27 @@ func (h *Handler) CreateEmployee(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	employeeData.RecordedBy = currentUserID(c)
 
 	employee, err := h.service.CreateEmployee(logger, &employeeData)
+	if errors.Is(err, ErrInvalidCustomFieldValue) || errors.Is(err, fieldcrypt.ErrReservedPrefix) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, ErrEmployeeIDTaken) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		logger.WithError(err).Error("Failed to create employee")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if err := h.restrict(c, logger, employee); err != nil {
+		logger.WithError(err).Error("Failed to load custom fields")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
 	c.JSON(http.StatusCreated, employee)
 }
@@ -57,6 +82,8 @@ func (h *Handler) CreateEmployee(c *gin.Context) {
 // @Tags Employees
 // @Produce json
 // @Param id path string true "Employee ID"
+// @Param as_of query string false "Reconstruct the record as it was on this date (YYYY-MM-DD)"
+// @Param known_at query string false "With as_of, use what was known at this time (RFC 3339)"
 // @Success 200 {object} models.Employee
 // @Failure 404 {object} map[string]string
 // @Router /employees/{id} [get]
@@ -69,6 +96,26 @@ func (h *Handler) GetEmployee(c *gin.Context) {
 		return
 	}
 
+	filter, err := parseHistoryFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if filter.AsOf != nil {
+		employee, err := h.service.GetEmployeeAsOf(logger, id, *filter.AsOf, filter.KnownAt)
+		if err != nil {
+			historyError(c, logger, err)
+			return
+		}
+		if err := h.restrict(c, logger, employee); err != nil {
+			logger.WithError(err).Error("Failed to load custom fields")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, employee)
+		return
+	}
+
 	employee, err := h.service.GetEmployeeByID(logger, id)
 	if err != nil {
 		logger.WithError(err).Error("Failed to get employee by ID")
@@ -76,6 +123,12 @@ func (h *Handler) GetEmployee(c *gin.Context) {
 		return
 	}
 
+	if err := h.restrict(c, logger, employee); err != nil {
+		logger.WithError(err).Error("Failed to load custom fields")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	c.JSON(http.StatusOK, employee)
 }
 
@@ -108,25 +161,42 @@ func (h *Handler) UpdateEmployee(c *gin.Context) {
 		return
 	}
 
+	employeeData.RecordedBy = currentUserID(c)
+
 	employee, err := h.service.UpdateEmployee(logger, id, &employeeData)
+	if errors.Is(err, ErrEmployeeNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, ErrEffectiveDateInFuture) || errors.Is(err, ErrBeforeFirstVersion) || errors.Is(err, ErrInvalidCustomFieldValue) ||
+		errors.Is(err, fieldcrypt.ErrReservedPrefix) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		logger.WithError(err).Error("Failed to update employee")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if err := h.restrict(c, logger, employee); err != nil {
+		logger.WithError(err).Error("Failed to load custom fields")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
 	c.JSON(http.StatusOK, employee)
 }
 
 // DeleteEmployee handles deleting an employee by their ID
 // @Summary Delete an employee
-// @Description Delete an employee by their ID
+// @Description Mark an employee as deleted and deactivate their user account. Their records are kept and can be restored until the retention period anonymizes them.
 // @Tags Employees
 // @Produce json
 // @Param id path string true "Employee ID"
 // @Success 204 "No Content"
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Router /employees/{id} [delete]
 func (h *Handler) DeleteEmployee(c *gin.Context) {
 	logger := c.MustGet("logger").(*logrus.Entry)
@@ -137,19 +207,79 @@ func (h *Handler) DeleteEmployee(c *gin.Context) {
 		return
 	}
 
-	err = h.service.DeleteEmployee(logger, id)
+	err = h.service.DeleteEmployee(logger, id, currentUserID(c))
 	if err != nil {
-		logger.WithError(err).Error("Failed to delete employee")
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		archiveError(c, logger, err)
 		return
 	}
 
 	c.Status(http.StatusNoContent)
 }
 
+// RestoreEmployee handles restoring a deleted employee
+// @Summary Restore a deleted employee
+// @Description Undo the deletion of an employee who has not been anonymized. Their user account stays deactivated.
+// @Tags Employees
+// @Produce json
+// @Param id path string true "Employee ID"
+// @Success 200 {object} models.Employee
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /employees/{id}/restore [post]
+func (h *Handler) RestoreEmployee(c *gin.Context) {
+	logger := c.MustGet("logger").(*logrus.Entry)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		logger.WithError(err).Warn("Invalid ID format")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid employee ID"})
+		return
+	}
+
+	employee, err := h.service.RestoreEmployee(logger, id)
+	if err != nil {
+		archiveError(c, logger, err)
+		return
+	}
+	if err := h.restrict(c, logger, employee); err != nil {
+		logger.WithError(err).Error("Failed to load custom fields")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, employee)
+}
+
+// ListDeletedEmployees handles listing deleted employees
+// @Summary List deleted employees
+// @Description Get a page of deleted employees. Accepts the same parameters as /employees/search.
+// @Tags Employees
+// @Produce json
+// @Success 200 {object} EmployeePage
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /employees/deleted [get]
+func (h *Handler) ListDeletedEmployees(c *gin.Context) {
+	h.searchEmployees(c, true)
+}
+
+// archiveError maps deletion and restore errors to HTTP responses
+func archiveError(c *gin.Context, logger *logrus.Entry, err error) {
+	switch {
+	case errors.Is(err, ErrEmployeeNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrEmployeeInUse), errors.Is(err, ErrEmployeeNotDeleted), errors.Is(err, ErrEmployeeAnonymized),
+		errors.Is(err, ErrManagerDeleted), errors.Is(err, auth.ErrLastAdmin):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		logger.WithError(err).Error("Failed to delete or restore employee")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
 // ListEmployees handles listing all employees
 // @Summary List all employees
-// @Description Get a list of all employees
+// @Description Get a list of all employees. Use /employees/search for filters, sorting and pages.
 // @Tags Employees
 // @Produce json
 // @Success 200 {array} models.Employee
@@ -164,5 +294,1213 @@ func (h *Handler) ListEmployees(c *gin.Context) {
 		return
 	}
 
+	restricted := make([]*models.Employee, len(employees))
+	for i := range employees {
+		restricted[i] = &employees[i]
+	}
+	if err := h.restrict(c, logger, restricted...); err != nil {
+		logger.WithError(err).Error("Failed to load custom fields")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	c.JSON(http.StatusOK, employees)
 }
+
+// SearchEmployees handles searching employees
+// @Summary Search employees
+// @Description Full-text search over name, email and employee ID with filters, sorting and cursor pagination
+// @Tags Employees
+// @Produce json
+// @Param q query string false "Search text"
+// @Param email query string false "Exact email address"
+// @Param phone query string false "Exact phone number, in any format (HR and admins only)"
+// @Param department_id query string false "Department ID"
+// @Param position_id query string false "Position ID"
+// @Param manager_id query string false "Manager ID"
+// @Param employment_status query string false "Comma separated employment statuses"
+// @Param hired_from query string false "Earliest hire date (YYYY-MM-DD)"
+// @Param hired_to query string false "Latest hire date (YYYY-MM-DD)"
+// @Param custom_fields[key] query string false "Custom field value, for example custom_fields[shirt_size]=L"
+// @Param sort query string false "last_name, first_name, email, employee_id or hire_date; prefix with - for descending"
+// @Param limit query int false "Page size (max 100)"
+// @Param cursor query string false "next_cursor from the previous page"
+// @Success 200 {object} EmployeePage
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /employees/search [get]
+func (h *Handler) SearchEmployees(c *gin.Context) {
+	h.searchEmployees(c, false)
+}
+
+func (h *Handler) searchEmployees(c *gin.Context, deleted bool) {
+	logger := c.MustGet("logger").(*logrus.Entry)
+	filter, err := parseSearchFilter(c)
+	if err != nil {
+		logger.WithError(err).Warn("Invalid search parameters")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter.Deleted = deleted
+	// Masked details cannot be searched for either
+	if filter.Phone != "" && !canSeeMaskedFields(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only HR and admins can filter by phone number"})
+		return
+	}
+	if err := h.customFieldFilter(c, logger, filter); err != nil {
+		customFieldError(c, logger, err)
+		return
+	}
+
+	page, err := h.service.SearchEmployees(logger, filter)
+	if err != nil {
+		if errors.Is(err, ErrInvalidCursor) || errors.Is(err, ErrInvalidSort) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.WithError(err).Error("Failed to search employees")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	employees := make([]*models.Employee, len(page.Employees))
+	for i := range page.Employees {
+		employees[i] = &page.Employees[i]
+	}
+	if err := h.restrict(c, logger, employees...); err != nil {
+		logger.WithError(err).Error("Failed to load custom fields")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// customFieldFilter reads custom_fields[key]=value parameters into the
+// filter. Only fields the current user can see can be filtered on.
+func (h *Handler) customFieldFilter(c *gin.Context, logger *logrus.Entry, filter *SearchFilter) error {
+	values := c.QueryMap("custom_fields")
+	if len(values) == 0 {
+		return nil
+	}
+	definitions, err := h.service.VisibleCustomFields(logger, middleware.CurrentRole(c))
+	if err != nil {
+		return err
+	}
+	byKey := map[string]*models.CustomFieldDefinition{}
+	for i := range definitions {
+		byKey[definitions[i].Key] = &definitions[i]
+	}
+
+	filter.CustomFields = models.CustomFields{}
+	for key, text := range values {
+		d, ok := byKey[key]
+		if !ok {
+			return fmt.Errorf("%w: unknown custom field %q", ErrInvalidCustomFieldValue, key)
+		}
+		value, problem := parseCustomFieldValue(d, text)
+		if problem != "" {
+			return fmt.Errorf("%w: %s %s", ErrInvalidCustomFieldValue, key, problem)
+		}
+		filter.CustomFields[key] = value
+	}
+	return nil
+}
+
+// parseSearchFilter reads a SearchFilter from the query string
+func parseSearchFilter(c *gin.Context) (*SearchFilter, error) {
+	filter := &SearchFilter{
+		Query:  c.Query("q"),
+		Email:  c.Query("email"),
+		Phone:  c.Query("phone"),
+		Sort:   c.Query("sort"),
+		Cursor: c.Query("cursor"),
+	}
+
+	for name, target := range map[string]**uuid.UUID{
+		"department_id": &filter.DepartmentID,
+		"position_id":   &filter.PositionID,
+		"manager_id":    &filter.ManagerID,
+	} {
+		if value := c.Query(name); value != "" {
+			id, err := uuid.Parse(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s", name)
+			}
+			*target = &id
+		}
+	}
+
+	for name, target := range map[string]**time.Time{
+		"hired_from": &filter.HiredFrom,
+		"hired_to":   &filter.HiredTo,
+	} {
+		if value := c.Query(name); value != "" {
+			date, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s, expected YYYY-MM-DD", name)
+			}
+			*target = &date
+		}
+	}
+
+	if statuses := c.Query("employment_status"); statuses != "" {
+		for _, status := range strings.Split(statuses, ",") {
+			switch status = strings.TrimSpace(status); status {
+			case "active", "inactive", "terminated":
+				filter.EmploymentStatus = append(filter.EmploymentStatus, status)
+			default:
+				return nil, fmt.Errorf("invalid employment_status %q", status)
+			}
+		}
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxPageSize {
+			return nil, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+		}
+		filter.Limit = n
+	}
+
+	return filter, nil
+}
+
+// TransferEmployee handles moving an employee to another department
+// @Summary Transfer an employee
+// @Tags Employees
+// @Accept json
+// @Produce json
+// @Param id path string true "Employee ID"
+// @Param transfer body models.EmployeeTransfer true "Transfer details"
+// @Success 201 {object} models.JobHistory
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /employees/{id}/transfer [post]
+func (h *Handler) TransferEmployee(c *gin.Context) {
+	var data models.EmployeeTransfer
+	h.recordJobEvent(c, &data, func(logger *logrus.Entry, id uuid.UUID, recordedBy *uuid.UUID) (*models.JobHistory, error) {
+		return h.service.TransferEmployee(logger, id, &data, recordedBy)
+	})
+}
+
+// ChangePosition handles moving an employee to another position
+// @Summary Change an employee's position
+// @Tags Employees
+// @Accept json
+// @Produce json
+// @Param id path string true "Employee ID"
+// @Param change body models.EmployeePositionChange true "New position"
+// @Success 201 {object} models.JobHistory
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /employees/{id}/position [post]
+func (h *Handler) ChangePosition(c *gin.Context) {
+	var data models.EmployeePositionChange
+	h.recordJobEvent(c, &data, func(logger *logrus.Entry, id uuid.UUID, recordedBy *uuid.UUID) (*models.JobHistory, error) {
+		return h.service.ChangePosition(logger, id, &data, recordedBy)
+	})
+}
+
+// PromoteEmployee handles promoting an employee
+// @Summary Promote an employee
+// @Tags Employees
+// @Accept json
+// @Produce json
+// @Param id path string true "Employee ID"
+// @Param promotion body models.EmployeePositionChange true "New position"
+// @Success 201 {object} models.JobHistory
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /employees/{id}/promote [post]
+func (h *Handler) PromoteEmployee(c *gin.Context) {
+	var data models.EmployeePositionChange
+	h.recordJobEvent(c, &data, func(logger *logrus.Entry, id uuid.UUID, recordedBy *uuid.UUID) (*models.JobHistory, error) {
+		return h.service.PromoteEmployee(logger, id, &data, recordedBy)
+	})
+}
+
+// SuspendEmployee handles suspending an employee
+// @Summary Suspend an employee
+// @Tags Employees
+// @Accept json
+// @Produce json
+// @Param id path string true "Employee ID"
+// @Param suspension body models.EmployeeStatusChange true "Suspension details"
+// @Success 201 {object} models.JobHistory
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /employees/{id}/suspend [post]
+func (h *Handler) SuspendEmployee(c *gin.Context) {
+	var data models.EmployeeStatusChange
+	h.recordJobEvent(c, &data, func(logger *logrus.Entry, id uuid.UUID, recordedBy *uuid.UUID) (*models.JobHistory, error) {
+		return h.service.SuspendEmployee(logger, id, &data, recordedBy)
+	})
+}
+
+// ReinstateEmployee handles ending an employee's suspension
+// @Summary Reinstate a suspended employee
+// @Tags Employees
+// @Accept json
+// @Produce json
+// @Param id path string true "Employee ID"
+// @Param reinstatement body models.EmployeeStatusChange true "Reinstatement details"
+// @Success 201 {object} models.JobHistory
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /employees/{id}/reinstate [post]
+func (h *Handler) ReinstateEmployee(c *gin.Context) {
+	var data models.EmployeeStatusChange
+	h.recordJobEvent(c, &data, func(logger *logrus.Entry, id uuid.UUID, recordedBy *uuid.UUID) (*models.JobHistory, error) {
+		return h.service.ReinstateEmployee(logger, id, &data, recordedBy)
+	})
+}
+
+// TerminateEmployee handles terminating an employee
+// @Summary Terminate an employee
+// @Description The termination takes effect the day after the last working day. A last working day in the future schedules it.
+// @Tags Employees
+// @Accept json
+// @Produce json
+// @Param id path string true "Employee ID"
+// @Param termination body models.EmployeeTermination true "Termination details"
+// @Success 201 {object} models.JobHistory
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /employees/{id}/terminate [post]
+func (h *Handler) TerminateEmployee(c *gin.Context) {
+	var data models.EmployeeTermination
+	h.recordJobEvent(c, &data, func(logger *logrus.Entry, id uuid.UUID, recordedBy *uuid.UUID) (*models.JobHistory, error) {
+		return h.service.TerminateEmployee(logger, id, &data, recordedBy)
+	})
+}
+
+// CancelTermination handles withdrawing a scheduled termination
+// @Summary Cancel a scheduled termination
+// @Tags Employees
+// @Param id path string true "Employee ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /employees/{id}/termination [delete]
+func (h *Handler) CancelTermination(c *gin.Context) {
+	logger := c.MustGet("logger").(*logrus.Entry)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		logger.WithError(err).Warn("Invalid ID format")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid employee ID"})
+		return
+	}
+
+	if err := h.service.CancelTermination(logger, id, currentUserID(c)); err != nil {
+		jobEventError(c, logger, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RehireEmployee handles rehiring a terminated employee
+// @Summary Rehire an employee
+// @Tags Employees
+// @Accept json
+// @Produce json
+// @Param id path string true "Employee ID"
+// @Param rehire body models.EmployeeRehire true "Rehire details"
+// @Success 201 {object} models.JobHistory
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /employees/{id}/rehire [post]
+func (h *Handler) RehireEmployee(c *gin.Context) {
+	var data models.EmployeeRehire
+	h.recordJobEvent(c, &data, func(logger *logrus.Entry, id uuid.UUID, recordedBy *uuid.UUID) (*models.JobHistory, error) {
+		return h.service.RehireEmployee(logger, id, &data, recordedBy)
+	})
+}
+
+// GetJobHistory handles listing an employee's job history
+// @Summary Get an employee's job history
+// @Description Without a date, lists every job history entry oldest first. With date, returns the entry in effect on that day.
+// @Tags Employees
+// @Produce json
+// @Param id path string true "Employee ID"
+// @Param date query string false "Date (YYYY-MM-DD)"
+// @Success 200 {array} models.JobHistory
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /employees/{id}/job-history [get]
+func (h *Handler) GetJobHistory(c *gin.Context) {
+	logger := c.MustGet("logger").(*logrus.Entry)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		logger.WithError(err).Warn("Invalid ID format")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid employee ID"})
+		return
+	}
+
+	if raw := c.Query("date"); raw != "" {
+		date, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date, expected YYYY-MM-DD"})
+			return
+		}
+		entry, err := h.service.GetJobAsOf(logger, id, date)
+		if err != nil {
+			jobEventError(c, logger, err)
+			return
+		}
+		c.JSON(http.StatusOK, entry)
+		return
+	}
+
+	history, err := h.service.GetJobHistory(logger, id)
+	if err != nil {
+		jobEventError(c, logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// recordJobEvent binds a lifecycle request into data and records the event
+func (h *Handler) recordJobEvent(c *gin.Context, data interface{}, record func(logger *logrus.Entry, id uuid.UUID, recordedBy *uuid.UUID) (*models.JobHistory, error)) {
+	logger := c.MustGet("logger").(*logrus.Entry)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		logger.WithError(err).Warn("Invalid ID format")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid employee ID"})
+		return
+	}
+
+	if err := c.ShouldBindJSON(data); err != nil {
+		logger.WithError(err).Warn("Failed to bind JSON for job event")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry, err := record(logger, id, currentUserID(c))
+	if err != nil {
+		jobEventError(c, logger, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, entry)
+}
+
+// jobEventError maps lifecycle errors to HTTP responses
+func jobEventError(c *gin.Context, logger *logrus.Entry, err error) {
+	switch {
+	case errors.Is(err, ErrEmployeeNotFound), errors.Is(err, ErrNoJobOnDate), errors.Is(err, ErrNoScheduledTermination):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrInvalidTransition), errors.Is(err, ErrEffectiveDateTooEarly), errors.Is(err, ErrTerminationScheduled),
+		errors.Is(err, auth.ErrLastAdmin):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrPositionNotFound), errors.Is(err, ErrInvalidReference), errors.Is(err, ErrNotAPromotion),
+		errors.Is(err, ErrPositionDepartmentMismatch), errors.Is(err, ErrInvalidManager), errors.Is(err, ErrManagerCycle), errors.Is(err, ErrEffectiveDateInFuture):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		logger.WithError(err).Error("Failed to record job event")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record job event"})
+	}
+}
+
+// currentUserID returns the authenticated user's ID, or nil if there is none
+func currentUserID(c *gin.Context) *uuid.UUID {
+	id, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		return nil
+	}
+	return &id
+}
+
+// GetEmployeeHistory handles listing the versions of an employee record
+// @Summary Get the history of an employee record
+// @Description Lists the versions of the record with the dates each was valid. known_at shows the history as it was recorded at that time; include_superseded also lists versions that were later corrected.
+// @Tags Employees
+// @Produce json
+// @Param id path string true "Employee ID"
+// @Param as_of query string false "Only the version valid on this date (YYYY-MM-DD)"
+// @Param known_at query string false "History as known at this time (RFC 3339)"
+// @Param include_superseded query bool false "Include corrected versions"
+// @Success 200 {array} models.EmployeeVersion
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /employees/{id}/history [get]
+func (h *Handler) GetEmployeeHistory(c *gin.Context) {
+	logger := c.MustGet("logger").(*logrus.Entry)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		logger.WithError(err).Warn("Invalid ID format")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid employee ID"})
+		return
+	}
+
+	filter, err := parseHistoryFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	versions, err := h.service.GetEmployeeHistory(logger, id, filter)
+	if err != nil {
+		historyError(c, logger, err)
+		return
+	}
+
+	employees := make([]*models.Employee, len(versions))
+	for i := range versions {
+		employees[i] = &versions[i].Employee
+	}
+	if err := h.restrict(c, logger, employees...); err != nil {
+		logger.WithError(err).Error("Failed to load custom fields")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, versions)
+}
+
+// parseHistoryFilter reads the as_of, known_at and include_superseded parameters
+func parseHistoryFilter(c *gin.Context) (*HistoryFilter, error) {
+	filter := &HistoryFilter{}
+	if raw := c.Query("as_of"); raw != "" {
+		asOf, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			return nil, errors.New("invalid as_of, expected YYYY-MM-DD")
+		}
+		filter.AsOf = &asOf
+	}
+	if raw := c.Query("known_at"); raw != "" {
+		knownAt, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return nil, errors.New("invalid known_at, expected an RFC 3339 timestamp")
+		}
+		filter.KnownAt = &knownAt
+	}
+	if raw := c.Query("include_superseded"); raw != "" {
+		include, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, errors.New("invalid include_superseded")
+		}
+		filter.IncludeSuperseded = include
+	}
+	return filter, nil
+}
+
+// historyError maps history errors to HTTP responses
+func historyError(c *gin.Context, logger *logrus.Entry, err error) {
+	switch {
+	case errors.Is(err, ErrEmployeeNotFound), errors.Is(err, ErrNoVersionOnDate):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		logger.WithError(err).Error("Failed to get employee history")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get employee history"})
+	}
+}
+
+// ImportEmployees handles importing employees from a CSV or XLSX file
+// @Summary Import employees
+// @Description Validate and create employees from the first sheet of a CSV or XLSX file. Nothing is imported if any row is invalid. Rows with an empty employee_id get a generated one, listed in generated_ids by row number.
+// @Tags Employees
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "CSV or XLSX file with a header row"
+// @Param dry_run query bool false "Only validate the file"
+// @Success 200 {object} ImportResult
+// @Success 201 {object} ImportResult
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 422 {object} ImportResult
+// @Failure 500 {object} map[string]string
+// @Router /employees/import [post]
+func (h *Handler) ImportEmployees(c *gin.Context) {
+	logger := c.MustGet("logger").(*logrus.Entry)
+
+	dryRun := false
+	if value := c.Query("dry_run"); value != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "dry_run must be true or false"})
+			return
+		}
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		logger.WithError(err).Warn("No file in import request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A CSV or XLSX file is required in the file field"})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		logger.WithError(err).Error("Failed to open uploaded file")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer file.Close()
+
+	// One more row for the header
+	rows, err := spreadsheet.Read(fileHeader.Filename, file, MaxImportRows+1)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to read import file")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.service.ImportEmployees(logger, rows, dryRun, currentUserID(c))
+	switch {
+	case errors.Is(err, ErrEmptyImport):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrEmployeeIDTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		logger.WithError(err).Error("Failed to import employees")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import employees"})
+	case len(result.Errors) > 0:
+		c.JSON(http.StatusUnprocessableEntity, result)
+	case dryRun:
+		c.JSON(http.StatusOK, result)
+	default:
+		c.JSON(http.StatusCreated, result)
+	}
+}
+
+// ListChangeRequests handles listing employees' change requests
+// @Summary List change requests
+// @Description List changes employees made to their own records, oldest first
+// @Tags Employees
+// @Produce json
+// @Param status query string false "pending, approved, rejected or cancelled"
+// @Param employee_id query string false "Employee ID"
+// @Success 200 {array} models.EmployeeChangeRequest
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /employees/change-requests [get]
+func (h *Handler) ListChangeRequests(c *gin.Context) {
+	logger := c.MustGet("logger").(*logrus.Entry)
+	filter := &ChangeRequestFilter{Status: c.Query("status")}
+	switch filter.Status {
+	case "", models.ChangeRequestPending, models.ChangeRequestApproved, models.ChangeRequestRejected, models.ChangeRequestCancelled:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be pending, approved, rejected or cancelled"})
+		return
+	}
+	if value := c.Query("employee_id"); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid employee ID"})
+			return
+		}
+		filter.EmployeeID = &id
+	}
+
+	requests, err := h.service.ListChangeRequests(logger, filter)
+	if err != nil {
+		logger.WithError(err).Error("Failed to list change requests")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list change requests"})
+		return
+	}
+
+	c.JSON(http.StatusOK, requests)
+}
+
+// ApproveChangeRequest handles approving a change request
+// @Summary Approve a change request
+// @Description Apply a pending change request to the employee's record
+// @Tags Employees
+// @Accept json
+// @Produce json
+// @Param id path string true "Change request ID"
+// @Param review body models.ChangeRequestReview false "Review note"
+// @Success 200 {object} models.EmployeeChangeRequest
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /employees/change-requests/{id}/approve [post]
+func (h *Handler) ApproveChangeRequest(c *gin.Context) {
+	h.reviewChangeRequest(c, true)
+}
+
+// RejectChangeRequest handles rejecting a change request
+// @Summary Reject a change request
+// @Tags Employees
+// @Accept json
+// @Produce json
+// @Param id path string true "Change request ID"
+// @Param review body models.ChangeRequestReview false "Review note"
+// @Success 200 {object} models.EmployeeChangeRequest
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /employees/change-requests/{id}/reject [post]
+func (h *Handler) RejectChangeRequest(c *gin.Context) {
+	h.reviewChangeRequest(c, false)
+}
+
+func (h *Handler) reviewChangeRequest(c *gin.Context, approve bool) {
+	logger := c.MustGet("logger").(*logrus.Entry)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid change request ID"})
+		return
+	}
+
+	var review models.ChangeRequestReview
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&review); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	request, err := h.service.ReviewChangeRequest(logger, id, approve, currentUserID(c), review.Note)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, request)
+	case errors.Is(err, ErrChangeRequestNotFound), errors.Is(err, ErrEmployeeNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrChangeRequestClosed), errors.Is(err, ErrOwnChangeRequest):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		logger.WithError(err).Error("Failed to review change request")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review change request"})
+	}
+}
+
+// GetReports handles listing the employees who report to a manager
+// @Summary List an employee's reports
+// @Description Lists direct reports, or with transitive=true everyone below the employee, breadth first. level is 1 for direct reports.
+// @Tags Org chart
+// @Produce json
+// @Param id path string true "Employee ID"
+// @Param transitive query bool false "Include indirect reports"
+// @Success 200 {array} models.OrgNode
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /employees/{id}/reports [get]
+func (h *Handler) GetReports(c *gin.Context) {
+	logger := c.MustGet("logger").(*logrus.Entry)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		logger.WithError(err).Warn("Invalid ID format")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid employee ID"})
+		return
+	}
+	transitive := false
+	if value := c.Query("transitive"); value != "" {
+		if transitive, err = strconv.ParseBool(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "transitive must be true or false"})
+			return
+		}
+	}
+
+	reports, err := h.service.GetReports(logger, id, transitive)
+	if err != nil {
+		orgChartError(c, logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, reports)
+}
+
+// GetChainOfCommand handles listing an employee's managers
+// @Summary Get an employee's chain of command
+// @Description Lists the employee's managers from the direct manager up to the top of the company.
+// @Tags Org chart
+// @Produce json
+// @Param id path string true "Employee ID"
+// @Success 200 {array} models.OrgNode
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /employees/{id}/chain-of-command [get]
+func (h *Handler) GetChainOfCommand(c *gin.Context) {
+	logger := c.MustGet("logger").(*logrus.Entry)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		logger.WithError(err).Warn("Invalid ID format")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid employee ID"})
+		return
+	}
+
+	chain, err := h.service.GetChainOfCommand(logger, id)
+	if err != nil {
+		orgChartError(c, logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, chain)
+}
+
+// GetOrgChart handles getting the company's reporting tree
+// @Summary Get the org chart
+// @Description Returns the reporting tree of the whole company, or of the part below root. format=dot returns it as a Graphviz graph.
+// @Tags Org chart
+// @Produce json,text/vnd.graphviz
+// @Param root query string false "Employee at the top of the chart"
+// @Param format query string false "json or dot"
+// @Success 200 {object} models.OrgChart
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /employees/org-chart [get]
+func (h *Handler) GetOrgChart(c *gin.Context) {
+	logger := c.MustGet("logger").(*logrus.Entry)
+	var root *uuid.UUID
+	if value := c.Query("root"); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid root employee ID"})
+			return
+		}
+		root = &id
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "dot" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or dot"})
+		return
+	}
+
+	chart, err := h.service.GetOrgChart(logger, root)
+	if err != nil {
+		orgChartError(c, logger, err)
+		return
+	}
+
+	if format == "dot" {
+		c.Header("Content-Disposition", `attachment; filename="org-chart.dot"`)
+		c.Data(http.StatusOK, "text/vnd.graphviz; charset=utf-8", []byte(orgChartDOT(chart)))
+		return
+	}
+	c.JSON(http.StatusOK, chart)
+}
+
+// GetSpanOfControl handles getting span-of-control statistics
+// @Summary Get span-of-control statistics
+// @Description Lists every manager's direct and total reports and the levels below them, widest first, with company-wide figures.
+// @Tags Org chart
+// @Produce json
+// @Success 200 {object} models.SpanOfControlStats
+// @Router /employees/org-chart/stats [get]
+func (h *Handler) GetSpanOfControl(c *gin.Context) {
+	logger := c.MustGet("logger").(*logrus.Entry)
+	stats, err := h.service.GetSpanOfControl(logger)
+	if err != nil {
+		orgChartError(c, logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// orgChartError maps org chart errors to HTTP responses
+func orgChartError(c *gin.Context, logger *logrus.Entry, err error) {
+	if errors.Is(err, ErrEmployeeNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	logger.WithError(err).Error("Failed to get org chart")
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get org chart"})
+}
+
+// ExportEmployees handles exporting employees
+// @Summary Export employees
+// @Description Download the employees matching the search parameters as CSV, in the format /employees/import accepts. Custom fields visible to the user are included as columns named by their key. Cells starting with =, +, - or @ are prefixed with a quote so spreadsheet programs do not run them as formulas; imports remove the quote.
+// @Tags Employees
+// @Produce text/csv
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /employees/export [get]
+func (h *Handler) ExportEmployees(c *gin.Context) {
+	logger := c.MustGet("logger").(*logrus.Entry)
+	filter, err := parseSearchFilter(c)
+	if err != nil {
+		logger.WithError(err).Warn("Invalid search parameters")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.customFieldFilter(c, logger, filter); err != nil {
+		customFieldError(c, logger, err)
+		return
+	}
+
+	employees, err := h.service.ExportEmployees(logger, filter)
+	if errors.Is(err, ErrInvalidSort) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logger.WithError(err).Error("Failed to export employees")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	definitions, err := h.service.VisibleCustomFields(logger, middleware.CurrentRole(c))
+	if err != nil {
+		customFieldError(c, logger, err)
+		return
+	}
+	restricted := make([]*models.Employee, len(employees))
+	for i := range employees {
+		restricted[i] = &employees[i]
+	}
+	if err := h.restrict(c, logger, restricted...); err != nil {
+		customFieldError(c, logger, err)
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="employees.csv"`)
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	w := csv.NewWriter(c.Writer)
+	w.Write(exportHeader(definitions))
+	for _, e := range restricted {
+		w.Write(exportRow(e, definitions))
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		logger.WithError(err).Error("Failed to write employee export")
+	}
+}
+
+// ListCustomFields handles listing custom field definitions
+// @Summary List custom fields
+// @Description List the custom fields on employee records that the user can see
+// @Tags Employees
+// @Produce json
+// @Success 200 {array} models.CustomFieldDefinition
+// @Failure 500 {object} map[string]string
+// @Router /employees/custom-fields [get]
+func (h *Handler) ListCustomFields(c *gin.Context) {
+	logger := c.MustGet("logger").(*logrus.Entry)
+	definitions, err := h.service.VisibleCustomFields(logger, middleware.CurrentRole(c))
+	if err != nil {
+		customFieldError(c, logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, definitions)
+}
+
+// CreateCustomField handles defining a custom field
+// @Summary Create a custom field
+// @Description Define a custom field on employee records. Types are text, number, boolean, date and select.
+// @Tags Employees
+// @Accept json
+// @Produce json
+// @Param field body models.CustomFieldDefinitionCreate true "Custom field"
+// @Success 201 {object} models.CustomFieldDefinition
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /employees/custom-fields [post]
+func (h *Handler) CreateCustomField(c *gin.Context) {
+	logger := c.MustGet("logger").(*logrus.Entry)
+	var data models.CustomFieldDefinitionCreate
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	definition, err := h.service.CreateCustomField(logger, &data)
+	if err != nil {
+		customFieldError(c, logger, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, definition)
+}
+
+// UpdateCustomField handles changing a custom field
+// @Summary Update a custom field
+// @Description Replace a custom field's settings. Its key and type cannot change. Existing values are checked against the new settings when they are next changed.
+// @Tags Employees
+// @Accept json
+// @Produce json
+// @Param id path string true "Custom field ID"
+// @Param field body models.CustomFieldDefinitionUpdate true "Custom field settings"
+// @Success 200 {object} models.CustomFieldDefinition
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /employees/custom-fields/{id} [put]
+func (h *Handler) UpdateCustomField(c *gin.Context) {
+	logger := c.MustGet("logger").(*logrus.Entry)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid custom field ID"})
+		return
+	}
+	var data models.CustomFieldDefinitionUpdate
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	definition, err := h.service.UpdateCustomField(logger, id, &data)
+	if err != nil {
+		customFieldError(c, logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, definition)
+}
+
+// DeleteCustomField handles deleting a custom field
+// @Summary Delete a custom field
+// @Description Delete a custom field and its values on every employee, including their history
+// @Tags Employees
+// @Param id path string true "Custom field ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /employees/custom-fields/{id} [delete]
+func (h *Handler) DeleteCustomField(c *gin.Context) {
+	logger := c.MustGet("logger").(*logrus.Entry)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid custom field ID"})
+		return
+	}
+
+	if err := h.service.DeleteCustomField(logger, id); err != nil {
+		customFieldError(c, logger, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// customFieldError maps custom field errors to HTTP responses
+func customFieldError(c *gin.Context, logger *logrus.Entry, err error) {
+	switch {
+	case errors.Is(err, ErrCustomFieldNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrInvalidCustomField), errors.Is(err, ErrInvalidCustomFieldValue):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrCustomFieldExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		logger.WithError(err).Error("Failed to handle custom fields")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// FindDuplicates handles reporting likely duplicate employees
+// @Summary Report duplicate employees
+// @Description Lists pairs of employees that probably describe the same person, scored from 0 to 1 on matching email, phone number and date of birth and on name similarity. The employee in each pair is the record created first.
+// @Tags Employees
+// @Produce json
+// @Param min_score query number false "Lowest score to report" default(0.7)
+// @Success 200 {array} models.DuplicateCandidate
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /employees/duplicates [get]
+func (h *Handler) FindDuplicates(c *gin.Context) {
+	logger := c.MustGet("logger").(*logrus.Entry)
+	minScore := DefaultDuplicateScore
+	if value := c.Query("min_score"); value != "" {
+		var err error
+		if minScore, err = strconv.ParseFloat(value, 64); err != nil || minScore <= 0 || minScore > 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "min_score must be a number above 0 and at most 1"})
+			return
+		}
+	}
+
+	candidates, err := h.service.FindDuplicates(logger, minScore)
+	if err != nil {
+		logger.WithError(err).Error("Failed to find duplicate employees")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find duplicate employees"})
+		return
+	}
+	for i := range candidates {
+		if err := h.restrict(c, logger, &candidates[i].Employee, &candidates[i].Duplicate); err != nil {
+			logger.WithError(err).Error("Failed to find duplicate employees")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find duplicate employees"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, candidates)
+}
+
+// MergeEmployees handles merging a duplicate employee into another
+// @Summary Merge a duplicate employee
+// @Description Moves the attendance, leave requests, salaries, payroll details, payslips, documents and performance reviews of the duplicate to the employee and deletes the duplicate, in one transaction. The employee's own details are kept. The merge is recorded in the audit log.
+// @Tags Employees
+// @Accept json
+// @Produce json
+// @Param id path string true "ID of the employee to keep"
+// @Param merge body models.EmployeeMergeRequest true "Duplicate to merge"
+// @Success 200 {object} models.EmployeeMerge
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /employees/{id}/merge [post]
+func (h *Handler) MergeEmployees(c *gin.Context) {
+	logger := c.MustGet("logger").(*logrus.Entry)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		logger.WithError(err).Warn("Invalid ID format")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid employee ID"})
+		return
+	}
+	var data models.EmployeeMergeRequest
+	if err := c.ShouldBindJSON(&data); err != nil {
+		logger.WithError(err).Warn("Failed to bind JSON for merge employees")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	merge, err := h.service.MergeEmployees(logger, id, &data, currentUserID(c), c.ClientIP())
+	if err != nil {
+		mergeError(c, logger, err)
+		return
+	}
+	if err := h.restrict(c, logger, merge.Employee); err != nil {
+		mergeError(c, logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, merge)
+}
+
+// mergeError maps merge errors to HTTP responses
+func mergeError(c *gin.Context, logger *logrus.Entry, err error) {
+	switch {
+	case errors.Is(err, ErrEmployeeNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrMergeIntoSelf):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrEmployeeInUse), errors.Is(err, ErrOverlappingSalaries), errors.Is(err, ErrOverlappingPayroll),
+		errors.Is(err, ErrOverlappingAttendance), errors.Is(err, auth.ErrLastAdmin):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		logger.WithError(err).Error("Failed to merge employees")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge employees"})
+	}
+}
+
+// ListNumberTemplates handles listing employee number templates
+// @Summary List employee number templates
+// @Description List the patterns employee IDs are generated from. The template without a department applies to employees without one of their own.
+// @Tags Employees
+// @Produce json
+// @Success 200 {array} models.EmployeeNumberTemplate
+// @Failure 500 {object} map[string]string
+// @Router /employees/number-templates [get]
+func (h *Handler) ListNumberTemplates(c *gin.Context) {
+	logger := c.MustGet("logger").(*logrus.Entry)
+	templates, err := h.service.ListNumberTemplates(logger)
+	if err != nil {
+		numberTemplateError(c, logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, templates)
+}
+
+// CreateNumberTemplate handles creating an employee number template
+// @Summary Create an employee number template
+// @Description Set the pattern employee IDs are generated from for a department, or without department_id the default pattern. Patterns contain {seq:N} for an N-digit sequence number and optionally {YYYY}, {YY} and {MM} for the hire date, e.g. ENG-{YYYY}-{seq:5}.
+// @Tags Employees
+// @Accept json
+// @Produce json
+// @Param template body models.EmployeeNumberTemplateCreate true "Template"
+// @Success 201 {object} models.EmployeeNumberTemplate
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /employees/number-templates [post]
+func (h *Handler) CreateNumberTemplate(c *gin.Context) {
+	logger := c.MustGet("logger").(*logrus.Entry)
+	var data models.EmployeeNumberTemplateCreate
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template, err := h.service.CreateNumberTemplate(logger, &data)
+	if err != nil {
+		numberTemplateError(c, logger, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, template)
+}
+
+// UpdateNumberTemplate handles changing an employee number template
+// @Summary Update an employee number template
+// @Description Change the pattern of a template. Existing employee IDs are kept.
+// @Tags Employees
+// @Accept json
+// @Produce json
+// @Param id path string true "Template ID"
+// @Param template body models.EmployeeNumberTemplateUpdate true "Pattern"
+// @Success 200 {object} models.EmployeeNumberTemplate
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /employees/number-templates/{id} [put]
+func (h *Handler) UpdateNumberTemplate(c *gin.Context) {
+	logger := c.MustGet("logger").(*logrus.Entry)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID"})
+		return
+	}
+	var data models.EmployeeNumberTemplateUpdate
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template, err := h.service.UpdateNumberTemplate(logger, id, &data)
+	if err != nil {
+		numberTemplateError(c, logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// DeleteNumberTemplate handles deleting an employee number template
+// @Summary Delete an employee number template
+// @Description Delete a template. Its department falls back to the default template.
+// @Tags Employees
+// @Param id path string true "Template ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /employees/number-templates/{id} [delete]
+func (h *Handler) DeleteNumberTemplate(c *gin.Context) {
+	logger := c.MustGet("logger").(*logrus.Entry)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID"})
+		return
+	}
+
+	if err := h.service.DeleteNumberTemplate(logger, id); err != nil {
+		numberTemplateError(c, logger, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// numberTemplateError maps employee number template errors to HTTP responses
+func numberTemplateError(c *gin.Context, logger *logrus.Entry, err error) {
+	switch {
+	case errors.Is(err, ErrNumberTemplateNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrInvalidNumberTemplate), errors.Is(err, ErrInvalidReference):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrNumberTemplateExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		logger.WithError(err).Error("Failed to handle employee number templates")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,416 @@
+package employee
+
+import (
+	"bytes"
+	"database/sql"
+	"employee-management/internal/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrBeforeFirstVersion is returned for changes dated before the employee's record begins
+	ErrBeforeFirstVersion = errors.New("the employee record does not exist yet on that date")
+	// ErrNoVersionOnDate is returned when no version of the record covers the requested time
+	ErrNoVersionOnDate = errors.New("no version of the employee record on that date")
+)
+
+// Version change types
+const (
+	ChangeCreate = "create"
+	ChangeUpdate = "update"
+)
+
+// version is an employee record version being rewritten. data holds the
+// record as JSON fields so changes can be compared and applied by name.
+type version struct {
+	models.EmployeeVersion
+	data map[string]json.RawMessage
+}
+
+// HistoryFilter selects versions of an employee record
+type HistoryFilter struct {
+	// AsOf is the day the record should be valid on
+	AsOf *time.Time
+	// KnownAt is the moment whose knowledge of the record is wanted. Zero
+	// means what is believed now.
+	KnownAt *time.Time
+	// IncludeSuperseded also lists versions that were later corrected
+	IncludeSuperseded bool
+}
+
+func encodeFields(value interface{}) (map[string]json.RawMessage, error) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	return fields, json.Unmarshal(raw, &fields)
+}
+
+// insertVersion writes a new version recorded at now
+func (r *repository) insertVersion(tx *sql.Tx, v *version, now time.Time) error {
+	sealed, err := r.sealFields(v.data)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(sealed)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO employee_versions (employee_id, valid_from, valid_to, recorded_at, recorded_by, change_type, changed_fields, data)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		v.EmployeeID, v.ValidFrom, v.ValidTo, now, v.RecordedBy, v.ChangeType, pq.Array(v.ChangedFields), data)
+	return err
+}
+
+// recordInitialVersion starts the history of a newly created employee
+func (r *repository) recordInitialVersion(tx *sql.Tx, employee *models.Employee, recordedBy *uuid.UUID) error {
+	data, err := encodeFields(employee)
+	if err != nil {
+		return err
+	}
+	return r.insertVersion(tx, &version{
+		EmployeeVersion: models.EmployeeVersion{
+			EmployeeID:    employee.ID,
+			ValidFrom:     truncateDate(employee.HireDate),
+			RecordedBy:    recordedBy,
+			ChangeType:    ChangeCreate,
+			ChangedFields: []string{},
+		},
+		data: data,
+	}, time.Now())
+}
+
+// recordChange applies changes, keyed by JSON field name, to the employee's
+// record from validFrom on. Existing versions are never modified: the version
+// in effect on validFrom is split, and later versions that still hold the old
+// value of a changed field are superseded by copies carrying the new value.
+// The employee row is then brought in line with the version in effect
+// today. The caller must hold a lock on the employee row.
+func (r *repository) recordChange(tx *sql.Tx, employeeID uuid.UUID, validFrom time.Time, changes map[string]interface{}, changeType string, recordedBy *uuid.UUID) error {
+	validFrom = truncateDate(validFrom)
+	versions, err := r.currentVersions(tx, employeeID)
+	if err != nil {
+		return err
+	}
+
+	// Custom fields change individually, on top of the values held on validFrom
+	if update, ok := changes["custom_fields"].(models.CustomFields); ok {
+		var current json.RawMessage
+		if base := versionAt(versions, validFrom); base != nil {
+			current = base.data["custom_fields"]
+		}
+		if changes["custom_fields"], err = mergeCustomFields(current, update); err != nil {
+			return err
+		}
+	}
+
+	newValues, err := encodeFields(changes)
+	if err != nil {
+		return err
+	}
+	superseded, inserted, err := planChange(versions, validFrom, newValues, changeType, recordedBy)
+	if err != nil || len(inserted) == 0 {
+		return err
+	}
+
+	now := time.Now()
+	if _, err := tx.Exec("UPDATE employee_versions SET superseded_at = $1 WHERE id = ANY($2)", now, pq.Array(superseded)); err != nil {
+		return err
+	}
+	for _, v := range inserted {
+		if err := r.insertVersion(tx, v, now); err != nil {
+			return err
+		}
+	}
+
+	return r.syncEmployee(tx, employeeID, currentVersion(versions))
+}
+
+// planChange works out which versions a change supersedes and the versions
+// replacing them. versions is updated in place to the resulting history.
+func planChange(versions []*version, validFrom time.Time, newValues map[string]json.RawMessage, changeType string, recordedBy *uuid.UUID) (superseded []uuid.UUID, inserted []*version, err error) {
+	index := versionIndex(versions, validFrom)
+	if index < 0 {
+		return nil, nil, ErrBeforeFirstVersion
+	}
+	base := versions[index]
+
+	changed := map[string]json.RawMessage{}
+	oldValues := map[string]json.RawMessage{}
+	for field, value := range newValues {
+		if !bytes.Equal(base.data[field], value) {
+			changed[field] = value
+			oldValues[field] = base.data[field]
+		}
+	}
+	if len(changed) == 0 {
+		return nil, nil, nil
+	}
+
+	changedFields := make([]string, 0, len(changed))
+	for field := range changed {
+		changedFields = append(changedFields, field)
+	}
+	sort.Strings(changedFields)
+
+	superseded = append(superseded, base.ID)
+	if base.ValidFrom.Before(validFrom) {
+		before := base.copy()
+		before.ValidTo = &validFrom
+		inserted = append(inserted, before)
+	}
+	after := base.copy()
+	after.ValidFrom = validFrom
+	after.ChangeType = changeType
+	after.ChangedFields = changedFields
+	if base.ValidFrom.Equal(validFrom) {
+		// A second change on the same day replaces the version, so keep
+		// what the first one changed too
+		after.ChangedFields = mergeFields(base.ChangedFields, changedFields)
+	}
+	after.RecordedBy = recordedBy
+	for field, value := range changed {
+		after.data[field] = value
+	}
+	inserted = append(inserted, after)
+	versions[index] = after
+
+	// Carry the change forward until a later version changed the same field
+	for i := index + 1; i < len(versions) && len(changed) > 0; i++ {
+		var later *version
+		for field, value := range changed {
+			if !bytes.Equal(versions[i].data[field], oldValues[field]) {
+				delete(changed, field)
+				continue
+			}
+			if later == nil {
+				later = versions[i].copy()
+			}
+			later.data[field] = value
+		}
+		if later != nil {
+			superseded = append(superseded, versions[i].ID)
+			inserted = append(inserted, later)
+			versions[i] = later
+		}
+	}
+
+	return superseded, inserted, nil
+}
+
+// versionIndex returns the index of the version in effect on the date, or -1
+func versionIndex(versions []*version, date time.Time) int {
+	for i, v := range versions {
+		if !v.ValidFrom.After(date) && (v.ValidTo == nil || date.Before(*v.ValidTo)) {
+			return i
+		}
+	}
+	return -1
+}
+
+// currentVersion returns the version in effect today. Versions dated later
+// only take effect on their day. A record that begins in the future is
+// represented by its first version until then.
+func currentVersion(versions []*version) *version {
+	if v := versionAt(versions, truncateDate(time.Now())); v != nil {
+		return v
+	}
+	return versions[0]
+}
+
+// versionAt returns the version in effect on the date, or nil
+func versionAt(versions []*version, date time.Time) *version {
+	if i := versionIndex(versions, date); i >= 0 {
+		return versions[i]
+	}
+	return nil
+}
+
+func mergeFields(a, b []string) []string {
+	seen := map[string]bool{}
+	merged := []string{}
+	for _, field := range append(append([]string{}, a...), b...) {
+		if !seen[field] {
+			seen[field] = true
+			merged = append(merged, field)
+		}
+	}
+	sort.Strings(merged)
+	return merged
+}
+
+// syncEmployee writes a version back to the employee row
+func (r *repository) syncEmployee(tx *sql.Tx, employeeID uuid.UUID, current *version) error {
+	var e models.Employee
+	if err := current.decode(&e); err != nil {
+		return err
+	}
+	if err := checkPlaintext(&e); err != nil {
+		return err
+	}
+	stored, err := r.sealEmployee(&e)
+	if err != nil {
+		return err
+	}
+	customFields, err := customFieldsJSON(e.CustomFields)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		UPDATE employees
+		SET first_name = $1, last_name = $2, date_of_birth = $3, gender = $4, marital_status = $5, phone_number = $6, email = $7, address = $8, emergency_contact_name = $9, emergency_contact_phone = $10, department_id = $11, position_id = $12, hire_date = $13, employment_status = $14, manager_id = $15,
+		    date_of_birth_encrypted = $16, email_index = $17, phone_number_index = $18, custom_fields = $19, updated_at = NOW()
+		WHERE id = $20`,
+		e.FirstName, e.LastName, stored.DateOfBirth, e.Gender, e.MaritalStatus, stored.PhoneNumber, stored.Email, stored.Address, stored.EmergencyContactName, stored.EmergencyContactPhone, e.DepartmentID, e.PositionID, e.HireDate, e.EmploymentStatus, e.ManagerID,
+		stored.DateOfBirthEncrypted, stored.EmailIndex, stored.PhoneIndex, customFields, employeeID)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+		return ErrInvalidReference
+	}
+	return err
+}
+
+// currentVersions locks and returns the versions currently believed, in valid time order
+func (r *repository) currentVersions(tx *sql.Tx, employeeID uuid.UUID) ([]*version, error) {
+	rows, err := tx.Query(`
+		SELECT `+versionColumns+`
+		FROM employee_versions
+		WHERE employee_id = $1 AND superseded_at IS NULL
+		ORDER BY valid_from
+		FOR UPDATE`, employeeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []*version
+	for rows.Next() {
+		v, err := r.scanVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+const versionColumns = `id, employee_id, valid_from, valid_to, recorded_at, superseded_at, recorded_by, change_type, changed_fields, data`
+
+func (r *repository) scanVersion(row interface{ Scan(...interface{}) error }) (*version, error) {
+	var v version
+	var data []byte
+	if err := row.Scan(&v.ID, &v.EmployeeID, &v.ValidFrom, &v.ValidTo, &v.RecordedAt, &v.SupersededAt, &v.RecordedBy,
+		&v.ChangeType, pq.Array(&v.ChangedFields), &data); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &v.data); err != nil {
+		return nil, err
+	}
+	if err := r.openFields(v.data); err != nil {
+		return nil, err
+	}
+	if err := v.decode(&v.Employee); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func (v *version) copy() *version {
+	c := &version{EmployeeVersion: v.EmployeeVersion, data: make(map[string]json.RawMessage, len(v.data))}
+	for field, value := range v.data {
+		c.data[field] = value
+	}
+	return c
+}
+
+func (v *version) decode(e *models.Employee) error {
+	raw, err := json.Marshal(v.data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, e)
+}
+
+// ListEmployeeVersions retrieves versions of an employee record in valid
+// time order. By default these are the versions believed now; KnownAt looks
+// at what was believed at an earlier moment.
+func (r *repository) ListEmployeeVersions(logger *logrus.Entry, id uuid.UUID, filter *HistoryFilter) ([]models.EmployeeVersion, error) {
+	startTime := time.Now()
+	conditions := []string{"employee_id = $1"}
+	args := []interface{}{id}
+	switch {
+	case filter.KnownAt != nil:
+		args = append(args, *filter.KnownAt)
+		conditions = append(conditions, fmt.Sprintf("recorded_at <= $%d AND (superseded_at IS NULL OR superseded_at > $%d)", len(args), len(args)))
+	case !filter.IncludeSuperseded:
+		conditions = append(conditions, "superseded_at IS NULL")
+	}
+	if filter.AsOf != nil {
+		args = append(args, truncateDate(*filter.AsOf))
+		conditions = append(conditions, fmt.Sprintf("valid_from <= $%d AND (valid_to IS NULL OR valid_to > $%d)", len(args), len(args)))
+	}
+
+	query := `
+		SELECT ` + versionColumns + `
+		FROM employee_versions
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY valid_from, recorded_at
+	`
+	rows, err := r.db.Query(query, args...)
+
+	logger.WithFields(logrus.Fields{
+		"query":    query,
+		"duration": time.Since(startTime),
+	}).Debug("Executed ListEmployeeVersions query")
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []models.EmployeeVersion{}
+	for rows.Next() {
+		v, err := r.scanVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, v.EmployeeVersion)
+	}
+	return versions, rows.Err()
+}
+
+// GetEmployeeHistory retrieves versions of an employee record
+func (s *Service) GetEmployeeHistory(logger *logrus.Entry, id uuid.UUID, filter *HistoryFilter) ([]models.EmployeeVersion, error) {
+	logger.WithField("employeeID", id).Info("Getting employee history")
+	if _, err := s.repo.GetEmployeeByID(logger, id); err != nil {
+		return nil, err
+	}
+	return s.repo.ListEmployeeVersions(logger, id, filter)
+}
+
+// GetEmployeeAsOf reconstructs an employee record as it was valid on a date,
+// according to what was known at knownAt, or now if knownAt is nil
+func (s *Service) GetEmployeeAsOf(logger *logrus.Entry, id uuid.UUID, asOf time.Time, knownAt *time.Time) (*models.Employee, error) {
+	logger.WithFields(logrus.Fields{"employeeID": id, "asOf": asOf}).Info("Getting employee as of date")
+	versions, err := s.repo.ListEmployeeVersions(logger, id, &HistoryFilter{AsOf: &asOf, KnownAt: knownAt})
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		if _, err := s.repo.GetEmployeeByID(logger, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrNoVersionOnDate
+	}
+	return &versions[len(versions)-1].Employee, nil
+}
@@ -0,0 +1,182 @@
+package employee
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"employee-management/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func date(value string) time.Time {
+	d, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// testVersion builds a version valid from one date to another ("" for open
+// ended) holding the given JSON fields
+func testVersion(from, to string, fields map[string]string) *version {
+	v := &version{
+		EmployeeVersion: models.EmployeeVersion{ID: uuid.New(), ValidFrom: date(from), ChangeType: ChangeCreate, ChangedFields: []string{}},
+		data:            map[string]json.RawMessage{},
+	}
+	if to != "" {
+		validTo := date(to)
+		v.ValidTo = &validTo
+	}
+	for field, value := range fields {
+		v.data[field] = json.RawMessage(value)
+	}
+	return v
+}
+
+func values(fields map[string]string) map[string]json.RawMessage {
+	raw := map[string]json.RawMessage{}
+	for field, value := range fields {
+		raw[field] = json.RawMessage(value)
+	}
+	return raw
+}
+
+// checkVersion compares a planned version with its expected validity and fields
+func checkVersion(t *testing.T, v *version, from, to string, fields map[string]string) {
+	t.Helper()
+	if !v.ValidFrom.Equal(date(from)) {
+		t.Errorf("valid_from = %s, want %s", v.ValidFrom.Format("2006-01-02"), from)
+	}
+	switch {
+	case to == "" && v.ValidTo != nil:
+		t.Errorf("valid_to = %s, want open ended", v.ValidTo.Format("2006-01-02"))
+	case to != "" && (v.ValidTo == nil || !v.ValidTo.Equal(date(to))):
+		t.Errorf("valid_to = %v, want %s", v.ValidTo, to)
+	}
+	for field, value := range fields {
+		if string(v.data[field]) != value {
+			t.Errorf("%s = %s, want %s", field, v.data[field], value)
+		}
+	}
+}
+
+func TestPlanChangeSplitsVersion(t *testing.T) {
+	first := testVersion("2026-01-01", "", map[string]string{"last_name": `"Doe"`, "email": `"jane@example.com"`})
+	versions := []*version{first}
+	recordedBy := uuid.New()
+
+	superseded, inserted, err := planChange(versions, date("2026-03-01"), values(map[string]string{"last_name": `"Smith"`, "email": `"jane@example.com"`}), ChangeUpdate, &recordedBy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(superseded, []uuid.UUID{first.ID}) {
+		t.Errorf("superseded = %v, want the first version", superseded)
+	}
+	if len(inserted) != 2 {
+		t.Fatalf("inserted %d versions, want 2", len(inserted))
+	}
+	checkVersion(t, inserted[0], "2026-01-01", "2026-03-01", map[string]string{"last_name": `"Doe"`})
+	checkVersion(t, inserted[1], "2026-03-01", "", map[string]string{"last_name": `"Smith"`, "email": `"jane@example.com"`})
+
+	after := inserted[1]
+	if after.ChangeType != ChangeUpdate || *after.RecordedBy != recordedBy {
+		t.Errorf("change type %q by %v, want %q by %v", after.ChangeType, after.RecordedBy, ChangeUpdate, recordedBy)
+	}
+	if !reflect.DeepEqual(after.ChangedFields, []string{"last_name"}) {
+		t.Errorf("changed fields = %v, want [last_name]", after.ChangedFields)
+	}
+	if versions[0] != after {
+		t.Error("versions was not updated to the resulting history")
+	}
+	if string(first.data["last_name"]) != `"Doe"` {
+		t.Error("planChange modified the superseded version")
+	}
+}
+
+func TestPlanChangeWithoutChanges(t *testing.T) {
+	versions := []*version{testVersion("2026-01-01", "", map[string]string{"last_name": `"Doe"`})}
+	superseded, inserted, err := planChange(versions, date("2026-03-01"), values(map[string]string{"last_name": `"Doe"`}), ChangeUpdate, nil)
+	if err != nil || superseded != nil || inserted != nil {
+		t.Errorf("planChange = %v, %v, %v, want nothing to do", superseded, inserted, err)
+	}
+}
+
+func TestPlanChangeBeforeFirstVersion(t *testing.T) {
+	versions := []*version{testVersion("2026-01-01", "", map[string]string{"last_name": `"Doe"`})}
+	_, _, err := planChange(versions, date("2025-12-31"), values(map[string]string{"last_name": `"Smith"`}), ChangeUpdate, nil)
+	if !errors.Is(err, ErrBeforeFirstVersion) {
+		t.Errorf("planChange error = %v, want ErrBeforeFirstVersion", err)
+	}
+}
+
+func TestPlanChangeSameDayKeepsEarlierChanges(t *testing.T) {
+	first := testVersion("2026-01-01", "2026-03-01", map[string]string{"last_name": `"Doe"`, "address": `"1 Main St"`})
+	second := testVersion("2026-03-01", "", map[string]string{"last_name": `"Smith"`, "address": `"1 Main St"`})
+	second.ChangeType = ChangeUpdate
+	second.ChangedFields = []string{"last_name"}
+	versions := []*version{first, second}
+
+	superseded, inserted, err := planChange(versions, date("2026-03-01"), values(map[string]string{"address": `"2 High St"`}), ChangeUpdate, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(superseded, []uuid.UUID{second.ID}) {
+		t.Errorf("superseded = %v, want the second version", superseded)
+	}
+	if len(inserted) != 1 {
+		t.Fatalf("inserted %d versions, want 1", len(inserted))
+	}
+	checkVersion(t, inserted[0], "2026-03-01", "", map[string]string{"last_name": `"Smith"`, "address": `"2 High St"`})
+	if !reflect.DeepEqual(inserted[0].ChangedFields, []string{"address", "last_name"}) {
+		t.Errorf("changed fields = %v, want [address last_name]", inserted[0].ChangedFields)
+	}
+}
+
+func TestPlanChangeCarriesForward(t *testing.T) {
+	first := testVersion("2026-01-01", "2026-06-01", map[string]string{"last_name": `"Doe"`, "address": `"1 Main St"`})
+	second := testVersion("2026-06-01", "2026-09-01", map[string]string{"last_name": `"Doe"`, "address": `"2 High St"`})
+	third := testVersion("2026-09-01", "", map[string]string{"last_name": `"Doe"`, "address": `"2 High St"`})
+	versions := []*version{first, second, third}
+
+	// The retroactive name change holds for the later versions, while the
+	// address change stops at the version that changed the address itself
+	superseded, inserted, err := planChange(versions, date("2026-03-01"), values(map[string]string{"last_name": `"Smith"`, "address": `"3 Low St"`}), ChangeUpdate, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(superseded, []uuid.UUID{first.ID, second.ID, third.ID}) {
+		t.Errorf("superseded = %v, want all three versions", superseded)
+	}
+	if len(inserted) != 4 {
+		t.Fatalf("inserted %d versions, want 4", len(inserted))
+	}
+	checkVersion(t, inserted[0], "2026-01-01", "2026-03-01", map[string]string{"last_name": `"Doe"`, "address": `"1 Main St"`})
+	checkVersion(t, inserted[1], "2026-03-01", "2026-06-01", map[string]string{"last_name": `"Smith"`, "address": `"3 Low St"`})
+	checkVersion(t, inserted[2], "2026-06-01", "2026-09-01", map[string]string{"last_name": `"Smith"`, "address": `"2 High St"`})
+	checkVersion(t, inserted[3], "2026-09-01", "", map[string]string{"last_name": `"Smith"`, "address": `"2 High St"`})
+}
+
+func TestPlanChangeStopsAtLaterChange(t *testing.T) {
+	first := testVersion("2026-01-01", "2026-06-01", map[string]string{"last_name": `"Doe"`})
+	second := testVersion("2026-06-01", "", map[string]string{"last_name": `"Jones"`})
+	versions := []*version{first, second}
+
+	superseded, inserted, err := planChange(versions, date("2026-03-01"), values(map[string]string{"last_name": `"Smith"`}), ChangeUpdate, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(superseded, []uuid.UUID{first.ID}) {
+		t.Errorf("superseded = %v, want only the first version", superseded)
+	}
+	if len(inserted) != 2 {
+		t.Fatalf("inserted %d versions, want 2", len(inserted))
+	}
+	checkVersion(t, inserted[1], "2026-03-01", "2026-06-01", map[string]string{"last_name": `"Smith"`})
+	if versions[1] != second {
+		t.Error("the later version was replaced")
+	}
+}
@@ -0,0 +1,530 @@
+package employee
+
+import (
+	"employee-management/internal/fieldcrypt"
+	"employee-management/internal/models"
+	"employee-management/internal/spreadsheet"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// MaxImportRows is the largest number of employees imported at once
+const MaxImportRows = 10000
+
+// ErrEmptyImport is returned for files without a header row
+var ErrEmptyImport = errors.New("the file has no header row")
+
+// ImportPosition is a position import rows can refer to by title
+type ImportPosition struct {
+	ID           uuid.UUID
+	Title        string
+	DepartmentID uuid.UUID
+}
+
+// ImportReferences holds what import rows can refer to. Maps are keyed by
+// ID and by lowercased name, username, email or employee ID.
+type ImportReferences struct {
+	Departments map[string]uuid.UUID
+	Positions   []ImportPosition
+	Users       map[string]uuid.UUID
+	Employees   map[string]uuid.UUID
+	// DeletedEmployeeIDs are the employee IDs still held by deleted employees
+	DeletedEmployeeIDs map[string]bool
+	UsersWithEmployee  map[uuid.UUID]bool
+}
+
+// ImportRowError is a problem with one row of an import. Row 1 is the header.
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// ImportResult reports the outcome of an import. Nothing is imported unless
+// every row is valid.
+type ImportResult struct {
+	DryRun   bool             `json:"dry_run"`
+	Rows     int              `json:"rows"`
+	Imported int              `json:"imported"`
+	Errors   []ImportRowError `json:"errors"`
+	// GeneratedIDs maps the rows imported without an employee ID to the ID
+	// generated for them
+	GeneratedIDs map[int]string `json:"generated_ids,omitempty"`
+}
+
+// importColumns maps accepted header names to the column they fill
+var importColumns = map[string]string{
+	"employee_id":             "employee_id",
+	"first_name":              "first_name",
+	"last_name":               "last_name",
+	"date_of_birth":           "date_of_birth",
+	"gender":                  "gender",
+	"marital_status":          "marital_status",
+	"phone_number":            "phone_number",
+	"phone":                   "phone_number",
+	"email":                   "email",
+	"address":                 "address",
+	"emergency_contact_name":  "emergency_contact_name",
+	"emergency_contact_phone": "emergency_contact_phone",
+	"hire_date":               "hire_date",
+	"employment_status":       "employment_status",
+	"status":                  "employment_status",
+	"department":              "department",
+	"department_id":           "department",
+	"department_name":         "department",
+	"position":                "position",
+	"position_id":             "position",
+	"position_title":          "position",
+	"manager":                 "manager",
+	"manager_id":              "manager",
+	"manager_employee_id":     "manager",
+	"manager_email":           "manager",
+	"user":                    "user",
+	"user_id":                 "user",
+	"username":                "user",
+	"user_email":              "user",
+}
+
+var requiredImportColumns = []string{
+	"first_name", "last_name", "date_of_birth", "gender", "marital_status", "phone_number",
+	"email", "address", "emergency_contact_name", "emergency_contact_phone", "hire_date",
+}
+
+var importValidator = func() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		return strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	})
+	return v
+}()
+
+// importRow is a parsed row on its way to becoming an employee
+type importRow struct {
+	number   int
+	employee models.EmployeeCreate
+	// manager is the index of the row's manager within the file, or -1
+	manager int
+}
+
+// ImportEmployees validates spreadsheet rows and, unless dryRun is set or a
+// row is invalid, creates all of the employees in a single transaction. The
+// first row holds column names. Departments and positions are matched by ID
+// or name, managers by ID, employee ID or email (including employees
+// earlier or later in the same file) and user accounts by ID, username or
+// email, defaulting to the account with the employee's email if there is
+// one. Custom fields
+// are read from columns named by their key. Rows without an employee ID get
+// a generated one.
+func (s *Service) ImportEmployees(logger *logrus.Entry, rows [][]string, dryRun bool, recordedBy *uuid.UUID) (*ImportResult, error) {
+	logger.WithFields(logrus.Fields{"rows": len(rows), "dryRun": dryRun}).Info("Importing employees")
+
+	result := &ImportResult{DryRun: dryRun, Errors: []ImportRowError{}}
+	if len(rows) == 0 {
+		return nil, ErrEmptyImport
+	}
+
+	definitions, err := s.repo.ListCustomFields(logger)
+	if err != nil {
+		return nil, err
+	}
+	columns, headerErrors := importHeader(rows[0], definitions)
+	if len(headerErrors) > 0 {
+		result.Errors = headerErrors
+		return result, nil
+	}
+
+	var data [][]string
+	var numbers []int
+	for i, row := range rows[1:] {
+		if !blankRow(row) {
+			data = append(data, row)
+			numbers = append(numbers, i+2)
+		}
+	}
+	result.Rows = len(data)
+	if len(data) > MaxImportRows {
+		result.Errors = append(result.Errors, ImportRowError{Message: fmt.Sprintf("the file has %d rows, at most %d can be imported at once", len(data), MaxImportRows)})
+		return result, nil
+	}
+
+	refs, err := s.repo.LoadImportReferences(logger)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := make([]*importRow, len(data))
+	codes := map[string]int{}
+	emails := map[string]int{}
+	for i, values := range data {
+		get := func(column string) string {
+			if index, ok := columns[column]; ok && index < len(values) {
+				return unescapeFormula(strings.TrimSpace(values[index]))
+			}
+			return ""
+		}
+		row, rowErrors := parseImportRow(numbers[i], get, refs, definitions)
+		result.Errors = append(result.Errors, rowErrors...)
+		parsed[i] = row
+
+		code := strings.ToLower(row.employee.EmployeeID)
+		if first, ok := codes[code]; ok && code != "" {
+			result.Errors = append(result.Errors, ImportRowError{Row: row.number, Column: "employee_id", Message: fmt.Sprintf("duplicates row %d", parsed[first].number)})
+		} else if code != "" {
+			codes[code] = i
+		}
+		if email := strings.ToLower(row.employee.Email); email != "" {
+			if _, ok := emails[email]; !ok {
+				emails[email] = i
+			}
+		}
+	}
+
+	result.Errors = append(result.Errors, checkImportDuplicates(parsed, refs)...)
+
+	// Managers can be other rows of the file
+	for i, values := range data {
+		reference := ""
+		if index, ok := columns["manager"]; ok && index < len(values) {
+			reference = strings.ToLower(unescapeFormula(strings.TrimSpace(values[index])))
+		}
+		if reference == "" || parsed[i].employee.ManagerID != nil {
+			continue
+		}
+		manager, ok := codes[reference]
+		if !ok {
+			manager, ok = emails[reference]
+		}
+		if ok {
+			if manager == i {
+				result.Errors = append(result.Errors, ImportRowError{Row: parsed[i].number, Column: "manager", Message: "an employee cannot be their own manager"})
+				continue
+			}
+			parsed[i].manager = manager
+			continue
+		}
+		result.Errors = append(result.Errors, ImportRowError{Row: parsed[i].number, Column: "manager", Message: fmt.Sprintf("no employee matches %q", reference)})
+	}
+
+	order, cycleErrors := managerOrder(parsed)
+	result.Errors = append(result.Errors, cycleErrors...)
+
+	sort.SliceStable(result.Errors, func(i, j int) bool { return result.Errors[i].Row < result.Errors[j].Row })
+	if len(result.Errors) > 0 || dryRun {
+		return result, nil
+	}
+
+	employees := make([]*models.EmployeeCreate, len(order))
+	position := make(map[int]int, len(order))
+	for i, index := range order {
+		position[index] = i
+	}
+	managerOf := map[int]int{}
+	for i, index := range order {
+		row := parsed[index]
+		row.employee.RecordedBy = recordedBy
+		employees[i] = &row.employee
+		if row.manager >= 0 {
+			managerOf[i] = position[row.manager]
+		}
+	}
+
+	generated := map[int]int{}
+	for i, index := range order {
+		if employees[i].EmployeeID == "" {
+			generated[i] = parsed[index].number
+		}
+	}
+
+	created, err := s.repo.CreateEmployees(logger, employees, managerOf)
+	if err != nil {
+		return nil, err
+	}
+	result.Imported = len(employees)
+	for i, number := range generated {
+		if result.GeneratedIDs == nil {
+			result.GeneratedIDs = map[int]string{}
+		}
+		result.GeneratedIDs[number] = created[i].EmployeeID
+	}
+	return result, nil
+}
+
+// customColumn is the column name used for a custom field's values
+func customColumn(key string) string {
+	return "custom_fields." + key
+}
+
+// importHeader maps each column to its index in the header row. Custom
+// fields are named by their key.
+func importHeader(header []string, definitions []models.CustomFieldDefinition) (map[string]int, []ImportRowError) {
+	custom := map[string]string{}
+	for _, d := range definitions {
+		custom[d.Key] = customColumn(d.Key)
+	}
+
+	columns := map[string]int{}
+	var errs []ImportRowError
+	for i, name := range header {
+		normalized := strings.ToLower(strings.TrimSpace(name))
+		normalized = strings.NewReplacer(" ", "_", "-", "_").Replace(normalized)
+		if normalized == "" {
+			continue
+		}
+		column, ok := importColumns[normalized]
+		if !ok {
+			column, ok = custom[normalized]
+		}
+		if !ok {
+			errs = append(errs, ImportRowError{Row: 1, Column: name, Message: "unknown column"})
+			continue
+		}
+		if _, duplicate := columns[column]; duplicate {
+			errs = append(errs, ImportRowError{Row: 1, Column: name, Message: fmt.Sprintf("more than one column for %s", column)})
+			continue
+		}
+		columns[column] = i
+	}
+	for _, column := range requiredImportColumns {
+		if _, ok := columns[column]; !ok {
+			errs = append(errs, ImportRowError{Row: 1, Column: column, Message: "required column is missing"})
+		}
+	}
+	for _, d := range definitions {
+		if _, ok := columns[customColumn(d.Key)]; d.Required && !ok {
+			errs = append(errs, ImportRowError{Row: 1, Column: d.Key, Message: "required column is missing"})
+		}
+	}
+	return columns, errs
+}
+
+func blankRow(row []string) bool {
+	for _, value := range row {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// parseImportRow builds an employee from one row and validates it
+func parseImportRow(number int, get func(column string) string, refs *ImportReferences, definitions []models.CustomFieldDefinition) (*importRow, []ImportRowError) {
+	row := &importRow{number: number, manager: -1}
+	var errs []ImportRowError
+	fail := func(column, format string, args ...interface{}) {
+		errs = append(errs, ImportRowError{Row: number, Column: column, Message: fmt.Sprintf(format, args...)})
+	}
+
+	e := &row.employee
+	e.EmployeeID = get("employee_id")
+	e.FirstName = get("first_name")
+	e.LastName = get("last_name")
+	e.Gender = strings.ToLower(get("gender"))
+	e.MaritalStatus = strings.ToLower(get("marital_status"))
+	e.PhoneNumber = get("phone_number")
+	e.Email = get("email")
+	e.Address = get("address")
+	e.EmergencyContactName = get("emergency_contact_name")
+	e.EmergencyContactPhone = get("emergency_contact_phone")
+	e.EmploymentStatus = strings.ToLower(get("employment_status"))
+	if e.EmploymentStatus == "" {
+		e.EmploymentStatus = StatusActive
+	}
+
+	for column, target := range map[string]*time.Time{"date_of_birth": &e.DateOfBirth, "hire_date": &e.HireDate} {
+		value := get(column)
+		if value == "" {
+			continue
+		}
+		date, err := spreadsheet.ParseDate(value)
+		if err != nil {
+			fail(column, "%s", err.Error())
+			continue
+		}
+		*target = date
+	}
+
+	if value := get("department"); value != "" {
+		if id, ok := refs.Departments[strings.ToLower(value)]; ok {
+			e.DepartmentID = &id
+		} else {
+			fail("department", "no department matches %q", value)
+		}
+	}
+
+	if value := get("position"); value != "" {
+		var matches []ImportPosition
+		for _, position := range refs.Positions {
+			if position.ID.String() == strings.ToLower(value) || strings.EqualFold(position.Title, value) {
+				if e.DepartmentID == nil || position.DepartmentID == *e.DepartmentID {
+					matches = append(matches, position)
+				}
+			}
+		}
+		switch len(matches) {
+		case 0:
+			fail("position", "no position matches %q in the department", value)
+		case 1:
+			e.PositionID = &matches[0].ID
+			e.DepartmentID = &matches[0].DepartmentID
+		default:
+			fail("position", "%q matches positions in several departments, add a department column", value)
+		}
+	}
+
+	if value := get("manager"); value != "" {
+		if id, ok := refs.Employees[strings.ToLower(value)]; ok {
+			e.ManagerID = &id
+		}
+		// Otherwise the manager may be another row; ImportEmployees checks
+	}
+
+	// Without a user column the account with the employee's email is linked
+	// if there is one; otherwise the employee can be invited later
+	user, named := get("user"), true
+	if user == "" {
+		user, named = e.Email, false
+	}
+	if user != "" {
+		if id, ok := refs.Users[strings.ToLower(user)]; ok {
+			e.UserID = &id
+			if refs.UsersWithEmployee[id] {
+				fail("user", "user %q is already linked to an employee", user)
+			}
+		} else if named {
+			fail("user", "no user account matches %q; create or invite the user first", user)
+		}
+	}
+
+	for i := range definitions {
+		d := &definitions[i]
+		value := get(customColumn(d.Key))
+		if value == "" {
+			if d.Required {
+				fail(d.Key, "is required")
+			}
+			continue
+		}
+		parsed, problem := parseCustomFieldValue(d, value)
+		if problem == "" {
+			parsed, problem = checkCustomFieldValue(d, parsed)
+		}
+		if problem != "" {
+			fail(d.Key, "%s", problem)
+			continue
+		}
+		if e.CustomFields == nil {
+			e.CustomFields = models.CustomFields{}
+		}
+		e.CustomFields[d.Key] = parsed
+	}
+
+	for column, value := range map[string]string{
+		"phone_number": e.PhoneNumber, "email": e.Email, "address": e.Address,
+		"emergency_contact_name": e.EmergencyContactName, "emergency_contact_phone": e.EmergencyContactPhone,
+	} {
+		if err := fieldcrypt.CheckPlaintext(value); err != nil {
+			fail(column, "%s", err.Error())
+		}
+	}
+
+	if err := importValidator.Struct(e); err != nil {
+		var validationErrors validator.ValidationErrors
+		if !errors.As(err, &validationErrors) {
+			fail("", "%s", err.Error())
+		}
+		for _, fieldErr := range validationErrors {
+			column := fieldErr.Field()
+			switch fieldErr.Tag() {
+			case "required":
+				fail(column, "is required")
+			case "oneof":
+				fail(column, "must be one of: %s", strings.ReplaceAll(fieldErr.Param(), " ", ", "))
+			case "email":
+				fail(column, "is not a valid email address")
+			default:
+				fail(column, "is invalid")
+			}
+		}
+	}
+
+	return row, errs
+}
+
+// checkImportDuplicates reports employee IDs and emails that already exist,
+// and users and emails used by more than one row
+func checkImportDuplicates(rows []*importRow, refs *ImportReferences) []ImportRowError {
+	var errs []ImportRowError
+	emails := map[string]int{}
+	users := map[uuid.UUID]int{}
+	for _, row := range rows {
+		e := &row.employee
+		if _, exists := refs.Employees[strings.ToLower(e.EmployeeID)]; exists && e.EmployeeID != "" {
+			errs = append(errs, ImportRowError{Row: row.number, Column: "employee_id", Message: fmt.Sprintf("an employee with ID %q already exists", e.EmployeeID)})
+		} else if refs.DeletedEmployeeIDs[strings.ToLower(e.EmployeeID)] {
+			errs = append(errs, ImportRowError{Row: row.number, Column: "employee_id", Message: fmt.Sprintf("employee ID %q belongs to a deleted employee", e.EmployeeID)})
+		}
+
+		email := strings.ToLower(e.Email)
+		if _, exists := refs.Employees[email]; exists && email != "" {
+			errs = append(errs, ImportRowError{Row: row.number, Column: "email", Message: fmt.Sprintf("an employee with email %q already exists", e.Email)})
+		} else if first, ok := emails[email]; ok && email != "" {
+			errs = append(errs, ImportRowError{Row: row.number, Column: "email", Message: fmt.Sprintf("duplicates row %d", first)})
+		} else {
+			emails[email] = row.number
+		}
+
+		if e.UserID != nil {
+			if first, ok := users[*e.UserID]; ok {
+				errs = append(errs, ImportRowError{Row: row.number, Column: "user", Message: fmt.Sprintf("the user is already used by row %d", first)})
+			} else {
+				users[*e.UserID] = row.number
+			}
+		}
+	}
+	return errs
+}
+
+// managerOrder returns the row indexes ordered so that managers come before
+// their reports, and reports rows whose managers form a cycle
+func managerOrder(rows []*importRow) ([]int, []ImportRowError) {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, len(rows))
+	order := make([]int, 0, len(rows))
+	var errs []ImportRowError
+
+	var visit func(i int) bool
+	visit = func(i int) bool {
+		switch state[i] {
+		case done:
+			return true
+		case visiting:
+			return false
+		}
+		state[i] = visiting
+		if manager := rows[i].manager; manager >= 0 && !visit(manager) {
+			state[i] = done
+			errs = append(errs, ImportRowError{Row: rows[i].number, Column: "manager", Message: "managers form a cycle"})
+			return false
+		}
+		state[i] = done
+		order = append(order, i)
+		return true
+	}
+
+	for i := range rows {
+		visit(i)
+	}
+	return order, errs
+}
@@ -0,0 +1,291 @@
+package employee
+
+import (
+	"employee-management/internal/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrEmployeeNotFound is returned when the employee does not exist
+	ErrEmployeeNotFound = errors.New("employee not found")
+	// ErrPositionNotFound is returned when the requested position does not exist
+	ErrPositionNotFound = errors.New("position not found")
+	// ErrInvalidReference is returned when a department, position or manager does not exist
+	ErrInvalidReference = errors.New("department, position or manager does not exist")
+	// ErrInvalidTransition is returned when a lifecycle event does not apply to the employee's status
+	ErrInvalidTransition = errors.New("invalid employment status transition")
+	// ErrEffectiveDateTooEarly is returned when an event would take effect before the previous one
+	ErrEffectiveDateTooEarly = errors.New("effective date is before the employee's last job change")
+	// ErrEffectiveDateInFuture is returned for events dated after today
+	ErrEffectiveDateInFuture = errors.New("effective date cannot be in the future")
+	// ErrNoJobOnDate is returned when the employee had no job on the requested date
+	ErrNoJobOnDate = errors.New("employee had no job on that date")
+	// ErrNotAPromotion is returned when the new position does not pay more than the current one
+	ErrNotAPromotion = errors.New("a promotion must be to a position with a higher salary range")
+	// ErrPositionDepartmentMismatch is returned when a position belongs to another department
+	ErrPositionDepartmentMismatch = errors.New("position belongs to a different department")
+	// ErrInvalidManager is returned when an employee would become their own manager
+	ErrInvalidManager = errors.New("an employee cannot be their own manager")
+	// ErrTerminationScheduled is returned for job events recorded while a termination is scheduled
+	ErrTerminationScheduled = errors.New("the employee's termination is scheduled; cancel it first")
+	// ErrNoScheduledTermination is returned when cancelling a termination that is not scheduled
+	ErrNoScheduledTermination = errors.New("no termination is scheduled for the employee")
+)
+
+// Employment statuses
+const (
+	StatusActive     = "active"
+	StatusInactive   = "inactive"
+	StatusTerminated = "terminated"
+)
+
+// truncateDate drops the time of day, keeping the calendar date
+func truncateDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// effectiveDate defaults an optional effective date to today
+func effectiveDate(date *time.Time) time.Time {
+	if date == nil {
+		return truncateDate(time.Now())
+	}
+	return truncateDate(*date)
+}
+
+// requireStatus checks that an event applies to the employee's current status
+func requireStatus(current *models.JobHistory, action string, allowed ...string) error {
+	for _, status := range allowed {
+		if current.EmploymentStatus == status {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: cannot %s an employee who is %s", ErrInvalidTransition, action, current.EmploymentStatus)
+}
+
+// nextJob copies the current job into a new entry for an event
+func nextJob(current *models.JobHistory, eventType string, date time.Time, reason string, recordedBy *uuid.UUID) *models.JobHistory {
+	return &models.JobHistory{
+		EventType:        eventType,
+		EffectiveDate:    date,
+		DepartmentID:     current.DepartmentID,
+		PositionID:       current.PositionID,
+		ManagerID:        current.ManagerID,
+		EmploymentStatus: current.EmploymentStatus,
+		Reason:           reason,
+		RecordedBy:       recordedBy,
+	}
+}
+
+func checkManager(id uuid.UUID, managerID *uuid.UUID) error {
+	if managerID != nil && *managerID == id {
+		return ErrInvalidManager
+	}
+	return nil
+}
+
+// TransferEmployee moves an employee to another department, optionally with a
+// new position and manager. Without a new position the current one is kept,
+// so it must already belong to the new department.
+func (s *Service) TransferEmployee(logger *logrus.Entry, id uuid.UUID, data *models.EmployeeTransfer, recordedBy *uuid.UUID) (*models.JobHistory, error) {
+	logger.WithField("employeeID", id).Info("Transferring employee")
+	if err := checkManager(id, data.ManagerID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.RecordJobEvent(logger, id, func(current *models.JobHistory) (*models.JobHistory, error) {
+		if err := requireStatus(current, "transfer", StatusActive, StatusInactive); err != nil {
+			return nil, err
+		}
+
+		entry := nextJob(current, models.JobEventTransfer, effectiveDate(data.EffectiveDate), data.Reason, recordedBy)
+		entry.DepartmentID = &data.DepartmentID
+		if data.PositionID != nil {
+			entry.PositionID = data.PositionID
+		}
+		if data.ManagerID != nil {
+			entry.ManagerID = data.ManagerID
+		}
+
+		if entry.PositionID != nil {
+			position, err := s.repo.GetPosition(logger, *entry.PositionID)
+			if err != nil {
+				return nil, err
+			}
+			if position.DepartmentID != data.DepartmentID {
+				return nil, ErrPositionDepartmentMismatch
+			}
+		}
+		return entry, nil
+	})
+}
+
+// ChangePosition moves an employee to another position, and with it to the
+// position's department
+func (s *Service) ChangePosition(logger *logrus.Entry, id uuid.UUID, data *models.EmployeePositionChange, recordedBy *uuid.UUID) (*models.JobHistory, error) {
+	logger.WithField("employeeID", id).Info("Changing employee position")
+	return s.changePosition(logger, id, data, recordedBy, models.JobEventPositionChange)
+}
+
+// PromoteEmployee moves an employee to a position with a higher salary range
+func (s *Service) PromoteEmployee(logger *logrus.Entry, id uuid.UUID, data *models.EmployeePositionChange, recordedBy *uuid.UUID) (*models.JobHistory, error) {
+	logger.WithField("employeeID", id).Info("Promoting employee")
+	return s.changePosition(logger, id, data, recordedBy, models.JobEventPromotion)
+}
+
+func (s *Service) changePosition(logger *logrus.Entry, id uuid.UUID, data *models.EmployeePositionChange, recordedBy *uuid.UUID, eventType string) (*models.JobHistory, error) {
+	if err := checkManager(id, data.ManagerID); err != nil {
+		return nil, err
+	}
+	position, err := s.repo.GetPosition(logger, data.PositionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.RecordJobEvent(logger, id, func(current *models.JobHistory) (*models.JobHistory, error) {
+		if err := requireStatus(current, "change the position of", StatusActive, StatusInactive); err != nil {
+			return nil, err
+		}
+		if current.PositionID != nil && *current.PositionID == position.ID {
+			return nil, fmt.Errorf("%w: the employee already holds this position", ErrInvalidTransition)
+		}
+
+		if eventType == models.JobEventPromotion && current.PositionID != nil {
+			previous, err := s.repo.GetPosition(logger, *current.PositionID)
+			if err != nil {
+				return nil, err
+			}
+			if position.SalaryRangeMax <= previous.SalaryRangeMax {
+				return nil, ErrNotAPromotion
+			}
+		}
+
+		entry := nextJob(current, eventType, effectiveDate(data.EffectiveDate), data.Reason, recordedBy)
+		entry.PositionID = &position.ID
+		entry.DepartmentID = &position.DepartmentID
+		if data.ManagerID != nil {
+			entry.ManagerID = data.ManagerID
+		}
+		return entry, nil
+	})
+}
+
+// SuspendEmployee marks an active employee inactive
+func (s *Service) SuspendEmployee(logger *logrus.Entry, id uuid.UUID, data *models.EmployeeStatusChange, recordedBy *uuid.UUID) (*models.JobHistory, error) {
+	logger.WithField("employeeID", id).Info("Suspending employee")
+	return s.repo.RecordJobEvent(logger, id, func(current *models.JobHistory) (*models.JobHistory, error) {
+		if err := requireStatus(current, "suspend", StatusActive); err != nil {
+			return nil, err
+		}
+		entry := nextJob(current, models.JobEventSuspension, effectiveDate(data.EffectiveDate), data.Reason, recordedBy)
+		entry.EmploymentStatus = StatusInactive
+		return entry, nil
+	})
+}
+
+// ReinstateEmployee makes a suspended employee active again
+func (s *Service) ReinstateEmployee(logger *logrus.Entry, id uuid.UUID, data *models.EmployeeStatusChange, recordedBy *uuid.UUID) (*models.JobHistory, error) {
+	logger.WithField("employeeID", id).Info("Reinstating employee")
+	return s.repo.RecordJobEvent(logger, id, func(current *models.JobHistory) (*models.JobHistory, error) {
+		if err := requireStatus(current, "reinstate", StatusInactive); err != nil {
+			return nil, err
+		}
+		entry := nextJob(current, models.JobEventReinstatement, effectiveDate(data.EffectiveDate), data.Reason, recordedBy)
+		entry.EmploymentStatus = StatusActive
+		return entry, nil
+	})
+}
+
+// TerminateEmployee ends an employee's employment after their last working
+// day and deactivates their user account. The termination takes effect the
+// day after, so the employee is still employed on their last working day. A
+// last working day in the future schedules the termination: the employee
+// stays as they are until it takes effect (see ApplyScheduledTerminations).
+func (s *Service) TerminateEmployee(logger *logrus.Entry, id uuid.UUID, data *models.EmployeeTermination, recordedBy *uuid.UUID) (*models.JobHistory, error) {
+	logger.WithField("employeeID", id).Info("Terminating employee")
+	lastWorkingDay := truncateDate(data.LastWorkingDay)
+
+	return s.repo.RecordJobEvent(logger, id, func(current *models.JobHistory) (*models.JobHistory, error) {
+		if err := requireStatus(current, "terminate", StatusActive, StatusInactive); err != nil {
+			return nil, err
+		}
+		entry := nextJob(current, models.JobEventTermination, lastWorkingDay.AddDate(0, 0, 1), data.Reason, recordedBy)
+		entry.EmploymentStatus = StatusTerminated
+		entry.LastWorkingDay = &lastWorkingDay
+		return entry, nil
+	})
+}
+
+// CancelTermination withdraws a termination that has not taken effect yet
+func (s *Service) CancelTermination(logger *logrus.Entry, id uuid.UUID, recordedBy *uuid.UUID) error {
+	logger.WithField("employeeID", id).Info("Cancelling scheduled termination")
+	return s.repo.CancelTermination(logger, id, recordedBy)
+}
+
+// ApplyScheduledTerminations brings employees whose scheduled termination
+// has taken effect in line with it and deactivates their user accounts. It
+// returns how many were terminated.
+func (s *Service) ApplyScheduledTerminations(logger *logrus.Entry) (int, error) {
+	logger.Info("Applying scheduled terminations")
+	return s.repo.ApplyScheduledTerminations(logger)
+}
+
+// RehireEmployee starts a new period of employment for a terminated employee.
+// Their user account is not reactivated automatically.
+func (s *Service) RehireEmployee(logger *logrus.Entry, id uuid.UUID, data *models.EmployeeRehire, recordedBy *uuid.UUID) (*models.JobHistory, error) {
+	logger.WithField("employeeID", id).Info("Rehiring employee")
+	if err := checkManager(id, data.ManagerID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.RecordJobEvent(logger, id, func(current *models.JobHistory) (*models.JobHistory, error) {
+		if err := requireStatus(current, "rehire", StatusTerminated); err != nil {
+			return nil, err
+		}
+
+		entry := nextJob(current, models.JobEventRehire, effectiveDate(data.EffectiveDate), data.Reason, recordedBy)
+		entry.EmploymentStatus = StatusActive
+		if data.DepartmentID != nil {
+			entry.DepartmentID = data.DepartmentID
+		}
+		if data.PositionID != nil {
+			entry.PositionID = data.PositionID
+		}
+		if data.ManagerID != nil {
+			entry.ManagerID = data.ManagerID
+		}
+
+		if entry.PositionID != nil && entry.DepartmentID != nil {
+			position, err := s.repo.GetPosition(logger, *entry.PositionID)
+			if err != nil {
+				return nil, err
+			}
+			if position.DepartmentID != *entry.DepartmentID {
+				return nil, ErrPositionDepartmentMismatch
+			}
+		}
+		return entry, nil
+	})
+}
+
+// GetJobHistory retrieves an employee's job history, oldest first
+func (s *Service) GetJobHistory(logger *logrus.Entry, id uuid.UUID) ([]models.JobHistory, error) {
+	logger.WithField("employeeID", id).Info("Getting employee job history")
+	if _, err := s.repo.GetEmployeeByID(logger, id); err != nil {
+		return nil, err
+	}
+	return s.repo.ListJobHistory(logger, id)
+}
+
+// GetJobAsOf retrieves the job an employee held on a date
+func (s *Service) GetJobAsOf(logger *logrus.Entry, id uuid.UUID, date time.Time) (*models.JobHistory, error) {
+	logger.WithFields(logrus.Fields{"employeeID": id, "date": date}).Info("Getting employee job as of date")
+	if _, err := s.repo.GetEmployeeByID(logger, id); err != nil {
+		return nil, err
+	}
+	return s.repo.GetJobAsOf(logger, id, truncateDate(date))
+}
@@ -0,0 +1,75 @@
+package employee
+
+import (
+	"employee-management/internal/fieldcrypt"
+	"employee-management/internal/middleware"
+	"employee-management/internal/models"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// maskedFields are the personal details only HR, admins and the employee
+// themselves may see
+var maskedFields = []string{
+	fieldcrypt.FieldDateOfBirth,
+	fieldcrypt.FieldPhoneNumber,
+	fieldcrypt.FieldAddress,
+	fieldcrypt.FieldEmergencyContactName,
+	fieldcrypt.FieldEmergencyContactPhone,
+}
+
+// canSeeMaskedFields reports whether the current user may see the personal
+// details of every employee
+func canSeeMaskedFields(c *gin.Context) bool {
+	switch middleware.CurrentRole(c) {
+	case middleware.RoleAdmin, middleware.RoleHR:
+		return true
+	}
+	return false
+}
+
+// canSeePersonalDetails reports whether the current user may see the
+// personal details of the employee
+func canSeePersonalDetails(c *gin.Context, e *models.Employee) bool {
+	if canSeeMaskedFields(c) {
+		return true
+	}
+	userID := currentUserID(c)
+	return userID != nil && e.UserID != nil && *userID == *e.UserID
+}
+
+// maskEmployee hides the personal details of the employee from a user
+// without clearance. The last digits of phone numbers are kept so callers
+// can still tell them apart.
+func maskEmployee(c *gin.Context, e *models.Employee) {
+	if canSeePersonalDetails(c, e) {
+		return
+	}
+	e.DateOfBirth = time.Time{}
+	e.PhoneNumber = maskPhone(e.PhoneNumber)
+	e.Address = ""
+	e.EmergencyContactName = ""
+	e.EmergencyContactPhone = maskPhone(e.EmergencyContactPhone)
+	e.MaskedFields = maskedFields
+}
+
+// maskPhone replaces all but the last two digits of a phone number
+func maskPhone(phone string) string {
+	digits := fieldcrypt.NormalizePhone(phone)
+	if len(digits) <= 2 {
+		return strings.Repeat("*", len(digits))
+	}
+	return strings.Repeat("*", len(digits)-2) + digits[len(digits)-2:]
+}
+
+// restrict masks the personal details and hides the custom fields of the
+// employees that the current user may not see
+func (h *Handler) restrict(c *gin.Context, logger *logrus.Entry, employees ...*models.Employee) error {
+	for _, e := range employees {
+		maskEmployee(c, e)
+	}
+	return h.service.HideCustomFields(logger, middleware.CurrentRole(c), employees...)
+}
@@ -0,0 +1,414 @@
+package employee
+
+import (
+	"database/sql"
+	"employee-management/internal/models"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrManagerCycle is returned when a manager change would make an employee their own indirect manager
+var ErrManagerCycle = errors.New("an employee cannot report to someone who reports to them")
+
+// ListReportingLines retrieves every employee who has not left the company,
+// with the details shown in the org chart
+func (r *repository) ListReportingLines(logger *logrus.Entry) ([]models.OrgNode, error) {
+	startTime := time.Now()
+	query := `
+		SELECT e.id, e.employee_id, e.first_name, e.last_name, COALESCE(p.title, ''), e.department_id, COALESCE(d.name, ''), e.employment_status, e.manager_id
+		FROM employees e
+		LEFT JOIN positions p ON p.id = e.position_id
+		LEFT JOIN departments d ON d.id = e.department_id
+		WHERE e.deleted_at IS NULL AND e.employment_status <> $1
+		ORDER BY e.last_name, e.first_name, e.employee_id`
+	rows, err := r.db.Query(query, StatusTerminated)
+
+	logger.WithFields(logrus.Fields{
+		"query":    query,
+		"duration": time.Since(startTime),
+	}).Debug("Executed ListReportingLines query")
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []models.OrgNode
+	for rows.Next() {
+		var node models.OrgNode
+		if err := rows.Scan(&node.ID, &node.EmployeeID, &node.FirstName, &node.LastName, &node.PositionTitle, &node.DepartmentID, &node.DepartmentName, &node.EmploymentStatus, &node.ManagerID); err != nil {
+			return nil, err
+		}
+		lines = append(lines, node)
+	}
+	return lines, rows.Err()
+}
+
+// DepartmentHead is a department's place in the department tree and who
+// heads it, which employees without a manager report to
+type DepartmentHead struct {
+	ID        uuid.UUID
+	ParentID  *uuid.UUID
+	ManagerID *uuid.UUID
+}
+
+// ListDepartmentHeads retrieves the departments that have not been merged
+// away, with their parent and head
+func (r *repository) ListDepartmentHeads(logger *logrus.Entry) ([]DepartmentHead, error) {
+	startTime := time.Now()
+	query := `SELECT id, parent_id, manager_id FROM departments WHERE merged_into_id IS NULL`
+	rows, err := r.db.Query(query)
+
+	logger.WithFields(logrus.Fields{
+		"query":    query,
+		"duration": time.Since(startTime),
+	}).Debug("Executed ListDepartmentHeads query")
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var heads []DepartmentHead
+	for rows.Next() {
+		var head DepartmentHead
+		if err := rows.Scan(&head.ID, &head.ParentID, &head.ManagerID); err != nil {
+			return nil, err
+		}
+		heads = append(heads, head)
+	}
+	return heads, rows.Err()
+}
+
+// sameID reports whether two optional IDs are equal
+func sameID(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// checkReportingCycle fails if the employee is now among their own managers.
+// Manager changes take an advisory lock first, so two concurrent changes
+// cannot form a cycle that neither of them sees.
+func checkReportingCycle(tx *sql.Tx, id uuid.UUID) error {
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext('employees.manager_id'))"); err != nil {
+		return err
+	}
+
+	var cycle bool
+	err := tx.QueryRow(`
+		WITH RECURSIVE chain (id, path) AS (
+			SELECT manager_id, ARRAY[id] FROM employees WHERE id = $1 AND manager_id IS NOT NULL
+			UNION ALL
+			SELECT e.manager_id, c.path || e.id
+			FROM chain c JOIN employees e ON e.id = c.id
+			WHERE e.manager_id IS NOT NULL AND NOT e.id = ANY(c.path)
+		)
+		SELECT EXISTS (SELECT 1 FROM chain WHERE id = $1)`, id,
+	).Scan(&cycle)
+	if err != nil {
+		return err
+	}
+	if cycle {
+		return ErrManagerCycle
+	}
+	return nil
+}
+
+// orgChart indexes reporting lines by employee and by manager
+type orgChart struct {
+	nodes   map[uuid.UUID]*models.OrgNode
+	reports map[uuid.UUID][]*models.OrgNode
+	order   []*models.OrgNode
+}
+
+func newOrgChart(lines []models.OrgNode, departments []DepartmentHead) *orgChart {
+	chart := &orgChart{
+		nodes:   make(map[uuid.UUID]*models.OrgNode, len(lines)),
+		reports: map[uuid.UUID][]*models.OrgNode{},
+	}
+	for i := range lines {
+		node := &lines[i]
+		chart.nodes[node.ID] = node
+		chart.order = append(chart.order, node)
+	}
+
+	heads := make(map[uuid.UUID]DepartmentHead, len(departments))
+	for _, department := range departments {
+		heads[department.ID] = department
+	}
+	for _, node := range chart.order {
+		node.ReportsToID = chart.reportsTo(node, heads)
+		if manager := chart.manager(node); manager != nil {
+			chart.reports[manager.ID] = append(chart.reports[manager.ID], node)
+		}
+	}
+	return chart
+}
+
+// reportsTo works out who the employee reports to: their manager if they
+// are still with the company, otherwise the head of the employee's
+// department. Department heads, and employees of a department without a
+// head, report to the head of the nearest department above theirs.
+func (o *orgChart) reportsTo(node *models.OrgNode, heads map[uuid.UUID]DepartmentHead) *uuid.UUID {
+	if node.ManagerID != nil && o.nodes[*node.ManagerID] != nil {
+		return node.ManagerID
+	}
+	seen := map[uuid.UUID]bool{}
+	for id := node.DepartmentID; id != nil && !seen[*id]; {
+		seen[*id] = true
+		department, ok := heads[*id]
+		if !ok {
+			return nil
+		}
+		if head := department.ManagerID; head != nil && *head != node.ID && o.nodes[*head] != nil {
+			return head
+		}
+		id = department.ParentID
+	}
+	return nil
+}
+
+// manager returns who the employee reports to, or nil if no one
+func (o *orgChart) manager(node *models.OrgNode) *models.OrgNode {
+	if node.ReportsToID == nil {
+		return nil
+	}
+	return o.nodes[*node.ReportsToID]
+}
+
+// below lists the employee's reports breadth first, only the direct ones
+// unless transitive is set
+func (o *orgChart) below(id uuid.UUID, transitive bool) []models.OrgNode {
+	reports := []models.OrgNode{}
+	seen := map[uuid.UUID]bool{id: true}
+	current := o.reports[id]
+	for level := 1; len(current) > 0; level++ {
+		var next []*models.OrgNode
+		for _, node := range current {
+			if seen[node.ID] {
+				continue
+			}
+			seen[node.ID] = true
+			report := *node
+			report.Level = level
+			reports = append(reports, report)
+			next = append(next, o.reports[node.ID]...)
+		}
+		if !transitive {
+			break
+		}
+		current = next
+	}
+	return reports
+}
+
+// above lists the employee's managers, the direct manager first
+func (o *orgChart) above(id uuid.UUID) []models.OrgNode {
+	chain := []models.OrgNode{}
+	seen := map[uuid.UUID]bool{id: true}
+	for manager := o.manager(o.nodes[id]); manager != nil && !seen[manager.ID]; manager = o.manager(manager) {
+		seen[manager.ID] = true
+		link := *manager
+		link.Level = len(chain) + 1
+		chain = append(chain, link)
+	}
+	return chain
+}
+
+// tree copies the employee and everyone below them into a tree, skipping
+// employees already seen
+func (o *orgChart) tree(id uuid.UUID, seen map[uuid.UUID]bool) *models.OrgNode {
+	seen[id] = true
+	node := *o.nodes[id]
+	for _, report := range o.reports[id] {
+		if !seen[report.ID] {
+			node.Reports = append(node.Reports, o.tree(report.ID, seen))
+		}
+	}
+	return &node
+}
+
+// chart builds the whole company's tree. Employees in a management cycle
+// have no root above them, so each cycle is listed and drawn from its first
+// member.
+func (o *orgChart) chart() *models.OrgChart {
+	chart := &models.OrgChart{Roots: []*models.OrgNode{}}
+	seen := map[uuid.UUID]bool{}
+	for _, node := range o.order {
+		if o.manager(node) == nil {
+			chart.Roots = append(chart.Roots, o.tree(node.ID, seen))
+		}
+	}
+
+	for _, node := range o.order {
+		if seen[node.ID] {
+			continue
+		}
+		// Everyone left is in a cycle or below one. Walk up to find it.
+		walked := map[uuid.UUID]bool{}
+		for !walked[node.ID] {
+			walked[node.ID] = true
+			node = o.manager(node)
+		}
+		var cycle []uuid.UUID
+		for member := node; len(cycle) == 0 || member != node; member = o.manager(member) {
+			cycle = append(cycle, member.ID)
+		}
+		chart.Cycles = append(chart.Cycles, cycle)
+		chart.Roots = append(chart.Roots, o.tree(node.ID, seen))
+	}
+	return chart
+}
+
+// spans computes the span of control of every manager
+func (o *orgChart) spans() *models.SpanOfControlStats {
+	stats := &models.SpanOfControlStats{Employees: len(o.order), Spans: []models.SpanOfControl{}}
+	var direct []int
+	total := 0
+	for _, node := range o.order {
+		reports := o.below(node.ID, true)
+		if len(reports) == 0 {
+			continue
+		}
+		span := models.SpanOfControl{
+			ManagerID:    node.ID,
+			EmployeeID:   node.EmployeeID,
+			FirstName:    node.FirstName,
+			LastName:     node.LastName,
+			TotalReports: len(reports),
+			Depth:        reports[len(reports)-1].Level,
+		}
+		for _, report := range reports {
+			if report.Level == 1 {
+				span.DirectReports++
+			}
+		}
+		stats.Spans = append(stats.Spans, span)
+		direct = append(direct, span.DirectReports)
+		total += span.DirectReports
+		if span.DirectReports > stats.MaxSpan {
+			stats.MaxSpan = span.DirectReports
+		}
+		if span.Depth > stats.Depth {
+			stats.Depth = span.Depth
+		}
+	}
+
+	stats.Managers = len(direct)
+	if stats.Managers > 0 {
+		stats.AverageSpan = float64(total) / float64(stats.Managers)
+		sort.Ints(direct)
+		middle := len(direct) / 2
+		stats.MedianSpan = float64(direct[middle])
+		if len(direct)%2 == 0 {
+			stats.MedianSpan = float64(direct[middle-1]+direct[middle]) / 2
+		}
+	}
+	sort.SliceStable(stats.Spans, func(i, j int) bool {
+		return stats.Spans[i].DirectReports > stats.Spans[j].DirectReports
+	})
+	return stats
+}
+
+// orgChartDOT renders a chart in Graphviz DOT format. The reporting line
+// closing each management cycle is drawn in red.
+func orgChartDOT(chart *models.OrgChart) string {
+	var b strings.Builder
+	b.WriteString("digraph org_chart {\n")
+	b.WriteString("\trankdir=TB;\n")
+	b.WriteString("\tnode [shape=box];\n")
+
+	var write func(node *models.OrgNode)
+	write = func(node *models.OrgNode) {
+		label := node.FirstName + " " + node.LastName
+		if node.PositionTitle != "" {
+			label += "\n" + node.PositionTitle
+		}
+		fmt.Fprintf(&b, "\t%s [label=%s];\n", strconv.Quote(node.ID.String()), strconv.Quote(label))
+		for _, report := range node.Reports {
+			fmt.Fprintf(&b, "\t%s -> %s;\n", strconv.Quote(node.ID.String()), strconv.Quote(report.ID.String()))
+			write(report)
+		}
+	}
+	for _, root := range chart.Roots {
+		write(root)
+	}
+	for _, cycle := range chart.Cycles {
+		// Each cycle is drawn from its first member, whose manager is the last one
+		fmt.Fprintf(&b, "\t%s -> %s [color=red];\n", strconv.Quote(cycle[1%len(cycle)].String()), strconv.Quote(cycle[0].String()))
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// loadOrgChart loads the reporting lines and department heads and checks the employee is among them
+func (s *Service) loadOrgChart(logger *logrus.Entry, id *uuid.UUID) (*orgChart, error) {
+	lines, err := s.repo.ListReportingLines(logger)
+	if err != nil {
+		return nil, err
+	}
+	departments, err := s.repo.ListDepartmentHeads(logger)
+	if err != nil {
+		return nil, err
+	}
+	chart := newOrgChart(lines, departments)
+	if id != nil && chart.nodes[*id] == nil {
+		return nil, ErrEmployeeNotFound
+	}
+	return chart, nil
+}
+
+// GetReports retrieves the employees reporting to a manager, only the
+// direct reports unless transitive is set
+func (s *Service) GetReports(logger *logrus.Entry, id uuid.UUID, transitive bool) ([]models.OrgNode, error) {
+	logger.WithFields(logrus.Fields{"employeeID": id, "transitive": transitive}).Info("Getting reports")
+	chart, err := s.loadOrgChart(logger, &id)
+	if err != nil {
+		return nil, err
+	}
+	return chart.below(id, transitive), nil
+}
+
+// GetChainOfCommand retrieves an employee's managers up to the top of the company
+func (s *Service) GetChainOfCommand(logger *logrus.Entry, id uuid.UUID) ([]models.OrgNode, error) {
+	logger.WithField("employeeID", id).Info("Getting chain of command")
+	chart, err := s.loadOrgChart(logger, &id)
+	if err != nil {
+		return nil, err
+	}
+	return chart.above(id), nil
+}
+
+// GetOrgChart retrieves the reporting tree of the whole company, or of the
+// part below root if given
+func (s *Service) GetOrgChart(logger *logrus.Entry, root *uuid.UUID) (*models.OrgChart, error) {
+	logger.WithField("root", root).Info("Getting org chart")
+	chart, err := s.loadOrgChart(logger, root)
+	if err != nil {
+		return nil, err
+	}
+	if root != nil {
+		return &models.OrgChart{Roots: []*models.OrgNode{chart.tree(*root, map[uuid.UUID]bool{})}}, nil
+	}
+	return chart.chart(), nil
+}
+
+// GetSpanOfControl computes span-of-control statistics for every manager
+func (s *Service) GetSpanOfControl(logger *logrus.Entry) (*models.SpanOfControlStats, error) {
+	logger.Info("Getting span of control statistics")
+	chart, err := s.loadOrgChart(logger, nil)
+	if err != nil {
+		return nil, err
+	}
+	return chart.spans(), nil
+}
@@ -1,12 +1,20 @@
 package employee
 
 import (
+	"database/sql"
+	"employee-management/internal/auth"
 	"employee-management/internal/database"
+	"employee-management/internal/fieldcrypt"
 	"employee-management/internal/models"
+	"employee-management/internal/position"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/sirupsen/logrus"
 )
 
@@ -15,39 +23,171 @@ type Repository interface {
 	CreateEmployee(logger *logrus.Entry, employeeData *models.EmployeeCreate) (*models.Employee, error)
 	GetEmployeeByID(logger *logrus.Entry, id uuid.UUID) (*models.Employee, error)
 	UpdateEmployee(logger *logrus.Entry, id uuid.UUID, employeeData *models.EmployeeUpdate) (*models.Employee, error)
-	DeleteEmployee(logger *logrus.Entry, id uuid.UUID) error
+	DeleteEmployee(logger *logrus.Entry, id uuid.UUID, deletedBy *uuid.UUID) error
+	RestoreEmployee(logger *logrus.Entry, id uuid.UUID) (*models.Employee, error)
+	AnonymizeEmployees(logger *logrus.Entry, deletedBefore time.Time) (int, error)
 	ListEmployees(logger *logrus.Entry) ([]models.Employee, error)
+	SearchEmployees(logger *logrus.Entry, filter *SearchFilter) (*EmployeePage, error)
+	RecordJobEvent(logger *logrus.Entry, id uuid.UUID, next func(current *models.JobHistory) (*models.JobHistory, error)) (*models.JobHistory, error)
+	CancelTermination(logger *logrus.Entry, id uuid.UUID, recordedBy *uuid.UUID) error
+	ApplyScheduledTerminations(logger *logrus.Entry) (int, error)
+	ListJobHistory(logger *logrus.Entry, id uuid.UUID) ([]models.JobHistory, error)
+	GetJobAsOf(logger *logrus.Entry, id uuid.UUID, date time.Time) (*models.JobHistory, error)
+	GetPosition(logger *logrus.Entry, id uuid.UUID) (*models.Position, error)
+	ListEmployeeVersions(logger *logrus.Entry, id uuid.UUID, filter *HistoryFilter) ([]models.EmployeeVersion, error)
+	CreateEmployees(logger *logrus.Entry, employees []*models.EmployeeCreate, managerOf map[int]int) ([]*models.Employee, error)
+	LoadImportReferences(logger *logrus.Entry) (*ImportReferences, error)
+	GetEmployeeByUserID(logger *logrus.Entry, userID uuid.UUID) (*models.Employee, error)
+	CreateChangeRequest(logger *logrus.Entry, request *models.EmployeeChangeRequest) (*models.EmployeeChangeRequest, error)
+	ListChangeRequests(logger *logrus.Entry, filter *ChangeRequestFilter) ([]models.EmployeeChangeRequest, error)
+	ReviewChangeRequest(logger *logrus.Entry, id uuid.UUID, approve bool, reviewedBy *uuid.UUID, note string) (*models.EmployeeChangeRequest, error)
+	ListReportingLines(logger *logrus.Entry) ([]models.OrgNode, error)
+	ListDepartmentHeads(logger *logrus.Entry) ([]DepartmentHead, error)
+	ReencryptEmployees(logger *logrus.Entry, batchSize int) (*ReencryptResult, error)
+	ListCustomFields(logger *logrus.Entry) ([]models.CustomFieldDefinition, error)
+	GetCustomField(logger *logrus.Entry, id uuid.UUID) (*models.CustomFieldDefinition, error)
+	CreateCustomField(logger *logrus.Entry, d *models.CustomFieldDefinition) (*models.CustomFieldDefinition, error)
+	UpdateCustomField(logger *logrus.Entry, d *models.CustomFieldDefinition) (*models.CustomFieldDefinition, error)
+	DeleteCustomField(logger *logrus.Entry, id uuid.UUID) error
+	ListNumberTemplates(logger *logrus.Entry) ([]models.EmployeeNumberTemplate, error)
+	CreateNumberTemplate(logger *logrus.Entry, t *models.EmployeeNumberTemplate) (*models.EmployeeNumberTemplate, error)
+	UpdateNumberTemplate(logger *logrus.Entry, id uuid.UUID, pattern string) (*models.EmployeeNumberTemplate, error)
+	DeleteNumberTemplate(logger *logrus.Entry, id uuid.UUID) error
+	TransferDepartmentWith(tx *sql.Tx, from, to uuid.UUID, reason string, recordedBy *uuid.UUID) (int64, error)
+	MergeEmployees(logger *logrus.Entry, id, duplicateID uuid.UUID, mergedBy *uuid.UUID, record func(tx *sql.Tx, merge *models.EmployeeMerge) error) (*models.EmployeeMerge, error)
 }
 
 // repository is the implementation of the Repository interface
 type repository struct {
-	db *database.DB
+	db     *database.DB
+	cipher *fieldcrypt.Cipher
 }
 
-// NewRepository creates a new employee repository
-func NewRepository(db *database.DB) Repository {
+// NewRepository creates a new employee repository. Sensitive fields are
+// encrypted with cipher, which is nil if field encryption is not configured.
+func NewRepository(db *database.DB, cipher *fieldcrypt.Cipher) Repository {
 	return &repository{
-		db: db,
+		db:     db,
+		cipher: cipher,
 	}
 }
 
-// CreateEmployee creates a new employee in the database
+// withTx runs fn in a transaction, committing if it returns nil
+func (r *repository) withTx(fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// employeeColumns are the columns read for an employee
+const employeeColumns = `id, user_id, employee_id, first_name, last_name, date_of_birth, gender, marital_status, phone_number, email, address, emergency_contact_name, emergency_contact_phone, department_id, position_id, hire_date, employment_status, manager_id, created_at, updated_at, deleted_at, deleted_by, anonymized_at, date_of_birth_encrypted, custom_fields`
+
+// employeeFields returns the scan destinations for employeeColumns. The date
+// of birth is NULL when it is encrypted.
+func employeeFields(employee *models.Employee, dateOfBirth *sql.NullTime, dateOfBirthEncrypted *sql.NullString) []interface{} {
+	return []interface{}{&employee.ID, &employee.UserID, &employee.EmployeeID, &employee.FirstName, &employee.LastName, dateOfBirth, &employee.Gender, &employee.MaritalStatus, &employee.PhoneNumber, &employee.Email, &employee.Address, &employee.EmergencyContactName, &employee.EmergencyContactPhone, &employee.DepartmentID, &employee.PositionID, &employee.HireDate, &employee.EmploymentStatus, &employee.ManagerID, &employee.CreatedAt, &employee.UpdatedAt, &employee.DeletedAt, &employee.DeletedBy, &employee.AnonymizedAt, dateOfBirthEncrypted, jsonColumn{&employee.CustomFields}}
+}
+
+// scanEmployee scans employeeColumns and decrypts the encrypted fields
+func (r *repository) scanEmployee(row interface{ Scan(...interface{}) error }, employee *models.Employee) error {
+	var dateOfBirth sql.NullTime
+	var dateOfBirthEncrypted sql.NullString
+	if err := row.Scan(employeeFields(employee, &dateOfBirth, &dateOfBirthEncrypted)...); err != nil {
+		return err
+	}
+	employee.DateOfBirth = dateOfBirth.Time
+	return r.openEmployee(employee, dateOfBirthEncrypted)
+}
+
+// createEmployeeQuery inserts an employee
+const createEmployeeQuery = `
+		INSERT INTO employees (user_id, employee_id, first_name, last_name, date_of_birth, gender, marital_status, phone_number, email, address, emergency_contact_name, emergency_contact_phone, department_id, position_id, hire_date, employment_status, manager_id, date_of_birth_encrypted, email_index, phone_number_index, custom_fields)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)
+		RETURNING ` + employeeColumns
+
+// createEmployee inserts an employee and starts their record and job history.
+// An employee ID is generated if none is given.
+func (r *repository) createEmployee(tx *sql.Tx, employeeData *models.EmployeeCreate) (*models.Employee, error) {
+	plain := &models.Employee{
+		DateOfBirth:           employeeData.DateOfBirth,
+		PhoneNumber:           employeeData.PhoneNumber,
+		Email:                 employeeData.Email,
+		Address:               employeeData.Address,
+		EmergencyContactName:  employeeData.EmergencyContactName,
+		EmergencyContactPhone: employeeData.EmergencyContactPhone,
+	}
+	if err := checkPlaintext(plain); err != nil {
+		return nil, err
+	}
+	stored, err := r.sealEmployee(plain)
+	if err != nil {
+		return nil, err
+	}
+	customFields, err := customFieldsJSON(employeeData.CustomFields)
+	if err != nil {
+		return nil, err
+	}
+	if employeeData.EmployeeID == "" {
+		if employeeData.EmployeeID, err = nextEmployeeNumber(tx, employeeData.DepartmentID, employeeData.HireDate); err != nil {
+			return nil, err
+		}
+	}
+
+	var employee models.Employee
+	err = r.scanEmployee(tx.QueryRow(createEmployeeQuery,
+		employeeData.UserID, employeeData.EmployeeID, employeeData.FirstName, employeeData.LastName, stored.DateOfBirth, employeeData.Gender, employeeData.MaritalStatus, stored.PhoneNumber, stored.Email, stored.Address, stored.EmergencyContactName, stored.EmergencyContactPhone, employeeData.DepartmentID, employeeData.PositionID, employeeData.HireDate, employeeData.EmploymentStatus, employeeData.ManagerID, stored.DateOfBirthEncrypted, stored.EmailIndex, stored.PhoneIndex, customFields,
+	), &employee)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" && pqErr.Constraint == "employees_employee_id_key" {
+		return nil, fmt.Errorf("%w: %s", ErrEmployeeIDTaken, employeeData.EmployeeID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.recordInitialVersion(tx, &employee, employeeData.RecordedBy); err != nil {
+		return nil, err
+	}
+
+	_, err = insertJobHistory(tx, &models.JobHistory{
+		EmployeeID:       employee.ID,
+		EventType:        models.JobEventHire,
+		EffectiveDate:    employee.HireDate,
+		DepartmentID:     employeeData.DepartmentID,
+		PositionID:       employeeData.PositionID,
+		ManagerID:        employeeData.ManagerID,
+		EmploymentStatus: employee.EmploymentStatus,
+		RecordedBy:       employeeData.RecordedBy,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if employeeData.PositionID != nil && employee.EmploymentStatus != StatusTerminated {
+		if err := position.FillRequisitionWith(tx, *employeeData.PositionID); err != nil {
+			return nil, err
+		}
+	}
+	return &employee, nil
+}
+
+// CreateEmployee creates a new employee in the database and records their
+// hire as the first job history entry
 func (r *repository) CreateEmployee(logger *logrus.Entry, employeeData *models.EmployeeCreate) (*models.Employee, error) {
 	startTime := time.Now()
-	var employee models.Employee
-	query := `
-		INSERT INTO employees (user_id, employee_id, first_name, last_name, date_of_birth, gender, marital_status, phone_number, email, address, emergency_contact_name, emergency_contact_phone, department_id, position_id, hire_date, employment_status, manager_id)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
-		RETURNING id, user_id, employee_id, first_name, last_name, date_of_birth, gender, marital_status, phone_number, email, address, emergency_contact_name, emergency_contact_phone, department_id, position_id, hire_date, employment_status, manager_id, created_at, updated_at
-	`
-	err := r.db.QueryRow(query,
-		employeeData.UserID, employeeData.EmployeeID, employeeData.FirstName, employeeData.LastName, employeeData.DateOfBirth, employeeData.Gender, employeeData.MaritalStatus, employeeData.PhoneNumber, employeeData.Email, employeeData.Address, employeeData.EmergencyContactName, employeeData.EmergencyContactPhone, employeeData.DepartmentID, employeeData.PositionID, employeeData.HireDate, employeeData.EmploymentStatus, employeeData.ManagerID,
-	).Scan(
-		&employee.ID, &employee.UserID, &employee.EmployeeID, &employee.FirstName, &employee.LastName, &employee.DateOfBirth, &employee.Gender, &employee.MaritalStatus, &employee.PhoneNumber, &employee.Email, &employee.Address, &employee.EmergencyContactName, &employee.EmergencyContactPhone, &employee.DepartmentID, &employee.PositionID, &employee.HireDate, &employee.EmploymentStatus, &employee.ManagerID, &employee.CreatedAt, &employee.UpdatedAt,
-	)
+	var employee *models.Employee
+	err := r.withTx(func(tx *sql.Tx) (err error) {
+		employee, err = r.createEmployee(tx, employeeData)
+		return err
+	})
 
 	logger.WithFields(logrus.Fields{
-		"query":    query,
+		"query":    createEmployeeQuery,
 		"duration": time.Since(startTime),
 	}).Debug("Executed CreateEmployee query")
 
@@ -55,20 +195,145 @@ func (r *repository) CreateEmployee(logger *logrus.Entry, employeeData *models.E
 		return nil, err
 	}
 
-	return &employee, nil
+	return employee, nil
+}
+
+// CreateEmployees creates several employees in a single transaction. Rows
+// are created in order; managerOf maps a row's index to the index of its
+// manager in the batch, which must come earlier.
+func (r *repository) CreateEmployees(logger *logrus.Entry, employees []*models.EmployeeCreate, managerOf map[int]int) ([]*models.Employee, error) {
+	startTime := time.Now()
+	created := make([]*models.Employee, len(employees))
+	err := r.withTx(func(tx *sql.Tx) error {
+		for i, employeeData := range employees {
+			if manager, ok := managerOf[i]; ok {
+				if manager >= i {
+					return fmt.Errorf("row %d is created before its manager", i)
+				}
+				employeeData.ManagerID = &created[manager].ID
+			}
+			employee, err := r.createEmployee(tx, employeeData)
+			if err != nil {
+				return fmt.Errorf("employee %s: %w", employeeData.EmployeeID, err)
+			}
+			created[i] = employee
+		}
+		return nil
+	})
+
+	logger.WithFields(logrus.Fields{
+		"rows":     len(employees),
+		"duration": time.Since(startTime),
+	}).Debug("Executed CreateEmployees transaction")
+
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// LoadImportReferences retrieves the departments, positions, users and
+// employees import rows can refer to
+func (r *repository) LoadImportReferences(logger *logrus.Entry) (*ImportReferences, error) {
+	startTime := time.Now()
+	refs := &ImportReferences{
+		Departments:        map[string]uuid.UUID{},
+		Users:              map[string]uuid.UUID{},
+		Employees:          map[string]uuid.UUID{},
+		DeletedEmployeeIDs: map[string]bool{},
+		UsersWithEmployee:  map[uuid.UUID]bool{},
+	}
+
+	queries := []struct {
+		query string
+		scan  func(rows *sql.Rows) error
+	}{
+		{"SELECT id, name FROM departments", func(rows *sql.Rows) error {
+			var id uuid.UUID
+			var name string
+			if err := rows.Scan(&id, &name); err != nil {
+				return err
+			}
+			refs.Departments[strings.ToLower(name)] = id
+			refs.Departments[id.String()] = id
+			return nil
+		}},
+		{"SELECT id, title, department_id FROM positions", func(rows *sql.Rows) error {
+			var position ImportPosition
+			if err := rows.Scan(&position.ID, &position.Title, &position.DepartmentID); err != nil {
+				return err
+			}
+			refs.Positions = append(refs.Positions, position)
+			return nil
+		}},
+		{"SELECT id, username, email FROM users", func(rows *sql.Rows) error {
+			var id uuid.UUID
+			var username, email string
+			if err := rows.Scan(&id, &username, &email); err != nil {
+				return err
+			}
+			refs.Users[strings.ToLower(username)] = id
+			refs.Users[strings.ToLower(email)] = id
+			refs.Users[id.String()] = id
+			return nil
+		}},
+		{"SELECT id, employee_id, email, user_id, deleted_at IS NOT NULL FROM employees", func(rows *sql.Rows) error {
+			var id uuid.UUID
+			var userID uuid.NullUUID
+			var code, email string
+			var deleted bool
+			if err := rows.Scan(&id, &code, &email, &userID, &deleted); err != nil {
+				return err
+			}
+			if _, err := r.openValue(fieldcrypt.FieldEmail, email, &email); err != nil {
+				return err
+			}
+			// Deleted employees keep their employee ID but cannot be referred to
+			if deleted {
+				refs.DeletedEmployeeIDs[strings.ToLower(code)] = true
+				return nil
+			}
+			refs.Employees[strings.ToLower(code)] = id
+			refs.Employees[strings.ToLower(email)] = id
+			refs.Employees[id.String()] = id
+			if userID.Valid {
+				refs.UsersWithEmployee[userID.UUID] = true
+			}
+			return nil
+		}},
+	}
+
+	for _, q := range queries {
+		rows, err := r.db.Query(q.query)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			if err := q.scan(rows); err != nil {
+				rows.Close()
+				return nil, err
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	logger.WithFields(logrus.Fields{
+		"duration": time.Since(startTime),
+	}).Debug("Executed LoadImportReferences queries")
+
+	return refs, nil
 }
 
 // GetEmployeeByID retrieves an employee by their ID from the database
 func (r *repository) GetEmployeeByID(logger *logrus.Entry, id uuid.UUID) (*models.Employee, error) {
 	startTime := time.Now()
 	var employee models.Employee
-	query := `
-		SELECT id, user_id, employee_id, first_name, last_name, date_of_birth, gender, marital_status, phone_number, email, address, emergency_contact_name, emergency_contact_phone, department_id, position_id, hire_date, employment_status, manager_id, created_at, updated_at
-		FROM employees WHERE id = $1
-	`
-	err := r.db.QueryRow(query, id).Scan(
-		&employee.ID, &employee.UserID, &employee.EmployeeID, &employee.FirstName, &employee.LastName, &employee.DateOfBirth, &employee.Gender, &employee.MaritalStatus, &employee.PhoneNumber, &employee.Email, &employee.Address, &employee.EmergencyContactName, &employee.EmergencyContactPhone, &employee.DepartmentID, &employee.PositionID, &employee.HireDate, &employee.EmploymentStatus, &employee.ManagerID, &employee.CreatedAt, &employee.UpdatedAt,
-	)
+	query := `SELECT ` + employeeColumns + ` FROM employees WHERE id = $1 AND deleted_at IS NULL`
+	err := r.scanEmployee(r.db.QueryRow(query, id), &employee)
 
 	logger.WithFields(logrus.Fields{
 		"query":    query,
@@ -76,97 +341,615 @@ func (r *repository) GetEmployeeByID(logger *logrus.Entry, id uuid.UUID) (*model
 	}).Debug("Executed GetEmployeeByID query")
 
 	if err != nil {
-		return nil, errors.New("employee not found")
+		return nil, ErrEmployeeNotFound
 	}
 
 	return &employee, nil
 }
 
-// UpdateEmployee updates an existing employee's information in the database
+// UpdateEmployee records a change to an employee's personal information or
+// hire date and returns the employee as they are now. Job details change
+// through lifecycle events.
 func (r *repository) UpdateEmployee(logger *logrus.Entry, id uuid.UUID, employeeData *models.EmployeeUpdate) (*models.Employee, error) {
 	startTime := time.Now()
-	var employee models.Employee
-	query := `
-		UPDATE employees
-		SET first_name = $1, last_name = $2, date_of_birth = $3, gender = $4, marital_status = $5, phone_number = $6, email = $7, address = $8, emergency_contact_name = $9, emergency_contact_phone = $10, department_id = $11, position_id = $12, hire_date = $13, employment_status = $14, manager_id = $15, updated_at = NOW()
-		WHERE id = $16
-		RETURNING id, user_id, employee_id, first_name, last_name, date_of_birth, gender, marital_status, phone_number, email, address, emergency_contact_name, emergency_contact_phone, department_id, position_id, hire_date, employment_status, manager_id, created_at, updated_at
-	`
-	err := r.db.QueryRow(query,
-		employeeData.FirstName, employeeData.LastName, employeeData.DateOfBirth, employeeData.Gender, employeeData.MaritalStatus, employeeData.PhoneNumber, employeeData.Email, employeeData.Address, employeeData.EmergencyContactName, employeeData.EmergencyContactPhone, employeeData.DepartmentID, employeeData.PositionID, employeeData.HireDate, employeeData.EmploymentStatus, employeeData.ManagerID, id,
-	).Scan(
-		&employee.ID, &employee.UserID, &employee.EmployeeID, &employee.FirstName, &employee.LastName, &employee.DateOfBirth, &employee.Gender, &employee.MaritalStatus, &employee.PhoneNumber, &employee.Email, &employee.Address, &employee.EmergencyContactName, &employee.EmergencyContactPhone, &employee.DepartmentID, &employee.PositionID, &employee.HireDate, &employee.EmploymentStatus, &employee.ManagerID, &employee.CreatedAt, &employee.UpdatedAt,
-	)
+
+	changes := map[string]interface{}{}
+	for field, value := range map[string]string{
+		"first_name":              employeeData.FirstName,
+		"last_name":               employeeData.LastName,
+		"gender":                  employeeData.Gender,
+		"marital_status":          employeeData.MaritalStatus,
+		"phone_number":            employeeData.PhoneNumber,
+		"email":                   employeeData.Email,
+		"address":                 employeeData.Address,
+		"emergency_contact_name":  employeeData.EmergencyContactName,
+		"emergency_contact_phone": employeeData.EmergencyContactPhone,
+	} {
+		if value != "" {
+			changes[field] = value
+		}
+	}
+	if employeeData.DateOfBirth != nil {
+		changes["date_of_birth"] = truncateDate(*employeeData.DateOfBirth)
+	}
+	if employeeData.HireDate != nil {
+		changes["hire_date"] = truncateDate(*employeeData.HireDate)
+	}
+	if len(employeeData.CustomFields) > 0 {
+		changes["custom_fields"] = employeeData.CustomFields
+	}
+
+	validFrom := effectiveDate(employeeData.EffectiveDate)
+	if validFrom.After(truncateDate(time.Now())) {
+		return nil, ErrEffectiveDateInFuture
+	}
+
+	err := r.withTx(func(tx *sql.Tx) error {
+		var locked uuid.UUID
+		err := tx.QueryRow("SELECT id FROM employees WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", id).Scan(&locked)
+		if err == sql.ErrNoRows {
+			return ErrEmployeeNotFound
+		}
+		if err != nil {
+			return err
+		}
+		return r.recordChange(tx, id, validFrom, changes, ChangeUpdate, employeeData.RecordedBy)
+	})
+
+	logger.WithFields(logrus.Fields{
+		"duration": time.Since(startTime),
+	}).Debug("Executed UpdateEmployee transaction")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetEmployeeByID(logger, id)
+}
+
+// ListEmployees retrieves a list of all employees from the database
+func (r *repository) ListEmployees(logger *logrus.Entry) ([]models.Employee, error) {
+	startTime := time.Now()
+	var employees []models.Employee
+	query := `SELECT ` + employeeColumns + ` FROM employees WHERE deleted_at IS NULL`
+	rows, err := r.db.Query(query)
 
 	logger.WithFields(logrus.Fields{
 		"query":    query,
 		"duration": time.Since(startTime),
-	}).Debug("Executed UpdateEmployee query")
+	}).Debug("Executed ListEmployees query")
 
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	return &employee, nil
+	for rows.Next() {
+		var employee models.Employee
+		if err := r.scanEmployee(rows, &employee); err != nil {
+			return nil, err
+		}
+		employees = append(employees, employee)
+	}
+
+	return employees, nil
 }
 
-// DeleteEmployee deletes an employee by their ID from the database
-func (r *repository) DeleteEmployee(logger *logrus.Entry, id uuid.UUID) error {
+// SearchEmployees retrieves one page of employees matching the filter, using
+// keyset pagination on the sort column and ID
+func (r *repository) SearchEmployees(logger *logrus.Entry, filter *SearchFilter) (*EmployeePage, error) {
 	startTime := time.Now()
-	query := "DELETE FROM employees WHERE id = $1"
-	result, err := r.db.Exec(query, id)
+
+	field, descending, err := parseSort(filter.Sort)
+	if err != nil {
+		return nil, err
+	}
+	// Encrypted emails are ciphertext, which neither orders nor pages by address
+	if field == "email" && r.cipher.Encrypts(fieldcrypt.FieldEmail) {
+		return nil, fmt.Errorf("%w: email is encrypted", ErrInvalidSort)
+	}
+	sortColumn := sortColumns[field]
+	sortKey := field
+	if descending {
+		sortKey = "-" + field
+	}
+
+	conditions := []string{"deleted_at IS NULL"}
+	if filter.Deleted {
+		conditions[0] = "deleted_at IS NOT NULL"
+	}
+	var args []interface{}
+	addCondition := func(format string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(format, len(args)))
+	}
+
+	if query := searchQuery(filter.Query); query != "" {
+		addCondition(searchVector+" @@ to_tsquery('simple', $%d)", query)
+	}
+	// Exact lookups use the blind index. Rows written before field encryption
+	// was configured have none until cmd/rotate-field-keys has run, so their
+	// plaintext is compared instead.
+	addLookup := func(indexColumn, plainColumn, plain string, index []byte) {
+		args = append(args, plain)
+		condition := fmt.Sprintf("%s = $%d", plainColumn, len(args))
+		if index != nil {
+			args = append(args, index)
+			condition = fmt.Sprintf("(%s = $%d OR (%s IS NULL AND %s))", indexColumn, len(args), indexColumn, condition)
+		}
+		conditions = append(conditions, condition)
+	}
+	if filter.Email != "" {
+		addLookup("email_index", "LOWER(email)", strings.ToLower(strings.TrimSpace(filter.Email)), r.cipher.EmailIndex(filter.Email))
+	}
+	if filter.Phone != "" {
+		addLookup("phone_number_index", `regexp_replace(phone_number, '\D', '', 'g')`, fieldcrypt.NormalizePhone(filter.Phone), r.cipher.PhoneIndex(filter.Phone))
+	}
+	if filter.DepartmentID != nil {
+		addCondition("department_id = $%d", *filter.DepartmentID)
+	}
+	if filter.PositionID != nil {
+		addCondition("position_id = $%d", *filter.PositionID)
+	}
+	if filter.ManagerID != nil {
+		addCondition("manager_id = $%d", *filter.ManagerID)
+	}
+	if len(filter.EmploymentStatus) > 0 {
+		addCondition("employment_status = ANY($%d)", pq.Array(filter.EmploymentStatus))
+	}
+	if filter.HiredFrom != nil {
+		addCondition("hire_date >= $%d", *filter.HiredFrom)
+	}
+	if filter.HiredTo != nil {
+		addCondition("hire_date <= $%d", *filter.HiredTo)
+	}
+	if len(filter.CustomFields) > 0 {
+		values, err := json.Marshal(filter.CustomFields)
+		if err != nil {
+			return nil, err
+		}
+		addCondition("custom_fields @> $%d", values)
+	}
+
+	direction, comparison := "ASC", ">"
+	if descending {
+		direction, comparison = "DESC", "<"
+	}
+	if filter.Cursor != "" {
+		after, err := decodeCursor(filter.Cursor, sortKey)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, after.Value, after.ID)
+		conditions = append(conditions, fmt.Sprintf("(%s, id) %s ($%d::%s, $%d)",
+			sortColumn.column, comparison, len(args)-1, sortColumn.sqlType, len(args)))
+	}
+
+	where := "WHERE " + strings.Join(conditions, " AND ")
+
+	limit := filter.Limit
+	if limit < 1 || limit > maxPageSize {
+		limit = defaultPageSize
+	}
+	// Fetch one extra row to find out whether there is a next page
+	args = append(args, limit+1)
+
+	query := fmt.Sprintf(`
+		SELECT `+employeeColumns+`
+		FROM employees
+		%s
+		ORDER BY %s %s, id %s
+		LIMIT $%d
+	`, where, sortColumn.column, direction, direction, len(args))
+	rows, err := r.db.Query(query, args...)
 
 	logger.WithFields(logrus.Fields{
 		"query":    query,
 		"duration": time.Since(startTime),
-	}).Debug("Executed DeleteEmployee query")
+	}).Debug("Executed SearchEmployees query")
 
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	rowsAffected, err := result.RowsAffected()
+	page := &EmployeePage{Employees: []models.Employee{}}
+	for rows.Next() {
+		var employee models.Employee
+		if err := r.scanEmployee(rows, &employee); err != nil {
+			return nil, err
+		}
+		page.Employees = append(page.Employees, employee)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Employees) > limit {
+		page.Employees = page.Employees[:limit]
+		last := &page.Employees[limit-1]
+		page.NextCursor = encodeCursor(cursor{Sort: sortKey, Value: sortValue(last, field), ID: last.ID})
+	}
+
+	return page, nil
+}
+
+// jobHistoryColumns are the columns read for a job history entry
+const jobHistoryColumns = `id, employee_id, event_type, effective_date, department_id, position_id, manager_id, employment_status, COALESCE(reason, ''), last_working_day, recorded_by, created_at`
+
+func scanJobHistory(row interface{ Scan(...interface{}) error }, entry *models.JobHistory) error {
+	return row.Scan(&entry.ID, &entry.EmployeeID, &entry.EventType, &entry.EffectiveDate, &entry.DepartmentID, &entry.PositionID, &entry.ManagerID, &entry.EmploymentStatus, &entry.Reason, &entry.LastWorkingDay, &entry.RecordedBy, &entry.CreatedAt)
+}
+
+func insertJobHistory(tx *sql.Tx, entry *models.JobHistory) (*models.JobHistory, error) {
+	var recorded models.JobHistory
+	err := scanJobHistory(tx.QueryRow(`
+		INSERT INTO employee_job_history (employee_id, event_type, effective_date, department_id, position_id, manager_id, employment_status, reason, last_working_day, recorded_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING `+jobHistoryColumns,
+		entry.EmployeeID, entry.EventType, entry.EffectiveDate, entry.DepartmentID, entry.PositionID, entry.ManagerID, entry.EmploymentStatus,
+		sql.NullString{String: entry.Reason, Valid: entry.Reason != ""}, entry.LastWorkingDay, entry.RecordedBy,
+	), &recorded)
 	if err != nil {
-		return err
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return nil, ErrInvalidReference
+		}
+		return nil, err
 	}
+	return &recorded, nil
+}
 
-	if rowsAffected == 0 {
-		return errors.New("employee not found")
+// lockLatestJob locks the employee and returns their user, their current job
+// and the type of their latest job history entry. The job's effective date is
+// that of the latest entry, which is in the future for a scheduled
+// termination.
+func lockLatestJob(tx *sql.Tx, id uuid.UUID) (uuid.NullUUID, *models.JobHistory, string, error) {
+	var userID uuid.NullUUID
+	var latestEvent string
+	current := &models.JobHistory{EmployeeID: id}
+	err := tx.QueryRow(`
+		SELECT e.user_id, e.department_id, e.position_id, e.manager_id, e.employment_status,
+		       COALESCE(h.effective_date, e.hire_date), COALESCE(h.event_type, '')
+		FROM employees e
+		LEFT JOIN LATERAL (
+			SELECT effective_date, event_type FROM employee_job_history
+			WHERE employee_id = e.id
+			ORDER BY effective_date DESC, created_at DESC
+			LIMIT 1
+		) h ON true
+		WHERE e.id = $1 AND e.deleted_at IS NULL
+		FOR UPDATE OF e`, id,
+	).Scan(&userID, &current.DepartmentID, &current.PositionID, &current.ManagerID, &current.EmploymentStatus, &current.EffectiveDate, &latestEvent)
+	if err == sql.ErrNoRows {
+		return userID, nil, "", ErrEmployeeNotFound
 	}
+	return userID, current, latestEvent, err
+}
+
+// RecordJobEvent locks the employee, passes their current job to next and
+// records the entry it returns, updating the employee to match. Only
+// terminations may take effect in the future; until they do, the employee
+// is left as they are and nothing else can be recorded.
+func (r *repository) RecordJobEvent(logger *logrus.Entry, id uuid.UUID, next func(current *models.JobHistory) (*models.JobHistory, error)) (*models.JobHistory, error) {
+	startTime := time.Now()
+	var recorded *models.JobHistory
+	err := r.withTx(func(tx *sql.Tx) error {
+		userID, current, latestEvent, err := lockLatestJob(tx, id)
+		if err != nil {
+			return err
+		}
+		today := truncateDate(time.Now())
+		if latestEvent == models.JobEventTermination && current.EffectiveDate.After(today) {
+			return ErrTerminationScheduled
+		}
+
+		entry, err := next(current)
+		if err != nil {
+			return err
+		}
+		entry.EmployeeID = id
+		entry.EffectiveDate = truncateDate(entry.EffectiveDate)
+		if entry.EffectiveDate.Before(current.EffectiveDate) {
+			return ErrEffectiveDateTooEarly
+		}
+		scheduled := entry.EffectiveDate.After(today)
+		if scheduled && entry.EventType != models.JobEventTermination {
+			return ErrEffectiveDateInFuture
+		}
+
+		if recorded, err = insertJobHistory(tx, entry); err != nil {
+			return err
+		}
+
+		changes := map[string]interface{}{
+			"department_id":     entry.DepartmentID,
+			"position_id":       entry.PositionID,
+			"manager_id":        entry.ManagerID,
+			"employment_status": entry.EmploymentStatus,
+		}
+		// A rehire restarts the employee's current period of employment
+		if entry.EventType == models.JobEventRehire {
+			changes["hire_date"] = entry.EffectiveDate
+		}
+		if err := r.recordChange(tx, id, entry.EffectiveDate, changes, entry.EventType, entry.RecordedBy); err != nil {
+			return err
+		}
+		if !sameID(entry.ManagerID, current.ManagerID) {
+			if err := checkReportingCycle(tx, id); err != nil {
+				return err
+			}
+		}
+		// Joining a position fills a seat of its open requisitions
+		joined := entry.EventType == models.JobEventRehire || !sameID(entry.PositionID, current.PositionID)
+		if joined && entry.PositionID != nil && entry.EmploymentStatus != StatusTerminated {
+			if err := position.FillRequisitionWith(tx, *entry.PositionID); err != nil {
+				return err
+			}
+		}
 
-	return nil
+		// Terminated employees lose access to the system
+		if entry.EventType == models.JobEventTermination && !scheduled && userID.Valid {
+			if _, err := auth.DeactivateUserWith(tx, userID.UUID); err != nil && !errors.Is(err, auth.ErrUserNotFound) {
+				return err
+			}
+		}
+		return nil
+	})
+
+	logger.WithFields(logrus.Fields{
+		"duration": time.Since(startTime),
+	}).Debug("Executed RecordJobEvent transaction")
+
+	if err != nil {
+		return nil, err
+	}
+	return recorded, nil
 }
 
-// ListEmployees retrieves a list of all employees from the database
-func (r *repository) ListEmployees(logger *logrus.Entry) ([]models.Employee, error) {
+// CancelTermination deletes an employee's scheduled termination from their
+// job history and withdraws the change it made to their record
+func (r *repository) CancelTermination(logger *logrus.Entry, id uuid.UUID, recordedBy *uuid.UUID) error {
+	startTime := time.Now()
+	err := r.withTx(func(tx *sql.Tx) error {
+		_, current, latestEvent, err := lockLatestJob(tx, id)
+		if err != nil {
+			return err
+		}
+		if latestEvent != models.JobEventTermination || !current.EffectiveDate.After(truncateDate(time.Now())) {
+			return ErrNoScheduledTermination
+		}
+
+		_, err = tx.Exec("DELETE FROM employee_job_history WHERE employee_id = $1 AND event_type = $2 AND effective_date = $3",
+			id, models.JobEventTermination, current.EffectiveDate)
+		if err != nil {
+			return err
+		}
+		// The employee row still holds the status from before the termination
+		changes := map[string]interface{}{"employment_status": current.EmploymentStatus}
+		return r.recordChange(tx, id, current.EffectiveDate, changes, ChangeUpdate, recordedBy)
+	})
+
+	logger.WithFields(logrus.Fields{
+		"duration": time.Since(startTime),
+	}).Debug("Executed CancelTermination transaction")
+
+	return err
+}
+
+// ApplyScheduledTerminations terminates the employees whose latest job
+// history entry in effect is a termination their record does not reflect
+// yet, each in its own transaction, and deactivates their user accounts.
+// The last active admin keeps their account.
+func (r *repository) ApplyScheduledTerminations(logger *logrus.Entry) (int, error) {
 	startTime := time.Now()
-	var employees []models.Employee
 	query := `
-		SELECT id, user_id, employee_id, first_name, last_name, date_of_birth, gender, marital_status, phone_number, email, address, emergency_contact_name, emergency_contact_phone, department_id, position_id, hire_date, employment_status, manager_id, created_at, updated_at
-		FROM employees
+		SELECT e.id FROM employees e
+		JOIN LATERAL (
+			SELECT event_type FROM employee_job_history
+			WHERE employee_id = e.id AND effective_date <= $1
+			ORDER BY effective_date DESC, created_at DESC
+			LIMIT 1
+		) h ON true
+		WHERE e.deleted_at IS NULL AND e.employment_status <> $2 AND h.event_type = $3
+		ORDER BY e.id
 	`
-	rows, err := r.db.Query(query)
+	rows, err := r.db.Query(query, truncateDate(time.Now()), StatusTerminated, models.JobEventTermination)
+	if err != nil {
+		return 0, err
+	}
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	terminated := 0
+	for _, id := range ids {
+		err := r.withTx(func(tx *sql.Tx) error {
+			var userID uuid.NullUUID
+			err := tx.QueryRow("SELECT user_id FROM employees WHERE id = $1 AND deleted_at IS NULL AND employment_status <> $2 FOR UPDATE",
+				id, StatusTerminated).Scan(&userID)
+			if err == sql.ErrNoRows {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			versions, err := r.currentVersions(tx, id)
+			if err != nil {
+				return err
+			}
+			if err := r.syncEmployee(tx, id, currentVersion(versions)); err != nil {
+				return err
+			}
+			terminated++
+
+			if !userID.Valid {
+				return nil
+			}
+			_, err = auth.DeactivateUserWith(tx, userID.UUID)
+			if errors.Is(err, auth.ErrLastAdmin) {
+				logger.WithField("employeeID", id).Warn("Kept the user account of a terminated employee who is the last active admin")
+				return nil
+			}
+			if err != nil && !errors.Is(err, auth.ErrUserNotFound) {
+				return err
+			}
+			return nil
+		})
+		if err != nil {
+			return terminated, err
+		}
+	}
+
+	logger.WithFields(logrus.Fields{
+		"query":      query,
+		"terminated": terminated,
+		"duration":   time.Since(startTime),
+	}).Debug("Executed ApplyScheduledTerminations transactions")
+
+	return terminated, nil
+}
+
+// TransferDepartmentWith transfers the current employees of a department to
+// another one as of today, inside the caller's transaction, and returns how
+// many moved. Deleted and terminated employees keep their department.
+func (r *repository) TransferDepartmentWith(tx *sql.Tx, from, to uuid.UUID, reason string, recordedBy *uuid.UUID) (int64, error) {
+	rows, err := tx.Query(`
+		SELECT id, position_id, manager_id, employment_status FROM employees
+		WHERE department_id = $1 AND deleted_at IS NULL AND employment_status <> $2
+		ORDER BY id FOR UPDATE`, from, StatusTerminated)
+	if err != nil {
+		return 0, err
+	}
+	var entries []*models.JobHistory
+	for rows.Next() {
+		entry := &models.JobHistory{
+			EventType:     models.JobEventTransfer,
+			EffectiveDate: truncateDate(time.Now()),
+			DepartmentID:  &to,
+			Reason:        reason,
+			RecordedBy:    recordedBy,
+		}
+		if err := rows.Scan(&entry.EmployeeID, &entry.PositionID, &entry.ManagerID, &entry.EmploymentStatus); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		entries = append(entries, entry)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, entry := range entries {
+		if _, err := insertJobHistory(tx, entry); err != nil {
+			return 0, err
+		}
+		// A scheduled termination was recorded in the old department
+		_, err := tx.Exec("UPDATE employee_job_history SET department_id = $1 WHERE employee_id = $2 AND department_id = $3 AND effective_date > $4",
+			to, entry.EmployeeID, from, entry.EffectiveDate)
+		if err != nil {
+			return 0, err
+		}
+		changes := map[string]interface{}{"department_id": entry.DepartmentID}
+		if err := r.recordChange(tx, entry.EmployeeID, entry.EffectiveDate, changes, entry.EventType, recordedBy); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(entries)), nil
+}
+
+// ListJobHistory retrieves an employee's job history, oldest first, with the
+// date each entry stopped being in effect
+func (r *repository) ListJobHistory(logger *logrus.Entry, id uuid.UUID) ([]models.JobHistory, error) {
+	startTime := time.Now()
+	query := `
+		SELECT ` + jobHistoryColumns + `,
+		       LEAD(effective_date) OVER (ORDER BY effective_date, created_at)
+		FROM employee_job_history
+		WHERE employee_id = $1
+		ORDER BY effective_date, created_at
+	`
+	rows, err := r.db.Query(query, id)
 
 	logger.WithFields(logrus.Fields{
 		"query":    query,
 		"duration": time.Since(startTime),
-	}).Debug("Executed ListEmployees query")
+	}).Debug("Executed ListJobHistory query")
 
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	history := []models.JobHistory{}
 	for rows.Next() {
-		var employee models.Employee
-		err := rows.Scan(
-			&employee.ID, &employee.UserID, &employee.EmployeeID, &employee.FirstName, &employee.LastName, &employee.DateOfBirth, &employee.Gender, &employee.MaritalStatus, &employee.PhoneNumber, &employee.Email, &employee.Address, &employee.EmergencyContactName, &employee.EmergencyContactPhone, &employee.DepartmentID, &employee.PositionID, &employee.HireDate, &employee.EmploymentStatus, &employee.ManagerID, &employee.CreatedAt, &employee.UpdatedAt,
-		)
+		var entry models.JobHistory
+		err := rows.Scan(&entry.ID, &entry.EmployeeID, &entry.EventType, &entry.EffectiveDate, &entry.DepartmentID, &entry.PositionID, &entry.ManagerID, &entry.EmploymentStatus, &entry.Reason, &entry.LastWorkingDay, &entry.RecordedBy, &entry.CreatedAt, &entry.EffectiveTo)
 		if err != nil {
 			return nil, err
 		}
-		employees = append(employees, employee)
+		history = append(history, entry)
 	}
 
-	return employees, nil
+	return history, rows.Err()
+}
+
+// GetJobAsOf retrieves the job history entry in effect on a date
+func (r *repository) GetJobAsOf(logger *logrus.Entry, id uuid.UUID, date time.Time) (*models.JobHistory, error) {
+	startTime := time.Now()
+	var entry models.JobHistory
+	query := `
+		SELECT ` + jobHistoryColumns + `,
+		       (SELECT MIN(effective_date) FROM employee_job_history n WHERE n.employee_id = h.employee_id AND n.effective_date > $2)
+		FROM employee_job_history h
+		WHERE employee_id = $1 AND effective_date <= $2
+		ORDER BY effective_date DESC, created_at DESC
+		LIMIT 1
+	`
+	err := r.db.QueryRow(query, id, date).Scan(&entry.ID, &entry.EmployeeID, &entry.EventType, &entry.EffectiveDate, &entry.DepartmentID, &entry.PositionID, &entry.ManagerID, &entry.EmploymentStatus, &entry.Reason, &entry.LastWorkingDay, &entry.RecordedBy, &entry.CreatedAt, &entry.EffectiveTo)
+
+	logger.WithFields(logrus.Fields{
+		"query":    query,
+		"duration": time.Since(startTime),
+	}).Debug("Executed GetJobAsOf query")
+
+	if err == sql.ErrNoRows {
+		return nil, ErrNoJobOnDate
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// GetPosition retrieves a position by its ID
+func (r *repository) GetPosition(logger *logrus.Entry, id uuid.UUID) (*models.Position, error) {
+	startTime := time.Now()
+	var position models.Position
+	query := `SELECT id, title, department_id, description, requirements, salary_range_min, salary_range_max, created_at, updated_at FROM positions WHERE id = $1`
+	err := r.db.QueryRow(query, id).Scan(&position.ID, &position.Title, &position.DepartmentID, &position.Description, &position.Requirements, &position.SalaryRangeMin, &position.SalaryRangeMax, &position.CreatedAt, &position.UpdatedAt)
+
+	logger.WithFields(logrus.Fields{
+		"query":    query,
+		"duration": time.Since(startTime),
+	}).Debug("Executed GetPosition query")
+
+	if err == sql.ErrNoRows {
+		return nil, ErrPositionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &position, nil
 }
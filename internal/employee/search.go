@@ -0,0 +1,136 @@
+package employee
+
+import (
+	"employee-management/internal/models"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+var (
+	// ErrInvalidCursor is returned when a cursor is malformed or was issued for a different sort
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrInvalidSort is returned when sorting by an unsupported field
+	ErrInvalidSort = errors.New("invalid sort field")
+)
+
+// sortColumns maps the sort fields accepted by the API to their columns and
+// the SQL type used to compare cursor values. Every column is NOT NULL so
+// keyset pagination never has to deal with nulls.
+var sortColumns = map[string]struct{ column, sqlType string }{
+	"last_name":   {"last_name", "text"},
+	"first_name":  {"first_name", "text"},
+	"email":       {"email", "text"},
+	"employee_id": {"employee_id", "text"},
+	"hire_date":   {"hire_date", "date"},
+}
+
+// searchVector is the full-text document for an employee. It must stay in
+// sync with the idx_employees_search index expression.
+const searchVector = `to_tsvector('simple', first_name || ' ' || last_name || ' ' || email || ' ' || translate(email, '@.', '  ') || ' ' || employee_id)`
+
+// SearchFilter narrows down, sorts and pages a list of employees
+type SearchFilter struct {
+	// Query is matched against name, email and employee ID. Each word matches
+	// as a prefix and all words must match.
+	Query string
+	// Email and Phone match exactly, ignoring case and phone number
+	// formatting. They use the blind indexes, so they work on encrypted fields.
+	Email            string
+	Phone            string
+	DepartmentID     *uuid.UUID
+	PositionID       *uuid.UUID
+	ManagerID        *uuid.UUID
+	EmploymentStatus []string
+	HiredFrom        *time.Time
+	HiredTo          *time.Time
+	// CustomFields matches employees holding all of the given custom field values
+	CustomFields models.CustomFields
+	// Sort is a field from sortColumns, prefixed with "-" for descending order
+	Sort   string
+	Limit  int
+	Cursor string
+	// Deleted lists deleted employees instead of current ones
+	Deleted bool
+}
+
+// EmployeePage is one page of search results. NextCursor is empty on the last page.
+type EmployeePage struct {
+	Employees  []models.Employee `json:"employees"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+// cursor marks the last row of a page: its sort value and ID break ties
+// between rows with equal sort values so pages never overlap or skip rows
+type cursor struct {
+	Sort  string    `json:"s"`
+	Value string    `json:"v"`
+	ID    uuid.UUID `json:"id"`
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(raw, sort string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Sort != sort || c.ID == uuid.Nil {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// parseSort splits a sort parameter into its field and direction
+func parseSort(sort string) (field string, descending bool, err error) {
+	field = strings.TrimPrefix(sort, "-")
+	if field == "" {
+		field = "last_name"
+	}
+	if _, ok := sortColumns[field]; !ok {
+		return "", false, ErrInvalidSort
+	}
+	return field, strings.HasPrefix(sort, "-"), nil
+}
+
+// sortValue returns the cursor value of an employee for a sort field
+func sortValue(e *models.Employee, field string) string {
+	switch field {
+	case "first_name":
+		return e.FirstName
+	case "email":
+		return e.Email
+	case "employee_id":
+		return e.EmployeeID
+	case "hire_date":
+		return e.HireDate.Format("2006-01-02")
+	default:
+		return e.LastName
+	}
+}
+
+var searchTermPattern = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+// searchQuery turns free text into a tsquery that prefix-matches every word.
+// Only letters and digits are kept so user input cannot inject tsquery syntax.
+func searchQuery(text string) string {
+	terms := searchTermPattern.FindAllString(strings.ToLower(text), 10)
+	for i, term := range terms {
+		terms[i] = term + ":*"
+	}
+	return strings.Join(terms, " & ")
+}
@@ -0,0 +1,41 @@
+package employee
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	want := cursor{Sort: "-hire_date", Value: "2026-01-01", ID: uuid.New()}
+	got, err := decodeCursor(encodeCursor(want), "-hire_date")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != want {
+		t.Errorf("decodeCursor = %+v, want %+v", *got, want)
+	}
+}
+
+func TestDecodeCursorRejectsInvalid(t *testing.T) {
+	valid := encodeCursor(cursor{Sort: "last_name", Value: "Doe", ID: uuid.New()})
+	tests := []struct {
+		name string
+		raw  string
+		sort string
+	}{
+		{"not base64", "!!", "last_name"},
+		{"not JSON", base64.RawURLEncoding.EncodeToString([]byte("Doe")), "last_name"},
+		{"other sort", valid, "-last_name"},
+		{"missing ID", encodeCursor(cursor{Sort: "last_name", Value: "Doe"}), "last_name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeCursor(tt.raw, tt.sort); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("decodeCursor error = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,311 @@
+package employee
+
+import (
+	"database/sql"
+	"employee-management/internal/fieldcrypt"
+	"employee-management/internal/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrNoLinkedEmployee is returned when the user has no employee record
+	ErrNoLinkedEmployee = errors.New("no employee record is linked to this user")
+	// ErrChangeRequestNotFound is returned when the change request does not exist
+	ErrChangeRequestNotFound = errors.New("change request not found")
+	// ErrChangeRequestClosed is returned when reviewing a request that is no longer pending
+	ErrChangeRequestClosed = errors.New("change request has already been reviewed")
+	// ErrOwnChangeRequest is returned when HR staff review their own change request
+	ErrOwnChangeRequest = errors.New("you cannot review your own change request")
+)
+
+// profileFieldsNeedingApproval are the self-service fields HR has to approve.
+// The address decides tax jurisdiction and where payroll mail goes.
+var profileFieldsNeedingApproval = map[string]bool{
+	"address": true,
+}
+
+// ProfileUpdateResult is the outcome of an employee updating their own
+// record. ChangeRequest holds the changes waiting for HR approval, if any.
+type ProfileUpdateResult struct {
+	Employee      *models.Employee              `json:"employee"`
+	ChangeRequest *models.EmployeeChangeRequest `json:"change_request,omitempty"`
+}
+
+// ChangeRequestFilter narrows down a list of change requests
+type ChangeRequestFilter struct {
+	EmployeeID *uuid.UUID
+	Status     string
+}
+
+// GetEmployeeByUserID retrieves the employee linked to a user account
+func (r *repository) GetEmployeeByUserID(logger *logrus.Entry, userID uuid.UUID) (*models.Employee, error) {
+	startTime := time.Now()
+	var employee models.Employee
+	query := `SELECT ` + employeeColumns + ` FROM employees WHERE user_id = $1 AND deleted_at IS NULL ORDER BY hire_date DESC LIMIT 1`
+	err := r.scanEmployee(r.db.QueryRow(query, userID), &employee)
+
+	logger.WithFields(logrus.Fields{
+		"query":    query,
+		"duration": time.Since(startTime),
+	}).Debug("Executed GetEmployeeByUserID query")
+
+	if err == sql.ErrNoRows {
+		return nil, ErrNoLinkedEmployee
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &employee, nil
+}
+
+const changeRequestColumns = `id, employee_id, requested_by, changes, status, reviewed_by, reviewed_at, COALESCE(review_note, ''), created_at`
+
+func (r *repository) scanChangeRequest(row interface{ Scan(...interface{}) error }) (*models.EmployeeChangeRequest, error) {
+	var request models.EmployeeChangeRequest
+	var changes []byte
+	err := row.Scan(&request.ID, &request.EmployeeID, &request.RequestedBy, &changes, &request.Status, &request.ReviewedBy, &request.ReviewedAt, &request.ReviewNote, &request.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(changes, &request.Changes); err != nil {
+		return nil, err
+	}
+	if err := r.openChanges(request.Changes); err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// CreateChangeRequest stores a change request, cancelling the employee's
+// previous pending one so the latest request is the one HR reviews
+func (r *repository) CreateChangeRequest(logger *logrus.Entry, request *models.EmployeeChangeRequest) (*models.EmployeeChangeRequest, error) {
+	startTime := time.Now()
+	for field, value := range request.Changes {
+		if err := fieldcrypt.CheckPlaintext(value); err != nil {
+			return nil, fmt.Errorf("%s: %w", field, err)
+		}
+	}
+	sealed, err := r.sealChanges(request.Changes)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := json.Marshal(sealed)
+	if err != nil {
+		return nil, err
+	}
+
+	var created *models.EmployeeChangeRequest
+	err = r.withTx(func(tx *sql.Tx) error {
+		if err := cancelChangeRequests(tx, request.EmployeeID); err != nil {
+			return err
+		}
+		var err error
+		created, err = r.scanChangeRequest(tx.QueryRow(`
+			INSERT INTO employee_change_requests (employee_id, requested_by, changes, status)
+			VALUES ($1, $2, $3, $4)
+			RETURNING `+changeRequestColumns,
+			request.EmployeeID, request.RequestedBy, changes, models.ChangeRequestPending))
+		return err
+	})
+
+	logger.WithFields(logrus.Fields{
+		"duration": time.Since(startTime),
+	}).Debug("Executed CreateChangeRequest transaction")
+
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// ListChangeRequests retrieves change requests matching the filter, oldest first
+func (r *repository) ListChangeRequests(logger *logrus.Entry, filter *ChangeRequestFilter) ([]models.EmployeeChangeRequest, error) {
+	startTime := time.Now()
+	conditions := []string{"TRUE"}
+	var args []interface{}
+	addCondition := func(format string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(format, len(args)))
+	}
+	if filter.EmployeeID != nil {
+		addCondition("employee_id = $%d", *filter.EmployeeID)
+	}
+	if filter.Status != "" {
+		addCondition("status = $%d", filter.Status)
+	}
+	query := `SELECT ` + changeRequestColumns + ` FROM employee_change_requests WHERE ` + strings.Join(conditions, " AND ") + ` ORDER BY created_at`
+	rows, err := r.db.Query(query, args...)
+
+	logger.WithFields(logrus.Fields{
+		"query":    query,
+		"duration": time.Since(startTime),
+	}).Debug("Executed ListChangeRequests query")
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []models.EmployeeChangeRequest{}
+	for rows.Next() {
+		request, err := r.scanChangeRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, *request)
+	}
+	return requests, rows.Err()
+}
+
+// ReviewChangeRequest approves or rejects a pending change request. An
+// approved request is applied to the employee record as of today.
+func (r *repository) ReviewChangeRequest(logger *logrus.Entry, id uuid.UUID, approve bool, reviewedBy *uuid.UUID, note string) (*models.EmployeeChangeRequest, error) {
+	startTime := time.Now()
+	var reviewed *models.EmployeeChangeRequest
+	err := r.withTx(func(tx *sql.Tx) error {
+		request, err := r.scanChangeRequest(tx.QueryRow(`SELECT `+changeRequestColumns+` FROM employee_change_requests WHERE id = $1 FOR UPDATE`, id))
+		if err == sql.ErrNoRows {
+			return ErrChangeRequestNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if request.Status != models.ChangeRequestPending {
+			return ErrChangeRequestClosed
+		}
+		if reviewedBy != nil && request.RequestedBy != nil && *reviewedBy == *request.RequestedBy {
+			return ErrOwnChangeRequest
+		}
+
+		status := models.ChangeRequestRejected
+		if approve {
+			status = models.ChangeRequestApproved
+			var locked uuid.UUID
+			err := tx.QueryRow("SELECT id FROM employees WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", request.EmployeeID).Scan(&locked)
+			if err == sql.ErrNoRows {
+				return ErrEmployeeNotFound
+			}
+			if err != nil {
+				return err
+			}
+			changes := map[string]interface{}{}
+			for field, value := range request.Changes {
+				changes[field] = value
+			}
+			if err := r.recordChange(tx, request.EmployeeID, truncateDate(time.Now()), changes, ChangeUpdate, reviewedBy); err != nil {
+				return err
+			}
+		}
+
+		reviewed, err = r.scanChangeRequest(tx.QueryRow(`
+			UPDATE employee_change_requests
+			SET status = $2, reviewed_by = $3, reviewed_at = NOW(), review_note = NULLIF($4, '')
+			WHERE id = $1
+			RETURNING `+changeRequestColumns,
+			id, status, reviewedBy, note))
+		return err
+	})
+
+	logger.WithFields(logrus.Fields{
+		"duration": time.Since(startTime),
+	}).Debug("Executed ReviewChangeRequest transaction")
+
+	if err != nil {
+		return nil, err
+	}
+	return reviewed, nil
+}
+
+// cancelChangeRequests cancels the pending change requests of employees
+func cancelChangeRequests(tx *sql.Tx, employeeIDs ...uuid.UUID) error {
+	_, err := tx.Exec("UPDATE employee_change_requests SET status = $2 WHERE employee_id = ANY($1) AND status = $3",
+		pq.Array(employeeIDs), models.ChangeRequestCancelled, models.ChangeRequestPending)
+	return err
+}
+
+// GetEmployeeByUserID retrieves the employee record of a user
+func (s *Service) GetEmployeeByUserID(logger *logrus.Entry, userID uuid.UUID) (*models.Employee, error) {
+	logger.WithField("userID", userID).Info("Getting employee by user ID")
+	return s.repo.GetEmployeeByUserID(logger, userID)
+}
+
+// UpdateOwnProfile applies a user's changes to their own employee record.
+// Changes to fields in profileFieldsNeedingApproval become a change request
+// for HR instead; the rest are applied immediately.
+func (s *Service) UpdateOwnProfile(logger *logrus.Entry, userID uuid.UUID, data *models.ProfileUpdate) (*ProfileUpdateResult, error) {
+	logger.WithField("userID", userID).Info("Updating own employee profile")
+	employee, err := s.repo.GetEmployeeByUserID(logger, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	requested := map[string]*string{
+		"phone_number":            data.PhoneNumber,
+		"address":                 data.Address,
+		"emergency_contact_name":  data.EmergencyContactName,
+		"emergency_contact_phone": data.EmergencyContactPhone,
+	}
+	current := map[string]string{
+		"phone_number":            employee.PhoneNumber,
+		"address":                 employee.Address,
+		"emergency_contact_name":  employee.EmergencyContactName,
+		"emergency_contact_phone": employee.EmergencyContactPhone,
+	}
+	direct := map[string]string{}
+	needsApproval := map[string]string{}
+	for field, value := range requested {
+		if value == nil || *value == current[field] {
+			continue
+		}
+		if profileFieldsNeedingApproval[field] {
+			needsApproval[field] = *value
+		} else {
+			direct[field] = *value
+		}
+	}
+
+	result := &ProfileUpdateResult{Employee: employee}
+	if len(direct) > 0 {
+		update := &models.EmployeeUpdate{
+			PhoneNumber:           direct["phone_number"],
+			Address:               direct["address"],
+			EmergencyContactName:  direct["emergency_contact_name"],
+			EmergencyContactPhone: direct["emergency_contact_phone"],
+			RecordedBy:            &userID,
+		}
+		if result.Employee, err = s.repo.UpdateEmployee(logger, employee.ID, update); err != nil {
+			return nil, err
+		}
+	}
+	if len(needsApproval) > 0 {
+		result.ChangeRequest, err = s.repo.CreateChangeRequest(logger, &models.EmployeeChangeRequest{
+			EmployeeID:  employee.ID,
+			RequestedBy: &userID,
+			Changes:     needsApproval,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// ListChangeRequests retrieves change requests for HR to review
+func (s *Service) ListChangeRequests(logger *logrus.Entry, filter *ChangeRequestFilter) ([]models.EmployeeChangeRequest, error) {
+	logger.WithField("status", filter.Status).Info("Listing change requests")
+	return s.repo.ListChangeRequests(logger, filter)
+}
+
+// ReviewChangeRequest approves or rejects a change request
+func (s *Service) ReviewChangeRequest(logger *logrus.Entry, id uuid.UUID, approve bool, reviewedBy *uuid.UUID, note string) (*models.EmployeeChangeRequest, error) {
+	logger.WithFields(logrus.Fields{"changeRequestID": id, "approve": approve}).Info("Reviewing change request")
+	return s.repo.ReviewChangeRequest(logger, id, approve, reviewedBy, note)
+}
@@ -1,6 +1,7 @@
 package employee
 
 import (
+	"employee-management/internal/audit"
 	"employee-management/internal/models"
 
 	"github.com/google/uuid"
@@ -9,19 +10,26 @@ import (
 
 // Service handles employee-related operations
 type Service struct {
-	repo Repository
+	repo  Repository
+	audit *audit.Recorder
 }
 
 // NewService creates a new employee service
-func NewService(repo Repository) *Service {
+func NewService(repo Repository, recorder *audit.Recorder) *Service {
 	return &Service{
-		repo: repo,
+		repo:  repo,
+		audit: recorder,
 	}
 }
 
 // CreateEmployee creates a new employee
 func (s *Service) CreateEmployee(logger *logrus.Entry, employeeData *models.EmployeeCreate) (*models.Employee, error) {
 	logger.Info("Creating a new employee")
+	customFields, err := s.checkCustomFields(logger, employeeData.CustomFields, false)
+	if err != nil {
+		return nil, err
+	}
+	employeeData.CustomFields = customFields
 	return s.repo.CreateEmployee(logger, employeeData)
 }
 
@@ -34,17 +42,24 @@ func (s *Service) GetEmployeeByID(logger *logrus.Entry, id uuid.UUID) (*models.E
 // UpdateEmployee updates an existing employee's information
 func (s *Service) UpdateEmployee(logger *logrus.Entry, id uuid.UUID, employeeData *models.EmployeeUpdate) (*models.Employee, error) {
 	logger.WithField("employeeID", id).Info("Updating employee")
+	if len(employeeData.CustomFields) > 0 {
+		customFields, err := s.checkCustomFields(logger, employeeData.CustomFields, true)
+		if err != nil {
+			return nil, err
+		}
+		employeeData.CustomFields = customFields
+	}
 	return s.repo.UpdateEmployee(logger, id, employeeData)
 }
 
-// DeleteEmployee deletes an employee by their ID
-func (s *Service) DeleteEmployee(logger *logrus.Entry, id uuid.UUID) error {
-	logger.WithField("employeeID", id).Info("Deleting employee")
-	return s.repo.DeleteEmployee(logger, id)
-}
-
 // ListEmployees retrieves a list of all employees
 func (s *Service) ListEmployees(logger *logrus.Entry) ([]models.Employee, error) {
 	logger.Info("Listing all employees")
 	return s.repo.ListEmployees(logger)
 }
+
+// SearchEmployees retrieves a page of employees matching the filter
+func (s *Service) SearchEmployees(logger *logrus.Entry, filter *SearchFilter) (*EmployeePage, error) {
+	logger.WithField("query", filter.Query).Info("Searching employees")
+	return s.repo.SearchEmployees(logger, filter)
+}
@@ -0,0 +1,279 @@
+package fieldcrypt
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Fields that can be encrypted. The employee fields are named like their
+// JSON fields.
+const (
+	FieldDateOfBirth           = "date_of_birth"
+	FieldPhoneNumber           = "phone_number"
+	FieldEmail                 = "email"
+	FieldAddress               = "address"
+	FieldEmergencyContactName  = "emergency_contact_name"
+	FieldEmergencyContactPhone = "emergency_contact_phone"
+	FieldSalaryAmount          = "salary_amount"
+)
+
+// DefaultFields are encrypted unless FIELD_ENCRYPTION_FIELDS says otherwise.
+// Email is left out because encrypting it disables searching and sorting by it.
+var DefaultFields = []string{
+	FieldDateOfBirth, FieldPhoneNumber, FieldAddress,
+	FieldEmergencyContactName, FieldEmergencyContactPhone, FieldSalaryAmount,
+}
+
+var supportedFields = map[string]bool{
+	FieldDateOfBirth: true, FieldPhoneNumber: true, FieldEmail: true, FieldAddress: true,
+	FieldEmergencyContactName: true, FieldEmergencyContactPhone: true, FieldSalaryAmount: true,
+}
+
+// prefix starts every encrypted value. The full format is
+// enc:v1:<master key ID>:<wrapped data key>:<nonce and ciphertext>, with both
+// binary parts in unpadded base64url.
+const prefix = "enc:v1:"
+
+var (
+	// ErrMalformed is returned for encrypted values that cannot be parsed
+	ErrMalformed = errors.New("malformed encrypted value")
+	// ErrNoKeys is returned when reading an encrypted value without encryption keys configured
+	ErrNoKeys = errors.New("value is encrypted but no field encryption keys are configured")
+	// ErrReservedPrefix is returned for plaintext values that would be taken for encrypted ones
+	ErrReservedPrefix = errors.New("value must not start with " + prefix)
+)
+
+var encoding = base64.RawURLEncoding
+
+// Cipher encrypts and decrypts field values. A nil Cipher encrypts nothing
+// and computes no blind indexes, so callers need not check whether field
+// encryption is configured.
+type Cipher struct {
+	kms      KMS
+	indexKey []byte
+	fields   map[string]bool
+
+	mu sync.Mutex
+	// current is the data key new values are encrypted with
+	current *dataKey
+	// unwrapped caches data keys by master key ID and wrapped key
+	unwrapped map[string][]byte
+}
+
+type dataKey struct {
+	masterKeyID string
+	wrapped     string
+	key         []byte
+}
+
+// New creates a cipher encrypting the given fields
+func New(kms KMS, indexKey []byte, fields []string) (*Cipher, error) {
+	c := &Cipher{kms: kms, indexKey: indexKey, fields: map[string]bool{}, unwrapped: map[string][]byte{}}
+	for _, field := range fields {
+		if !supportedFields[field] {
+			return nil, fmt.Errorf("field %q cannot be encrypted", field)
+		}
+		c.fields[field] = true
+	}
+	return c, nil
+}
+
+// FromEnv creates the cipher configured by FIELD_ENCRYPTION_KEYS_FILE, the key
+// file (see LoadKeyFile), and FIELD_ENCRYPTION_FIELDS, a comma-separated list
+// of fields that defaults to DefaultFields. It returns nil if no key file is
+// configured.
+func FromEnv() (*Cipher, error) {
+	path := os.Getenv("FIELD_ENCRYPTION_KEYS_FILE")
+	if path == "" {
+		return nil, nil
+	}
+	kms, err := LoadKeyFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := DefaultFields
+	if value, ok := os.LookupEnv("FIELD_ENCRYPTION_FIELDS"); ok {
+		fields = nil
+		for _, field := range strings.Split(value, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				fields = append(fields, field)
+			}
+		}
+	}
+	return New(kms, kms.IndexKey(), fields)
+}
+
+// Encrypts reports whether values of the field are encrypted
+func (c *Cipher) Encrypts(field string) bool {
+	return c != nil && c.fields[field]
+}
+
+// IsEncrypted reports whether a stored value is encrypted
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// CheckPlaintext returns ErrReservedPrefix if a plaintext value starts like
+// an encrypted one. Stored values are only told apart by their prefix, so
+// such values must be refused before they are written.
+func CheckPlaintext(value string) error {
+	if IsEncrypted(value) {
+		return ErrReservedPrefix
+	}
+	return nil
+}
+
+// Sealed reports whether a stored value of the field is encrypted and has to
+// be decrypted. Fields no longer configured to be encrypted are decrypted
+// until their values are rewritten. Without a cipher nothing is decrypted.
+func (c *Cipher) Sealed(field, value string) bool {
+	return c != nil && supportedFields[field] && IsEncrypted(value)
+}
+
+// MasterKeyID returns the ID of the master key protecting an encrypted value
+func MasterKeyID(value string) string {
+	parts := strings.SplitN(strings.TrimPrefix(value, prefix), ":", 3)
+	if !IsEncrypted(value) || len(parts) != 3 {
+		return ""
+	}
+	return parts[0]
+}
+
+// Current reports whether a stored value of the field is stored the way it
+// would be written now: encrypted under the primary master key if the field
+// is encrypted, and in plaintext otherwise
+func (c *Cipher) Current(field, value string) bool {
+	if !c.Encrypts(field) {
+		return !IsEncrypted(value)
+	}
+	return MasterKeyID(value) == c.kms.PrimaryKeyID()
+}
+
+// Encrypt encrypts a value of the field. The field name is bound to the
+// ciphertext, so it cannot be moved to another field.
+func (c *Cipher) Encrypt(field string, plaintext []byte) (string, error) {
+	key, err := c.dataKey()
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(key.key)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := seal(aead, plaintext, []byte(field))
+	if err != nil {
+		return "", err
+	}
+	return prefix + key.masterKeyID + ":" + key.wrapped + ":" + encoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts a value of the field encrypted by Encrypt
+func (c *Cipher) Decrypt(field, value string) ([]byte, error) {
+	if c == nil {
+		return nil, ErrNoKeys
+	}
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	if !IsEncrypted(value) || len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+	key, err := c.unwrap(parts[0], parts[1])
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := open(aead, sealed, []byte(field))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt %s: %w", field, err)
+	}
+	return plaintext, nil
+}
+
+// dataKey returns the data key for new values, creating it on first use
+func (c *Cipher) dataKey() (*dataKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.current != nil {
+		return c.current, nil
+	}
+
+	key := make([]byte, masterKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	masterKeyID, wrapped, err := c.kms.WrapKey(key)
+	if err != nil {
+		return nil, err
+	}
+	c.current = &dataKey{masterKeyID: masterKeyID, wrapped: encoding.EncodeToString(wrapped), key: key}
+	c.unwrapped[masterKeyID+":"+c.current.wrapped] = key
+	return c.current, nil
+}
+
+// unwrap returns the data key of a value, asking the KMS the first time
+func (c *Cipher) unwrap(masterKeyID, wrapped string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if key, ok := c.unwrapped[masterKeyID+":"+wrapped]; ok {
+		return key, nil
+	}
+
+	raw, err := encoding.DecodeString(wrapped)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	key, err := c.kms.UnwrapKey(masterKeyID, raw)
+	if err != nil {
+		return nil, err
+	}
+	c.unwrapped[masterKeyID+":"+wrapped] = key
+	return key, nil
+}
+
+// EmailIndex returns the blind index of an email address, which matches
+// regardless of case and surrounding spaces. It is nil without a cipher or
+// for an empty address.
+func (c *Cipher) EmailIndex(email string) []byte {
+	return c.blindIndex(FieldEmail, strings.ToLower(strings.TrimSpace(email)))
+}
+
+// PhoneIndex returns the blind index of a phone number, which matches
+// regardless of formatting. It is nil without a cipher or for a number
+// without digits.
+func (c *Cipher) PhoneIndex(phone string) []byte {
+	return c.blindIndex(FieldPhoneNumber, NormalizePhone(phone))
+}
+
+// NormalizePhone keeps only the digits of a phone number
+func NormalizePhone(phone string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, phone)
+}
+
+func (c *Cipher) blindIndex(field, value string) []byte {
+	if c == nil || value == "" {
+		return nil
+	}
+	mac := hmac.New(sha256.New, c.indexKey)
+	mac.Write([]byte(field + ":" + value))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,196 @@
+package fieldcrypt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeKeyFile writes a key file with the given master key IDs, each key
+// derived from its ID so files sharing an ID share the key
+func writeKeyFile(t *testing.T, primary string, ids ...string) string {
+	t.Helper()
+	derive := func(seed string) string {
+		return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte(seed[:1]), masterKeySize))
+	}
+	file := map[string]any{"primary": primary, "index_key": derive("index")}
+	keys := []map[string]string{}
+	for _, id := range ids {
+		keys = append(keys, map[string]string{"id": id, "key": derive(id)})
+	}
+	file["keys"] = keys
+
+	raw, err := json.Marshal(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(path, raw, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newTestCipher(t *testing.T, primary string, ids ...string) *Cipher {
+	t.Helper()
+	kms, err := LoadKeyFile(writeKeyFile(t, primary, ids...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := New(kms, kms.IndexKey(), DefaultFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	c := newTestCipher(t, "a", "a")
+	value, err := c.Encrypt(FieldPhoneNumber, []byte("+1 555 0100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(value) || MasterKeyID(value) != "a" {
+		t.Fatalf("Encrypt = %q, want a value encrypted under key a", value)
+	}
+	if strings.Contains(value, "555") {
+		t.Errorf("Encrypt leaked the plaintext: %q", value)
+	}
+
+	plaintext, err := c.Decrypt(FieldPhoneNumber, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plaintext) != "+1 555 0100" {
+		t.Errorf("Decrypt = %q, want %q", plaintext, "+1 555 0100")
+	}
+}
+
+func TestDecryptBindsField(t *testing.T) {
+	c := newTestCipher(t, "a", "a")
+	value, err := c.Encrypt(FieldPhoneNumber, []byte("+1 555 0100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Decrypt(FieldAddress, value); err == nil {
+		t.Error("Decrypt accepted a value moved to another field")
+	}
+}
+
+func TestDecryptAfterRotation(t *testing.T) {
+	old := newTestCipher(t, "a", "a")
+	value, err := old.Encrypt(FieldAddress, []byte("1 Main St"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := newTestCipher(t, "b", "a", "b")
+	plaintext, err := rotated.Decrypt(FieldAddress, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plaintext) != "1 Main St" {
+		t.Errorf("Decrypt = %q, want %q", plaintext, "1 Main St")
+	}
+	if rotated.Current(FieldAddress, value) {
+		t.Error("Current reported a value under a retired key as current")
+	}
+
+	reencrypted, err := rotated.Encrypt(FieldAddress, plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rotated.Current(FieldAddress, reencrypted) {
+		t.Error("Current reported a value under the primary key as outdated")
+	}
+}
+
+func TestDecryptUnknownMasterKey(t *testing.T) {
+	value, err := newTestCipher(t, "a", "a").Encrypt(FieldAddress, []byte("1 Main St"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newTestCipher(t, "b", "b").Decrypt(FieldAddress, value); !errors.Is(err, ErrUnknownMasterKey) {
+		t.Errorf("Decrypt error = %v, want ErrUnknownMasterKey", err)
+	}
+}
+
+func TestDecryptMalformed(t *testing.T) {
+	c := newTestCipher(t, "a", "a")
+	for _, value := range []string{"plain", prefix + "a", prefix + "a:!!:!!", prefix + "a:b:c:d"} {
+		if _, err := c.Decrypt(FieldAddress, value); !errors.Is(err, ErrMalformed) {
+			t.Errorf("Decrypt(%q) error = %v, want ErrMalformed", value, err)
+		}
+	}
+}
+
+func TestNilCipher(t *testing.T) {
+	var c *Cipher
+	if c.Encrypts(FieldPhoneNumber) {
+		t.Error("nil cipher encrypts fields")
+	}
+	if c.Sealed(FieldPhoneNumber, prefix+"a:b:c") {
+		t.Error("nil cipher reports values as sealed")
+	}
+	if c.EmailIndex("jane@example.com") != nil {
+		t.Error("nil cipher computes blind indexes")
+	}
+	if _, err := c.Decrypt(FieldPhoneNumber, prefix+"a:b:c"); !errors.Is(err, ErrNoKeys) {
+		t.Errorf("Decrypt error = %v, want ErrNoKeys", err)
+	}
+}
+
+func TestCurrentForPlaintextFields(t *testing.T) {
+	c := newTestCipher(t, "a", "a")
+	if !c.Current(FieldEmail, "jane@example.com") {
+		t.Error("plaintext value of a plaintext field is not current")
+	}
+	if c.Current(FieldEmail, prefix+"a:b:c") {
+		t.Error("encrypted value of a plaintext field is current")
+	}
+	if c.Current(FieldAddress, "1 Main St") {
+		t.Error("plaintext value of an encrypted field is current")
+	}
+}
+
+func TestBlindIndexes(t *testing.T) {
+	c := newTestCipher(t, "a", "a")
+	if !bytes.Equal(c.EmailIndex(" Jane@Example.com "), c.EmailIndex("jane@example.com")) {
+		t.Error("EmailIndex depends on case or spaces")
+	}
+	if !bytes.Equal(c.PhoneIndex("+1 (555) 010-0"), c.PhoneIndex("15550100")) {
+		t.Error("PhoneIndex depends on formatting")
+	}
+	if bytes.Equal(c.EmailIndex("15550100"), c.PhoneIndex("15550100")) {
+		t.Error("email and phone indexes collide")
+	}
+	if c.EmailIndex("") != nil || c.PhoneIndex("n/a") != nil {
+		t.Error("blind index computed for an empty value")
+	}
+}
+
+func TestCheckPlaintext(t *testing.T) {
+	if err := CheckPlaintext("enc:v1:anything"); !errors.Is(err, ErrReservedPrefix) {
+		t.Errorf("CheckPlaintext error = %v, want ErrReservedPrefix", err)
+	}
+	if err := CheckPlaintext("1 Main St"); err != nil {
+		t.Errorf("CheckPlaintext error = %v, want nil", err)
+	}
+}
+
+func TestNewRejectsUnsupportedField(t *testing.T) {
+	if _, err := New(nil, nil, []string{"first_name"}); err == nil {
+		t.Error("New accepted a field that cannot be encrypted")
+	}
+}
+
+func TestLoadKeyFileRejectsUnlistedPrimary(t *testing.T) {
+	if _, err := LoadKeyFile(writeKeyFile(t, "b", "a")); err == nil {
+		t.Error("LoadKeyFile accepted a primary key that is not listed")
+	}
+}
@@ -0,0 +1,147 @@
+// Package fieldcrypt encrypts individual database fields with envelope
+// encryption: values are encrypted with a data key, and the data key is
+// encrypted ("wrapped") with a master key held by a key management service.
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// masterKeySize is the length of master and data keys, for AES-256
+const masterKeySize = 32
+
+// minIndexKeyLength is the shortest blind index key accepted
+const minIndexKeyLength = 32
+
+// ErrUnknownMasterKey is returned when a data key was wrapped with a master key the KMS does not have
+var ErrUnknownMasterKey = errors.New("unknown master key")
+
+// KMS wraps and unwraps data keys with master keys that never leave it
+type KMS interface {
+	// PrimaryKeyID is the master key new data keys are wrapped with
+	PrimaryKeyID() string
+	WrapKey(dataKey []byte) (keyID string, wrapped []byte, err error)
+	UnwrapKey(keyID string, wrapped []byte) ([]byte, error)
+}
+
+// FileKMS is a KMS whose master keys are read from a local file. It stands in
+// for a cloud key management service.
+type FileKMS struct {
+	primary  string
+	keys     map[string]cipher.AEAD
+	indexKey []byte
+}
+
+// keyFile is the JSON file holding the master keys and the blind index key
+type keyFile struct {
+	Primary string `json:"primary"`
+	Keys    []struct {
+		ID  string `json:"id"`
+		Key string `json:"key"`
+	} `json:"keys"`
+	IndexKey string `json:"index_key"`
+}
+
+// LoadKeyFile reads a key file. Keys are base64-encoded 32 byte values, for
+// example from openssl rand -base64 32:
+//
+//	{"primary": "2026-10",
+//	 "keys": [
+//	   {"id": "2026-09", "key": "..."},
+//	   {"id": "2026-10", "key": "..."}
+//	 ],
+//	 "index_key": "..."}
+func LoadKeyFile(path string) (*FileKMS, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file keyFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("parse key file %s: %w", path, err)
+	}
+
+	kms := &FileKMS{primary: file.Primary, keys: map[string]cipher.AEAD{}}
+	for _, k := range file.Keys {
+		if k.ID == "" || strings.Contains(k.ID, ":") {
+			return nil, fmt.Errorf("key file %s: invalid key id %q", path, k.ID)
+		}
+		if _, exists := kms.keys[k.ID]; exists {
+			return nil, fmt.Errorf("key file %s: duplicate key id %q", path, k.ID)
+		}
+		key, err := base64.StdEncoding.DecodeString(k.Key)
+		if err != nil || len(key) != masterKeySize {
+			return nil, fmt.Errorf("key file %s: key %q must be %d base64-encoded bytes", path, k.ID, masterKeySize)
+		}
+		if kms.keys[k.ID], err = newAEAD(key); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := kms.keys[kms.primary]; !ok {
+		return nil, fmt.Errorf("key file %s: primary key %q is not listed", path, kms.primary)
+	}
+
+	if kms.indexKey, err = base64.StdEncoding.DecodeString(file.IndexKey); err != nil || len(kms.indexKey) < minIndexKeyLength {
+		return nil, fmt.Errorf("key file %s: index_key must be at least %d base64-encoded bytes", path, minIndexKeyLength)
+	}
+	return kms, nil
+}
+
+// PrimaryKeyID returns the ID of the key new data keys are wrapped with
+func (k *FileKMS) PrimaryKeyID() string {
+	return k.primary
+}
+
+// IndexKey returns the key blind indexes are computed with
+func (k *FileKMS) IndexKey() []byte {
+	return k.indexKey
+}
+
+// WrapKey encrypts a data key with the primary master key
+func (k *FileKMS) WrapKey(dataKey []byte) (string, []byte, error) {
+	wrapped, err := seal(k.keys[k.primary], dataKey, []byte(k.primary))
+	return k.primary, wrapped, err
+}
+
+// UnwrapKey decrypts a data key wrapped with the named master key
+func (k *FileKMS) UnwrapKey(keyID string, wrapped []byte) ([]byte, error) {
+	aead, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownMasterKey, keyID)
+	}
+	return open(aead, wrapped, []byte(keyID))
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext with a random nonce, which is prepended to the result
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts the output of seal
+func open(aead cipher.AEAD, sealed, additionalData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
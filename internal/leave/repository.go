@@ -22,6 +22,7 @@ type Repository interface {
 	CreateLeaveRequest(leaveRequestData *models.LeaveRequestCreate) (*models.LeaveRequest, error)
 	GetLeaveRequestByID(id uuid.UUID) (*models.LeaveRequest, error)
 	ListLeaveRequests() ([]models.LeaveRequest, error)
+	ListLeaveRequestsByEmployeeID(employeeID uuid.UUID) ([]models.LeaveRequest, error)
 	UpdateLeaveRequestStatus(id uuid.UUID, status string, approvedBy *uuid.UUID) (*models.LeaveRequest, error)
 }
 
@@ -193,6 +194,31 @@ func (r *repository) ListLeaveRequests() ([]models.LeaveRequest, error) {
 	return leaveRequests, nil
 }
 
+// ListLeaveRequestsByEmployeeID retrieves an employee's leave requests, newest first
+func (r *repository) ListLeaveRequestsByEmployeeID(employeeID uuid.UUID) ([]models.LeaveRequest, error) {
+	leaveRequests := []models.LeaveRequest{}
+	query := `SELECT id, employee_id, leave_type_id, start_date, end_date, reason, status, approved_by, approved_at, created_at, updated_at
+			  FROM leave_requests WHERE employee_id = $1 ORDER BY start_date DESC`
+	rows, err := r.db.Query(query, employeeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var leaveRequest models.LeaveRequest
+		var approvedAt sql.NullTime
+		if err := rows.Scan(&leaveRequest.ID, &leaveRequest.EmployeeID, &leaveRequest.LeaveTypeID, &leaveRequest.StartDate, &leaveRequest.EndDate, &leaveRequest.Reason, &leaveRequest.Status, &leaveRequest.ApprovedBy, &approvedAt, &leaveRequest.CreatedAt, &leaveRequest.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if approvedAt.Valid {
+			leaveRequest.ApprovedAt = &approvedAt.Time
+		}
+		leaveRequests = append(leaveRequests, leaveRequest)
+	}
+	return leaveRequests, rows.Err()
+}
+
 // UpdateLeaveRequestStatus updates the status of a leave request
 func (r *repository) UpdateLeaveRequestStatus(id uuid.UUID, status string, approvedBy *uuid.UUID) (*models.LeaveRequest, error) {
 	var leaveRequest models.LeaveRequest
@@ -55,6 +55,10 @@ func (s *Service) ListLeaveRequests() ([]models.LeaveRequest, error) {
 	return s.repo.ListLeaveRequests()
 }
 
+func (s *Service) ListLeaveRequestsByEmployeeID(employeeID uuid.UUID) ([]models.LeaveRequest, error) {
+	return s.repo.ListLeaveRequestsByEmployeeID(employeeID)
+}
+
 func (s *Service) ApproveLeaveRequest(id uuid.UUID, approvedByUserID uuid.UUID) (*models.LeaveRequest, error) {
 	request, err := s.repo.GetLeaveRequestByID(id)
 	if err != nil {
@@ -0,0 +1,56 @@
+package mail
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Message is an outgoing plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers email messages
+type Sender interface {
+	Send(msg Message) error
+}
+
+// NewSenderFromEnv builds a Sender from the MAIL_DRIVER environment variable.
+// Supported drivers are "smtp", "file" and "memory". There is no default, so
+// that a server without mail configured fails to start instead of silently
+// dropping password reset emails.
+func NewSenderFromEnv() (Sender, error) {
+	switch driver := os.Getenv("MAIL_DRIVER"); driver {
+	case "":
+		return nil, errors.New("MAIL_DRIVER is not set; use smtp, file or memory")
+	case "smtp":
+		port, err := strconv.Atoi(getEnv("SMTP_PORT", "587"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid SMTP_PORT: %w", err)
+		}
+		return NewSMTPSender(SMTPConfig{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     getEnv("MAIL_FROM", "no-reply@localhost"),
+		})
+	case "file":
+		return NewFileSender(getEnv("MAIL_DIR", "mail"))
+	case "memory":
+		return NewMemorySender(), nil
+	default:
+		return nil, fmt.Errorf("unknown MAIL_DRIVER %q", driver)
+	}
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,65 @@
+package mail
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// maxMemoryMessages is how many messages a MemorySender keeps
+const maxMemoryMessages = 1000
+
+// MemorySender keeps the most recent sent messages in memory. It is intended
+// for tests and local development.
+type MemorySender struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// NewMemorySender creates a new in-memory sender
+func NewMemorySender() *MemorySender {
+	return &MemorySender{}
+}
+
+// Send records the message, dropping the oldest one once maxMemoryMessages
+// are kept
+func (s *MemorySender) Send(msg Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.messages) >= maxMemoryMessages {
+		s.messages = append(s.messages[:0], s.messages[1:]...)
+	}
+	s.messages = append(s.messages, msg)
+	return nil
+}
+
+// Messages returns a copy of the messages kept so far
+func (s *MemorySender) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// FileSender writes each message to its own file in a directory
+type FileSender struct {
+	dir string
+}
+
+// NewFileSender creates a new file sender, creating dir if needed
+func NewFileSender(dir string) (*FileSender, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	return &FileSender{dir: dir}, nil
+}
+
+// Send writes the message to a new .eml file
+func (s *FileSender) Send(msg Message) error {
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405"), uuid.New().String())
+	content := fmt.Sprintf("To: %s\nSubject: %s\n\n%s\n", msg.To, msg.Subject, msg.Body)
+	return os.WriteFile(filepath.Join(s.dir, name), []byte(content), 0o640)
+}
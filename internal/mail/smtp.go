@@ -0,0 +1,52 @@
+package mail
+
+import (
+	"errors"
+	"fmt"
+	"net/smtp"
+	"strings"
+)
+
+// SMTPConfig holds the settings for an SMTP relay
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// SMTPSender sends messages through an SMTP relay
+type SMTPSender struct {
+	config SMTPConfig
+}
+
+// NewSMTPSender creates a new SMTP sender
+func NewSMTPSender(config SMTPConfig) (*SMTPSender, error) {
+	if config.Host == "" {
+		return nil, errors.New("SMTP host is required")
+	}
+	return &SMTPSender{config: config}, nil
+}
+
+// Send delivers the message through the configured relay
+func (s *SMTPSender) Send(msg Message) error {
+	var auth smtp.Auth
+	if s.config.Username != "" {
+		auth = smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)
+	}
+
+	addr := fmt.Sprintf("%s:%d", s.config.Host, s.config.Port)
+	return smtp.SendMail(addr, auth, s.config.From, []string{msg.To}, s.format(msg))
+}
+
+func (s *SMTPSender) format(msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", s.config.From)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n\r\n")
+	b.WriteString(msg.Body)
+	return []byte(b.String())
+}
@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"employee-management/internal/auth"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// TokenValidator validates a bearer token and returns its claims
+type TokenValidator interface {
+	ValidateToken(tokenString string) (*auth.Claims, error)
+	IsTokenRevoked(claims *auth.Claims) (bool, error)
+	ValidateAPIToken(token, ip string) (*auth.Claims, error)
+}
+
+// Authenticate is a Gin middleware that requires a valid bearer token, either
+// a JWT access token or a personal API token, and stores the caller's identity
+// (claims, user_id, username, role and, for API tokens, scopes) in the context
+func Authenticate(validator TokenValidator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+			return
+		}
+
+		tokenString, found := strings.CutPrefix(authHeader, "Bearer ")
+		if !found || tokenString == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
+			return
+		}
+
+		if strings.HasPrefix(tokenString, auth.APITokenPrefix) {
+			claims, err := validator.ValidateAPIToken(tokenString, c.ClientIP())
+			if err != nil {
+				if errors.Is(err, auth.ErrInvalidAPIToken) {
+					c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+					return
+				}
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate token"})
+				return
+			}
+			setIdentity(c, claims)
+			c.Set("scopes", claims.Scopes)
+			c.Next()
+			return
+		}
+
+		claims, err := validator.ValidateToken(tokenString)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		revoked, err := validator.IsTokenRevoked(claims)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate token"})
+			return
+		}
+		if revoked {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			return
+		}
+
+		setIdentity(c, claims)
+		c.Next()
+	}
+}
+
+func setIdentity(c *gin.Context, claims *auth.Claims) {
+	c.Set("claims", claims)
+	c.Set("user_id", claims.UserID)
+	c.Set("username", claims.Username)
+	c.Set("role", claims.Role)
+}
+
+// CurrentUserID returns the authenticated user's ID from the context
+func CurrentUserID(c *gin.Context) (uuid.UUID, error) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		return uuid.Nil, errors.New("no authenticated user")
+	}
+	return uuid.Parse(userID)
+}
+
+// CurrentRole returns the authenticated user's role from the context
+func CurrentRole(c *gin.Context) string {
+	return c.GetString("role")
+}
+
+// CurrentScopes returns the scopes of the API token the request was made
+// with. ok is false for requests authenticated with an access token.
+func CurrentScopes(c *gin.Context) (scopes []string, ok bool) {
+	value, exists := c.Get("scopes")
+	if !exists {
+		return nil, false
+	}
+	scopes, ok = value.([]string)
+	return scopes, ok
+}
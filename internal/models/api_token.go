@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIToken is a long-lived personal token used by scripts and integrations
+type APIToken struct {
+	ID          uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	UserID      uuid.UUID  `gorm:"type:uuid;not null" json:"user_id"`
+	Name        string     `gorm:"not null" json:"name"`
+	TokenPrefix string     `gorm:"not null" json:"token_prefix"`
+	Scopes      []string   `gorm:"type:text[];not null" json:"scopes"`
+	ExpiresAt   time.Time  `gorm:"not null" json:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	LastUsedIP  string     `json:"last_used_ip"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// APITokenCreate represents data for creating an API token
+type APITokenCreate struct {
+	Name   string   `json:"name" binding:"required,max=100"`
+	Scopes []string `json:"scopes" binding:"required,min=1"`
+	// ExpiresInDays defaults to 90
+	ExpiresInDays int `json:"expires_in_days" binding:"omitempty,min=1,max=365"`
+}
+
+// TableName specifies the table name for APIToken model
+func (APIToken) TableName() string {
+	return "api_tokens"
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditEvent records a security- or compliance-relevant action
+type AuditEvent struct {
+	ID          uuid.UUID              `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	ActorUserID *uuid.UUID             `gorm:"type:uuid" json:"actor_user_id"`
+	Action      string                 `gorm:"not null;index" json:"action"`
+	TargetType  string                 `json:"target_type"`
+	TargetID    string                 `json:"target_id"`
+	Details     map[string]interface{} `gorm:"type:jsonb" json:"details"`
+	IPAddress   string                 `json:"ip_address"`
+	CreatedAt   time.Time              `json:"created_at"`
+}
+
+// AuditEventCreate represents data for recording a new audit event
+type AuditEventCreate struct {
+	ActorUserID *uuid.UUID
+	Action      string
+	TargetType  string
+	TargetID    string
+	Details     map[string]interface{}
+	IPAddress   string
+}
+
+// TableName specifies the table name for AuditEvent model
+func (AuditEvent) TableName() string {
+	return "audit_events"
+}
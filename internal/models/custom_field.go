@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Custom field types
+const (
+	CustomFieldText    = "text"
+	CustomFieldNumber  = "number"
+	CustomFieldBoolean = "boolean"
+	CustomFieldDate    = "date"
+	CustomFieldSelect  = "select"
+)
+
+// CustomFields holds an employee's custom field values by key. Numbers are
+// float64, dates are YYYY-MM-DD strings.
+type CustomFields map[string]interface{}
+
+// CustomFieldDefinition describes an attribute tracked for employees on top
+// of the built-in ones
+type CustomFieldDefinition struct {
+	ID       uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	Key      string    `gorm:"uniqueIndex;not null" json:"key"`
+	Label    string    `gorm:"not null" json:"label"`
+	Type     string    `gorm:"not null" json:"type"`
+	Required bool      `gorm:"not null" json:"required"`
+	// Options are the allowed values of a select field
+	Options []string `gorm:"type:text[]" json:"options,omitempty"`
+	// Pattern is a regular expression text values must match in full
+	Pattern string `json:"pattern,omitempty"`
+	// Min and Max bound numbers, or the length of text
+	Min *float64 `gorm:"column:min_value" json:"min,omitempty"`
+	Max *float64 `gorm:"column:max_value" json:"max,omitempty"`
+	// VisibleTo lists the roles that can see the field besides admins. Empty
+	// means everyone.
+	VisibleTo []string  `gorm:"type:text[]" json:"visible_to"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CustomFieldDefinitionCreate holds the data for defining a custom field
+type CustomFieldDefinitionCreate struct {
+	Key       string   `json:"key" binding:"required"`
+	Label     string   `json:"label" binding:"required,max=100"`
+	Type      string   `json:"type" binding:"required,oneof=text number boolean date select"`
+	Required  bool     `json:"required"`
+	Options   []string `json:"options"`
+	Pattern   string   `json:"pattern"`
+	Min       *float64 `json:"min"`
+	Max       *float64 `json:"max"`
+	VisibleTo []string `json:"visible_to"`
+}
+
+// CustomFieldDefinitionUpdate replaces a custom field's settings. The key and
+// type cannot change, since existing values depend on them.
+type CustomFieldDefinitionUpdate struct {
+	Label     string   `json:"label" binding:"required,max=100"`
+	Required  bool     `json:"required"`
+	Options   []string `json:"options"`
+	Pattern   string   `json:"pattern"`
+	Min       *float64 `json:"min"`
+	Max       *float64 `json:"max"`
+	VisibleTo []string `json:"visible_to"`
+}
+
+// TableName specifies the table name for CustomFieldDefinition model
+func (CustomFieldDefinition) TableName() string {
+	return "custom_field_definitions"
+}
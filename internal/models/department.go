@@ -11,20 +11,84 @@ type Department struct {
 	Name        string     `gorm:"uniqueIndex;not null" json:"name" validate:"required"`
 	Description string     `gorm:"not null" json:"description" validate:"required"`
 	ManagerID   *uuid.UUID `gorm:"type:uuid" json:"manager_id"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
+	ParentID    *uuid.UUID `gorm:"type:uuid" json:"parent_id"`
+	// CostCenter and LegalEntityID are inherited from the parent when empty
+	CostCenter    string     `json:"cost_center"`
+	LegalEntityID *uuid.UUID `gorm:"type:uuid" json:"legal_entity_id"`
+	// MergedIntoID is set once the department has been merged into another
+	MergedIntoID *uuid.UUID `gorm:"type:uuid" json:"merged_into_id,omitempty"`
+	MergedAt     *time.Time `json:"merged_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
 }
 
 type DepartmentCreate struct {
-	Name        string     `json:"name" validate:"required"`
-	Description string     `json:"description" validate:"required"`
-	ManagerID   *uuid.UUID `json:"manager_id"`
+	Name          string     `json:"name" validate:"required"`
+	Description   string     `json:"description" validate:"required"`
+	ManagerID     *uuid.UUID `json:"manager_id"`
+	ParentID      *uuid.UUID `json:"parent_id"`
+	CostCenter    string     `json:"cost_center" binding:"max=50"`
+	LegalEntityID *uuid.UUID `json:"legal_entity_id"`
 }
 
+// DepartmentUpdate replaces a department's name, description and manager.
+// The cost center and legal entity are kept when left out; an empty cost
+// center or the nil UUID as legal entity clears them. The parent changes by
+// moving the department.
 type DepartmentUpdate struct {
-	Name        string     `json:"name"`
-	Description string     `json:"description"`
-	ManagerID   *uuid.UUID `json:"manager_id"`
+	Name          string     `json:"name"`
+	Description   string     `json:"description"`
+	ManagerID     *uuid.UUID `json:"manager_id"`
+	CostCenter    *string    `json:"cost_center" binding:"omitempty,max=50"`
+	LegalEntityID *uuid.UUID `json:"legal_entity_id"`
+}
+
+// DepartmentMove holds a department's new parent, nil for the top level
+type DepartmentMove struct {
+	ParentID *uuid.UUID `json:"parent_id"`
+}
+
+// DepartmentMerge names the department another one is merged into
+type DepartmentMerge struct {
+	IntoID uuid.UUID `json:"into_id" binding:"required"`
+}
+
+// DepartmentMergeResult describes a completed department merge
+type DepartmentMergeResult struct {
+	Department *Department `json:"department"`
+	MergedID   uuid.UUID   `json:"merged_id"`
+	// Employees, Positions and Departments count what moved to the department
+	Employees   int64 `json:"employees"`
+	Positions   int64 `json:"positions"`
+	Departments int64 `json:"departments"`
+}
+
+// DepartmentDependents counts what still refers to a department
+type DepartmentDependents struct {
+	// Employees counts current employees, FormerEmployees deleted and
+	// terminated ones
+	Employees       int64 `json:"employees"`
+	FormerEmployees int64 `json:"former_employees"`
+	Positions       int64 `json:"positions"`
+	// Departments counts sub-departments, MergedDepartments departments
+	// merged into or below the department
+	Departments       int64 `json:"departments"`
+	MergedDepartments int64 `json:"merged_departments"`
+	JobHistory        int64 `json:"job_history"`
+}
+
+// Empty reports whether nothing refers to the department
+func (d DepartmentDependents) Empty() bool {
+	return d == DepartmentDependents{}
+}
+
+// DepartmentDeletion describes a deleted department and where its
+// dependents went
+type DepartmentDeletion struct {
+	ID           uuid.UUID  `json:"id"`
+	ReassignedTo *uuid.UUID `json:"reassigned_to,omitempty"`
+	// Moved counts what was reassigned
+	Moved DepartmentDependents `json:"moved"`
 }
 
 type DepartmentResponse struct {
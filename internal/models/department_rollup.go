@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Payroll cost groupings
+const (
+	CostGroupDepartment  = "department"
+	CostGroupCostCenter  = "cost_center"
+	CostGroupLegalEntity = "legal_entity"
+)
+
+// DepartmentNode is a department's place in the department tree. CostCenter
+// and LegalEntityID are the ones in effect, including inherited ones.
+type DepartmentNode struct {
+	ID            uuid.UUID  `json:"id"`
+	Name          string     `json:"name"`
+	ParentID      *uuid.UUID `json:"parent_id"`
+	ManagerID     *uuid.UUID `json:"manager_id"`
+	CostCenter    string     `json:"cost_center"`
+	LegalEntityID *uuid.UUID `json:"legal_entity_id"`
+	// Headcount counts the current employees of the department itself,
+	// TotalHeadcount also those of its sub-departments
+	Headcount      int               `json:"headcount"`
+	TotalHeadcount int               `json:"total_headcount"`
+	Children       []*DepartmentNode `json:"children,omitempty"`
+}
+
+// PayrollCost is the payroll of one department, cost center or legal entity
+// over a period. Key is the department ID, cost center or legal entity ID,
+// and empty for pay that is not assigned to any.
+type PayrollCost struct {
+	Key             string  `json:"key"`
+	Name            string  `json:"name"`
+	Employees       int     `json:"employees"`
+	GrossPay        float64 `json:"gross_pay"`
+	TaxAmount       float64 `json:"tax_amount"`
+	OtherDeductions float64 `json:"other_deductions"`
+	NetPay          float64 `json:"net_pay"`
+}
+
+// PayrollCostReport holds the payroll costs of the payroll runs whose pay
+// period ends between From and To
+type PayrollCostReport struct {
+	From    time.Time     `json:"from"`
+	To      time.Time     `json:"to"`
+	GroupBy string        `json:"group_by"`
+	Groups  []PayrollCost `json:"groups"`
+}
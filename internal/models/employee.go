@@ -8,7 +8,7 @@ import (
 
 type Employee struct {
 	ID                    uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
-	UserID                uuid.UUID  `gorm:"type:uuid;not null" json:"user_id" validate:"required"`
+	UserID                *uuid.UUID `gorm:"type:uuid" json:"user_id"`
 	EmployeeID            string     `gorm:"uniqueIndex;not null" json:"employee_id" validate:"required"`
 	FirstName             string     `gorm:"not null" json:"first_name" validate:"required"`
 	LastName              string     `gorm:"not null" json:"last_name" validate:"required"`
@@ -20,35 +20,56 @@ type Employee struct {
 	Address               string     `gorm:"not null" json:"address" validate:"required"`
 	EmergencyContactName  string     `gorm:"not null" json:"emergency_contact_name" validate:"required"`
 	EmergencyContactPhone string     `gorm:"not null" json:"emergency_contact_phone" validate:"required"`
-	DepartmentID          uuid.UUID  `gorm:"type:uuid" json:"department_id"`
-	PositionID            uuid.UUID  `gorm:"type:uuid" json:"position_id"`
+	DepartmentID          *uuid.UUID `gorm:"type:uuid" json:"department_id"`
+	PositionID            *uuid.UUID `gorm:"type:uuid" json:"position_id"`
 	HireDate              time.Time  `gorm:"not null" json:"hire_date" validate:"required"`
 	EmploymentStatus      string     `gorm:"not null" json:"employment_status" validate:"required,oneof=active inactive terminated"`
 	ManagerID             *uuid.UUID `gorm:"type:uuid" json:"manager_id"`
-	CreatedAt             time.Time  `json:"created_at"`
-	UpdatedAt             time.Time  `json:"updated_at"`
+	// CustomFields only holds the fields visible to the user viewing the
+	// employee
+	CustomFields CustomFields `gorm:"type:jsonb" json:"custom_fields,omitempty"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+	// DeletedAt is set when the employee is deleted. Their row and related
+	// records are kept, and their personal details are anonymized once the
+	// retention period has passed.
+	DeletedAt    *time.Time `json:"deleted_at,omitempty"`
+	DeletedBy    *uuid.UUID `json:"deleted_by,omitempty"`
+	AnonymizedAt *time.Time `json:"anonymized_at,omitempty"`
+	// MaskedFields lists the personal details hidden from the user viewing
+	// the employee
+	MaskedFields []string `gorm:"-" json:"masked_fields,omitempty"`
 }
 
 type EmployeeCreate struct {
-	UserID                uuid.UUID  `json:"user_id" validate:"required"`
-	EmployeeID            string     `json:"employee_id" validate:"required"`
-	FirstName             string     `json:"first_name" validate:"required"`
-	LastName              string     `json:"last_name" validate:"required"`
-	DateOfBirth           time.Time  `json:"date_of_birth" validate:"required"`
-	Gender                string     `json:"gender" validate:"required,oneof=male female other"`
-	MaritalStatus         string     `json:"marital_status" validate:"required,oneof=single married divorced widowed"`
-	PhoneNumber           string     `json:"phone_number" validate:"required"`
-	Email                 string     `json:"email" validate:"required,email"`
-	Address               string     `json:"address" validate:"required"`
-	EmergencyContactName  string     `json:"emergency_contact_name" validate:"required"`
-	EmergencyContactPhone string     `json:"emergency_contact_phone" validate:"required"`
-	DepartmentID          *uuid.UUID `json:"department_id"`
-	PositionID            *uuid.UUID `json:"position_id"`
-	HireDate              time.Time  `json:"hire_date" validate:"required"`
-	EmploymentStatus      string     `json:"employment_status" validate:"required,oneof=active inactive terminated"`
-	ManagerID             *uuid.UUID `json:"manager_id"`
+	// UserID links a user account. Without one the employee can be invited
+	// and linked later.
+	UserID *uuid.UUID `json:"user_id"`
+	// EmployeeID is generated from the employee number template when empty
+	EmployeeID            string       `json:"employee_id" validate:"max=50"`
+	FirstName             string       `json:"first_name" validate:"required"`
+	LastName              string       `json:"last_name" validate:"required"`
+	DateOfBirth           time.Time    `json:"date_of_birth" validate:"required"`
+	Gender                string       `json:"gender" validate:"required,oneof=male female other"`
+	MaritalStatus         string       `json:"marital_status" validate:"required,oneof=single married divorced widowed"`
+	PhoneNumber           string       `json:"phone_number" validate:"required"`
+	Email                 string       `json:"email" validate:"required,email"`
+	Address               string       `json:"address" validate:"required"`
+	EmergencyContactName  string       `json:"emergency_contact_name" validate:"required"`
+	EmergencyContactPhone string       `json:"emergency_contact_phone" validate:"required"`
+	DepartmentID          *uuid.UUID   `json:"department_id"`
+	PositionID            *uuid.UUID   `json:"position_id"`
+	HireDate              time.Time    `json:"hire_date" validate:"required"`
+	EmploymentStatus      string       `json:"employment_status" validate:"required,oneof=active inactive terminated"`
+	ManagerID             *uuid.UUID   `json:"manager_id"`
+	CustomFields          CustomFields `json:"custom_fields"`
+	// RecordedBy is the user creating the employee, recorded on the hire entry
+	RecordedBy *uuid.UUID `json:"-"`
 }
 
+// EmployeeUpdate holds an employee's personal details and hire date. Fields
+// left empty keep their current values. Department, position, manager and
+// status change through lifecycle events instead.
 type EmployeeUpdate struct {
 	FirstName             string     `json:"first_name"`
 	LastName              string     `json:"last_name"`
@@ -60,16 +81,21 @@ type EmployeeUpdate struct {
 	Address               string     `json:"address"`
 	EmergencyContactName  string     `json:"emergency_contact_name"`
 	EmergencyContactPhone string     `json:"emergency_contact_phone"`
-	DepartmentID          *uuid.UUID `json:"department_id"`
-	PositionID            *uuid.UUID `json:"position_id"`
 	HireDate              *time.Time `json:"hire_date"`
-	EmploymentStatus      string     `json:"employment_status" validate:"oneof=active inactive terminated"`
-	ManagerID             *uuid.UUID `json:"manager_id"`
+	// CustomFields sets the given custom fields and keeps the others. A null
+	// value clears a field.
+	CustomFields CustomFields `json:"custom_fields"`
+	// EffectiveDate is the day the change took effect, today if not given. A
+	// backdated change is carried forward to later versions of the record
+	// until one of them changed the same field.
+	EffectiveDate *time.Time `json:"effective_date"`
+	// RecordedBy is the user making the change
+	RecordedBy *uuid.UUID `json:"-"`
 }
 
 type EmployeeResponse struct {
 	ID                    uuid.UUID  `json:"id"`
-	UserID                uuid.UUID  `json:"user_id"`
+	UserID                *uuid.UUID `json:"user_id"`
 	EmployeeID            string     `json:"employee_id"`
 	FirstName             string     `json:"first_name"`
 	LastName              string     `json:"last_name"`
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Change request statuses
+const (
+	ChangeRequestPending   = "pending"
+	ChangeRequestApproved  = "approved"
+	ChangeRequestRejected  = "rejected"
+	ChangeRequestCancelled = "cancelled"
+)
+
+// EmployeeChangeRequest is a change an employee made to their own record
+// that waits for HR approval before it is applied
+type EmployeeChangeRequest struct {
+	ID          uuid.UUID         `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	EmployeeID  uuid.UUID         `gorm:"type:uuid;not null;index" json:"employee_id"`
+	RequestedBy *uuid.UUID        `gorm:"type:uuid" json:"requested_by"`
+	Changes     map[string]string `gorm:"type:jsonb" json:"changes"`
+	Status      string            `gorm:"not null" json:"status"`
+	ReviewedBy  *uuid.UUID        `gorm:"type:uuid" json:"reviewed_by,omitempty"`
+	ReviewedAt  *time.Time        `json:"reviewed_at,omitempty"`
+	ReviewNote  string            `json:"review_note,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+}
+
+// ChangeRequestReview is HR's decision on a change request
+type ChangeRequestReview struct {
+	Note string `json:"note"`
+}
+
+// ProfileUpdate holds the details employees may change on their own record.
+// Fields left out keep their current values.
+type ProfileUpdate struct {
+	PhoneNumber           *string `json:"phone_number" binding:"omitempty,min=1,max=20"`
+	Address               *string `json:"address" binding:"omitempty,min=1"`
+	EmergencyContactName  *string `json:"emergency_contact_name" binding:"omitempty,min=1,max=255"`
+	EmergencyContactPhone *string `json:"emergency_contact_phone" binding:"omitempty,min=1,max=20"`
+}
+
+// TableName specifies the table name for EmployeeChangeRequest model
+func (EmployeeChangeRequest) TableName() string {
+	return "employee_change_requests"
+}
@@ -0,0 +1,38 @@
+package models
+
+import "github.com/google/uuid"
+
+// Reasons two employee records are considered duplicates
+const (
+	DuplicateSameEmail       = "same_email"
+	DuplicateSamePhone       = "same_phone"
+	DuplicateSameDateOfBirth = "same_date_of_birth"
+	DuplicateSimilarName     = "similar_name"
+)
+
+// DuplicateCandidate is a pair of employee records that probably describe the
+// same person. Employee is the record created first.
+type DuplicateCandidate struct {
+	Employee  Employee `json:"employee"`
+	Duplicate Employee `json:"duplicate"`
+	// Score ranges from 0 to 1, higher meaning more likely duplicates
+	Score   float64  `json:"score"`
+	Reasons []string `json:"reasons"`
+	// NameSimilarity ranges from 0 for unrelated names to 1 for the same name
+	NameSimilarity float64 `json:"name_similarity"`
+}
+
+// EmployeeMergeRequest names the duplicate record to merge into an employee
+type EmployeeMergeRequest struct {
+	DuplicateID uuid.UUID `json:"duplicate_id" binding:"required"`
+	Reason      string    `json:"reason" binding:"max=500"`
+}
+
+// EmployeeMerge describes a completed merge. Moved counts the re-pointed
+// records by table.
+type EmployeeMerge struct {
+	Employee            *Employee        `json:"employee"`
+	DuplicateID         uuid.UUID        `json:"duplicate_id"`
+	DuplicateEmployeeID string           `json:"duplicate_employee_id"`
+	Moved               map[string]int64 `json:"moved"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EmployeeNumberTemplate is the pattern employee IDs are generated from when
+// none is given, for one department or, without a department, for all others
+type EmployeeNumberTemplate struct {
+	ID           uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	DepartmentID *uuid.UUID `gorm:"type:uuid;uniqueIndex" json:"department_id"`
+	// Pattern holds {seq:N} for an N-digit sequence number and optionally
+	// {YYYY}, {YY} and {MM} for the hire date
+	Pattern string `gorm:"not null" json:"pattern"`
+	// Example is the pattern rendered for a hire today with sequence number 1
+	Example   string    `gorm:"-" json:"example"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// EmployeeNumberTemplateCreate holds the data for creating a template
+type EmployeeNumberTemplateCreate struct {
+	DepartmentID *uuid.UUID `json:"department_id"`
+	Pattern      string     `json:"pattern" binding:"required,max=100"`
+}
+
+// EmployeeNumberTemplateUpdate holds a template's new pattern
+type EmployeeNumberTemplateUpdate struct {
+	Pattern string `json:"pattern" binding:"required,max=100"`
+}
+
+// TableName specifies the table name for EmployeeNumberTemplate model
+func (EmployeeNumberTemplate) TableName() string {
+	return "employee_number_templates"
+}
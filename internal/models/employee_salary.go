@@ -13,8 +13,19 @@ type EmployeeSalary struct {
 	Amount            float64    `gorm:"not null" json:"amount" validate:"required,gt=0"`
 	EffectiveDate     time.Time  `gorm:"not null" json:"effective_date" validate:"required"`
 	EndDate           *time.Time `json:"end_date"`
-	CreatedAt         time.Time  `json:"created_at"`
-	UpdatedAt         time.Time  `json:"updated_at"`
+	// BandStatus is pending while a salary outside the position's salary
+	// band waits for approval. Only approved salaries are paid.
+	BandStatus     string     `gorm:"not null;default:approved" json:"band_status"`
+	RequestedBy    *uuid.UUID `gorm:"type:uuid" json:"requested_by,omitempty"`
+	BandReviewedBy *uuid.UUID `gorm:"type:uuid" json:"band_reviewed_by,omitempty"`
+	BandReviewedAt *time.Time `json:"band_reviewed_at,omitempty"`
+	BandReviewNote string     `json:"band_review_note,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	// BandCheck and Warning describe the band check of a salary just
+	// created or updated
+	BandCheck *SalaryBandCheck `gorm:"-" json:"band_check,omitempty"`
+	Warning   string           `gorm:"-" json:"warning,omitempty"`
 }
 
 type EmployeeSalaryCreate struct {
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EmployeeVersion is one version of an employee record in a bitemporal
+// history. The record was valid from ValidFrom until ValidTo in the real
+// world, and was what the system believed from RecordedAt until SupersededAt.
+// A correction never modifies a version; it supersedes it with new ones.
+type EmployeeVersion struct {
+	ID            uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	EmployeeID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"employee_id"`
+	ValidFrom     time.Time  `gorm:"not null" json:"valid_from"`
+	ValidTo       *time.Time `json:"valid_to"`
+	RecordedAt    time.Time  `gorm:"not null" json:"recorded_at"`
+	SupersededAt  *time.Time `json:"superseded_at,omitempty"`
+	RecordedBy    *uuid.UUID `gorm:"type:uuid" json:"recorded_by"`
+	ChangeType    string     `gorm:"not null" json:"change_type"`
+	ChangedFields []string   `gorm:"type:text[]" json:"changed_fields"`
+	Employee      Employee   `gorm:"type:jsonb;column:data" json:"employee"`
+}
+
+// TableName specifies the table name for EmployeeVersion model
+func (EmployeeVersion) TableName() string {
+	return "employee_versions"
+}
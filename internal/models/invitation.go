@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Invitation lets a new user create an account with a role chosen by hr or an admin
+type Invitation struct {
+	ID             uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	Email          string     `gorm:"not null;index" json:"email" validate:"required,email"`
+	Role           string     `gorm:"not null" json:"role" validate:"required,oneof=admin hr employee manager"`
+	EmployeeID     *uuid.UUID `gorm:"type:uuid" json:"employee_id"`
+	InvitedBy      uuid.UUID  `gorm:"type:uuid;not null" json:"invited_by"`
+	ExpiresAt      time.Time  `gorm:"not null" json:"expires_at"`
+	AcceptedAt     *time.Time `json:"accepted_at"`
+	AcceptedUserID *uuid.UUID `gorm:"type:uuid" json:"accepted_user_id"`
+	RevokedAt      *time.Time `json:"revoked_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// InvitationCreate represents data for inviting a new user
+type InvitationCreate struct {
+	Email      string     `json:"email" binding:"required,email"`
+	Role       string     `json:"role" binding:"required,oneof=admin hr employee manager"`
+	EmployeeID *uuid.UUID `json:"employee_id"`
+	// ExpiresInHours defaults to the configured invitation lifetime
+	ExpiresInHours int `json:"expires_in_hours" binding:"omitempty,min=1,max=720"`
+}
+
+// InvitationAccept represents data for redeeming an invitation
+type InvitationAccept struct {
+	Code     string `json:"code" binding:"required"`
+	Username string `json:"username" binding:"required,min=3,max=30"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+// TableName specifies the table name for Invitation model
+func (Invitation) TableName() string {
+	return "invitations"
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Job history event types
+const (
+	JobEventHire           = "hire"
+	JobEventTransfer       = "transfer"
+	JobEventPositionChange = "position_change"
+	JobEventPromotion      = "promotion"
+	JobEventSuspension     = "suspension"
+	JobEventReinstatement  = "reinstatement"
+	JobEventTermination    = "termination"
+	JobEventRehire         = "rehire"
+)
+
+// JobHistory is an effective-dated snapshot of an employee's job. Each entry
+// is in effect from EffectiveDate until the next entry's EffectiveDate.
+type JobHistory struct {
+	ID               uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	EmployeeID       uuid.UUID  `gorm:"type:uuid;not null;index" json:"employee_id"`
+	EventType        string     `gorm:"not null" json:"event_type"`
+	EffectiveDate    time.Time  `gorm:"not null" json:"effective_date"`
+	DepartmentID     *uuid.UUID `gorm:"type:uuid" json:"department_id"`
+	PositionID       *uuid.UUID `gorm:"type:uuid" json:"position_id"`
+	ManagerID        *uuid.UUID `gorm:"type:uuid" json:"manager_id"`
+	EmploymentStatus string     `gorm:"not null" json:"employment_status"`
+	Reason           string     `json:"reason,omitempty"`
+	LastWorkingDay   *time.Time `json:"last_working_day,omitempty"`
+	RecordedBy       *uuid.UUID `gorm:"type:uuid" json:"recorded_by"`
+	CreatedAt        time.Time  `json:"created_at"`
+	// EffectiveTo is the day the next entry takes effect, or nil for the current entry
+	EffectiveTo *time.Time `gorm:"-" json:"effective_to"`
+}
+
+// EmployeeTransfer represents data for moving an employee to another department
+type EmployeeTransfer struct {
+	DepartmentID  uuid.UUID  `json:"department_id" binding:"required"`
+	PositionID    *uuid.UUID `json:"position_id"`
+	ManagerID     *uuid.UUID `json:"manager_id"`
+	EffectiveDate *time.Time `json:"effective_date"`
+	Reason        string     `json:"reason"`
+}
+
+// EmployeePositionChange represents data for a position change or promotion
+type EmployeePositionChange struct {
+	PositionID    uuid.UUID  `json:"position_id" binding:"required"`
+	ManagerID     *uuid.UUID `json:"manager_id"`
+	EffectiveDate *time.Time `json:"effective_date"`
+	Reason        string     `json:"reason"`
+}
+
+// EmployeeStatusChange represents data for suspending or reinstating an employee
+type EmployeeStatusChange struct {
+	EffectiveDate *time.Time `json:"effective_date"`
+	Reason        string     `json:"reason" binding:"required"`
+}
+
+// EmployeeTermination represents data for terminating an employee. The
+// termination takes effect the day after the last working day, which may be
+// in the future to schedule it.
+type EmployeeTermination struct {
+	LastWorkingDay time.Time `json:"last_working_day" binding:"required"`
+	Reason         string    `json:"reason" binding:"required"`
+}
+
+// EmployeeRehire represents data for rehiring a terminated employee. Fields
+// left out keep their values from before the termination.
+type EmployeeRehire struct {
+	DepartmentID  *uuid.UUID `json:"department_id"`
+	PositionID    *uuid.UUID `json:"position_id"`
+	ManagerID     *uuid.UUID `json:"manager_id"`
+	EffectiveDate *time.Time `json:"effective_date"`
+	Reason        string     `json:"reason"`
+}
+
+// TableName specifies the table name for JobHistory model
+func (JobHistory) TableName() string {
+	return "employee_job_history"
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LegalEntity is a company of the group that departments belong to
+type LegalEntity struct {
+	ID                 uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	Name               string    `gorm:"uniqueIndex;not null" json:"name"`
+	RegistrationNumber string    `json:"registration_number"`
+	// Country is an ISO 3166-1 alpha-2 code
+	Country   string    `json:"country"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// LegalEntityCreate holds the data for creating or replacing a legal entity
+type LegalEntityCreate struct {
+	Name               string `json:"name" binding:"required,max=255"`
+	RegistrationNumber string `json:"registration_number" binding:"max=100"`
+	Country            string `json:"country" binding:"omitempty,iso3166_1_alpha2"`
+}
+
+// TableName specifies the table name for LegalEntity model
+func (LegalEntity) TableName() string {
+	return "legal_entities"
+}
@@ -0,0 +1,55 @@
+package models
+
+import "github.com/google/uuid"
+
+// OrgNode is an employee's place in the reporting structure
+type OrgNode struct {
+	ID               uuid.UUID  `json:"id"`
+	EmployeeID       string     `json:"employee_id"`
+	FirstName        string     `json:"first_name"`
+	LastName         string     `json:"last_name"`
+	PositionTitle    string     `json:"position_title,omitempty"`
+	DepartmentID     *uuid.UUID `json:"department_id,omitempty"`
+	DepartmentName   string     `json:"department_name,omitempty"`
+	EmploymentStatus string     `json:"employment_status"`
+	ManagerID        *uuid.UUID `json:"manager_id"`
+	// ReportsToID is who the employee reports to in the chart: their
+	// manager, or without one who is still with the company, the head of
+	// their department or of the nearest department above it
+	ReportsToID *uuid.UUID `json:"reports_to_id"`
+	// Level is how many reporting lines away from the employee asked about
+	// this one is. It is only set in lists of reports and managers.
+	Level   int        `json:"level,omitempty"`
+	Reports []*OrgNode `json:"reports,omitempty"`
+}
+
+// OrgChart is the company's reporting structure. Roots are the employees
+// reporting to no one. Cycles lists groups of employees who manage each other;
+// each group is added to Roots starting from its first member.
+type OrgChart struct {
+	Roots  []*OrgNode    `json:"roots"`
+	Cycles [][]uuid.UUID `json:"cycles,omitempty"`
+}
+
+// SpanOfControl describes the team of one manager
+type SpanOfControl struct {
+	ManagerID     uuid.UUID `json:"manager_id"`
+	EmployeeID    string    `json:"employee_id"`
+	FirstName     string    `json:"first_name"`
+	LastName      string    `json:"last_name"`
+	DirectReports int       `json:"direct_reports"`
+	TotalReports  int       `json:"total_reports"`
+	// Depth is the number of management levels below the manager
+	Depth int `json:"depth"`
+}
+
+// SpanOfControlStats summarizes how wide and deep the organization is
+type SpanOfControlStats struct {
+	Employees   int             `json:"employees"`
+	Managers    int             `json:"managers"`
+	AverageSpan float64         `json:"average_span"`
+	MedianSpan  float64         `json:"median_span"`
+	MaxSpan     int             `json:"max_span"`
+	Depth       int             `json:"depth"`
+	Spans       []SpanOfControl `json:"spans"`
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Requisition statuses
+const (
+	RequisitionPending   = "pending"
+	RequisitionApproved  = "approved"
+	RequisitionRejected  = "rejected"
+	RequisitionFilled    = "filled"
+	RequisitionCancelled = "cancelled"
+)
+
+// HeadcountBudget is the number of seats approved for a position from
+// PeriodStart to PeriodEnd, both inclusive
+type HeadcountBudget struct {
+	ID          uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	PositionID  uuid.UUID  `gorm:"type:uuid;not null;index" json:"position_id"`
+	PeriodStart time.Time  `gorm:"type:date;not null" json:"period_start"`
+	PeriodEnd   time.Time  `gorm:"type:date;not null" json:"period_end"`
+	Headcount   int        `gorm:"not null" json:"headcount"`
+	Notes       string     `json:"notes"`
+	CreatedBy   *uuid.UUID `gorm:"type:uuid" json:"created_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// HeadcountBudgetCreate holds the data for creating or replacing a budget
+type HeadcountBudgetCreate struct {
+	PeriodStart time.Time `json:"period_start" binding:"required"`
+	PeriodEnd   time.Time `json:"period_end" binding:"required"`
+	Headcount   int       `json:"headcount" binding:"min=0"`
+	Notes       string    `json:"notes"`
+}
+
+// PositionRequisition asks to open vacancies on a position. Once approved
+// its seats count as open vacancies until they are filled or it is
+// cancelled.
+type PositionRequisition struct {
+	ID         uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	PositionID uuid.UUID `gorm:"type:uuid;not null;index" json:"position_id"`
+	Headcount  int       `gorm:"not null" json:"headcount"`
+	// FilledHeadcount counts the employees who joined the position since the
+	// requisition was approved
+	FilledHeadcount int        `gorm:"not null" json:"filled_headcount"`
+	Reason          string     `json:"reason"`
+	TargetStartDate *time.Time `gorm:"type:date" json:"target_start_date,omitempty"`
+	Status          string     `gorm:"not null" json:"status"`
+	RequestedBy     *uuid.UUID `gorm:"type:uuid" json:"requested_by,omitempty"`
+	ReviewedBy      *uuid.UUID `gorm:"type:uuid" json:"reviewed_by,omitempty"`
+	ReviewedAt      *time.Time `json:"reviewed_at,omitempty"`
+	ReviewNote      string     `json:"review_note,omitempty"`
+	ClosedAt        *time.Time `json:"closed_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// PositionRequisitionCreate holds the data for requesting vacancies
+type PositionRequisitionCreate struct {
+	PositionID      uuid.UUID  `json:"position_id" binding:"required"`
+	Headcount       int        `json:"headcount" binding:"omitempty,min=1"`
+	Reason          string     `json:"reason" binding:"required"`
+	TargetStartDate *time.Time `json:"target_start_date"`
+}
+
+// RequisitionReview is the decision on, or the closing note of, a requisition
+type RequisitionReview struct {
+	Note string `json:"note"`
+}
+
+// PositionHeadcount compares a position's budget on a date with its seats.
+// Filled counts current employees in the position and Vacant the budgeted
+// seats that are not filled, of which Open are being recruited for through
+// approved requisitions. Budgeted is nil when the position has no budget
+// for the date, and then every filled seat is over budget.
+type PositionHeadcount struct {
+	PositionID   uuid.UUID `json:"position_id"`
+	Title        string    `json:"title"`
+	DepartmentID uuid.UUID `json:"department_id"`
+	Budgeted     *int      `json:"budgeted"`
+	Filled       int       `json:"filled"`
+	Open         int       `json:"open"`
+	Vacant       int       `json:"vacant"`
+	OverBudget   int       `json:"over_budget"`
+}
+
+// DepartmentHeadcount sums the position headcounts of a department.
+// Unbudgeted counts the employees in positions without a budget, which
+// always count as over budget.
+type DepartmentHeadcount struct {
+	DepartmentID uuid.UUID `json:"department_id"`
+	Name         string    `json:"name"`
+	Budgeted     int       `json:"budgeted"`
+	Filled       int       `json:"filled"`
+	Open         int       `json:"open"`
+	Vacant       int       `json:"vacant"`
+	Unbudgeted   int       `json:"unbudgeted"`
+	OverBudget   int       `json:"over_budget"`
+}
+
+// TableName specifies the table name for HeadcountBudget model
+func (HeadcountBudget) TableName() string {
+	return "position_headcount_budgets"
+}
+
+// TableName specifies the table name for PositionRequisition model
+func (PositionRequisition) TableName() string {
+	return "position_requisitions"
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"github.com/google/uuid"
+)
+
+// Salary band statuses of employee salaries
+const (
+	SalaryBandApproved = "approved"
+	SalaryBandPending  = "pending"
+	SalaryBandRejected = "rejected"
+)
+
+// Where base pay falls relative to the salary band
+const (
+	BandPlacementBelow  = "below"
+	BandPlacementWithin = "within"
+	BandPlacementAbove  = "above"
+	BandPlacementNone   = "no_band"
+)
+
+// SalaryBandCheck compares an employee's base pay, the sum of their
+// recurring earnings, with the salary range of their position. CompaRatio
+// is base pay divided by the midpoint of the range, and is nil when the
+// employee has no position.
+type SalaryBandCheck struct {
+	EmployeeID     uuid.UUID  `json:"employee_id"`
+	EmployeeName   string     `json:"employee_name"`
+	DepartmentID   *uuid.UUID `json:"department_id"`
+	DepartmentName string     `json:"department_name,omitempty"`
+	PositionID     *uuid.UUID `json:"position_id"`
+	PositionTitle  string     `json:"position_title,omitempty"`
+	SalaryRangeMin float64    `json:"salary_range_min"`
+	SalaryRangeMax float64    `json:"salary_range_max"`
+	Midpoint       float64    `json:"midpoint"`
+	BasePay        float64    `json:"base_pay"`
+	CompaRatio     *float64   `json:"compa_ratio"`
+	Placement      string     `json:"placement"`
+}
+
+// DepartmentOutOfBand lists the employees of a department whose base pay
+// is outside their position's salary band
+type DepartmentOutOfBand struct {
+	DepartmentID *uuid.UUID        `json:"department_id"`
+	Name         string            `json:"name"`
+	Below        int               `json:"below"`
+	Above        int               `json:"above"`
+	Employees    []SalaryBandCheck `json:"employees"`
+}
+
+// SalaryBandReview is the decision on a salary waiting for band approval
+type SalaryBandReview struct {
+	Note string `json:"note"`
+}
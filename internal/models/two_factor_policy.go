@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// TwoFactorPolicy records whether two-factor authentication is mandatory for a role
+type TwoFactorPolicy struct {
+	Role      string    `gorm:"primary_key" json:"role"`
+	Required  bool      `gorm:"not null;default:false" json:"required"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TwoFactorPolicyUpdate represents data for changing a role's 2FA requirement
+type TwoFactorPolicyUpdate struct {
+	Required *bool `json:"required" binding:"required"`
+}
+
+// TableName specifies the table name for TwoFactorPolicy model
+func (TwoFactorPolicy) TableName() string {
+	return "two_factor_policies"
+}
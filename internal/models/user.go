@@ -17,6 +17,10 @@ type User struct {
 	LastLogin *time.Time `json:"last_login"`
 	CreatedAt time.Time  `json:"created_at"`
 	UpdatedAt time.Time  `json:"updated_at"`
+
+	TwoFactorEnabled   bool       `gorm:"default:false" json:"two_factor_enabled"`
+	MustChangePassword bool       `gorm:"default:false" json:"must_change_password"`
+	LockedUntil        *time.Time `json:"locked_until"`
 }
 
 // UserLogin represents user login credentials
@@ -30,7 +34,29 @@ type UserRegister struct {
 	Username string `json:"username" validate:"required,min=3,max=30"`
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required,min=6"`
-	Role     string `json:"role" validate:"required,oneof=admin hr employee manager"`
+}
+
+// UserRoleUpdate represents a role change made by an admin
+type UserRoleUpdate struct {
+	Role string `json:"role" binding:"required,oneof=admin hr employee manager"`
+}
+
+// UserStatusUpdate represents activating or deactivating a user
+type UserStatusUpdate struct {
+	IsActive *bool `json:"is_active" binding:"required"`
+}
+
+// UserPasswordSet represents an admin setting another user's password. Without
+// a password only the must-change flag is updated.
+type UserPasswordSet struct {
+	Password           string `json:"password"`
+	MustChangePassword *bool  `json:"must_change_password"`
+}
+
+// PasswordChange represents a user changing their own password
+type PasswordChange struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
 }
 
 // UserResponse represents user data returned in API responses
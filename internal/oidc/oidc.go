@@ -0,0 +1,383 @@
+// Package oidc is a minimal OpenID Connect relying party: discovery, the
+// authorization code flow with PKCE, and ID token verification.
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Config holds the relying party settings registered with the identity provider
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Provider talks to one OpenID Connect identity provider
+type Provider struct {
+	config Config
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *discoveryDocument
+	keys      map[string]interface{}
+	keysAt    time.Time
+}
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// IDTokenClaims are the ID token claims the application uses. Claims holds
+// the full set so that custom claims such as groups can be read.
+type IDTokenClaims struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+	Name              string
+	Claims            map[string]interface{}
+}
+
+// minKeyRefresh limits how often an unknown kid triggers a JWKS download
+const minKeyRefresh = time.Minute
+
+// NewProvider creates a provider. Discovery happens lazily on first use so the
+// application can start while the identity provider is unreachable.
+func NewProvider(config Config) *Provider {
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+	return &Provider{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Issuer returns the configured issuer URL
+func (p *Provider) Issuer() string {
+	return p.config.Issuer
+}
+
+// GenerateVerifier returns a random PKCE code verifier (RFC 7636)
+func GenerateVerifier() (string, error) {
+	return randomString(32)
+}
+
+// GenerateState returns a random value suitable for the state and nonce parameters
+func GenerateState() (string, error) {
+	return randomString(32)
+}
+
+// CodeChallenge derives the S256 challenge for a verifier
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL returns the URL the user is sent to in order to log in
+func (p *Provider) AuthCodeURL(state, nonce, verifier string) (string, error) {
+	doc, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.config.ClientID)
+	params.Set("redirect_uri", p.config.RedirectURL)
+	params.Set("scope", strings.Join(p.config.Scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", CodeChallenge(verifier))
+	params.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(doc.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return doc.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange trades an authorization code for tokens and returns the verified
+// ID token claims. nonce must match the value sent with the authorization request.
+func (p *Provider) Exchange(code, verifier, nonce string) (*IDTokenClaims, error) {
+	doc, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("code_verifier", verifier)
+
+	req, err := http.NewRequest(http.MethodPost, doc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		return nil, fmt.Errorf("parse token response: %w", err)
+	}
+	if tokens.IDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+
+	return p.verifyIDToken(tokens.IDToken, nonce, doc.Issuer)
+}
+
+func (p *Provider) verifyIDToken(rawToken, nonce, issuer string) (*IDTokenClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(rawToken, claims, p.keyfunc,
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "EdDSA"}),
+		jwt.WithIssuer(issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute))
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+
+	if got, _ := claims["nonce"].(string); got == "" || got != nonce {
+		return nil, errors.New("invalid id_token: nonce mismatch")
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, errors.New("invalid id_token: missing sub")
+	}
+
+	result := &IDTokenClaims{Subject: subject, Claims: claims}
+	result.Email, _ = claims["email"].(string)
+	result.PreferredUsername, _ = claims["preferred_username"].(string)
+	result.Name, _ = claims["name"].(string)
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		result.EmailVerified = verified
+	case string:
+		// Some providers send the flag as a string
+		result.EmailVerified = verified == "true"
+	}
+	return result, nil
+}
+
+// StringsClaim returns a claim holding a list of strings, such as groups.
+// A single string value is returned as a one-element list.
+func (c *IDTokenClaims) StringsClaim(name string) []string {
+	switch value := c.Claims[name].(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+func (p *Provider) discover() (*discoveryDocument, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var doc discoveryDocument
+	if err := p.getJSON(strings.TrimRight(p.config.Issuer, "/")+"/.well-known/openid-configuration", &doc); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if doc.Issuer != p.config.Issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer %q does not match %q", doc.Issuer, p.config.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, errors.New("oidc discovery: document is missing endpoints")
+	}
+
+	p.discovery = &doc
+	return p.discovery, nil
+}
+
+// keyfunc finds the provider key named by the token's kid, downloading the
+// JWKS again when the kid is unknown since the provider may have rotated keys
+func (p *Provider) keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	if time.Since(p.keysAt) < minKeyRefresh {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	var set struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := p.getJSON(p.discovery.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("fetch jwks: %w", err)
+	}
+
+	keys := map[string]interface{}{}
+	for _, raw := range set.Keys {
+		id, key, err := parseJWK(raw)
+		if err != nil {
+			// Skip keys we cannot use, such as encryption keys
+			continue
+		}
+		keys[id] = key
+	}
+	p.keys = keys
+	p.keysAt = time.Now()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookupKey finds a key by kid. A token without kid matches when the provider
+// publishes a single key.
+func (p *Provider) lookupKey(kid string) (interface{}, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+func parseJWK(raw json.RawMessage) (string, interface{}, error) {
+	var jwk struct {
+		KeyType string `json:"kty"`
+		KeyID   string `json:"kid"`
+		Use     string `json:"use"`
+		Curve   string `json:"crv"`
+		N       string `json:"n"`
+		E       string `json:"e"`
+		X       string `json:"x"`
+		Y       string `json:"y"`
+	}
+	if err := json.Unmarshal(raw, &jwk); err != nil {
+		return "", nil, err
+	}
+	if jwk.Use != "" && jwk.Use != "sig" {
+		return "", nil, errors.New("not a signing key")
+	}
+
+	decode := base64.RawURLEncoding.DecodeString
+	switch jwk.KeyType {
+	case "RSA":
+		n, err := decode(jwk.N)
+		if err != nil {
+			return "", nil, err
+		}
+		e, err := decode(jwk.E)
+		if err != nil {
+			return "", nil, err
+		}
+		return jwk.KeyID, &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return "", nil, fmt.Errorf("unsupported curve %q", jwk.Curve)
+		}
+		x, err := decode(jwk.X)
+		if err != nil {
+			return "", nil, err
+		}
+		y, err := decode(jwk.Y)
+		if err != nil {
+			return "", nil, err
+		}
+		return jwk.KeyID, &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		if jwk.Curve != "Ed25519" {
+			return "", nil, fmt.Errorf("unsupported curve %q", jwk.Curve)
+		}
+		x, err := decode(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return "", nil, errors.New("invalid Ed25519 key")
+		}
+		return jwk.KeyID, ed25519.PublicKey(x), nil
+	}
+	return "", nil, fmt.Errorf("unsupported key type %q", jwk.KeyType)
+}
+
+func (p *Provider) getJSON(url string, v interface{}) error {
+	resp, err := p.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %s", url, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
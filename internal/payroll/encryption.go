@@ -0,0 +1,146 @@
+package payroll
+
+import (
+	"database/sql"
+	"employee-management/internal/fieldcrypt"
+	"employee-management/internal/models"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// sealAmount returns the values of the amount and amount_encrypted columns
+// for a salary amount
+func (r *repository) sealAmount(amount float64) (interface{}, interface{}, error) {
+	if !r.cipher.Encrypts(fieldcrypt.FieldSalaryAmount) {
+		return amount, nil, nil
+	}
+	raw, err := json.Marshal(amount)
+	if err != nil {
+		return nil, nil, err
+	}
+	sealed, err := r.cipher.Encrypt(fieldcrypt.FieldSalaryAmount, raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	return nil, sealed, nil
+}
+
+// scanSalary scans a row of salaryColumns, decrypting the amount
+func (r *repository) scanSalary(row interface{ Scan(...interface{}) error }) (*models.EmployeeSalary, error) {
+	var s models.EmployeeSalary
+	var amount sql.NullFloat64
+	var amountEncrypted sql.NullString
+	err := row.Scan(&s.ID, &s.EmployeeID, &s.SalaryComponentID, &amount, &amountEncrypted, &s.EffectiveDate, &s.EndDate,
+		&s.BandStatus, &s.RequestedBy, &s.BandReviewedBy, &s.BandReviewedAt, &s.BandReviewNote, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	s.Amount = amount.Float64
+	if amountEncrypted.Valid {
+		raw, err := r.cipher.Decrypt(fieldcrypt.FieldSalaryAmount, amountEncrypted.String)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(raw, &s.Amount); err != nil {
+			return nil, err
+		}
+	}
+	return &s, nil
+}
+
+// ReencryptSalaries rewrites every salary amount not stored as currently
+// configured, in batches of one transaction each, and returns how many were
+// rewritten
+func (r *repository) ReencryptSalaries(logger *logrus.Entry, batchSize int) (int, error) {
+	startTime := time.Now()
+	rewritten := 0
+	after := uuid.Nil
+	for {
+		ids, n, err := r.reencryptSalaryBatch(after, batchSize)
+		if err != nil {
+			return rewritten, err
+		}
+		rewritten += n
+		if len(ids) < batchSize {
+			break
+		}
+		after = ids[len(ids)-1]
+	}
+
+	logger.WithFields(logrus.Fields{
+		"salaries": rewritten,
+		"duration": time.Since(startTime),
+	}).Debug("Executed ReencryptSalaries transactions")
+
+	return rewritten, nil
+}
+
+// reencryptSalaryBatch rewrites the stale salaries among the batch after the
+// given ID and returns the IDs of the batch
+func (r *repository) reencryptSalaryBatch(after uuid.UUID, batchSize int) ([]uuid.UUID, int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT id, amount, amount_encrypted FROM employee_salaries
+		WHERE id > $1 ORDER BY id LIMIT $2 FOR UPDATE`, after, batchSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	var ids []uuid.UUID
+	var stale []*models.EmployeeSalary
+	for rows.Next() {
+		var id uuid.UUID
+		var amount sql.NullFloat64
+		var amountEncrypted sql.NullString
+		if err := rows.Scan(&id, &amount, &amountEncrypted); err != nil {
+			rows.Close()
+			return nil, 0, err
+		}
+		ids = append(ids, id)
+
+		encrypts := r.cipher.Encrypts(fieldcrypt.FieldSalaryAmount)
+		if amountEncrypted.Valid == encrypts && (!encrypts || r.cipher.Current(fieldcrypt.FieldSalaryAmount, amountEncrypted.String)) {
+			continue
+		}
+		s := &models.EmployeeSalary{ID: id, Amount: amount.Float64}
+		if amountEncrypted.Valid {
+			raw, err := r.cipher.Decrypt(fieldcrypt.FieldSalaryAmount, amountEncrypted.String)
+			if err == nil {
+				err = json.Unmarshal(raw, &s.Amount)
+			}
+			if err != nil {
+				rows.Close()
+				return nil, 0, err
+			}
+		}
+		stale = append(stale, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	for _, s := range stale {
+		amount, amountEncrypted, err := r.sealAmount(s.Amount)
+		if err != nil {
+			return nil, 0, err
+		}
+		if _, err := tx.Exec("UPDATE employee_salaries SET amount = $1, amount_encrypted = $2 WHERE id = $3", amount, amountEncrypted, s.ID); err != nil {
+			return nil, 0, err
+		}
+	}
+	return ids, len(stale), tx.Commit()
+}
+
+// ReencryptSalaries brings the storage of salary amounts in line with the
+// field encryption settings
+func (s *Service) ReencryptSalaries(logger *logrus.Entry, batchSize int) (int, error) {
+	logger.WithField("batchSize", batchSize).Info("Re-encrypting salary amounts")
+	return s.repo.ReencryptSalaries(logger, batchSize)
+}
@@ -2,6 +2,7 @@ package payroll
 
 import (
 	"employee-management/internal/models"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -71,12 +72,23 @@ func (h *Handler) CreateEmployeeSalary(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	salary, err := h.service.CreateEmployeeSalary(logger, &input)
-	if err != nil {
+	salary, err := h.service.CreateEmployeeSalary(logger, &input, currentUserID(c))
+	switch {
+	case errors.Is(err, ErrEmployeeNotFound), errors.Is(err, ErrSalaryComponentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, ErrOutsideSalaryBand):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	case err != nil:
 		logger.WithError(err).Error("Failed to create employee salary")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create employee salary"})
 		return
 	}
+	if salary.BandStatus == models.SalaryBandPending {
+		c.JSON(http.StatusAccepted, salary)
+		return
+	}
 	c.JSON(http.StatusCreated, salary)
 }
 
@@ -92,6 +104,112 @@ func (h *Handler) GetEmployeeSalaries(c *gin.Context) {
 	c.JSON(http.StatusOK, salaries)
 }
 
+// --- Salary Band Handlers ---
+
+// GetSalaryBand returns an employee's base pay, compa-ratio and placement in
+// their position's salary band
+func (h *Handler) GetSalaryBand(c *gin.Context) {
+	logger := c.MustGet("logger").(*logrus.Entry)
+	employeeID, err := uuid.Parse(c.Param("employeeId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid employee ID"})
+		return
+	}
+	band, err := h.service.GetSalaryBand(logger, employeeID)
+	switch {
+	case errors.Is(err, ErrEmployeeNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		logger.WithError(err).Error("Failed to get salary band")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get salary band"})
+		return
+	}
+	c.JSON(http.StatusOK, band)
+}
+
+// GetOutOfBandReport lists the employees paid outside their salary band by
+// department
+func (h *Handler) GetOutOfBandReport(c *gin.Context) {
+	logger := c.MustGet("logger").(*logrus.Entry)
+	var departmentID *uuid.UUID
+	if value := c.Query("department_id"); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid department ID"})
+			return
+		}
+		departmentID = &id
+	}
+	report, err := h.service.OutOfBandReport(logger, departmentID)
+	if err != nil {
+		logger.WithError(err).Error("Failed to build out-of-band report")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build out-of-band report"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// ListPendingSalaries lists the salaries waiting for band approval
+func (h *Handler) ListPendingSalaries(c *gin.Context) {
+	logger := c.MustGet("logger").(*logrus.Entry)
+	salaries, err := h.service.ListPendingSalaries(logger)
+	if err != nil {
+		logger.WithError(err).Error("Failed to list pending salaries")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list pending salaries"})
+		return
+	}
+	c.JSON(http.StatusOK, salaries)
+}
+
+// ApproveSalaryBand approves a salary outside its salary band
+func (h *Handler) ApproveSalaryBand(c *gin.Context) {
+	h.reviewSalaryBand(c, true)
+}
+
+// RejectSalaryBand rejects a salary outside its salary band
+func (h *Handler) RejectSalaryBand(c *gin.Context) {
+	h.reviewSalaryBand(c, false)
+}
+
+func (h *Handler) reviewSalaryBand(c *gin.Context, approve bool) {
+	logger := c.MustGet("logger").(*logrus.Entry)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid salary ID"})
+		return
+	}
+	var review models.SalaryBandReview
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&review); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	salary, err := h.service.ReviewSalaryBand(logger, id, approve, currentUserID(c), review.Note)
+	switch {
+	case errors.Is(err, ErrSalaryNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrSalaryNotPending), errors.Is(err, ErrOwnSalaryApproval):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		logger.WithError(err).Error("Failed to review salary")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review salary"})
+	default:
+		c.JSON(http.StatusOK, salary)
+	}
+}
+
+// currentUserID returns the authenticated user's ID, or nil if there is none
+func currentUserID(c *gin.Context) *uuid.UUID {
+	id, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		return nil
+	}
+	return &id
+}
+
 // --- Tax Bracket Handlers ---
 
 func (h *Handler) CreateTaxBracket(c *gin.Context) {
@@ -1,7 +1,9 @@
 package payroll
 
 import (
+	"database/sql"
 	"employee-management/internal/database"
+	"employee-management/internal/fieldcrypt"
 	"employee-management/internal/models"
 	"time"
 
@@ -19,12 +21,18 @@ type Repository interface {
 	DeleteSalaryComponent(logger *logrus.Entry, id uuid.UUID) error
 
 	// Employee Salary methods
-	CreateEmployeeSalary(logger *logrus.Entry, data *models.EmployeeSalaryCreate) (*models.EmployeeSalary, error)
+	CreateEmployeeSalary(logger *logrus.Entry, data *models.EmployeeSalaryCreate, requestedBy *uuid.UUID, check BandCheck) (*models.EmployeeSalary, error)
 	GetEmployeeSalariesByEmployeeID(logger *logrus.Entry, employeeID uuid.UUID) ([]models.EmployeeSalary, error)
 	GetEmployeeSalary(logger *logrus.Entry, id uuid.UUID) (*models.EmployeeSalary, error)
-	UpdateEmployeeSalary(logger *logrus.Entry, id uuid.UUID, data *models.EmployeeSalaryUpdate) (*models.EmployeeSalary, error)
+	UpdateEmployeeSalary(logger *logrus.Entry, id uuid.UUID, data *models.EmployeeSalaryUpdate, check BandCheck) (*models.EmployeeSalary, error)
 	DeleteEmployeeSalary(logger *logrus.Entry, id uuid.UUID) error
 
+	// Salary band methods
+	ListSalaryBands(logger *logrus.Entry, employeeID *uuid.UUID) ([]models.SalaryBandCheck, error)
+	GetBasePay(logger *logrus.Entry, date time.Time, employeeID *uuid.UUID, except *uuid.UUID) (map[uuid.UUID]float64, error)
+	ListPendingSalaries(logger *logrus.Entry) ([]models.EmployeeSalary, error)
+	ReviewSalaryBand(logger *logrus.Entry, id uuid.UUID, approve bool, reviewedBy *uuid.UUID, note string) (*models.EmployeeSalary, error)
+
 	// Tax Bracket methods
 	CreateTaxBracket(logger *logrus.Entry, data *models.TaxBracketCreate) (*models.TaxBracket, error)
 	GetTaxBracketByID(logger *logrus.Entry, id uuid.UUID) (*models.TaxBracket, error)
@@ -45,15 +53,21 @@ type Repository interface {
 	// Payslip methods
 	CreatePayslip(logger *logrus.Entry, data *models.PayslipCreate) (*models.Payslip, error)
 	GetPayslip(logger *logrus.Entry, id uuid.UUID) (*models.Payslip, error)
+	ListPayslipsByEmployeeID(logger *logrus.Entry, employeeID uuid.UUID) ([]models.Payslip, error)
+
+	// Field encryption
+	ReencryptSalaries(logger *logrus.Entry, batchSize int) (int, error)
 }
 
 type repository struct {
-	db *database.DB
+	db     *database.DB
+	cipher *fieldcrypt.Cipher
 }
 
-// NewRepository creates a new payroll repository
-func NewRepository(db *database.DB) Repository {
-	return &repository{db}
+// NewRepository creates a new payroll repository. Salary amounts are
+// encrypted if the cipher is configured to encrypt them.
+func NewRepository(db *database.DB, cipher *fieldcrypt.Cipher) Repository {
+	return &repository{db, cipher}
 }
 
 func logQuery(logger *logrus.Entry, query string, startTime time.Time) {
@@ -135,34 +149,56 @@ func (r *repository) DeleteSalaryComponent(logger *logrus.Entry, id uuid.UUID) e
 
 // --- Employee Salary ---
 
-func (r *repository) CreateEmployeeSalary(logger *logrus.Entry, data *models.EmployeeSalaryCreate) (*models.EmployeeSalary, error) {
+const salaryColumns = "id, employee_id, salary_component_id, amount, amount_encrypted, effective_date, end_date, " +
+	"band_status, requested_by, band_reviewed_by, band_reviewed_at, COALESCE(band_review_note, ''), created_at, updated_at"
+
+// CreateEmployeeSalary adds a salary. With check, the employee's salaries
+// are locked and check decides the band status from the base pay on the
+// effective date, so concurrent changes cannot take base pay out of the band.
+// Without it the salary is approved.
+func (r *repository) CreateEmployeeSalary(logger *logrus.Entry, data *models.EmployeeSalaryCreate, requestedBy *uuid.UUID, check BandCheck) (*models.EmployeeSalary, error) {
 	startTime := time.Now()
-	var s models.EmployeeSalary
-	query := `INSERT INTO employee_salaries (employee_id, salary_component_id, amount, effective_date, end_date)
-			  VALUES ($1, $2, $3, $4, $5)
-			  RETURNING id, employee_id, salary_component_id, amount, effective_date, end_date, created_at, updated_at`
-	err := r.db.QueryRow(query, data.EmployeeID, data.SalaryComponentID, data.Amount, data.EffectiveDate, data.EndDate).Scan(
-		&s.ID, &s.EmployeeID, &s.SalaryComponentID, &s.Amount, &s.EffectiveDate, &s.EndDate, &s.CreatedAt, &s.UpdatedAt,
-	)
+	amount, amountEncrypted, err := r.sealAmount(data.Amount)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	bandStatus := models.SalaryBandApproved
+	if check != nil {
+		if bandStatus, err = r.checkBand(tx, check, data.EmployeeID, data.SalaryComponentID, data.EffectiveDate); err != nil {
+			return nil, err
+		}
+	}
+
+	query := `INSERT INTO employee_salaries (employee_id, salary_component_id, amount, amount_encrypted, effective_date, end_date, band_status, requested_by)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			  RETURNING ` + salaryColumns
+	s, err := r.scanSalary(tx.QueryRow(query, data.EmployeeID, data.SalaryComponentID, amount, amountEncrypted, data.EffectiveDate, data.EndDate, bandStatus, requestedBy))
 	logQuery(logger, query, startTime)
-	return &s, err
+	if err != nil {
+		return nil, err
+	}
+	return s, tx.Commit()
 }
+
 func (r *repository) GetEmployeeSalary(logger *logrus.Entry, id uuid.UUID) (*models.EmployeeSalary, error) {
 	startTime := time.Now()
-	var s models.EmployeeSalary
-	query := `SELECT id, employee_id, salary_component_id, amount, effective_date, end_date, created_at, updated_at
+	query := `SELECT ` + salaryColumns + `
 			  FROM employee_salaries WHERE id = $1`
-	err := r.db.QueryRow(query, id).Scan(
-		&s.ID, &s.EmployeeID, &s.SalaryComponentID, &s.Amount, &s.EffectiveDate, &s.EndDate, &s.CreatedAt, &s.UpdatedAt,
-	)
+	s, err := r.scanSalary(r.db.QueryRow(query, id))
 	logQuery(logger, query, startTime)
-	return &s, err
+	return s, err
 }
 
 func (r *repository) GetEmployeeSalariesByEmployeeID(logger *logrus.Entry, employeeID uuid.UUID) ([]models.EmployeeSalary, error) {
 	startTime := time.Now()
 	var salaries []models.EmployeeSalary
-	query := `SELECT id, employee_id, salary_component_id, amount, effective_date, end_date, created_at, updated_at
+	query := `SELECT ` + salaryColumns + `
 			  FROM employee_salaries WHERE employee_id = $1 AND (end_date IS NULL OR end_date > NOW())`
 	rows, err := r.db.Query(query, employeeID)
 	logQuery(logger, query, startTime)
@@ -171,27 +207,63 @@ func (r *repository) GetEmployeeSalariesByEmployeeID(logger *logrus.Entry, emplo
 	}
 	defer rows.Close()
 	for rows.Next() {
-		var s models.EmployeeSalary
-		if err := rows.Scan(&s.ID, &s.EmployeeID, &s.SalaryComponentID, &s.Amount, &s.EffectiveDate, &s.EndDate, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		s, err := r.scanSalary(rows)
+		if err != nil {
 			return nil, err
 		}
-		salaries = append(salaries, s)
+		salaries = append(salaries, *s)
 	}
 	return salaries, nil
 }
 
-func (r *repository) UpdateEmployeeSalary(logger *logrus.Entry, id uuid.UUID, data *models.EmployeeSalaryUpdate) (*models.EmployeeSalary, error) {
+// UpdateEmployeeSalary changes a salary's amount and end date. With check,
+// an approved salary is checked against the band as of the later of today and
+// its effective date, with the employee's salaries locked.
+func (r *repository) UpdateEmployeeSalary(logger *logrus.Entry, id uuid.UUID, data *models.EmployeeSalaryUpdate, check BandCheck) (*models.EmployeeSalary, error) {
 	startTime := time.Now()
-	var s models.EmployeeSalary
+	amount, amountEncrypted, err := r.sealAmount(data.Amount)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if check != nil {
+		var employeeID, componentID uuid.UUID
+		var effectiveDate time.Time
+		var bandStatus string
+		err := tx.QueryRow("SELECT employee_id, salary_component_id, effective_date, band_status FROM employee_salaries WHERE id = $1", id).
+			Scan(&employeeID, &componentID, &effectiveDate, &bandStatus)
+		if err == sql.ErrNoRows {
+			return nil, ErrSalaryNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		if bandStatus == models.SalaryBandApproved {
+			date := time.Now()
+			if effectiveDate.After(date) {
+				date = effectiveDate
+			}
+			if _, err := r.checkBand(tx, check, employeeID, componentID, date); err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	query := `UPDATE employee_salaries
-			  SET amount = $1, end_date = $2, updated_at = NOW()
-			  WHERE id = $3
-			  RETURNING id, employee_id, salary_component_id, amount, effective_date, end_date, created_at, updated_at`
-	err := r.db.QueryRow(query, data.Amount, data.EndDate, id).Scan(
-		&s.ID, &s.EmployeeID, &s.SalaryComponentID, &s.Amount, &s.EffectiveDate, &s.EndDate, &s.CreatedAt, &s.UpdatedAt,
-	)
+			  SET amount = $1, amount_encrypted = $2, end_date = $3, updated_at = NOW()
+			  WHERE id = $4
+			  RETURNING ` + salaryColumns
+	s, err := r.scanSalary(tx.QueryRow(query, amount, amountEncrypted, data.EndDate, id))
 	logQuery(logger, query, startTime)
-	return &s, err
+	if err != nil {
+		return nil, err
+	}
+	return s, tx.Commit()
 }
 
 func (r *repository) DeleteEmployeeSalary(logger *logrus.Entry, id uuid.UUID) error {
@@ -396,3 +468,25 @@ func (r *repository) GetPayslip(logger *logrus.Entry, id uuid.UUID) (*models.Pay
 	logQuery(logger, query, startTime)
 	return &ps, err
 }
+
+func (r *repository) ListPayslipsByEmployeeID(logger *logrus.Entry, employeeID uuid.UUID) ([]models.Payslip, error) {
+	startTime := time.Now()
+	query := `SELECT id, employee_id, payroll_id, pay_period_start, pay_period_end, gross_pay, tax_amount, deductions, net_pay, file_path, created_at
+			  FROM payslips WHERE employee_id = $1 ORDER BY pay_period_end DESC`
+	rows, err := r.db.Query(query, employeeID)
+	logQuery(logger, query, startTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payslips := []models.Payslip{}
+	for rows.Next() {
+		var ps models.Payslip
+		if err := rows.Scan(&ps.ID, &ps.EmployeeID, &ps.PayrollID, &ps.PayPeriodStart, &ps.PayPeriodEnd, &ps.GrossPay, &ps.TaxAmount, &ps.Deductions, &ps.NetPay, &ps.FilePath, &ps.CreatedAt); err != nil {
+			return nil, err
+		}
+		payslips = append(payslips, ps)
+	}
+	return payslips, rows.Err()
+}
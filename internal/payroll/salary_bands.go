@@ -0,0 +1,382 @@
+package payroll
+
+import (
+	"database/sql"
+	"employee-management/internal/models"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Salary band enforcement modes. With warn, salaries outside the band are
+// saved with a warning; with approval, they wait for a second reviewer
+// before they are paid.
+const (
+	BandEnforcementOff      = "off"
+	BandEnforcementWarn     = "warn"
+	BandEnforcementBlock    = "block"
+	BandEnforcementApproval = "approval"
+)
+
+var (
+	// ErrInvalidBandEnforcement is returned for an unknown enforcement mode
+	ErrInvalidBandEnforcement = errors.New("salary band enforcement must be off, warn, block or approval")
+	// ErrEmployeeNotFound is returned when an employee does not exist
+	ErrEmployeeNotFound = errors.New("employee not found")
+	// ErrSalaryComponentNotFound is returned when a salary component does not exist
+	ErrSalaryComponentNotFound = errors.New("salary component not found")
+	// ErrOutsideSalaryBand is returned when base pay outside the position's salary band is refused
+	ErrOutsideSalaryBand = errors.New("base pay is outside the position's salary band")
+	// ErrSalaryNotFound is returned when an employee salary does not exist
+	ErrSalaryNotFound = errors.New("employee salary not found")
+	// ErrSalaryNotPending is returned when reviewing a salary that is not waiting for band approval
+	ErrSalaryNotPending = errors.New("salary is not waiting for band approval")
+	// ErrOwnSalaryApproval is returned when users review a salary they entered themselves
+	ErrOwnSalaryApproval = errors.New("salaries cannot be approved or rejected by the user who entered them")
+)
+
+// ParseBandEnforcement checks an enforcement mode, defaulting to warn
+func ParseBandEnforcement(mode string) (string, error) {
+	switch mode {
+	case "":
+		return BandEnforcementWarn, nil
+	case BandEnforcementOff, BandEnforcementWarn, BandEnforcementBlock, BandEnforcementApproval:
+		return mode, nil
+	}
+	return "", fmt.Errorf("%w, not %q", ErrInvalidBandEnforcement, mode)
+}
+
+// --- Repository ---
+
+// ListSalaryBands retrieves the position and salary range of every current
+// employee, or of one employee, without their pay
+func (r *repository) ListSalaryBands(logger *logrus.Entry, employeeID *uuid.UUID) ([]models.SalaryBandCheck, error) {
+	startTime := time.Now()
+	query := `SELECT e.id, e.first_name || ' ' || e.last_name, e.department_id, COALESCE(d.name, ''), e.position_id,
+			         COALESCE(p.title, ''), COALESCE(p.salary_range_min, 0), COALESCE(p.salary_range_max, 0)
+			  FROM employees e
+			  LEFT JOIN departments d ON d.id = e.department_id
+			  LEFT JOIN positions p ON p.id = e.position_id
+			  WHERE e.deleted_at IS NULL AND e.employment_status <> 'terminated' AND ($1::uuid IS NULL OR e.id = $1)
+			  ORDER BY e.last_name, e.first_name, e.id`
+	rows, err := r.db.Query(query, employeeID)
+	logQuery(logger, query, startTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bands []models.SalaryBandCheck
+	for rows.Next() {
+		var band models.SalaryBandCheck
+		if err := rows.Scan(&band.EmployeeID, &band.EmployeeName, &band.DepartmentID, &band.DepartmentName, &band.PositionID,
+			&band.PositionTitle, &band.SalaryRangeMin, &band.SalaryRangeMax); err != nil {
+			return nil, err
+		}
+		bands = append(bands, band)
+	}
+	return bands, rows.Err()
+}
+
+// BandCheck decides the band status of a salary about to be saved from the
+// employee's base pay on its date, not counting the salary's component
+type BandCheck func(basePay float64) (string, error)
+
+// basePayQuery sums the approved recurring earnings in effect on $1, for
+// every employee or the one $2, leaving out the component $3
+const basePayQuery = `SELECT ` + salaryColumns + `
+			  FROM employee_salaries
+			  WHERE salary_component_id IN (SELECT id FROM salary_components WHERE type = 'earning' AND is_recurring)
+			    AND band_status = 'approved' AND effective_date <= $1 AND (end_date IS NULL OR end_date > $1)
+			    AND ($2::uuid IS NULL OR employee_id = $2) AND ($3::uuid IS NULL OR salary_component_id <> $3)`
+
+// checkBand locks the employee's salaries until the transaction ends and
+// runs check on their base pay on date, without the component
+func (r *repository) checkBand(tx *sql.Tx, check BandCheck, employeeID, componentID uuid.UUID, date time.Time) (string, error) {
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext('employee_salaries:' || $1::text))", employeeID); err != nil {
+		return "", err
+	}
+	rows, err := tx.Query(basePayQuery, date, employeeID, componentID)
+	if err != nil {
+		return "", err
+	}
+	basePay, err := r.sumBasePay(rows)
+	if err != nil {
+		return "", err
+	}
+	return check(basePay[employeeID])
+}
+
+// GetBasePay sums the approved recurring earnings in effect on a date by
+// employee, for every employee or one. Salaries of the except component are
+// left out.
+func (r *repository) GetBasePay(logger *logrus.Entry, date time.Time, employeeID *uuid.UUID, except *uuid.UUID) (map[uuid.UUID]float64, error) {
+	startTime := time.Now()
+	rows, err := r.db.Query(basePayQuery, date, employeeID, except)
+	logQuery(logger, basePayQuery, startTime)
+	if err != nil {
+		return nil, err
+	}
+	return r.sumBasePay(rows)
+}
+
+// sumBasePay adds up salaries by employee and closes the rows
+func (r *repository) sumBasePay(rows *sql.Rows) (map[uuid.UUID]float64, error) {
+	defer rows.Close()
+
+	basePay := map[uuid.UUID]float64{}
+	for rows.Next() {
+		salary, err := r.scanSalary(rows)
+		if err != nil {
+			return nil, err
+		}
+		basePay[salary.EmployeeID] += salary.Amount
+	}
+	return basePay, rows.Err()
+}
+
+// ListPendingSalaries retrieves the salaries waiting for band approval,
+// oldest first
+func (r *repository) ListPendingSalaries(logger *logrus.Entry) ([]models.EmployeeSalary, error) {
+	startTime := time.Now()
+	query := `SELECT ` + salaryColumns + `
+			  FROM employee_salaries WHERE band_status = 'pending' ORDER BY created_at`
+	rows, err := r.db.Query(query)
+	logQuery(logger, query, startTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	salaries := []models.EmployeeSalary{}
+	for rows.Next() {
+		salary, err := r.scanSalary(rows)
+		if err != nil {
+			return nil, err
+		}
+		salaries = append(salaries, *salary)
+	}
+	return salaries, rows.Err()
+}
+
+// ReviewSalaryBand approves or rejects a salary waiting for band approval
+func (r *repository) ReviewSalaryBand(logger *logrus.Entry, id uuid.UUID, approve bool, reviewedBy *uuid.UUID, note string) (*models.EmployeeSalary, error) {
+	startTime := time.Now()
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	salary, err := r.scanSalary(tx.QueryRow(`SELECT `+salaryColumns+` FROM employee_salaries WHERE id = $1 FOR UPDATE`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrSalaryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if salary.BandStatus != models.SalaryBandPending {
+		return nil, ErrSalaryNotPending
+	}
+	if reviewedBy != nil && salary.RequestedBy != nil && *reviewedBy == *salary.RequestedBy {
+		return nil, ErrOwnSalaryApproval
+	}
+
+	status := models.SalaryBandRejected
+	if approve {
+		status = models.SalaryBandApproved
+	}
+	query := `UPDATE employee_salaries
+			  SET band_status = $1, band_reviewed_by = $2, band_reviewed_at = NOW(), band_review_note = NULLIF($3, ''), updated_at = NOW()
+			  WHERE id = $4
+			  RETURNING ` + salaryColumns
+	salary, err = r.scanSalary(tx.QueryRow(query, status, reviewedBy, note, id))
+	logQuery(logger, query, startTime)
+	if err != nil {
+		return nil, err
+	}
+	return salary, tx.Commit()
+}
+
+// --- Service ---
+
+// placeInBand fills in the midpoint, compa-ratio and placement of base pay
+// in the employee's salary band
+func placeInBand(band *models.SalaryBandCheck, basePay float64) {
+	band.BasePay = roundMoney(basePay)
+	band.CompaRatio = nil
+	if band.PositionID == nil {
+		band.Midpoint = 0
+		band.Placement = models.BandPlacementNone
+		return
+	}
+	band.Midpoint = roundMoney((band.SalaryRangeMin + band.SalaryRangeMax) / 2)
+	if band.Midpoint > 0 {
+		ratio := math.Round(band.BasePay/band.Midpoint*1000) / 1000
+		band.CompaRatio = &ratio
+	}
+	switch {
+	case band.BasePay < band.SalaryRangeMin:
+		band.Placement = models.BandPlacementBelow
+	case band.BasePay > band.SalaryRangeMax:
+		band.Placement = models.BandPlacementAbove
+	default:
+		band.Placement = models.BandPlacementWithin
+	}
+}
+
+// roundMoney rounds an amount to cents
+func roundMoney(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// bandWarning describes base pay outside the salary band
+func bandWarning(band *models.SalaryBandCheck) string {
+	return fmt.Sprintf("base pay %.2f is %s the salary band %.2f-%.2f of position %s",
+		band.BasePay, band.Placement, band.SalaryRangeMin, band.SalaryRangeMax, band.PositionTitle)
+}
+
+// salaryBand returns the salary band of an employee whose salary of the
+// component changes, ready to place base pay in, or nil if the component is
+// not part of base pay
+func (s *Service) salaryBand(logger *logrus.Entry, employeeID, componentID uuid.UUID) (*models.SalaryBandCheck, error) {
+	component, err := s.repo.GetSalaryComponentByID(logger, componentID)
+	if err == sql.ErrNoRows {
+		return nil, ErrSalaryComponentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if component.Type != "earning" || !component.IsRecurring {
+		return nil, nil
+	}
+
+	bands, err := s.repo.ListSalaryBands(logger, &employeeID)
+	if err != nil {
+		return nil, err
+	}
+	if len(bands) == 0 {
+		return nil, ErrEmployeeNotFound
+	}
+	return &bands[0], nil
+}
+
+// enforceSalaryBand applies the enforcement mode to a salary about to be
+// saved and returns its band status. Salaries outside the band are refused
+// with block, and wait for approval with approval unless pendingAllowed is
+// false, in which case they are refused too.
+func (s *Service) enforceSalaryBand(logger *logrus.Entry, band *models.SalaryBandCheck, pendingAllowed bool) (string, string, error) {
+	if band == nil || band.Placement == models.BandPlacementWithin || band.Placement == models.BandPlacementNone {
+		return models.SalaryBandApproved, "", nil
+	}
+	warning := bandWarning(band)
+	switch s.bandEnforcement {
+	case BandEnforcementBlock:
+		return "", "", fmt.Errorf("%w: %s", ErrOutsideSalaryBand, warning)
+	case BandEnforcementApproval:
+		if !pendingAllowed {
+			return "", "", fmt.Errorf("%w: %s; add a new salary to request approval", ErrOutsideSalaryBand, warning)
+		}
+		return models.SalaryBandPending, warning, nil
+	case BandEnforcementWarn:
+		logger.WithField("employeeID", band.EmployeeID).Warn("Salary outside salary band: " + warning)
+		return models.SalaryBandApproved, warning, nil
+	}
+	return models.SalaryBandApproved, "", nil
+}
+
+// GetSalaryBand returns an employee's base pay today compared with their
+// position's salary band
+func (s *Service) GetSalaryBand(logger *logrus.Entry, employeeID uuid.UUID) (*models.SalaryBandCheck, error) {
+	bands, err := s.repo.ListSalaryBands(logger, &employeeID)
+	if err != nil {
+		return nil, err
+	}
+	if len(bands) == 0 {
+		return nil, ErrEmployeeNotFound
+	}
+	basePay, err := s.repo.GetBasePay(logger, time.Now(), &employeeID, nil)
+	if err != nil {
+		return nil, err
+	}
+	band := &bands[0]
+	placeInBand(band, basePay[employeeID])
+	return band, nil
+}
+
+// OutOfBandReport lists, by department, the current employees whose base
+// pay today is outside their position's salary band, optionally for one
+// department only. Employees without a position are left out.
+func (s *Service) OutOfBandReport(logger *logrus.Entry, departmentID *uuid.UUID) ([]models.DepartmentOutOfBand, error) {
+	bands, err := s.repo.ListSalaryBands(logger, nil)
+	if err != nil {
+		return nil, err
+	}
+	basePay, err := s.repo.GetBasePay(logger, time.Now(), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	byDepartment := map[uuid.UUID]*models.DepartmentOutOfBand{}
+	for i := range bands {
+		band := &bands[i]
+		if band.PositionID == nil {
+			continue
+		}
+		if departmentID != nil && (band.DepartmentID == nil || *band.DepartmentID != *departmentID) {
+			continue
+		}
+		placeInBand(band, basePay[band.EmployeeID])
+		if band.Placement == models.BandPlacementWithin {
+			continue
+		}
+
+		key := uuid.Nil
+		if band.DepartmentID != nil {
+			key = *band.DepartmentID
+		}
+		department, ok := byDepartment[key]
+		if !ok {
+			department = &models.DepartmentOutOfBand{DepartmentID: band.DepartmentID, Name: band.DepartmentName}
+			if band.DepartmentID == nil {
+				department.Name = "Unassigned"
+			}
+			byDepartment[key] = department
+		}
+		if band.Placement == models.BandPlacementBelow {
+			department.Below++
+		} else {
+			department.Above++
+		}
+		department.Employees = append(department.Employees, *band)
+	}
+
+	report := []models.DepartmentOutOfBand{}
+	for _, department := range byDepartment {
+		report = append(report, *department)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		a, b := report[i], report[j]
+		if a.Below+a.Above != b.Below+b.Above {
+			return a.Below+a.Above > b.Below+b.Above
+		}
+		return a.Name < b.Name
+	})
+	return report, nil
+}
+
+// ListPendingSalaries lists the salaries waiting for band approval
+func (s *Service) ListPendingSalaries(logger *logrus.Entry) ([]models.EmployeeSalary, error) {
+	return s.repo.ListPendingSalaries(logger)
+}
+
+// ReviewSalaryBand approves or rejects a salary outside its salary band.
+// Approved salaries are paid from the next payroll run.
+func (s *Service) ReviewSalaryBand(logger *logrus.Entry, id uuid.UUID, approve bool, reviewedBy *uuid.UUID, note string) (*models.EmployeeSalary, error) {
+	return s.repo.ReviewSalaryBand(logger, id, approve, reviewedBy, note)
+}
@@ -1,6 +1,7 @@
 package payroll
 
 import (
+	"database/sql"
 	"employee-management/internal/models"
 	"errors"
 	"time"
@@ -18,13 +19,17 @@ type EmployeeService interface {
 type Service struct {
 	repo            Repository
 	employeeService EmployeeService
+	bandEnforcement string
 }
 
-// NewService creates a new payroll service
-func NewService(repo Repository, employeeService EmployeeService) *Service {
+// NewService creates a new payroll service. bandEnforcement says what
+// happens to salaries outside the position's salary band; see
+// BandEnforcementWarn and the other modes.
+func NewService(repo Repository, employeeService EmployeeService, bandEnforcement string) *Service {
 	return &Service{
 		repo:            repo,
 		employeeService: employeeService,
+		bandEnforcement: bandEnforcement,
 	}
 }
 
@@ -52,8 +57,34 @@ func (s *Service) DeleteSalaryComponent(logger *logrus.Entry, id uuid.UUID) erro
 
 // --- Employee Salary ---
 
-func (s *Service) CreateEmployeeSalary(logger *logrus.Entry, data *models.EmployeeSalaryCreate) (*models.EmployeeSalary, error) {
-	return s.repo.CreateEmployeeSalary(logger, data)
+// CreateEmployeeSalary adds a salary to an employee, enforcing their
+// position's salary band on base pay as of the effective date
+func (s *Service) CreateEmployeeSalary(logger *logrus.Entry, data *models.EmployeeSalaryCreate, requestedBy *uuid.UUID) (*models.EmployeeSalary, error) {
+	var band *models.SalaryBandCheck
+	var warning string
+	var check BandCheck
+	if s.bandEnforcement != BandEnforcementOff {
+		var err error
+		if band, err = s.salaryBand(logger, data.EmployeeID, data.SalaryComponentID); err != nil {
+			return nil, err
+		}
+	}
+	if band != nil {
+		check = func(basePay float64) (string, error) {
+			placeInBand(band, basePay+data.Amount)
+			status, bandWarning, err := s.enforceSalaryBand(logger, band, true)
+			warning = bandWarning
+			return status, err
+		}
+	}
+
+	salary, err := s.repo.CreateEmployeeSalary(logger, data, requestedBy, check)
+	if err != nil {
+		return nil, err
+	}
+	salary.BandCheck = band
+	salary.Warning = warning
+	return salary, nil
 }
 
 func (s *Service) GetEmployeeSalaries(logger *logrus.Entry, employeeID uuid.UUID) ([]models.EmployeeSalary, error) {
@@ -64,8 +95,44 @@ func (s *Service) GetEmployeeSalary(logger *logrus.Entry, id uuid.UUID) (*models
 	return s.repo.GetEmployeeSalary(logger, id)
 }
 
+// UpdateEmployeeSalary changes a salary's amount and end date. Amounts
+// outside the salary band cannot wait for approval here, so with approval
+// they are refused like with block.
 func (s *Service) UpdateEmployeeSalary(logger *logrus.Entry, id uuid.UUID, data *models.EmployeeSalaryUpdate) (*models.EmployeeSalary, error) {
-	return s.repo.UpdateEmployeeSalary(logger, id, data)
+	var band *models.SalaryBandCheck
+	var warning string
+	var check BandCheck
+	if s.bandEnforcement != BandEnforcementOff {
+		current, err := s.repo.GetEmployeeSalary(logger, id)
+		if err == sql.ErrNoRows {
+			return nil, ErrSalaryNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		if band, err = s.salaryBand(logger, current.EmployeeID, current.SalaryComponentID); err != nil {
+			return nil, err
+		}
+	}
+	if band != nil {
+		checked := band
+		band = nil
+		check = func(basePay float64) (string, error) {
+			placeInBand(checked, basePay+data.Amount)
+			band = checked
+			status, bandWarning, err := s.enforceSalaryBand(logger, checked, false)
+			warning = bandWarning
+			return status, err
+		}
+	}
+
+	salary, err := s.repo.UpdateEmployeeSalary(logger, id, data, check)
+	if err != nil {
+		return nil, err
+	}
+	salary.BandCheck = band
+	salary.Warning = warning
+	return salary, nil
 }
 
 func (s *Service) DeleteEmployeeSalary(logger *logrus.Entry, id uuid.UUID) error {
@@ -143,6 +210,10 @@ func (s *Service) CalculatePayroll(logger *logrus.Entry, input *CalculatePayroll
 		var grossPay, deductions float64
 		var taxableEarnings float64
 		for _, salary := range salaries {
+			// Salaries waiting for band approval, or rejected, are not paid
+			if salary.BandStatus != models.SalaryBandApproved {
+				continue
+			}
 			comp, err := s.repo.GetSalaryComponentByID(logger, salary.SalaryComponentID)
 			if err != nil {
 				continue
@@ -273,3 +344,7 @@ func (s *Service) ProcessPayroll(logger *logrus.Entry, id uuid.UUID) (*models.Pa
 func (s *Service) GetPayslip(logger *logrus.Entry, id uuid.UUID) (*models.Payslip, error) {
 	return s.repo.GetPayslip(logger, id)
 }
+
+func (s *Service) ListPayslipsByEmployeeID(logger *logrus.Entry, employeeID uuid.UUID) ([]models.Payslip, error) {
+	return s.repo.ListPayslipsByEmployeeID(logger, employeeID)
+}
@@ -2,7 +2,10 @@ package position
 
 import (
 	"employee-management/internal/models"
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -40,7 +43,7 @@ func (h *Handler) CreatePosition(c *gin.Context) {
 
 	position, err := h.service.CreatePosition(&positionData)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		positionError(c, err)
 		return
 	}
 
@@ -100,7 +103,7 @@ func (h *Handler) UpdatePosition(c *gin.Context) {
 
 	position, err := h.service.UpdatePosition(id, &positionData)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		positionError(c, err)
 		return
 	}
 
@@ -150,3 +153,397 @@ func (h *Handler) ListPositions(c *gin.Context) {
 
 	c.JSON(http.StatusOK, positions)
 }
+
+// ListBudgets handles listing a position's headcount budgets
+// @Summary List a position's headcount budgets
+// @Tags Positions
+// @Produce json
+// @Param id path string true "Position ID"
+// @Success 200 {array} models.HeadcountBudget
+// @Failure 404 {object} map[string]string
+// @Router /positions/{id}/budgets [get]
+func (h *Handler) ListBudgets(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid position ID"})
+		return
+	}
+
+	budgets, err := h.service.ListBudgets(id)
+	if err != nil {
+		positionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, budgets)
+}
+
+// CreateBudget handles setting a position's headcount budget for a period
+// @Summary Create a headcount budget
+// @Description Set the number of approved seats of a position for a period. Periods of a position may not overlap.
+// @Tags Positions
+// @Accept json
+// @Produce json
+// @Param id path string true "Position ID"
+// @Param budget body models.HeadcountBudgetCreate true "Budget data"
+// @Success 201 {object} models.HeadcountBudget
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /positions/{id}/budgets [post]
+func (h *Handler) CreateBudget(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid position ID"})
+		return
+	}
+
+	var data models.HeadcountBudgetCreate
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	budget, err := h.service.CreateBudget(id, &data, currentUserID(c))
+	if err != nil {
+		positionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, budget)
+}
+
+// UpdateBudget handles replacing a headcount budget
+// @Summary Update a headcount budget
+// @Tags Positions
+// @Accept json
+// @Produce json
+// @Param id path string true "Budget ID"
+// @Param budget body models.HeadcountBudgetCreate true "Budget data"
+// @Success 200 {object} models.HeadcountBudget
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /positions/budgets/{id} [put]
+func (h *Handler) UpdateBudget(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid budget ID"})
+		return
+	}
+
+	var data models.HeadcountBudgetCreate
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	budget, err := h.service.UpdateBudget(id, &data)
+	if err != nil {
+		positionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, budget)
+}
+
+// DeleteBudget handles deleting a headcount budget
+// @Summary Delete a headcount budget
+// @Tags Positions
+// @Param id path string true "Budget ID"
+// @Success 204 "No Content"
+// @Failure 404 {object} map[string]string
+// @Router /positions/budgets/{id} [delete]
+func (h *Handler) DeleteBudget(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid budget ID"})
+		return
+	}
+
+	if err := h.service.DeleteBudget(id); err != nil {
+		positionError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetHeadcount handles comparing position budgets with filled seats
+// @Summary Get position headcounts
+// @Description Lists every position's budget on a date with its filled, vacant and open seats and how far it is over budget
+// @Tags Positions
+// @Produce json
+// @Param date query string false "Date (YYYY-MM-DD), today if not given"
+// @Param department_id query string false "Only positions of this department"
+// @Success 200 {array} models.PositionHeadcount
+// @Failure 400 {object} map[string]string
+// @Router /positions/headcount [get]
+func (h *Handler) GetHeadcount(c *gin.Context) {
+	date, ok := headcountDate(c)
+	if !ok {
+		return
+	}
+	var departmentID *uuid.UUID
+	if value := c.Query("department_id"); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid department ID"})
+			return
+		}
+		departmentID = &id
+	}
+
+	headcounts, err := h.service.PositionHeadcounts(date, departmentID)
+	if err != nil {
+		positionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, headcounts)
+}
+
+// GetDepartmentHeadcount handles summing position headcounts by department
+// @Summary Get headcounts by department
+// @Description Sums the position headcounts of each department, most over budget first. over_budget=true lists only departments over budget.
+// @Tags Positions
+// @Produce json
+// @Param date query string false "Date (YYYY-MM-DD), today if not given"
+// @Param over_budget query bool false "Only departments over budget"
+// @Success 200 {array} models.DepartmentHeadcount
+// @Failure 400 {object} map[string]string
+// @Router /positions/headcount/departments [get]
+func (h *Handler) GetDepartmentHeadcount(c *gin.Context) {
+	date, ok := headcountDate(c)
+	if !ok {
+		return
+	}
+	overBudgetOnly, err := strconv.ParseBool(c.DefaultQuery("over_budget", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "over_budget must be true or false"})
+		return
+	}
+
+	departments, err := h.service.DepartmentHeadcounts(date, overBudgetOnly)
+	if err != nil {
+		positionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, departments)
+}
+
+// headcountDate reads the date query parameter, today if not given, and
+// responds with 400 if it is invalid
+func headcountDate(c *gin.Context) (time.Time, bool) {
+	value := c.Query("date")
+	if value == "" {
+		return time.Now(), true
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date must be a date (YYYY-MM-DD)"})
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+// ListRequisitions handles listing requisitions
+// @Summary List requisitions
+// @Tags Positions
+// @Produce json
+// @Param status query string false "pending, approved, rejected, filled or cancelled"
+// @Param position_id query string false "Position ID"
+// @Success 200 {array} models.PositionRequisition
+// @Failure 400 {object} map[string]string
+// @Router /positions/requisitions [get]
+func (h *Handler) ListRequisitions(c *gin.Context) {
+	filter := &RequisitionFilter{Status: c.Query("status")}
+	switch filter.Status {
+	case "", models.RequisitionPending, models.RequisitionApproved, models.RequisitionRejected, models.RequisitionFilled, models.RequisitionCancelled:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+	if value := c.Query("position_id"); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid position ID"})
+			return
+		}
+		filter.PositionID = &id
+	}
+
+	requisitions, err := h.service.ListRequisitions(filter)
+	if err != nil {
+		positionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, requisitions)
+}
+
+// GetRequisition handles retrieving a requisition by its ID
+// @Summary Get a requisition by ID
+// @Tags Positions
+// @Produce json
+// @Param id path string true "Requisition ID"
+// @Success 200 {object} models.PositionRequisition
+// @Failure 404 {object} map[string]string
+// @Router /positions/requisitions/{id} [get]
+func (h *Handler) GetRequisition(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid requisition ID"})
+		return
+	}
+
+	requisition, err := h.service.GetRequisition(id)
+	if err != nil {
+		positionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, requisition)
+}
+
+// CreateRequisition handles requesting vacancies on a position
+// @Summary Create a requisition
+// @Description Request vacancies on a position. The vacancies open once the requisition is approved.
+// @Tags Positions
+// @Accept json
+// @Produce json
+// @Param requisition body models.PositionRequisitionCreate true "Requisition data"
+// @Success 201 {object} models.PositionRequisition
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /positions/requisitions [post]
+func (h *Handler) CreateRequisition(c *gin.Context) {
+	var data models.PositionRequisitionCreate
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	requisition, err := h.service.CreateRequisition(&data, currentUserID(c))
+	if err != nil {
+		positionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, requisition)
+}
+
+// ApproveRequisition handles approving a requisition
+// @Summary Approve a requisition
+// @Description Opens the requisition's vacancies. Refused when they would exceed the position's headcount budget.
+// @Tags Positions
+// @Accept json
+// @Produce json
+// @Param id path string true "Requisition ID"
+// @Param review body models.RequisitionReview false "Review note"
+// @Success 200 {object} models.PositionRequisition
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /positions/requisitions/{id}/approve [post]
+func (h *Handler) ApproveRequisition(c *gin.Context) {
+	h.updateRequisition(c, models.RequisitionApproved)
+}
+
+// RejectRequisition handles rejecting a requisition
+// @Summary Reject a requisition
+// @Tags Positions
+// @Accept json
+// @Produce json
+// @Param id path string true "Requisition ID"
+// @Param review body models.RequisitionReview false "Review note"
+// @Success 200 {object} models.PositionRequisition
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /positions/requisitions/{id}/reject [post]
+func (h *Handler) RejectRequisition(c *gin.Context) {
+	h.updateRequisition(c, models.RequisitionRejected)
+}
+
+// FillRequisition handles marking an approved requisition as filled
+// @Summary Mark a requisition as filled
+// @Tags Positions
+// @Accept json
+// @Produce json
+// @Param id path string true "Requisition ID"
+// @Param review body models.RequisitionReview false "Note"
+// @Success 200 {object} models.PositionRequisition
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /positions/requisitions/{id}/fill [post]
+func (h *Handler) FillRequisition(c *gin.Context) {
+	h.updateRequisition(c, models.RequisitionFilled)
+}
+
+// CancelRequisition handles cancelling a pending or approved requisition
+// @Summary Cancel a requisition
+// @Tags Positions
+// @Accept json
+// @Produce json
+// @Param id path string true "Requisition ID"
+// @Param review body models.RequisitionReview false "Note"
+// @Success 200 {object} models.PositionRequisition
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /positions/requisitions/{id}/cancel [post]
+func (h *Handler) CancelRequisition(c *gin.Context) {
+	h.updateRequisition(c, models.RequisitionCancelled)
+}
+
+func (h *Handler) updateRequisition(c *gin.Context, status string) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid requisition ID"})
+		return
+	}
+	var review models.RequisitionReview
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&review); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var requisition *models.PositionRequisition
+	switch status {
+	case models.RequisitionApproved, models.RequisitionRejected:
+		requisition, err = h.service.ReviewRequisition(id, status == models.RequisitionApproved, currentUserID(c), review.Note)
+	default:
+		requisition, err = h.service.CloseRequisition(id, status, review.Note)
+	}
+	if err != nil {
+		positionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, requisition)
+}
+
+// positionError maps position, salary band, budget and requisition errors to HTTP responses
+func positionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrPositionNotFound), errors.Is(err, ErrBudgetNotFound), errors.Is(err, ErrRequisitionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrBudgetPeriod), errors.Is(err, ErrInvalidSalaryRange):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrBudgetOverlap), errors.Is(err, ErrRequisitionClosed), errors.Is(err, ErrOwnRequisition),
+		errors.Is(err, ErrNoBudget), errors.Is(err, ErrOverBudget):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// currentUserID returns the authenticated user's ID, or nil if there is none
+func currentUserID(c *gin.Context) *uuid.UUID {
+	id, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		return nil
+	}
+	return &id
+}
@@ -0,0 +1,537 @@
+package position
+
+import (
+	"database/sql"
+	"employee-management/internal/models"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrBudgetNotFound is returned when a headcount budget does not exist
+	ErrBudgetNotFound = errors.New("headcount budget not found")
+	// ErrBudgetPeriod is returned when a budget ends before it starts
+	ErrBudgetPeriod = errors.New("period_end must not be before period_start")
+	// ErrBudgetOverlap is returned when a budget overlaps another budget of the same position
+	ErrBudgetOverlap = errors.New("the position already has a headcount budget for part of this period")
+	// ErrRequisitionNotFound is returned when a requisition does not exist
+	ErrRequisitionNotFound = errors.New("requisition not found")
+	// ErrRequisitionClosed is returned when a requisition is not in a state that allows the change
+	ErrRequisitionClosed = errors.New("requisition can no longer be changed this way")
+	// ErrOwnRequisition is returned when users review their own requisition
+	ErrOwnRequisition = errors.New("requisitions cannot be approved or rejected by the user who requested them")
+	// ErrNoBudget is returned when approving a requisition for a position without a budget
+	ErrNoBudget = errors.New("the position has no headcount budget for the start date")
+	// ErrOverBudget is returned when approving a requisition would exceed the position's budget
+	ErrOverBudget = errors.New("approving the requisition would exceed the position's headcount budget")
+)
+
+// truncateDate drops the time of day
+func truncateDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// withTx runs fn in a transaction, committing if it returns nil
+func (s *Service) withTx(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// lockPosition locks a position for the rest of the transaction
+func lockPosition(tx *sql.Tx, id uuid.UUID) error {
+	var locked uuid.UUID
+	err := tx.QueryRow("SELECT id FROM positions WHERE id = $1 FOR UPDATE", id).Scan(&locked)
+	if err == sql.ErrNoRows {
+		return ErrPositionNotFound
+	}
+	return err
+}
+
+// budgetColumns are the columns read for a headcount budget
+const budgetColumns = `id, position_id, period_start, period_end, headcount, notes, created_by, created_at, updated_at`
+
+// scanBudget scans a row of budgetColumns
+func scanBudget(row interface{ Scan(...interface{}) error }, budget *models.HeadcountBudget) error {
+	return row.Scan(&budget.ID, &budget.PositionID, &budget.PeriodStart, &budget.PeriodEnd, &budget.Headcount,
+		&budget.Notes, &budget.CreatedBy, &budget.CreatedAt, &budget.UpdatedAt)
+}
+
+// checkBudgetOverlap returns ErrBudgetOverlap if another budget of the
+// position, other than except, covers part of the period
+func checkBudgetOverlap(tx *sql.Tx, positionID uuid.UUID, data *models.HeadcountBudgetCreate, except uuid.UUID) error {
+	var overlaps bool
+	err := tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM position_headcount_budgets
+			WHERE position_id = $1 AND id <> $2 AND period_start <= $4 AND period_end >= $3
+		)`, positionID, except, truncateDate(data.PeriodStart), truncateDate(data.PeriodEnd),
+	).Scan(&overlaps)
+	if err != nil {
+		return err
+	}
+	if overlaps {
+		return ErrBudgetOverlap
+	}
+	return nil
+}
+
+// CreateBudget sets the headcount budget of a position for a period
+func (s *Service) CreateBudget(positionID uuid.UUID, data *models.HeadcountBudgetCreate, createdBy *uuid.UUID) (*models.HeadcountBudget, error) {
+	if truncateDate(data.PeriodEnd).Before(truncateDate(data.PeriodStart)) {
+		return nil, ErrBudgetPeriod
+	}
+
+	var budget models.HeadcountBudget
+	err := s.withTx(func(tx *sql.Tx) error {
+		if err := lockPosition(tx, positionID); err != nil {
+			return err
+		}
+		if err := checkBudgetOverlap(tx, positionID, data, uuid.Nil); err != nil {
+			return err
+		}
+		return scanBudget(tx.QueryRow(`
+			INSERT INTO position_headcount_budgets (position_id, period_start, period_end, headcount, notes, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING `+budgetColumns,
+			positionID, truncateDate(data.PeriodStart), truncateDate(data.PeriodEnd), data.Headcount, data.Notes, createdBy,
+		), &budget)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &budget, nil
+}
+
+// UpdateBudget replaces the period, headcount and notes of a budget
+func (s *Service) UpdateBudget(id uuid.UUID, data *models.HeadcountBudgetCreate) (*models.HeadcountBudget, error) {
+	if truncateDate(data.PeriodEnd).Before(truncateDate(data.PeriodStart)) {
+		return nil, ErrBudgetPeriod
+	}
+
+	var budget models.HeadcountBudget
+	err := s.withTx(func(tx *sql.Tx) error {
+		var positionID uuid.UUID
+		err := tx.QueryRow("SELECT position_id FROM position_headcount_budgets WHERE id = $1", id).Scan(&positionID)
+		if err == sql.ErrNoRows {
+			return ErrBudgetNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if err := lockPosition(tx, positionID); err != nil {
+			return err
+		}
+		if err := checkBudgetOverlap(tx, positionID, data, id); err != nil {
+			return err
+		}
+		err = scanBudget(tx.QueryRow(`
+			UPDATE position_headcount_budgets
+			SET period_start = $1, period_end = $2, headcount = $3, notes = $4, updated_at = NOW()
+			WHERE id = $5
+			RETURNING `+budgetColumns,
+			truncateDate(data.PeriodStart), truncateDate(data.PeriodEnd), data.Headcount, data.Notes, id,
+		), &budget)
+		if err == sql.ErrNoRows {
+			return ErrBudgetNotFound
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &budget, nil
+}
+
+// DeleteBudget deletes a headcount budget
+func (s *Service) DeleteBudget(id uuid.UUID) error {
+	result, err := s.db.Exec("DELETE FROM position_headcount_budgets WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrBudgetNotFound
+	}
+	return nil
+}
+
+// ListBudgets retrieves the headcount budgets of a position, oldest period
+// first
+func (s *Service) ListBudgets(positionID uuid.UUID) ([]models.HeadcountBudget, error) {
+	if _, err := s.GetPositionByID(positionID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`SELECT `+budgetColumns+` FROM position_headcount_budgets WHERE position_id = $1 ORDER BY period_start`, positionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	budgets := []models.HeadcountBudget{}
+	for rows.Next() {
+		var budget models.HeadcountBudget
+		if err := scanBudget(rows, &budget); err != nil {
+			return nil, err
+		}
+		budgets = append(budgets, budget)
+	}
+	return budgets, rows.Err()
+}
+
+// requisitionColumns are the columns read for a requisition
+const requisitionColumns = `id, position_id, headcount, filled_headcount, reason, target_start_date, status, requested_by,
+	reviewed_by, reviewed_at, COALESCE(review_note, ''), closed_at, created_at, updated_at`
+
+// scanRequisition scans a row of requisitionColumns
+func scanRequisition(row interface{ Scan(...interface{}) error }, requisition *models.PositionRequisition) error {
+	return row.Scan(&requisition.ID, &requisition.PositionID, &requisition.Headcount, &requisition.FilledHeadcount, &requisition.Reason, &requisition.TargetStartDate,
+		&requisition.Status, &requisition.RequestedBy, &requisition.ReviewedBy, &requisition.ReviewedAt, &requisition.ReviewNote,
+		&requisition.ClosedAt, &requisition.CreatedAt, &requisition.UpdatedAt)
+}
+
+// CreateRequisition requests vacancies on a position. It waits for approval
+// before the vacancies count as open.
+func (s *Service) CreateRequisition(data *models.PositionRequisitionCreate, requestedBy *uuid.UUID) (*models.PositionRequisition, error) {
+	if _, err := s.GetPositionByID(data.PositionID); err != nil {
+		return nil, err
+	}
+	headcount := data.Headcount
+	if headcount == 0 {
+		headcount = 1
+	}
+	var targetStartDate *time.Time
+	if data.TargetStartDate != nil {
+		date := truncateDate(*data.TargetStartDate)
+		targetStartDate = &date
+	}
+
+	var requisition models.PositionRequisition
+	err := scanRequisition(s.db.QueryRow(`
+		INSERT INTO position_requisitions (position_id, headcount, reason, target_start_date, requested_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+requisitionColumns,
+		data.PositionID, headcount, data.Reason, targetStartDate, requestedBy,
+	), &requisition)
+	if err != nil {
+		return nil, err
+	}
+	return &requisition, nil
+}
+
+// RequisitionFilter narrows down a requisition listing
+type RequisitionFilter struct {
+	Status     string
+	PositionID *uuid.UUID
+}
+
+// ListRequisitions retrieves requisitions, newest first
+func (s *Service) ListRequisitions(filter *RequisitionFilter) ([]models.PositionRequisition, error) {
+	rows, err := s.db.Query(`
+		SELECT `+requisitionColumns+` FROM position_requisitions
+		WHERE ($1 = '' OR status = $1) AND ($2::uuid IS NULL OR position_id = $2)
+		ORDER BY created_at DESC`, filter.Status, filter.PositionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requisitions := []models.PositionRequisition{}
+	for rows.Next() {
+		var requisition models.PositionRequisition
+		if err := scanRequisition(rows, &requisition); err != nil {
+			return nil, err
+		}
+		requisitions = append(requisitions, requisition)
+	}
+	return requisitions, rows.Err()
+}
+
+// GetRequisition retrieves a requisition by its ID
+func (s *Service) GetRequisition(id uuid.UUID) (*models.PositionRequisition, error) {
+	var requisition models.PositionRequisition
+	err := scanRequisition(s.db.QueryRow(`SELECT `+requisitionColumns+` FROM position_requisitions WHERE id = $1`, id), &requisition)
+	if err == sql.ErrNoRows {
+		return nil, ErrRequisitionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &requisition, nil
+}
+
+// lockRequisition locks a requisition and its position for the rest of the
+// transaction and returns it
+func lockRequisition(tx *sql.Tx, id uuid.UUID) (*models.PositionRequisition, error) {
+	var positionID uuid.UUID
+	err := tx.QueryRow("SELECT position_id FROM position_requisitions WHERE id = $1", id).Scan(&positionID)
+	if err == sql.ErrNoRows {
+		return nil, ErrRequisitionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	// The position is locked first, like when budgets change
+	if err := lockPosition(tx, positionID); err != nil {
+		return nil, err
+	}
+	var requisition models.PositionRequisition
+	if err := scanRequisition(tx.QueryRow(`SELECT `+requisitionColumns+` FROM position_requisitions WHERE id = $1 FOR UPDATE`, id), &requisition); err != nil {
+		return nil, err
+	}
+	return &requisition, nil
+}
+
+// ReviewRequisition approves or rejects a pending requisition. Approval is
+// refused when the filled seats and open vacancies of the position, with
+// this requisition, would exceed its budget on the target start date, or
+// today if that is later.
+func (s *Service) ReviewRequisition(id uuid.UUID, approve bool, reviewedBy *uuid.UUID, note string) (*models.PositionRequisition, error) {
+	var requisition models.PositionRequisition
+	err := s.withTx(func(tx *sql.Tx) error {
+		current, err := lockRequisition(tx, id)
+		if err != nil {
+			return err
+		}
+		if current.Status != models.RequisitionPending {
+			return ErrRequisitionClosed
+		}
+		if reviewedBy != nil && current.RequestedBy != nil && *reviewedBy == *current.RequestedBy {
+			return ErrOwnRequisition
+		}
+
+		status := models.RequisitionRejected
+		if approve {
+			status = models.RequisitionApproved
+			if err := checkRequisitionBudget(tx, current); err != nil {
+				return err
+			}
+		}
+		return scanRequisition(tx.QueryRow(`
+			UPDATE position_requisitions
+			SET status = $1, reviewed_by = $2, reviewed_at = NOW(), review_note = NULLIF($3, ''), updated_at = NOW()
+			WHERE id = $4
+			RETURNING `+requisitionColumns, status, reviewedBy, note, id,
+		), &requisition)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &requisition, nil
+}
+
+// checkRequisitionBudget returns an error unless the position's budget has
+// room for the requisition's seats
+func checkRequisitionBudget(tx *sql.Tx, requisition *models.PositionRequisition) error {
+	date := truncateDate(time.Now())
+	if requisition.TargetStartDate != nil && requisition.TargetStartDate.After(date) {
+		date = truncateDate(*requisition.TargetStartDate)
+	}
+
+	var budget int
+	err := tx.QueryRow(`
+		SELECT headcount FROM position_headcount_budgets
+		WHERE position_id = $1 AND $2 BETWEEN period_start AND period_end`, requisition.PositionID, date,
+	).Scan(&budget)
+	if err == sql.ErrNoRows {
+		return ErrNoBudget
+	}
+	if err != nil {
+		return err
+	}
+
+	var filled, open int
+	err = tx.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM employees
+			 WHERE position_id = $1 AND deleted_at IS NULL AND employment_status <> 'terminated'),
+			(SELECT COALESCE(SUM(headcount - filled_headcount), 0) FROM position_requisitions
+			 WHERE position_id = $1 AND status = $2)`, requisition.PositionID, models.RequisitionApproved,
+	).Scan(&filled, &open)
+	if err != nil {
+		return err
+	}
+	if filled+open+requisition.Headcount > budget {
+		return fmt.Errorf("%w: %d budgeted, %d filled, %d open", ErrOverBudget, budget, filled, open)
+	}
+	return nil
+}
+
+// FillRequisitionWith counts an employee joining a position against the
+// oldest approved requisition of the position, inside the caller's
+// transaction. The requisition is marked filled once all its seats are.
+// Nothing happens if the position has no approved requisition.
+func FillRequisitionWith(tx *sql.Tx, positionID uuid.UUID) error {
+	_, err := tx.Exec(`
+		UPDATE position_requisitions
+		SET filled_headcount = filled_headcount + 1,
+		    status = CASE WHEN filled_headcount + 1 = headcount THEN $2 ELSE status END,
+		    closed_at = CASE WHEN filled_headcount + 1 = headcount THEN NOW() ELSE closed_at END,
+		    updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM position_requisitions
+			WHERE position_id = $1 AND status = $3
+			ORDER BY reviewed_at, created_at
+			LIMIT 1
+			FOR UPDATE
+		)`, positionID, models.RequisitionFilled, models.RequisitionApproved)
+	return err
+}
+
+// CloseRequisition marks an approved requisition as filled, or cancels a
+// pending or approved one
+func (s *Service) CloseRequisition(id uuid.UUID, status string, note string) (*models.PositionRequisition, error) {
+	var requisition models.PositionRequisition
+	err := s.withTx(func(tx *sql.Tx) error {
+		current, err := lockRequisition(tx, id)
+		if err != nil {
+			return err
+		}
+		switch {
+		case status == models.RequisitionFilled && current.Status == models.RequisitionApproved:
+		case status == models.RequisitionCancelled && (current.Status == models.RequisitionPending || current.Status == models.RequisitionApproved):
+		default:
+			return ErrRequisitionClosed
+		}
+		return scanRequisition(tx.QueryRow(`
+			UPDATE position_requisitions
+			SET status = $1, closed_at = NOW(), review_note = COALESCE(NULLIF($2, ''), review_note), updated_at = NOW()
+			WHERE id = $3
+			RETURNING `+requisitionColumns, status, note, id,
+		), &requisition)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &requisition, nil
+}
+
+// PositionHeadcounts compares every position's budget on a date with the
+// employees in it on that date, optionally for one department only. Open
+// vacancies are the unfilled seats of requisitions approved now.
+func (s *Service) PositionHeadcounts(date time.Time, departmentID *uuid.UUID) ([]models.PositionHeadcount, error) {
+	date = truncateDate(date)
+	rows, err := s.db.Query(`
+		SELECT p.id, p.title, p.department_id, b.headcount,
+		       COALESCE(f.filled, 0), COALESCE(r.open, 0)
+		FROM positions p
+		LEFT JOIN position_headcount_budgets b
+		       ON b.position_id = p.id AND $1 BETWEEN b.period_start AND b.period_end
+		LEFT JOIN (
+			SELECT j.position_id, COUNT(*) AS filled
+			FROM employees e
+			JOIN LATERAL (
+				SELECT h.position_id, h.employment_status FROM employee_job_history h
+				WHERE h.employee_id = e.id AND h.effective_date <= $1
+				ORDER BY h.effective_date DESC, h.created_at DESC
+				LIMIT 1
+			) j ON true
+			WHERE e.deleted_at IS NULL AND j.employment_status <> 'terminated' AND j.position_id IS NOT NULL
+			GROUP BY j.position_id
+		) f ON f.position_id = p.id
+		LEFT JOIN (
+			SELECT position_id, SUM(headcount - filled_headcount) AS open FROM position_requisitions
+			WHERE status = $3 GROUP BY position_id
+		) r ON r.position_id = p.id
+		WHERE $2::uuid IS NULL OR p.department_id = $2
+		ORDER BY p.title, p.id`, date, departmentID, models.RequisitionApproved)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	headcounts := []models.PositionHeadcount{}
+	for rows.Next() {
+		var headcount models.PositionHeadcount
+		if err := rows.Scan(&headcount.PositionID, &headcount.Title, &headcount.DepartmentID, &headcount.Budgeted,
+			&headcount.Filled, &headcount.Open); err != nil {
+			return nil, err
+		}
+		budgeted := 0
+		if headcount.Budgeted != nil {
+			budgeted = *headcount.Budgeted
+		}
+		if headcount.Filled > budgeted {
+			headcount.OverBudget = headcount.Filled - budgeted
+		} else {
+			headcount.Vacant = budgeted - headcount.Filled
+		}
+		headcounts = append(headcounts, headcount)
+	}
+	return headcounts, rows.Err()
+}
+
+// DepartmentHeadcounts sums the position headcounts on a date by department,
+// most over budget first. With overBudgetOnly, only departments with more
+// employees than budgeted seats in some position are listed.
+func (s *Service) DepartmentHeadcounts(date time.Time, overBudgetOnly bool) ([]models.DepartmentHeadcount, error) {
+	positions, err := s.PositionHeadcounts(date, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query("SELECT id, name FROM departments")
+	if err != nil {
+		return nil, err
+	}
+	names := map[uuid.UUID]string{}
+	for rows.Next() {
+		var id uuid.UUID
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		names[id] = name
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	byDepartment := map[uuid.UUID]*models.DepartmentHeadcount{}
+	for _, position := range positions {
+		department, ok := byDepartment[position.DepartmentID]
+		if !ok {
+			department = &models.DepartmentHeadcount{DepartmentID: position.DepartmentID, Name: names[position.DepartmentID]}
+			byDepartment[position.DepartmentID] = department
+		}
+		if position.Budgeted != nil {
+			department.Budgeted += *position.Budgeted
+		} else {
+			department.Unbudgeted += position.Filled
+		}
+		department.Filled += position.Filled
+		department.Open += position.Open
+		department.Vacant += position.Vacant
+		department.OverBudget += position.OverBudget
+	}
+
+	departments := []models.DepartmentHeadcount{}
+	for _, department := range byDepartment {
+		if overBudgetOnly && department.OverBudget == 0 {
+			continue
+		}
+		departments = append(departments, *department)
+	}
+	sort.Slice(departments, func(i, j int) bool {
+		if departments[i].OverBudget != departments[j].OverBudget {
+			return departments[i].OverBudget > departments[j].OverBudget
+		}
+		return departments[i].Name < departments[j].Name
+	})
+	return departments, nil
+}
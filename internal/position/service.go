@@ -8,6 +8,13 @@ import (
 	"github.com/google/uuid"
 )
 
+var (
+	// ErrPositionNotFound is returned when a position does not exist
+	ErrPositionNotFound = errors.New("position not found")
+	// ErrInvalidSalaryRange is returned when a salary range minimum is above its maximum
+	ErrInvalidSalaryRange = errors.New("salary_range_min must not be greater than salary_range_max")
+)
+
 // Service handles position-related operations
 type Service struct {
 	db *database.DB
@@ -22,6 +29,10 @@ func NewService(db *database.DB) *Service {
 
 // CreatePosition creates a new position
 func (s *Service) CreatePosition(positionData *models.PositionCreate) (*models.Position, error) {
+	if positionData.SalaryRangeMin > positionData.SalaryRangeMax {
+		return nil, ErrInvalidSalaryRange
+	}
+
 	var position models.Position
 	query := `
 		INSERT INTO positions (title, department_id, description, requirements, salary_range_min, salary_range_max)
@@ -53,7 +64,7 @@ func (s *Service) GetPositionByID(id uuid.UUID) (*models.Position, error) {
 	)
 
 	if err != nil {
-		return nil, errors.New("position not found")
+		return nil, ErrPositionNotFound
 	}
 
 	return &position, nil
@@ -61,6 +72,10 @@ func (s *Service) GetPositionByID(id uuid.UUID) (*models.Position, error) {
 
 // UpdatePosition updates an existing position's information
 func (s *Service) UpdatePosition(id uuid.UUID, positionData *models.PositionUpdate) (*models.Position, error) {
+	if positionData.SalaryRangeMin > positionData.SalaryRangeMax {
+		return nil, ErrInvalidSalaryRange
+	}
+
 	var position models.Position
 	query := `
 		UPDATE positions
@@ -94,7 +109,7 @@ func (s *Service) DeletePosition(id uuid.UUID) error {
 	}
 
 	if rowsAffected == 0 {
-		return errors.New("position not found")
+		return ErrPositionNotFound
 	}
 
 	return nil
@@ -0,0 +1,234 @@
+// Package selfservice serves the /me endpoints, which let employees see and
+// maintain their own records without knowing their employee ID.
+package selfservice
+
+import (
+	"employee-management/internal/attendance"
+	"employee-management/internal/document"
+	"employee-management/internal/employee"
+	"employee-management/internal/fieldcrypt"
+	"employee-management/internal/leave"
+	"employee-management/internal/models"
+	"employee-management/internal/payroll"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Handler handles requests about the signed-in user's own employee record
+type Handler struct {
+	employees  *employee.Service
+	attendance *attendance.Service
+	leave      *leave.Service
+	payroll    *payroll.Service
+	documents  *document.Service
+}
+
+// NewHandler creates a new self-service handler
+func NewHandler(employees *employee.Service, attendance *attendance.Service, leave *leave.Service, payroll *payroll.Service, documents *document.Service) *Handler {
+	return &Handler{
+		employees:  employees,
+		attendance: attendance,
+		leave:      leave,
+		payroll:    payroll,
+		documents:  documents,
+	}
+}
+
+// currentEmployee resolves the employee linked to the signed-in user. It
+// writes the error response and returns nil if there is none.
+func (h *Handler) currentEmployee(c *gin.Context) *models.Employee {
+	logger := c.MustGet("logger").(*logrus.Entry)
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil
+	}
+
+	me, err := h.employees.GetEmployeeByUserID(logger, userID)
+	if errors.Is(err, employee.ErrNoLinkedEmployee) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return nil
+	}
+	if err != nil {
+		logger.WithError(err).Error("Failed to get employee of current user")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get employee record"})
+		return nil
+	}
+	if err := h.employees.HideCustomFields(logger, c.GetString("role"), me); err != nil {
+		logger.WithError(err).Error("Failed to load custom fields")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get employee record"})
+		return nil
+	}
+	return me
+}
+
+// GetProfile handles getting the signed-in user's employee record
+// @Summary Get my employee record
+// @Tags Self-service
+// @Produce json
+// @Success 200 {object} models.Employee
+// @Failure 404 {object} map[string]string
+// @Router /me [get]
+func (h *Handler) GetProfile(c *gin.Context) {
+	if me := h.currentEmployee(c); me != nil {
+		c.JSON(http.StatusOK, me)
+	}
+}
+
+// UpdateProfile handles employees updating their own contact details
+// @Summary Update my contact details
+// @Description Phone number and emergency contact change immediately. An address change is sent to HR for approval and the response is 202 Accepted.
+// @Tags Self-service
+// @Accept json
+// @Produce json
+// @Param profile body models.ProfileUpdate true "Changed details"
+// @Success 200 {object} employee.ProfileUpdateResult
+// @Success 202 {object} employee.ProfileUpdateResult
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /me [put]
+func (h *Handler) UpdateProfile(c *gin.Context) {
+	logger := c.MustGet("logger").(*logrus.Entry)
+	var data models.ProfileUpdate
+	if err := c.ShouldBindJSON(&data); err != nil {
+		logger.WithError(err).Warn("Failed to bind JSON for profile update")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	result, err := h.employees.UpdateOwnProfile(logger, userID, &data)
+	if err == nil {
+		err = h.employees.HideCustomFields(logger, c.GetString("role"), result.Employee)
+	}
+	switch {
+	case errors.Is(err, employee.ErrNoLinkedEmployee):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, fieldcrypt.ErrReservedPrefix):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		logger.WithError(err).Error("Failed to update own profile")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+	case result.ChangeRequest != nil:
+		c.JSON(http.StatusAccepted, result)
+	default:
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// ListChangeRequests handles listing the signed-in user's change requests
+// @Summary List my change requests
+// @Tags Self-service
+// @Produce json
+// @Success 200 {array} models.EmployeeChangeRequest
+// @Failure 404 {object} map[string]string
+// @Router /me/change-requests [get]
+func (h *Handler) ListChangeRequests(c *gin.Context) {
+	logger := c.MustGet("logger").(*logrus.Entry)
+	me := h.currentEmployee(c)
+	if me == nil {
+		return
+	}
+
+	requests, err := h.employees.ListChangeRequests(logger, &employee.ChangeRequestFilter{EmployeeID: &me.ID})
+	if err != nil {
+		logger.WithError(err).Error("Failed to list own change requests")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list change requests"})
+		return
+	}
+	c.JSON(http.StatusOK, requests)
+}
+
+// ListAttendance handles listing the signed-in user's attendance
+// @Summary List my attendance
+// @Tags Self-service
+// @Produce json
+// @Success 200 {array} models.Attendance
+// @Failure 404 {object} map[string]string
+// @Router /me/attendance [get]
+func (h *Handler) ListAttendance(c *gin.Context) {
+	me := h.currentEmployee(c)
+	if me == nil {
+		return
+	}
+
+	records, err := h.attendance.ListAttendanceByEmployeeID(me.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list attendance"})
+		return
+	}
+	c.JSON(http.StatusOK, records)
+}
+
+// ListLeaveRequests handles listing the signed-in user's leave requests
+// @Summary List my leave requests
+// @Tags Self-service
+// @Produce json
+// @Success 200 {array} models.LeaveRequest
+// @Failure 404 {object} map[string]string
+// @Router /me/leave-requests [get]
+func (h *Handler) ListLeaveRequests(c *gin.Context) {
+	me := h.currentEmployee(c)
+	if me == nil {
+		return
+	}
+
+	requests, err := h.leave.ListLeaveRequestsByEmployeeID(me.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list leave requests"})
+		return
+	}
+	c.JSON(http.StatusOK, requests)
+}
+
+// ListPayslips handles listing the signed-in user's payslips
+// @Summary List my payslips
+// @Tags Self-service
+// @Produce json
+// @Success 200 {array} models.Payslip
+// @Failure 404 {object} map[string]string
+// @Router /me/payslips [get]
+func (h *Handler) ListPayslips(c *gin.Context) {
+	logger := c.MustGet("logger").(*logrus.Entry)
+	me := h.currentEmployee(c)
+	if me == nil {
+		return
+	}
+
+	payslips, err := h.payroll.ListPayslipsByEmployeeID(logger, me.ID)
+	if err != nil {
+		logger.WithError(err).Error("Failed to list own payslips")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list payslips"})
+		return
+	}
+	c.JSON(http.StatusOK, payslips)
+}
+
+// ListDocuments handles listing the signed-in user's documents
+// @Summary List my documents
+// @Tags Self-service
+// @Produce json
+// @Success 200 {array} models.Document
+// @Failure 404 {object} map[string]string
+// @Router /me/documents [get]
+func (h *Handler) ListDocuments(c *gin.Context) {
+	me := h.currentEmployee(c)
+	if me == nil {
+		return
+	}
+
+	docs, err := h.documents.ListDocumentsByEmployeeID(me.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list documents"})
+		return
+	}
+	c.JSON(http.StatusOK, docs)
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"employee-management/internal/auth"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// loadKeySet builds the token signing keys from the environment:
+//
+//   - JWT_KEYS_FILE names a key manifest (see auth.LoadKeySet). It is reloaded
+//     every JWT_KEYS_RELOAD_INTERVAL (default 5m) so rotated keys are picked up,
+//     or only at startup if it is 0.
+//   - Otherwise JWT_SECRET configures a single HS256 key.
+//   - Otherwise, outside release mode only, an ephemeral key is generated.
+func loadKeySet(logger *logrus.Logger) (*auth.KeySet, error) {
+	if path := os.Getenv("JWT_KEYS_FILE"); path != "" {
+		keys, err := auth.LoadKeySet(path)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := keys.SigningKey(time.Now()); err != nil {
+			return nil, err
+		}
+
+		value := getEnv("JWT_KEYS_RELOAD_INTERVAL", "5m")
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval < 0 {
+			return nil, fmt.Errorf("invalid JWT_KEYS_RELOAD_INTERVAL %q", value)
+		}
+		if interval > 0 {
+			go reloadKeySet(keys, interval, logger)
+		}
+
+		return keys, nil
+	}
+
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		logger.Warn("Signing tokens with JWT_SECRET (HS256); other services cannot verify them through JWKS")
+		return auth.NewHMACKeySet(getEnv("JWT_SECRET_KID", "default"), []byte(secret))
+	}
+
+	if gin.Mode() == gin.ReleaseMode {
+		return nil, errors.New("no signing keys configured: set JWT_KEYS_FILE or JWT_SECRET")
+	}
+
+	logger.Warn("No signing keys configured, using an ephemeral key; tokens will not survive a restart")
+	return auth.NewEphemeralKeySet()
+}
+
+// reloadKeySet periodically re-reads the key manifest. A broken manifest is
+// logged and the previously loaded keys stay in use.
+func reloadKeySet(keys *auth.KeySet, interval time.Duration, logger *logrus.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := keys.Reload(); err != nil {
+			logger.WithError(err).Error("Failed to reload token signing keys")
+			continue
+		}
+		if _, err := keys.SigningKey(time.Now()); err != nil {
+			logger.WithError(err).Error("Token signing keys have no active key")
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"employee-management/internal/auth"
+	"employee-management/internal/oidc"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// loadOIDCConfig configures single sign-on from the environment. It returns
+// nil when OIDC_ISSUER is unset.
+//
+// OIDC_ROLE_MAPPING maps IdP groups to roles as "group=role,group=role".
+// OIDC_DEFAULT_ROLE (default employee) is given to users in no mapped group;
+// set it to "none" to refuse them.
+func loadOIDCConfig() (*auth.OIDCConfig, error) {
+	issuer := os.Getenv("OIDC_ISSUER")
+	if issuer == "" {
+		return nil, nil
+	}
+
+	clientID := os.Getenv("OIDC_CLIENT_ID")
+	redirectURL := os.Getenv("OIDC_REDIRECT_URL")
+	if clientID == "" || redirectURL == "" {
+		return nil, fmt.Errorf("OIDC_CLIENT_ID and OIDC_REDIRECT_URL are required when OIDC_ISSUER is set")
+	}
+
+	roleMapping := map[string]string{}
+	for _, pair := range strings.Split(os.Getenv("OIDC_ROLE_MAPPING"), ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		group, role, found := strings.Cut(pair, "=")
+		role = strings.TrimSpace(role)
+		if !found || !auth.IsKnownRole(role) {
+			return nil, fmt.Errorf("invalid OIDC_ROLE_MAPPING entry %q", pair)
+		}
+		roleMapping[strings.TrimSpace(group)] = role
+	}
+
+	defaultRole := getEnv("OIDC_DEFAULT_ROLE", "employee")
+	if defaultRole == "none" {
+		defaultRole = ""
+	} else if !auth.IsKnownRole(defaultRole) {
+		return nil, fmt.Errorf("invalid OIDC_DEFAULT_ROLE %q", defaultRole)
+	}
+
+	syncRoles, err := strconv.ParseBool(getEnv("OIDC_SYNC_ROLES", "true"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid OIDC_SYNC_ROLES: %w", err)
+	}
+
+	return &auth.OIDCConfig{
+		Provider: oidc.NewProvider(oidc.Config{
+			Issuer:       issuer,
+			ClientID:     clientID,
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:  redirectURL,
+			Scopes:       strings.Fields(getEnv("OIDC_SCOPES", "openid email profile")),
+		}),
+		GroupsClaim: getEnv("OIDC_GROUPS_CLAIM", "groups"),
+		RoleMapping: roleMapping,
+		DefaultRole: defaultRole,
+		SyncRoles:   syncRoles,
+	}, nil
+}
@@ -0,0 +1,267 @@
+package server
+
+import (
+	"employee-management/internal/auth"
+	"employee-management/internal/middleware"
+
+	"github.com/google/uuid"
+)
+
+// publicRoutes are reachable under /api/v1 without a token
+var publicRoutes = []string{
+	"POST /api/v1/auth/login",
+	"POST /api/v1/auth/register",
+	"POST /api/v1/auth/forgot-password",
+	"POST /api/v1/auth/reset-password",
+	"POST /api/v1/auth/refresh",
+	"POST /api/v1/auth/2fa/verify",
+	"POST /api/v1/auth/2fa/enroll",
+	"POST /api/v1/auth/2fa/enroll/confirm",
+	"POST /api/v1/auth/invitations/accept",
+	"POST /api/v1/auth/password/expired",
+	"GET /api/v1/auth/oidc/login",
+	"GET /api/v1/auth/oidc/callback",
+}
+
+// permissions returns the role table guarding every authenticated /api/v1 route
+func (s *Server) permissions() middleware.Permissions {
+	const (
+		admin    = middleware.RoleAdmin
+		hr       = middleware.RoleHR
+		manager  = middleware.RoleManager
+		employee = middleware.RoleEmployee
+	)
+	var (
+		staff    = []string{admin, hr}
+		managers = []string{admin, hr, manager}
+		everyone = []string{admin, hr, manager, employee}
+	)
+
+	ownsEmployee := middleware.OwnedBy(middleware.Param("id"), s.ownerOf(`SELECT user_id FROM employees WHERE id = $1 AND deleted_at IS NULL`))
+	ownsSalaries := middleware.OwnedBy(middleware.Param("employeeId"), s.ownerOf(`SELECT user_id FROM employees WHERE id = $1 AND deleted_at IS NULL`))
+	ownsDocuments := middleware.OwnedBy(middleware.Query("employeeId"), s.ownerOf(`SELECT user_id FROM employees WHERE id = $1 AND deleted_at IS NULL`))
+	ownsAttendance := middleware.OwnedBy(middleware.Param("id"), s.ownerOf(`
+		SELECT e.user_id FROM attendance a JOIN employees e ON e.id = a.employee_id WHERE a.id = $1`))
+	ownsLeaveRequest := middleware.OwnedBy(middleware.Param("id"), s.ownerOf(`
+		SELECT e.user_id FROM leave_requests l JOIN employees e ON e.id = l.employee_id WHERE l.id = $1`))
+	ownsPayslip := middleware.OwnedBy(middleware.Param("id"), s.ownerOf(`
+		SELECT e.user_id FROM payslips p JOIN employees e ON e.id = p.employee_id WHERE p.id = $1`))
+	ownsDocument := middleware.OwnedBy(middleware.Param("id"), s.ownerOf(`
+		SELECT e.user_id FROM documents d JOIN employees e ON e.id = d.employee_id WHERE d.id = $1`))
+
+	return middleware.Permissions{
+		// Auth
+		"POST /api/v1/auth/logout":         middleware.Allow(everyone...),
+		"PUT /api/v1/auth/password":        middleware.Allow(everyone...),
+		"GET /api/v1/auth/sessions":        middleware.Allow(everyone...),
+		"DELETE /api/v1/auth/sessions/:id": middleware.Allow(everyone...),
+
+		// API tokens. Token management is never available to API tokens themselves.
+		"GET /api/v1/auth/api-tokens":        middleware.Allow(everyone...),
+		"GET /api/v1/auth/api-tokens/scopes": middleware.Allow(everyone...),
+		"POST /api/v1/auth/api-tokens":       middleware.Allow(everyone...),
+		"DELETE /api/v1/auth/api-tokens/:id": middleware.Allow(everyone...),
+		"GET /api/v1/api-tokens/":            middleware.Allow(admin),
+		"DELETE /api/v1/api-tokens/:id":      middleware.Allow(admin),
+
+		// Two-factor authentication
+		"POST /api/v1/auth/2fa/setup":          middleware.Allow(everyone...),
+		"POST /api/v1/auth/2fa/confirm":        middleware.Allow(everyone...),
+		"POST /api/v1/auth/2fa/disable":        middleware.Allow(everyone...),
+		"POST /api/v1/auth/2fa/recovery-codes": middleware.Allow(everyone...),
+		"GET /api/v1/auth/2fa/policies":        middleware.Allow(admin),
+		"PUT /api/v1/auth/2fa/policies/:role":  middleware.Allow(admin),
+
+		// User administration
+		"GET /api/v1/users/":             middleware.Allow(admin),
+		"GET /api/v1/users/:id":          middleware.Allow(admin),
+		"PUT /api/v1/users/:id/role":     middleware.Allow(admin),
+		"PUT /api/v1/users/:id/status":   middleware.Allow(admin),
+		"PUT /api/v1/users/:id/password": middleware.Allow(admin),
+		"POST /api/v1/users/:id/unlock":  middleware.Allow(admin),
+
+		// Invitations
+		"GET /api/v1/invitations/":       middleware.Allow(staff...),
+		"POST /api/v1/invitations/":      middleware.Allow(staff...),
+		"DELETE /api/v1/invitations/:id": middleware.Allow(staff...),
+
+		// Audit
+		"GET /api/v1/audit-events": middleware.Allow(admin),
+
+		// Self-service
+		"GET /api/v1/me":                 middleware.Allow(everyone...).WithScopes(auth.ScopeEmployeesRead),
+		"PUT /api/v1/me":                 middleware.Allow(everyone...).WithScopes(auth.ScopeEmployeesWrite),
+		"GET /api/v1/me/change-requests": middleware.Allow(everyone...).WithScopes(auth.ScopeEmployeesRead),
+		"GET /api/v1/me/attendance":      middleware.Allow(everyone...).WithScopes(auth.ScopeAttendanceRead),
+		"GET /api/v1/me/leave-requests":  middleware.Allow(everyone...).WithScopes(auth.ScopeLeaveRead),
+		"GET /api/v1/me/payslips":        middleware.Allow(everyone...).WithScopes(auth.ScopePayrollRead),
+		"GET /api/v1/me/documents":       middleware.Allow(everyone...).WithScopes(auth.ScopeDocumentsRead),
+
+		// Employees
+		"GET /api/v1/employees/":             middleware.Allow(managers...).WithScopes(auth.ScopeEmployeesRead),
+		"GET /api/v1/employees/search":       middleware.Allow(managers...).WithScopes(auth.ScopeEmployeesRead),
+		"GET /api/v1/employees/:id":          middleware.Allow(managers...).OrOwner(ownsEmployee, employee).WithScopes(auth.ScopeEmployeesRead),
+		"POST /api/v1/employees/":            middleware.Allow(staff...).WithScopes(auth.ScopeEmployeesWrite),
+		"PUT /api/v1/employees/:id":          middleware.Allow(staff...).WithScopes(auth.ScopeEmployeesWrite),
+		"DELETE /api/v1/employees/:id":       middleware.Allow(staff...).WithScopes(auth.ScopeEmployeesWrite),
+		"POST /api/v1/employees/import":      middleware.Allow(staff...).WithScopes(auth.ScopeEmployeesWrite),
+		"GET /api/v1/employees/deleted":      middleware.Allow(staff...).WithScopes(auth.ScopeEmployeesRead),
+		"POST /api/v1/employees/:id/restore": middleware.Allow(staff...).WithScopes(auth.ScopeEmployeesWrite),
+
+		// Employee lifecycle
+		"POST /api/v1/employees/:id/transfer":      middleware.Allow(staff...).WithScopes(auth.ScopeEmployeesWrite),
+		"POST /api/v1/employees/:id/position":      middleware.Allow(staff...).WithScopes(auth.ScopeEmployeesWrite),
+		"POST /api/v1/employees/:id/promote":       middleware.Allow(staff...).WithScopes(auth.ScopeEmployeesWrite),
+		"POST /api/v1/employees/:id/suspend":       middleware.Allow(staff...).WithScopes(auth.ScopeEmployeesWrite),
+		"POST /api/v1/employees/:id/reinstate":     middleware.Allow(staff...).WithScopes(auth.ScopeEmployeesWrite),
+		"POST /api/v1/employees/:id/terminate":     middleware.Allow(staff...).WithScopes(auth.ScopeEmployeesWrite),
+		"DELETE /api/v1/employees/:id/termination": middleware.Allow(staff...).WithScopes(auth.ScopeEmployeesWrite),
+		"POST /api/v1/employees/:id/rehire":        middleware.Allow(staff...).WithScopes(auth.ScopeEmployeesWrite),
+		"GET /api/v1/employees/:id/history":        middleware.Allow(managers...).OrOwner(ownsEmployee, employee).WithScopes(auth.ScopeEmployeesRead),
+		"GET /api/v1/employees/:id/job-history":    middleware.Allow(managers...).OrOwner(ownsEmployee, employee).WithScopes(auth.ScopeEmployeesRead),
+
+		// Org chart
+		"GET /api/v1/employees/:id/reports":          middleware.Allow(managers...).OrOwner(ownsEmployee, employee).WithScopes(auth.ScopeEmployeesRead),
+		"GET /api/v1/employees/:id/chain-of-command": middleware.Allow(managers...).OrOwner(ownsEmployee, employee).WithScopes(auth.ScopeEmployeesRead),
+		"GET /api/v1/employees/org-chart":            middleware.Allow(everyone...).WithScopes(auth.ScopeEmployeesRead),
+		"GET /api/v1/employees/org-chart/stats":      middleware.Allow(managers...).WithScopes(auth.ScopeEmployeesRead),
+
+		// Self-service change requests
+		"GET /api/v1/employees/change-requests":              middleware.Allow(staff...).WithScopes(auth.ScopeEmployeesRead),
+		"POST /api/v1/employees/change-requests/:id/approve": middleware.Allow(staff...).WithScopes(auth.ScopeEmployeesWrite),
+		"POST /api/v1/employees/change-requests/:id/reject":  middleware.Allow(staff...).WithScopes(auth.ScopeEmployeesWrite),
+
+		// Custom fields and export
+		"GET /api/v1/employees/custom-fields":        middleware.Allow(everyone...).WithScopes(auth.ScopeEmployeesRead),
+		"POST /api/v1/employees/custom-fields":       middleware.Allow(admin),
+		"PUT /api/v1/employees/custom-fields/:id":    middleware.Allow(admin),
+		"DELETE /api/v1/employees/custom-fields/:id": middleware.Allow(admin),
+		"GET /api/v1/employees/export":               middleware.Allow(staff...).WithScopes(auth.ScopeEmployeesRead),
+
+		// Duplicates
+		"GET /api/v1/employees/duplicates": middleware.Allow(staff...).WithScopes(auth.ScopeEmployeesRead),
+		"POST /api/v1/employees/:id/merge": middleware.Allow(staff...).WithScopes(auth.ScopeEmployeesWrite),
+
+		// Employee number templates
+		"GET /api/v1/employees/number-templates":        middleware.Allow(staff...).WithScopes(auth.ScopeEmployeesRead),
+		"POST /api/v1/employees/number-templates":       middleware.Allow(admin),
+		"PUT /api/v1/employees/number-templates/:id":    middleware.Allow(admin),
+		"DELETE /api/v1/employees/number-templates/:id": middleware.Allow(admin),
+
+		// Departments
+		"GET /api/v1/departments/":               middleware.Allow(everyone...).WithScopes(auth.ScopeDepartmentsRead),
+		"GET /api/v1/departments/:id":            middleware.Allow(everyone...).WithScopes(auth.ScopeDepartmentsRead),
+		"POST /api/v1/departments/":              middleware.Allow(staff...),
+		"PUT /api/v1/departments/:id":            middleware.Allow(staff...),
+		"DELETE /api/v1/departments/:id":         middleware.Allow(staff...),
+		"GET /api/v1/departments/tree":           middleware.Allow(everyone...).WithScopes(auth.ScopeDepartmentsRead),
+		"GET /api/v1/departments/costs":          middleware.Allow(staff...).WithScopes(auth.ScopePayrollRead),
+		"POST /api/v1/departments/:id/move":      middleware.Allow(staff...),
+		"POST /api/v1/departments/:id/merge":     middleware.Allow(staff...),
+		"GET /api/v1/departments/:id/dependents": middleware.Allow(staff...).WithScopes(auth.ScopeDepartmentsRead),
+
+		// Legal entities
+		"GET /api/v1/legal-entities/":       middleware.Allow(everyone...).WithScopes(auth.ScopeDepartmentsRead),
+		"GET /api/v1/legal-entities/:id":    middleware.Allow(everyone...).WithScopes(auth.ScopeDepartmentsRead),
+		"POST /api/v1/legal-entities/":      middleware.Allow(admin),
+		"PUT /api/v1/legal-entities/:id":    middleware.Allow(admin),
+		"DELETE /api/v1/legal-entities/:id": middleware.Allow(admin),
+
+		// Positions
+		"GET /api/v1/positions/":                      middleware.Allow(everyone...).WithScopes(auth.ScopePositionsRead),
+		"GET /api/v1/positions/:id":                   middleware.Allow(everyone...).WithScopes(auth.ScopePositionsRead),
+		"POST /api/v1/positions/":                     middleware.Allow(staff...),
+		"PUT /api/v1/positions/:id":                   middleware.Allow(staff...),
+		"DELETE /api/v1/positions/:id":                middleware.Allow(staff...),
+		"GET /api/v1/positions/:id/budgets":           middleware.Allow(managers...).WithScopes(auth.ScopePositionsRead),
+		"POST /api/v1/positions/:id/budgets":          middleware.Allow(staff...),
+		"PUT /api/v1/positions/budgets/:id":           middleware.Allow(staff...),
+		"DELETE /api/v1/positions/budgets/:id":        middleware.Allow(staff...),
+		"GET /api/v1/positions/headcount":             middleware.Allow(managers...).WithScopes(auth.ScopePositionsRead),
+		"GET /api/v1/positions/headcount/departments": middleware.Allow(managers...).WithScopes(auth.ScopePositionsRead),
+
+		// Requisitions. Managers request vacancies, HR decides on them.
+		"GET /api/v1/positions/requisitions":              middleware.Allow(managers...).WithScopes(auth.ScopePositionsRead),
+		"POST /api/v1/positions/requisitions":             middleware.Allow(managers...),
+		"GET /api/v1/positions/requisitions/:id":          middleware.Allow(managers...).WithScopes(auth.ScopePositionsRead),
+		"POST /api/v1/positions/requisitions/:id/approve": middleware.Allow(staff...),
+		"POST /api/v1/positions/requisitions/:id/reject":  middleware.Allow(staff...),
+		"POST /api/v1/positions/requisitions/:id/fill":    middleware.Allow(staff...),
+		"POST /api/v1/positions/requisitions/:id/cancel":  middleware.Allow(staff...),
+
+		// Attendance
+		"GET /api/v1/attendance/":           middleware.Allow(managers...).WithScopes(auth.ScopeAttendanceRead),
+		"GET /api/v1/attendance/:id":        middleware.Allow(managers...).OrOwner(ownsAttendance, employee).WithScopes(auth.ScopeAttendanceRead),
+		"POST /api/v1/attendance/check-in":  middleware.Allow(everyone...),
+		"POST /api/v1/attendance/check-out": middleware.Allow(everyone...),
+		"POST /api/v1/attendance/":          middleware.Allow(staff...),
+		"PUT /api/v1/attendance/:id":        middleware.Allow(staff...),
+
+		// Leave types
+		"GET /api/v1/leave/types/":       middleware.Allow(everyone...).WithScopes(auth.ScopeLeaveRead),
+		"GET /api/v1/leave/types/:id":    middleware.Allow(everyone...).WithScopes(auth.ScopeLeaveRead),
+		"POST /api/v1/leave/types/":      middleware.Allow(staff...),
+		"PUT /api/v1/leave/types/:id":    middleware.Allow(staff...),
+		"DELETE /api/v1/leave/types/:id": middleware.Allow(staff...),
+
+		// Leave requests
+		"GET /api/v1/leave/requests/":            middleware.Allow(managers...).WithScopes(auth.ScopeLeaveRead),
+		"POST /api/v1/leave/requests/":           middleware.Allow(everyone...),
+		"GET /api/v1/leave/requests/:id":         middleware.Allow(managers...).OrOwner(ownsLeaveRequest, employee).WithScopes(auth.ScopeLeaveRead),
+		"PUT /api/v1/leave/requests/:id/approve": middleware.Allow(managers...),
+		"PUT /api/v1/leave/requests/:id/reject":  middleware.Allow(managers...),
+
+		// Payroll
+		"POST /api/v1/payroll/calculate":                         middleware.Allow(staff...),
+		"GET /api/v1/payroll/":                                   middleware.Allow(staff...).WithScopes(auth.ScopePayrollRead, auth.ScopePayrollExport),
+		"GET /api/v1/payroll/:id":                                middleware.Allow(staff...).WithScopes(auth.ScopePayrollRead, auth.ScopePayrollExport),
+		"POST /api/v1/payroll/:id/approve":                       middleware.Allow(staff...),
+		"POST /api/v1/payroll/:id/process":                       middleware.Allow(staff...),
+		"POST /api/v1/payroll/components/":                       middleware.Allow(staff...),
+		"GET /api/v1/payroll/components/":                        middleware.Allow(staff...).WithScopes(auth.ScopePayrollRead),
+		"GET /api/v1/payroll/components/:id":                     middleware.Allow(staff...).WithScopes(auth.ScopePayrollRead),
+		"POST /api/v1/payroll/employee-salaries/":                middleware.Allow(staff...),
+		"GET /api/v1/payroll/employee-salaries/:employeeId":      middleware.Allow(staff...).OrOwner(ownsSalaries, manager, employee).WithScopes(auth.ScopePayrollRead),
+		"GET /api/v1/payroll/salary-bands/employees/:employeeId": middleware.Allow(staff...).WithScopes(auth.ScopePayrollRead),
+		"GET /api/v1/payroll/salary-bands/out-of-band":           middleware.Allow(staff...).WithScopes(auth.ScopePayrollRead),
+		"GET /api/v1/payroll/salary-bands/pending":               middleware.Allow(staff...).WithScopes(auth.ScopePayrollRead),
+		"POST /api/v1/payroll/salary-bands/pending/:id/approve":  middleware.Allow(staff...),
+		"POST /api/v1/payroll/salary-bands/pending/:id/reject":   middleware.Allow(staff...),
+		"POST /api/v1/payroll/tax-brackets/":                     middleware.Allow(staff...),
+		"GET /api/v1/payroll/tax-brackets/":                      middleware.Allow(staff...).WithScopes(auth.ScopePayrollRead),
+
+		// Payslips
+		"GET /api/v1/payslips/:id": middleware.Allow(staff...).OrOwner(ownsPayslip, manager, employee).WithScopes(auth.ScopePayrollRead, auth.ScopePayrollExport),
+
+		// Documents
+		"POST /api/v1/documents/":      middleware.Allow(staff...),
+		"GET /api/v1/documents/":       middleware.Allow(staff...).OrOwner(ownsDocuments, manager, employee).WithScopes(auth.ScopeDocumentsRead),
+		"GET /api/v1/documents/:id":    middleware.Allow(staff...).OrOwner(ownsDocument, manager, employee).WithScopes(auth.ScopeDocumentsRead),
+		"DELETE /api/v1/documents/:id": middleware.Allow(staff...),
+
+		// Reports
+		"GET /api/v1/reports/":              middleware.Allow(staff...).WithScopes(auth.ScopePayrollExport),
+		"GET /api/v1/reports/:type":         middleware.Allow(staff...).WithScopes(auth.ScopePayrollExport),
+		"POST /api/v1/reports/:type/export": middleware.Allow(staff...).WithScopes(auth.ScopePayrollExport),
+
+		// Notifications
+		"GET /api/v1/notifications/":         middleware.Allow(everyone...),
+		"PUT /api/v1/notifications/:id/read": middleware.Allow(everyone...),
+		"PUT /api/v1/notifications/read-all": middleware.Allow(everyone...),
+	}
+}
+
+// ownerOf returns an OwnerLookup that runs query with the resource ID and
+// scans the owning user ID
+func (s *Server) ownerOf(query string) middleware.OwnerLookup {
+	return func(id string) (uuid.UUID, error) {
+		resourceID, err := uuid.Parse(id)
+		if err != nil {
+			return uuid.Nil, err
+		}
+		// Employees without a user account are owned by no one
+		var userID uuid.NullUUID
+		err = s.db.QueryRow(query, resourceID).Scan(&userID)
+		return userID.UUID, err
+	}
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"employee-management/internal/employee"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// startRetentionJob anonymizes deleted employees once they have been deleted
+// for EMPLOYEE_RETENTION_DAYS, checking every EMPLOYEE_RETENTION_INTERVAL
+// (default 24h). Without EMPLOYEE_RETENTION_DAYS deleted employees are kept
+// as they are.
+func startRetentionJob(service *employee.Service, logger *logrus.Logger) error {
+	value := os.Getenv("EMPLOYEE_RETENTION_DAYS")
+	if value == "" {
+		logger.Info("EMPLOYEE_RETENTION_DAYS is not set, deleted employees will not be anonymized")
+		return nil
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 1 {
+		return fmt.Errorf("invalid EMPLOYEE_RETENTION_DAYS %q", value)
+	}
+	value = getEnv("EMPLOYEE_RETENTION_INTERVAL", "24h")
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval <= 0 {
+		return fmt.Errorf("invalid EMPLOYEE_RETENTION_INTERVAL %q", value)
+	}
+
+	go applyRetentionPolicy(service, time.Duration(days)*24*time.Hour, interval, logger)
+	return nil
+}
+
+// applyRetentionPolicy runs the retention policy now and then periodically.
+// Failures are logged and retried on the next run.
+func applyRetentionPolicy(service *employee.Service, retention, interval time.Duration, logger *logrus.Logger) {
+	entry := logger.WithField("job", "employee-retention")
+	run := func() {
+		anonymized, err := service.ApplyRetentionPolicy(entry, retention)
+		if err != nil {
+			entry.WithError(err).Error("Failed to anonymize deleted employees")
+			return
+		}
+		if anonymized > 0 {
+			entry.WithField("anonymized", anonymized).Info("Anonymized deleted employees")
+		}
+	}
+
+	run()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		run()
+	}
+}
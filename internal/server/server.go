@@ -2,17 +2,22 @@ package server
 
 import (
 	"employee-management/internal/attendance"
+	"employee-management/internal/audit"
 	"employee-management/internal/auth"
 	"employee-management/internal/database"
 	"employee-management/internal/department"
 	"employee-management/internal/document"
 	"employee-management/internal/employee"
+	"employee-management/internal/fieldcrypt"
 	"employee-management/internal/leave"
+	"employee-management/internal/mail"
 	"employee-management/internal/middleware"
 	"employee-management/internal/payroll"
 	"employee-management/internal/position"
+	"employee-management/internal/selfservice"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -27,7 +32,9 @@ type Server struct {
 	router            *gin.Engine
 	db                *database.DB
 	logger            *logrus.Logger
+	authService       *auth.Service
 	authHandler       *auth.Handler
+	auditHandler      *audit.Handler
 	employeeHandler   *employee.Handler
 	departmentHandler *department.Handler
 	positionHandler   *position.Handler
@@ -35,12 +42,13 @@ type Server struct {
 	leaveHandler      *leave.Handler
 	payrollHandler    *payroll.Handler
 	documentHandler   *document.Handler
+	selfHandler       *selfservice.Handler
 }
 
 // NewServer creates a new server instance
 func NewServer(db *database.DB, logger *logrus.Logger) *Server {
-	// Set Gin to release mode in production
-	gin.SetMode(gin.ReleaseMode)
+	// Gin runs in release mode unless GIN_MODE says otherwise
+	gin.SetMode(getEnv("GIN_MODE", gin.ReleaseMode))
 
 	// Create router
 	router := gin.New()
@@ -60,19 +68,79 @@ func NewServer(db *database.DB, logger *logrus.Logger) *Server {
 	}
 
 	// Initialize auth service and handler
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		jwtSecret = "default-secret" // Fallback for development
+	keys, err := loadKeySet(logger)
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to load token signing keys")
 	}
 
-	authService := auth.NewService(db, jwtSecret)
+	mailer, err := mail.NewSenderFromEnv()
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to configure mail sender")
+	}
+
+	accessTokenTTL, err := time.ParseDuration(getEnv("ACCESS_TOKEN_TTL", "15m"))
+	if err != nil {
+		logger.WithError(err).Fatal("Invalid ACCESS_TOKEN_TTL")
+	}
+	refreshTokenTTL, err := time.ParseDuration(getEnv("REFRESH_TOKEN_TTL", "720h"))
+	if err != nil {
+		logger.WithError(err).Fatal("Invalid REFRESH_TOKEN_TTL")
+	}
+
+	allowSelfRegistration, err := strconv.ParseBool(getEnv("ALLOW_SELF_REGISTRATION", "false"))
+	if err != nil {
+		logger.WithError(err).Fatal("Invalid ALLOW_SELF_REGISTRATION")
+	}
+
+	minPasswordLength, err := strconv.Atoi(getEnv("PASSWORD_MIN_LENGTH", "8"))
+	if err != nil {
+		logger.WithError(err).Fatal("Invalid PASSWORD_MIN_LENGTH")
+	}
+	passwordPolicy, err := auth.NewPasswordPolicy(minPasswordLength, os.Getenv("BREACHED_PASSWORDS_FILE"))
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to load password policy")
+	}
+
+	oidcConfig, err := loadOIDCConfig()
+	if err != nil {
+		logger.WithError(err).Fatal("Invalid single sign-on configuration")
+	}
+
+	auditRecorder := audit.NewRecorder(db)
+	auditHandler := audit.NewHandler(auditRecorder)
+
+	authService := auth.NewService(db, auth.Config{
+		Keys:            keys,
+		Issuer:          getEnv("JWT_ISSUER", "employee-management"),
+		AppURL:          getEnv("APP_URL", "http://localhost:8080"),
+		AccessTokenTTL:  accessTokenTTL,
+		RefreshTokenTTL: refreshTokenTTL,
+
+		AllowSelfRegistration: allowSelfRegistration,
+		PasswordPolicy:        passwordPolicy,
+		OIDC:                  oidcConfig,
+	}, mailer, auditRecorder)
 	authHandler := auth.NewHandler(authService)
 
-	employeeRepo := employee.NewRepository(db)
-	employeeService := employee.NewService(employeeRepo)
+	fieldCipher, err := fieldcrypt.FromEnv()
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to load field encryption keys")
+	}
+	if fieldCipher == nil {
+		logger.Info("Field encryption is disabled; set FIELD_ENCRYPTION_KEYS_FILE to enable it")
+	}
+
+	employeeRepo := employee.NewRepository(db, fieldCipher)
+	employeeService := employee.NewService(employeeRepo, auditRecorder)
 	employeeHandler := employee.NewHandler(employeeService)
+	if err := startRetentionJob(employeeService, logger); err != nil {
+		logger.WithError(err).Fatal("Invalid employee retention configuration")
+	}
+	if err := startTerminationJob(employeeService, logger); err != nil {
+		logger.WithError(err).Fatal("Invalid employee termination configuration")
+	}
 
-	departmentService := department.NewService(db)
+	departmentService := department.NewService(db, employeeRepo)
 	departmentHandler := department.NewHandler(departmentService)
 
 	positionService := position.NewService(db)
@@ -85,19 +153,27 @@ func NewServer(db *database.DB, logger *logrus.Logger) *Server {
 	leaveService := leave.NewService(leaveRepo)
 	leaveHandler := leave.NewHandler(leaveService)
 
-	payrollRepo := payroll.NewRepository(db)
-	payrollService := payroll.NewService(payrollRepo, employeeService)
+	payrollRepo := payroll.NewRepository(db, fieldCipher)
+	bandEnforcement, err := payroll.ParseBandEnforcement(os.Getenv("SALARY_BAND_ENFORCEMENT"))
+	if err != nil {
+		logger.WithError(err).Fatal("Invalid SALARY_BAND_ENFORCEMENT")
+	}
+	payrollService := payroll.NewService(payrollRepo, employeeService, bandEnforcement)
 	payrollHandler := payroll.NewHandler(payrollService)
 
 	documentRepo := document.NewRepository(db)
 	documentService := document.NewService(documentRepo)
 	documentHandler := document.NewHandler(documentService)
 
+	selfHandler := selfservice.NewHandler(employeeService, attendanceService, leaveService, payrollService, documentService)
+
 	return &Server{
 		router:            router,
 		db:                db,
 		logger:            logger,
+		authService:       authService,
 		authHandler:       authHandler,
+		auditHandler:      auditHandler,
 		employeeHandler:   employeeHandler,
 		departmentHandler: departmentHandler,
 		positionHandler:   positionHandler,
@@ -105,6 +181,7 @@ func NewServer(db *database.DB, logger *logrus.Logger) *Server {
 		leaveHandler:      leaveHandler,
 		payrollHandler:    payrollHandler,
 		documentHandler:   documentHandler,
+		selfHandler:       selfHandler,
 	}
 }
 
@@ -117,18 +194,90 @@ func (s *Server) setupRoutes() {
 	s.router.GET("/health", s.healthCheck)
 	s.router.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
+	// Public keys for services that verify our tokens
+	s.router.GET("/.well-known/jwks.json", s.jwks)
+
 	// API v1 routes
 	v1 := s.router.Group("/api/v1")
+
+	// Public auth routes
+	public := v1.Group("/auth")
+	{
+		public.POST("/login", s.login)
+		public.POST("/register", s.register)
+		public.POST("/forgot-password", s.forgotPassword)
+		public.POST("/reset-password", s.resetPassword)
+		public.POST("/refresh", s.refreshToken)
+		public.POST("/2fa/verify", s.verifyTwoFactor)
+		public.POST("/2fa/enroll", s.enrollTwoFactor)
+		public.POST("/2fa/enroll/confirm", s.confirmTwoFactorEnrollment)
+		public.POST("/invitations/accept", s.acceptInvitation)
+		public.POST("/password/expired", s.completePasswordChange)
+		public.GET("/oidc/login", s.oidcLogin)
+		public.GET("/oidc/callback", s.oidcCallback)
+	}
+
+	// Everything else requires a valid token and a matching permission rule
+	v1 = v1.Group("", middleware.Authenticate(s.authService), s.permissions().Authorize())
 	{
 		// Auth routes
 		auth := v1.Group("/auth")
 		{
-			auth.POST("/login", s.login)
 			auth.POST("/logout", s.logout)
-			auth.POST("/register", s.register)
-			auth.POST("/forgot-password", s.forgotPassword)
-			auth.POST("/reset-password", s.resetPassword)
-			auth.POST("/refresh", s.refreshToken)
+			auth.PUT("/password", s.changePassword)
+			auth.GET("/sessions", s.listSessions)
+			auth.DELETE("/sessions/:id", s.revokeSession)
+			auth.GET("/api-tokens", s.listAPITokens)
+			auth.GET("/api-tokens/scopes", s.listAPIScopes)
+			auth.POST("/api-tokens", s.createAPIToken)
+			auth.DELETE("/api-tokens/:id", s.revokeAPIToken)
+			auth.POST("/2fa/setup", s.setupTwoFactor)
+			auth.POST("/2fa/confirm", s.confirmTwoFactor)
+			auth.POST("/2fa/disable", s.disableTwoFactor)
+			auth.POST("/2fa/recovery-codes", s.regenerateRecoveryCodes)
+			auth.GET("/2fa/policies", s.listTwoFactorPolicies)
+			auth.PUT("/2fa/policies/:role", s.updateTwoFactorPolicy)
+		}
+
+		// User administration routes
+		users := v1.Group("/users")
+		{
+			users.GET("/", s.listUsers)
+			users.GET("/:id", s.getUser)
+			users.PUT("/:id/role", s.updateUserRole)
+			users.PUT("/:id/status", s.updateUserStatus)
+			users.PUT("/:id/password", s.setUserPassword)
+			users.POST("/:id/unlock", s.unlockUser)
+		}
+
+		// API token administration routes
+		apiTokens := v1.Group("/api-tokens")
+		{
+			apiTokens.GET("/", s.listAllAPITokens)
+			apiTokens.DELETE("/:id", s.adminRevokeAPIToken)
+		}
+
+		// Invitation routes
+		invitations := v1.Group("/invitations")
+		{
+			invitations.GET("/", s.listInvitations)
+			invitations.POST("/", s.createInvitation)
+			invitations.DELETE("/:id", s.revokeInvitation)
+		}
+
+		// Audit routes
+		v1.GET("/audit-events", s.listAuditEvents)
+
+		// Self-service routes
+		me := v1.Group("/me")
+		{
+			me.GET("", s.getOwnProfile)
+			me.PUT("", s.updateOwnProfile)
+			me.GET("/change-requests", s.listOwnChangeRequests)
+			me.GET("/attendance", s.listOwnAttendance)
+			me.GET("/leave-requests", s.listOwnLeaveRequests)
+			me.GET("/payslips", s.listOwnPayslips)
+			me.GET("/documents", s.listOwnDocuments)
 		}
 
 		// Employee routes
@@ -140,6 +289,37 @@ func (s *Server) setupRoutes() {
 			employees.PUT("/:id", s.updateEmployee)
 			employees.DELETE("/:id", s.deleteEmployee)
 			employees.GET("/search", s.searchEmployees)
+			employees.POST("/import", s.importEmployees)
+			employees.GET("/deleted", s.listDeletedEmployees)
+			employees.POST("/:id/restore", s.restoreEmployee)
+			employees.POST("/:id/transfer", s.transferEmployee)
+			employees.POST("/:id/position", s.changeEmployeePosition)
+			employees.POST("/:id/promote", s.promoteEmployee)
+			employees.POST("/:id/suspend", s.suspendEmployee)
+			employees.POST("/:id/reinstate", s.reinstateEmployee)
+			employees.POST("/:id/terminate", s.terminateEmployee)
+			employees.DELETE("/:id/termination", s.cancelEmployeeTermination)
+			employees.POST("/:id/rehire", s.rehireEmployee)
+			employees.GET("/:id/job-history", s.getEmployeeJobHistory)
+			employees.GET("/:id/history", s.getEmployeeHistory)
+			employees.GET("/:id/reports", s.getEmployeeReports)
+			employees.GET("/:id/chain-of-command", s.getChainOfCommand)
+			employees.GET("/org-chart", s.getOrgChart)
+			employees.GET("/org-chart/stats", s.getSpanOfControl)
+			employees.GET("/change-requests", s.listChangeRequests)
+			employees.POST("/change-requests/:id/approve", s.approveChangeRequest)
+			employees.POST("/change-requests/:id/reject", s.rejectChangeRequest)
+			employees.GET("/custom-fields", s.listCustomFields)
+			employees.POST("/custom-fields", s.createCustomField)
+			employees.PUT("/custom-fields/:id", s.updateCustomField)
+			employees.DELETE("/custom-fields/:id", s.deleteCustomField)
+			employees.GET("/export", s.exportEmployees)
+			employees.GET("/duplicates", s.findDuplicateEmployees)
+			employees.POST("/:id/merge", s.mergeEmployees)
+			employees.GET("/number-templates", s.listNumberTemplates)
+			employees.POST("/number-templates", s.createNumberTemplate)
+			employees.PUT("/number-templates/:id", s.updateNumberTemplate)
+			employees.DELETE("/number-templates/:id", s.deleteNumberTemplate)
 		}
 
 		// Department routes
@@ -150,6 +330,21 @@ func (s *Server) setupRoutes() {
 			departments.POST("/", s.createDepartment)
 			departments.PUT("/:id", s.updateDepartment)
 			departments.DELETE("/:id", s.deleteDepartment)
+			departments.GET("/tree", s.getDepartmentTree)
+			departments.GET("/costs", s.getPayrollCosts)
+			departments.POST("/:id/move", s.moveDepartment)
+			departments.POST("/:id/merge", s.mergeDepartments)
+			departments.GET("/:id/dependents", s.getDepartmentDependents)
+		}
+
+		// Legal entity routes
+		legalEntities := v1.Group("/legal-entities")
+		{
+			legalEntities.GET("/", s.listLegalEntities)
+			legalEntities.GET("/:id", s.getLegalEntity)
+			legalEntities.POST("/", s.createLegalEntity)
+			legalEntities.PUT("/:id", s.updateLegalEntity)
+			legalEntities.DELETE("/:id", s.deleteLegalEntity)
 		}
 
 		// Position routes
@@ -160,6 +355,19 @@ func (s *Server) setupRoutes() {
 			positions.POST("/", s.createPosition)
 			positions.PUT("/:id", s.updatePosition)
 			positions.DELETE("/:id", s.deletePosition)
+			positions.GET("/:id/budgets", s.listPositionBudgets)
+			positions.POST("/:id/budgets", s.createPositionBudget)
+			positions.PUT("/budgets/:id", s.updatePositionBudget)
+			positions.DELETE("/budgets/:id", s.deletePositionBudget)
+			positions.GET("/headcount", s.getPositionHeadcount)
+			positions.GET("/headcount/departments", s.getDepartmentHeadcount)
+			positions.GET("/requisitions", s.listRequisitions)
+			positions.POST("/requisitions", s.createRequisition)
+			positions.GET("/requisitions/:id", s.getRequisition)
+			positions.POST("/requisitions/:id/approve", s.approveRequisition)
+			positions.POST("/requisitions/:id/reject", s.rejectRequisition)
+			positions.POST("/requisitions/:id/fill", s.fillRequisition)
+			positions.POST("/requisitions/:id/cancel", s.cancelRequisition)
 		}
 
 		// Attendance routes
@@ -221,6 +429,16 @@ func (s *Server) setupRoutes() {
 				employeeSalaries.GET("/:employeeId", s.getEmployeeSalaries)
 			}
 
+			// Salary Bands
+			salaryBands := payrollRoutes.Group("/salary-bands")
+			{
+				salaryBands.GET("/employees/:employeeId", s.getSalaryBand)
+				salaryBands.GET("/out-of-band", s.getOutOfBandReport)
+				salaryBands.GET("/pending", s.listPendingSalaries)
+				salaryBands.POST("/pending/:id/approve", s.approveSalaryBand)
+				salaryBands.POST("/pending/:id/reject", s.rejectSalaryBand)
+			}
+
 			// Tax Brackets
 			taxBrackets := payrollRoutes.Group("/tax-brackets")
 			{
@@ -266,6 +484,9 @@ func (s *Server) setupRoutes() {
 func (s *Server) Run() error {
 	// Setup routes
 	s.setupRoutes()
+	if err := s.permissions().Check(s.router.Routes(), "/api/v1", publicRoutes...); err != nil {
+		return err
+	}
 
 	// Create HTTP server
 	server := &http.Server{
@@ -288,12 +509,101 @@ func (s *Server) healthCheck(c *gin.Context) {
 }
 
 // Auth handlers
+func (s *Server) jwks(c *gin.Context) { s.authHandler.JWKS(c) }
+func (s *Server) listAPIScopes(c *gin.Context) {
+	s.authHandler.ListAPIScopes(c)
+}
+func (s *Server) listAPITokens(c *gin.Context) {
+	s.authHandler.ListAPITokens(c)
+}
+func (s *Server) createAPIToken(c *gin.Context) {
+	s.authHandler.CreateAPIToken(c)
+}
+func (s *Server) revokeAPIToken(c *gin.Context) {
+	s.authHandler.RevokeAPIToken(c)
+}
+func (s *Server) listAllAPITokens(c *gin.Context) {
+	s.authHandler.ListAllAPITokens(c)
+}
+func (s *Server) adminRevokeAPIToken(c *gin.Context) {
+	s.authHandler.AdminRevokeAPIToken(c)
+}
+func (s *Server) oidcLogin(c *gin.Context) {
+	s.authHandler.OIDCLogin(c)
+}
+func (s *Server) oidcCallback(c *gin.Context) {
+	s.authHandler.OIDCCallback(c)
+}
 func (s *Server) login(c *gin.Context)          { s.authHandler.Login(c) }
 func (s *Server) logout(c *gin.Context)         { s.authHandler.Logout(c) }
 func (s *Server) register(c *gin.Context)       { s.authHandler.Register(c) }
 func (s *Server) forgotPassword(c *gin.Context) { s.authHandler.ForgotPassword(c) }
 func (s *Server) resetPassword(c *gin.Context)  { s.authHandler.ResetPassword(c) }
 func (s *Server) refreshToken(c *gin.Context)   { s.authHandler.RefreshToken(c) }
+func (s *Server) listSessions(c *gin.Context)   { s.authHandler.ListSessions(c) }
+func (s *Server) revokeSession(c *gin.Context)  { s.authHandler.RevokeSession(c) }
+func (s *Server) verifyTwoFactor(c *gin.Context) {
+	s.authHandler.VerifyTwoFactor(c)
+}
+func (s *Server) enrollTwoFactor(c *gin.Context) {
+	s.authHandler.EnrollTwoFactor(c)
+}
+func (s *Server) confirmTwoFactorEnrollment(c *gin.Context) {
+	s.authHandler.ConfirmTwoFactorEnrollment(c)
+}
+func (s *Server) setupTwoFactor(c *gin.Context) {
+	s.authHandler.SetupTwoFactor(c)
+}
+func (s *Server) confirmTwoFactor(c *gin.Context) {
+	s.authHandler.ConfirmTwoFactor(c)
+}
+func (s *Server) disableTwoFactor(c *gin.Context) {
+	s.authHandler.DisableTwoFactor(c)
+}
+func (s *Server) regenerateRecoveryCodes(c *gin.Context) {
+	s.authHandler.RegenerateRecoveryCodes(c)
+}
+func (s *Server) listTwoFactorPolicies(c *gin.Context) {
+	s.authHandler.ListTwoFactorPolicies(c)
+}
+func (s *Server) updateTwoFactorPolicy(c *gin.Context) {
+	s.authHandler.UpdateTwoFactorPolicy(c)
+}
+func (s *Server) changePassword(c *gin.Context) {
+	s.authHandler.ChangePassword(c)
+}
+func (s *Server) completePasswordChange(c *gin.Context) {
+	s.authHandler.CompletePasswordChange(c)
+}
+func (s *Server) listUsers(c *gin.Context) {
+	s.authHandler.ListUsers(c)
+}
+func (s *Server) getUser(c *gin.Context) {
+	s.authHandler.GetUser(c)
+}
+func (s *Server) updateUserRole(c *gin.Context) {
+	s.authHandler.UpdateUserRole(c)
+}
+func (s *Server) updateUserStatus(c *gin.Context) {
+	s.authHandler.UpdateUserStatus(c)
+}
+func (s *Server) setUserPassword(c *gin.Context) {
+	s.authHandler.SetUserPassword(c)
+}
+func (s *Server) unlockUser(c *gin.Context) { s.authHandler.UnlockUser(c) }
+func (s *Server) createInvitation(c *gin.Context) {
+	s.authHandler.CreateInvitation(c)
+}
+func (s *Server) listInvitations(c *gin.Context) {
+	s.authHandler.ListInvitations(c)
+}
+func (s *Server) revokeInvitation(c *gin.Context) {
+	s.authHandler.RevokeInvitation(c)
+}
+func (s *Server) acceptInvitation(c *gin.Context) {
+	s.authHandler.AcceptInvitation(c)
+}
+func (s *Server) listAuditEvents(c *gin.Context) { s.auditHandler.ListEvents(c) }
 func (s *Server) listEmployees(c *gin.Context) {
 	s.employeeHandler.ListEmployees(c)
 }
@@ -310,8 +620,108 @@ func (s *Server) deleteEmployee(c *gin.Context) {
 	s.employeeHandler.DeleteEmployee(c)
 }
 func (s *Server) searchEmployees(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "search employees endpoint"})
+	s.employeeHandler.SearchEmployees(c)
+}
+func (s *Server) listDeletedEmployees(c *gin.Context) {
+	s.employeeHandler.ListDeletedEmployees(c)
+}
+func (s *Server) restoreEmployee(c *gin.Context) {
+	s.employeeHandler.RestoreEmployee(c)
+}
+func (s *Server) importEmployees(c *gin.Context) {
+	s.employeeHandler.ImportEmployees(c)
+}
+func (s *Server) transferEmployee(c *gin.Context) {
+	s.employeeHandler.TransferEmployee(c)
+}
+func (s *Server) changeEmployeePosition(c *gin.Context) {
+	s.employeeHandler.ChangePosition(c)
+}
+func (s *Server) promoteEmployee(c *gin.Context) {
+	s.employeeHandler.PromoteEmployee(c)
+}
+func (s *Server) suspendEmployee(c *gin.Context) {
+	s.employeeHandler.SuspendEmployee(c)
+}
+func (s *Server) reinstateEmployee(c *gin.Context) {
+	s.employeeHandler.ReinstateEmployee(c)
+}
+func (s *Server) terminateEmployee(c *gin.Context) {
+	s.employeeHandler.TerminateEmployee(c)
+}
+func (s *Server) cancelEmployeeTermination(c *gin.Context) {
+	s.employeeHandler.CancelTermination(c)
+}
+func (s *Server) rehireEmployee(c *gin.Context) {
+	s.employeeHandler.RehireEmployee(c)
+}
+func (s *Server) getEmployeeJobHistory(c *gin.Context) {
+	s.employeeHandler.GetJobHistory(c)
+}
+func (s *Server) getEmployeeHistory(c *gin.Context) {
+	s.employeeHandler.GetEmployeeHistory(c)
+}
+func (s *Server) getEmployeeReports(c *gin.Context) {
+	s.employeeHandler.GetReports(c)
+}
+func (s *Server) getChainOfCommand(c *gin.Context) {
+	s.employeeHandler.GetChainOfCommand(c)
+}
+func (s *Server) getOrgChart(c *gin.Context) {
+	s.employeeHandler.GetOrgChart(c)
+}
+func (s *Server) getSpanOfControl(c *gin.Context) {
+	s.employeeHandler.GetSpanOfControl(c)
+}
+func (s *Server) listCustomFields(c *gin.Context) {
+	s.employeeHandler.ListCustomFields(c)
+}
+func (s *Server) createCustomField(c *gin.Context) {
+	s.employeeHandler.CreateCustomField(c)
+}
+func (s *Server) updateCustomField(c *gin.Context) {
+	s.employeeHandler.UpdateCustomField(c)
+}
+func (s *Server) deleteCustomField(c *gin.Context) {
+	s.employeeHandler.DeleteCustomField(c)
+}
+func (s *Server) exportEmployees(c *gin.Context) {
+	s.employeeHandler.ExportEmployees(c)
+}
+func (s *Server) findDuplicateEmployees(c *gin.Context) {
+	s.employeeHandler.FindDuplicates(c)
+}
+func (s *Server) mergeEmployees(c *gin.Context) {
+	s.employeeHandler.MergeEmployees(c)
+}
+func (s *Server) listNumberTemplates(c *gin.Context) {
+	s.employeeHandler.ListNumberTemplates(c)
 }
+func (s *Server) createNumberTemplate(c *gin.Context) {
+	s.employeeHandler.CreateNumberTemplate(c)
+}
+func (s *Server) updateNumberTemplate(c *gin.Context) {
+	s.employeeHandler.UpdateNumberTemplate(c)
+}
+func (s *Server) deleteNumberTemplate(c *gin.Context) {
+	s.employeeHandler.DeleteNumberTemplate(c)
+}
+func (s *Server) listChangeRequests(c *gin.Context) {
+	s.employeeHandler.ListChangeRequests(c)
+}
+func (s *Server) approveChangeRequest(c *gin.Context) {
+	s.employeeHandler.ApproveChangeRequest(c)
+}
+func (s *Server) rejectChangeRequest(c *gin.Context) {
+	s.employeeHandler.RejectChangeRequest(c)
+}
+func (s *Server) getOwnProfile(c *gin.Context)         { s.selfHandler.GetProfile(c) }
+func (s *Server) updateOwnProfile(c *gin.Context)      { s.selfHandler.UpdateProfile(c) }
+func (s *Server) listOwnChangeRequests(c *gin.Context) { s.selfHandler.ListChangeRequests(c) }
+func (s *Server) listOwnAttendance(c *gin.Context)     { s.selfHandler.ListAttendance(c) }
+func (s *Server) listOwnLeaveRequests(c *gin.Context)  { s.selfHandler.ListLeaveRequests(c) }
+func (s *Server) listOwnPayslips(c *gin.Context)       { s.selfHandler.ListPayslips(c) }
+func (s *Server) listOwnDocuments(c *gin.Context)      { s.selfHandler.ListDocuments(c) }
 func (s *Server) listDepartments(c *gin.Context) {
 	s.departmentHandler.ListDepartments(c)
 }
@@ -327,6 +737,36 @@ func (s *Server) updateDepartment(c *gin.Context) {
 func (s *Server) deleteDepartment(c *gin.Context) {
 	s.departmentHandler.DeleteDepartment(c)
 }
+func (s *Server) getDepartmentTree(c *gin.Context) {
+	s.departmentHandler.GetDepartmentTree(c)
+}
+func (s *Server) getPayrollCosts(c *gin.Context) {
+	s.departmentHandler.GetPayrollCosts(c)
+}
+func (s *Server) moveDepartment(c *gin.Context) {
+	s.departmentHandler.MoveDepartment(c)
+}
+func (s *Server) mergeDepartments(c *gin.Context) {
+	s.departmentHandler.MergeDepartments(c)
+}
+func (s *Server) getDepartmentDependents(c *gin.Context) {
+	s.departmentHandler.GetDepartmentDependents(c)
+}
+func (s *Server) listLegalEntities(c *gin.Context) {
+	s.departmentHandler.ListLegalEntities(c)
+}
+func (s *Server) getLegalEntity(c *gin.Context) {
+	s.departmentHandler.GetLegalEntity(c)
+}
+func (s *Server) createLegalEntity(c *gin.Context) {
+	s.departmentHandler.CreateLegalEntity(c)
+}
+func (s *Server) updateLegalEntity(c *gin.Context) {
+	s.departmentHandler.UpdateLegalEntity(c)
+}
+func (s *Server) deleteLegalEntity(c *gin.Context) {
+	s.departmentHandler.DeleteLegalEntity(c)
+}
 func (s *Server) listPositions(c *gin.Context) {
 	s.positionHandler.ListPositions(c)
 }
@@ -342,6 +782,45 @@ func (s *Server) updatePosition(c *gin.Context) {
 func (s *Server) deletePosition(c *gin.Context) {
 	s.positionHandler.DeletePosition(c)
 }
+func (s *Server) listPositionBudgets(c *gin.Context) {
+	s.positionHandler.ListBudgets(c)
+}
+func (s *Server) createPositionBudget(c *gin.Context) {
+	s.positionHandler.CreateBudget(c)
+}
+func (s *Server) updatePositionBudget(c *gin.Context) {
+	s.positionHandler.UpdateBudget(c)
+}
+func (s *Server) deletePositionBudget(c *gin.Context) {
+	s.positionHandler.DeleteBudget(c)
+}
+func (s *Server) getPositionHeadcount(c *gin.Context) {
+	s.positionHandler.GetHeadcount(c)
+}
+func (s *Server) getDepartmentHeadcount(c *gin.Context) {
+	s.positionHandler.GetDepartmentHeadcount(c)
+}
+func (s *Server) listRequisitions(c *gin.Context) {
+	s.positionHandler.ListRequisitions(c)
+}
+func (s *Server) createRequisition(c *gin.Context) {
+	s.positionHandler.CreateRequisition(c)
+}
+func (s *Server) getRequisition(c *gin.Context) {
+	s.positionHandler.GetRequisition(c)
+}
+func (s *Server) approveRequisition(c *gin.Context) {
+	s.positionHandler.ApproveRequisition(c)
+}
+func (s *Server) rejectRequisition(c *gin.Context) {
+	s.positionHandler.RejectRequisition(c)
+}
+func (s *Server) fillRequisition(c *gin.Context) {
+	s.positionHandler.FillRequisition(c)
+}
+func (s *Server) cancelRequisition(c *gin.Context) {
+	s.positionHandler.CancelRequisition(c)
+}
 func (s *Server) listAttendance(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "list attendance endpoint"})
 }
@@ -384,6 +863,11 @@ func (s *Server) listSalaryComponents(c *gin.Context)  { s.payrollHandler.ListSa
 func (s *Server) getSalaryComponent(c *gin.Context)    { s.payrollHandler.GetSalaryComponent(c) }
 func (s *Server) createEmployeeSalary(c *gin.Context)  { s.payrollHandler.CreateEmployeeSalary(c) }
 func (s *Server) getEmployeeSalaries(c *gin.Context)   { s.payrollHandler.GetEmployeeSalaries(c) }
+func (s *Server) getSalaryBand(c *gin.Context)         { s.payrollHandler.GetSalaryBand(c) }
+func (s *Server) getOutOfBandReport(c *gin.Context)    { s.payrollHandler.GetOutOfBandReport(c) }
+func (s *Server) listPendingSalaries(c *gin.Context)   { s.payrollHandler.ListPendingSalaries(c) }
+func (s *Server) approveSalaryBand(c *gin.Context)     { s.payrollHandler.ApproveSalaryBand(c) }
+func (s *Server) rejectSalaryBand(c *gin.Context)      { s.payrollHandler.RejectSalaryBand(c) }
 func (s *Server) createTaxBracket(c *gin.Context)      { s.payrollHandler.CreateTaxBracket(c) }
 func (s *Server) getTaxBrackets(c *gin.Context)        { s.payrollHandler.GetTaxBrackets(c) }
 func (s *Server) getPayslip(c *gin.Context)            { s.payrollHandler.GetPayslip(c) }
@@ -412,3 +896,10 @@ func (s *Server) markNotificationAsRead(c *gin.Context) {
 func (s *Server) markAllNotificationsAsRead(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "mark all notifications as read endpoint"})
 }
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"employee-management/internal/employee"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// startTerminationJob applies scheduled terminations once they take effect,
+// checking every EMPLOYEE_TERMINATION_INTERVAL (default 1h)
+func startTerminationJob(service *employee.Service, logger *logrus.Logger) error {
+	value := getEnv("EMPLOYEE_TERMINATION_INTERVAL", "1h")
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval <= 0 {
+		return fmt.Errorf("invalid EMPLOYEE_TERMINATION_INTERVAL %q", value)
+	}
+
+	go applyScheduledTerminations(service, interval, logger)
+	return nil
+}
+
+// applyScheduledTerminations applies due terminations now and then
+// periodically. Failures are logged and retried on the next run.
+func applyScheduledTerminations(service *employee.Service, interval time.Duration, logger *logrus.Logger) {
+	entry := logger.WithField("job", "employee-terminations")
+	run := func() {
+		terminated, err := service.ApplyScheduledTerminations(entry)
+		if err != nil {
+			entry.WithError(err).Error("Failed to apply scheduled terminations")
+		}
+		if terminated > 0 {
+			entry.WithField("terminated", terminated).Info("Applied scheduled terminations")
+		}
+	}
+
+	run()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		run()
+	}
+}
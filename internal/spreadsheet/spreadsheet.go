@@ -0,0 +1,90 @@
+// Package spreadsheet reads tabular data from CSV and XLSX files.
+package spreadsheet
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxFileSize is the largest file Read accepts
+const MaxFileSize = 20 << 20
+
+// ErrUnsupportedFormat is returned for files that are neither CSV nor XLSX
+var ErrUnsupportedFormat = errors.New("unsupported file format, expected .csv or .xlsx")
+
+// ErrTooLarge is returned for files larger than MaxFileSize
+var ErrTooLarge = fmt.Errorf("file is larger than %d MB", MaxFileSize>>20)
+
+// Read returns the rows of a CSV file or of the first sheet of an XLSX
+// workbook, choosing the format by the file name's extension. Files with
+// more than maxRows rows are refused.
+func Read(name string, r io.Reader, maxRows int) ([][]string, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxFileSize {
+		return nil, ErrTooLarge
+	}
+
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return ReadCSV(bytes.NewReader(data), maxRows)
+	case ".xlsx":
+		return ReadXLSX(bytes.NewReader(data), int64(len(data)), maxRows)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+// ReadCSV returns the rows of a CSV file, refusing files with more than
+// maxRows rows. Rows may have different lengths and a leading UTF-8 byte
+// order mark is ignored.
+func ReadCSV(r io.Reader, maxRows int) ([][]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var rows [][]string
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) >= maxRows {
+			return nil, fmt.Errorf("file has more than %d rows", maxRows)
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) > 0 && len(rows[0]) > 0 {
+		rows[0][0] = strings.TrimPrefix(rows[0][0], "\ufeff")
+	}
+	return rows, nil
+}
+
+// excelEpoch is day zero of the 1900 date system, shifted to absorb Excel's
+// nonexistent 29 February 1900
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// ParseDate parses a date written as YYYY-MM-DD, or as the serial number
+// XLSX files store dates as
+func ParseDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		return date, nil
+	}
+	if serial, err := strconv.ParseFloat(value, 64); err == nil && serial >= 1 && serial < 2958466 {
+		return excelEpoch.AddDate(0, 0, int(serial)), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", value)
+}
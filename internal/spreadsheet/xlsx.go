@@ -0,0 +1,296 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxPartSize limits how much of a single workbook part is decompressed, so
+	// a small, highly compressed file cannot exhaust memory
+	maxPartSize = 100 << 20
+	// maxMetadataSize limits the workbook and its relationships, which only
+	// list the sheets and are decoded whole
+	maxMetadataSize = 1 << 20
+	// maxColumns is the sheet width limit of XLSX itself, up to column XFD
+	maxColumns = 16384
+	// maxCells limits the cells returned, counting the empty ones that fill
+	// the gaps in sparse rows, and the shared strings kept
+	maxCells = 5000000
+)
+
+type workbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type relationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// richText is a string that is either plain or made of formatted runs
+type richText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t richText) String() string {
+	if len(t.Runs) == 0 {
+		return t.Text
+	}
+	var b strings.Builder
+	for _, run := range t.Runs {
+		b.WriteString(run.Text)
+	}
+	return b.String()
+}
+
+type cell struct {
+	Ref    string   `xml:"r,attr"`
+	Type   string   `xml:"t,attr"`
+	Value  string   `xml:"v"`
+	Inline richText `xml:"is"`
+}
+
+// ReadXLSX returns the rows of the first sheet of an XLSX workbook, refusing
+// sheets with more than maxRows rows. Numbers, including dates, are returned
+// as they are stored; see ParseDate.
+func ReadXLSX(r io.ReaderAt, size int64, maxRows int) ([][]string, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("not a valid XLSX file: %w", err)
+	}
+	files := map[string]*zip.File{}
+	for _, f := range archive.File {
+		files[strings.TrimPrefix(f.Name, "/")] = f
+	}
+
+	var book workbook
+	if err := decodePart(files, "xl/workbook.xml", &book); err != nil {
+		return nil, err
+	}
+	if len(book.Sheets) == 0 {
+		return nil, errors.New("workbook has no sheets")
+	}
+	var rels relationships
+	if err := decodePart(files, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+	sheetPath := ""
+	for _, rel := range rels.Relationships {
+		if rel.ID == book.Sheets[0].RID {
+			if strings.HasPrefix(rel.Target, "/") {
+				sheetPath = strings.TrimPrefix(rel.Target, "/")
+			} else {
+				sheetPath = path.Join("xl", rel.Target)
+			}
+		}
+	}
+	if sheetPath == "" {
+		return nil, fmt.Errorf("sheet %q not found in workbook", book.Sheets[0].Name)
+	}
+
+	var shared []string
+	if _, ok := files["xl/sharedStrings.xml"]; ok {
+		if shared, err = readSharedStrings(files); err != nil {
+			return nil, err
+		}
+	}
+	return readSheet(files, sheetPath, shared, maxRows)
+}
+
+// readSharedStrings returns the strings cells of type "s" refer to by index.
+// The part is read an item at a time.
+func readSharedStrings(files map[string]*zip.File) ([]string, error) {
+	const name = "xl/sharedStrings.xml"
+	var shared []string
+	err := streamPart(files, name, func(d *xml.Decoder, start xml.StartElement) error {
+		if start.Name.Local != "si" {
+			return nil
+		}
+		if len(shared) >= maxCells {
+			return fmt.Errorf("workbook has more than %d shared strings", maxCells)
+		}
+		var item richText
+		if err := d.DecodeElement(&item, &start); err != nil {
+			return err
+		}
+		shared = append(shared, item.String())
+		return nil
+	}, nil)
+	return shared, err
+}
+
+// readSheet returns the rows of a sheet. The part is read a cell at a time
+// and the limits are checked as rows and cells arrive, so a large sheet is
+// refused before it is held in memory.
+func readSheet(files map[string]*zip.File, name string, shared []string, maxRows int) ([][]string, error) {
+	var rows [][]string
+	var values []string
+	inRow := false
+	cells := 0
+	err := streamPart(files, name, func(d *xml.Decoder, start xml.StartElement) error {
+		switch start.Name.Local {
+		case "row":
+			index := len(rows) + 1
+			for _, attr := range start.Attr {
+				if attr.Name.Local != "r" {
+					continue
+				}
+				var err error
+				if index, err = strconv.Atoi(attr.Value); err != nil {
+					return fmt.Errorf("invalid row number %q", attr.Value)
+				}
+			}
+			if index > maxRows || len(rows) >= maxRows {
+				return fmt.Errorf("sheet has more than %d rows", maxRows)
+			}
+			// Rows without data are left out of the file, so keep numbering by index
+			for index > len(rows)+1 {
+				rows = append(rows, nil)
+			}
+			values, inRow = nil, true
+		case "c":
+			if !inRow {
+				return d.Skip()
+			}
+			var c cell
+			if err := d.DecodeElement(&c, &start); err != nil {
+				return err
+			}
+			column := len(values)
+			if c.Ref != "" {
+				var err error
+				if column, err = columnIndex(c.Ref); err != nil {
+					return err
+				}
+			}
+			if column >= maxColumns {
+				return fmt.Errorf("sheet has more than %d columns", maxColumns)
+			}
+			if column >= len(values) {
+				if cells += column + 1 - len(values); cells > maxCells {
+					return fmt.Errorf("sheet has more than %d cells", maxCells)
+				}
+			}
+			for len(values) < column {
+				values = append(values, "")
+			}
+
+			value := c.Value
+			switch c.Type {
+			case "s":
+				index, err := strconv.Atoi(c.Value)
+				if err != nil || index < 0 || index >= len(shared) {
+					return fmt.Errorf("cell %s refers to a missing shared string", c.Ref)
+				}
+				value = shared[index]
+			case "inlineStr":
+				value = c.Inline.String()
+			case "b":
+				value = map[string]string{"0": "false", "1": "true"}[c.Value]
+			}
+			if column < len(values) {
+				values[column] = value
+			} else {
+				values = append(values, value)
+			}
+		}
+		return nil
+	}, func(end xml.EndElement) {
+		if end.Name.Local == "row" && inRow {
+			rows = append(rows, values)
+			values, inRow = nil, false
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// streamPart reads a workbook part token by token, passing start elements to
+// onStart, which may consume the element, and end elements to onEnd
+func streamPart(files map[string]*zip.File, name string, onStart func(d *xml.Decoder, start xml.StartElement) error, onEnd func(end xml.EndElement)) error {
+	f, ok := files[name]
+	if !ok {
+		return fmt.Errorf("not a valid XLSX file: %s is missing", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	d := xml.NewDecoder(io.LimitReader(rc, maxPartSize))
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read %s: %w", name, err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if err := onStart(d, t); err != nil {
+				return fmt.Errorf("read %s: %w", name, err)
+			}
+		case xml.EndElement:
+			if onEnd != nil {
+				onEnd(t)
+			}
+		}
+	}
+}
+
+// decodePart decodes a small workbook part whole
+func decodePart(files map[string]*zip.File, name string, v interface{}) error {
+	f, ok := files[name]
+	if !ok {
+		return fmt.Errorf("not a valid XLSX file: %s is missing", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	if err := xml.NewDecoder(io.LimitReader(rc, maxMetadataSize)).Decode(v); err != nil {
+		return fmt.Errorf("read %s: %w", name, err)
+	}
+	return nil
+}
+
+// columnIndex returns the zero-based column of a cell reference such as
+// "AB12". Columns beyond XFD are refused.
+func columnIndex(ref string) (int, error) {
+	column := 0
+	for i, r := range ref {
+		if r >= 'A' && r <= 'Z' {
+			column = column*26 + int(r-'A') + 1
+			if column > maxColumns {
+				return 0, fmt.Errorf("cell reference %q is beyond column XFD", ref)
+			}
+			continue
+		}
+		if i == 0 {
+			break
+		}
+		return column - 1, nil
+	}
+	return 0, fmt.Errorf("invalid cell reference %q", ref)
+}
@@ -0,0 +1,193 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testWorkbook = `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Employees" sheetId="1" r:id="rId1"/><sheet name="Other" sheetId="2" r:id="rId2"/></sheets>
+</workbook>`
+
+const testRelationships = `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId2" Target="worksheets/sheet2.xml"/>
+<Relationship Id="rId1" Target="worksheets/sheet1.xml"/>
+</Relationships>`
+
+// buildXLSX zips the given parts, adding the workbook and its relationships
+// unless they are given
+func buildXLSX(t *testing.T, parts map[string]string) []byte {
+	t.Helper()
+	if _, ok := parts["xl/workbook.xml"]; !ok {
+		parts["xl/workbook.xml"] = testWorkbook
+	}
+	if _, ok := parts["xl/_rels/workbook.xml.rels"]; !ok {
+		parts["xl/_rels/workbook.xml.rels"] = testRelationships
+	}
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range parts {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func sheet(rows string) string {
+	return `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` + rows + `</sheetData></worksheet>`
+}
+
+func readXLSX(data []byte, maxRows int) ([][]string, error) {
+	return ReadXLSX(bytes.NewReader(data), int64(len(data)), maxRows)
+}
+
+func TestReadXLSX(t *testing.T) {
+	data := buildXLSX(t, map[string]string{
+		"xl/sharedStrings.xml": `<sst><si><t>employee_id</t></si><si><t>first_name</t></si><si><r><t>Ja</t></r><r><t>ne</t></r></si></sst>`,
+		"xl/worksheets/sheet1.xml": sheet(`
+<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c></row>
+<row r="2"><c r="A2" t="inlineStr"><is><t>E001</t></is></c><c r="B2" t="s"><v>2</v></c><c r="D2"><v>45000</v></c><c r="E2" t="b"><v>1</v></c></row>
+<row r="4"><c r="B4" t="str"><v>formula result</v></c></row>`),
+		"xl/worksheets/sheet2.xml": sheet(`<row r="1"><c r="A1" t="inlineStr"><is><t>wrong sheet</t></is></c></row>`),
+	})
+
+	rows, err := readXLSX(data, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"employee_id", "first_name"},
+		{"E001", "Jane", "", "45000", "true"},
+		nil,
+		{"", "formula result"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("ReadXLSX = %q, want %q", rows, want)
+	}
+}
+
+func TestReadXLSXWithoutCellReferences(t *testing.T) {
+	data := buildXLSX(t, map[string]string{
+		"xl/worksheets/sheet1.xml": sheet(`<row><c><v>1</v></c><c><v>2</v></c></row><row><c><v>3</v></c></row>`),
+	})
+	rows, err := readXLSX(data, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]string{{"1", "2"}, {"3"}}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("ReadXLSX = %q, want %q", rows, want)
+	}
+}
+
+func TestReadXLSXAbsoluteSheetTarget(t *testing.T) {
+	data := buildXLSX(t, map[string]string{
+		"xl/_rels/workbook.xml.rels": `<Relationships><Relationship Id="rId1" Target="/xl/worksheets/data.xml"/></Relationships>`,
+		"xl/worksheets/data.xml":     sheet(`<row r="1"><c r="A1"><v>1</v></c></row>`),
+	})
+	rows, err := readXLSX(data, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]string{{"1"}}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("ReadXLSX = %q, want %q", rows, want)
+	}
+}
+
+func TestReadXLSXErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		parts   map[string]string
+		maxRows int
+		wantErr string
+	}{
+		{
+			name:    "too many rows",
+			parts:   map[string]string{"xl/worksheets/sheet1.xml": sheet(`<row r="1"/><row r="2"/><row r="3"/>`)},
+			maxRows: 2,
+			wantErr: "more than 2 rows",
+		},
+		{
+			name:    "row number beyond the limit",
+			parts:   map[string]string{"xl/worksheets/sheet1.xml": sheet(`<row r="1000000"/>`)},
+			maxRows: 10,
+			wantErr: "more than 10 rows",
+		},
+		{
+			name:    "column beyond XFD",
+			parts:   map[string]string{"xl/worksheets/sheet1.xml": sheet(`<row r="1"><c r="XFE1"><v>1</v></c></row>`)},
+			maxRows: 10,
+			wantErr: "beyond column XFD",
+		},
+		{
+			name:    "missing shared string",
+			parts:   map[string]string{"xl/worksheets/sheet1.xml": sheet(`<row r="1"><c r="A1" t="s"><v>3</v></c></row>`)},
+			maxRows: 10,
+			wantErr: "missing shared string",
+		},
+		{
+			name:    "missing sheet",
+			parts:   map[string]string{},
+			maxRows: 10,
+			wantErr: "xl/worksheets/sheet1.xml is missing",
+		},
+		{
+			name: "no sheets",
+			parts: map[string]string{
+				"xl/workbook.xml":          `<workbook><sheets/></workbook>`,
+				"xl/worksheets/sheet1.xml": sheet(``),
+			},
+			maxRows: 10,
+			wantErr: "no sheets",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readXLSX(buildXLSX(t, tt.parts), tt.maxRows)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ReadXLSX error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadXLSXNotAZip(t *testing.T) {
+	if _, err := readXLSX([]byte("employee_id,first_name\n"), 10); err == nil {
+		t.Error("ReadXLSX accepted a CSV file")
+	}
+}
+
+func TestColumnIndex(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want int
+	}{
+		{"A1", 0},
+		{"Z9", 25},
+		{"AA10", 26},
+		{"AB12", 27},
+		{"XFD1048576", maxColumns - 1},
+	}
+	for _, tt := range tests {
+		got, err := columnIndex(tt.ref)
+		if err != nil || got != tt.want {
+			t.Errorf("columnIndex(%q) = %d, %v, want %d", tt.ref, got, err, tt.want)
+		}
+	}
+	for _, ref := range []string{"", "1", "a1", "AB", "XFE1"} {
+		if _, err := columnIndex(ref); err == nil {
+			t.Errorf("columnIndex(%q) accepted an invalid reference", ref)
+		}
+	}
+}